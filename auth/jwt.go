@@ -0,0 +1,467 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The algorithms of signing keys.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	// ErrInvalidToken is returned by Tokens.Verify for a token which is malformed,
+	// signed by an unknown key, or whose signature or issuer is wrong.
+	ErrInvalidToken = errors.New("invalid token")
+
+	// ErrTokenExpired is returned by Tokens.Verify for a token past its expiry.
+	ErrTokenExpired = errors.New("the token is expired")
+
+	// ErrTokenNotValidYet is returned by Tokens.Verify for a token used before its nbf or iat.
+	ErrTokenNotValidYet = errors.New("the token is not valid yet")
+
+	// errUnknownKey is returned by verifyToken when no key has the kid of a token.
+	errUnknownKey = errors.New("unknown signing key")
+)
+
+type (
+
+	// SigningKey is a key signing and verifying tokens. HS256 keys have
+	// a Secret. RS256 and EdDSA keys have a Public key, and a Private key
+	// unless they only verify the tokens signed before a rotation.
+	SigningKey struct {
+		ID        string
+		Algorithm string
+		Secret    []byte
+		Private   crypto.Signer
+		Public    crypto.PublicKey
+	}
+
+	// Claims are the claims of the tokens of users. Times are Unix seconds.
+	Claims struct {
+		Issuer    string `json:"iss,omitempty"`
+		Subject   string `json:"sub"`
+		Name      string `json:"name,omitempty"`
+		ID        string `json:"jti,omitempty"`
+		IssuedAt  int64  `json:"iat"`
+		NotBefore int64  `json:"nbf,omitempty"`
+		ExpiresAt int64  `json:"exp"`
+	}
+
+	// Tokens issues and verifies JSON Web Tokens. The first of Keys signs
+	// new tokens and every key verifies the tokens having its kid, so a key is
+	// rotated by putting a new key first and removing the old one once its
+	// tokens expired.
+	Tokens struct {
+		Keys []SigningKey
+
+		// Issuer is the iss of the tokens, checked when it is set.
+		Issuer string
+
+		// TTL is how long a token is valid.
+		TTL time.Duration
+
+		// Leeway is how far the clocks of servers may be apart,
+		// allowed when checking exp, nbf and iat.
+		Leeway time.Duration
+
+		// Now returns the current time. Tests may replace it.
+		Now func() time.Time
+	}
+
+	// JWK is a public key of a JSON Web Key Set.
+	JWK struct {
+		KeyType   string `json:"kty"`
+		ID        string `json:"kid"`
+		Algorithm string `json:"alg"`
+		Use       string `json:"use"`
+		N         string `json:"n,omitempty"`
+		E         string `json:"e,omitempty"`
+		Curve     string `json:"crv,omitempty"`
+		X         string `json:"x,omitempty"`
+	}
+
+	// JWKSet is a JSON Web Key Set.
+	JWKSet struct {
+		Keys []JWK `json:"keys"`
+	}
+
+	// header is the JOSE header of a token.
+	header struct {
+		Algorithm string `json:"alg"`
+		Type      string `json:"typ,omitempty"`
+		KeyID     string `json:"kid,omitempty"`
+	}
+)
+
+// encoding is the base64url encoding without padding of the parts of a token.
+var encoding = base64.RawURLEncoding
+
+// UserID returns the ID of the user of the subject of the claims.
+func (claims Claims) UserID() (int, error) {
+	id, err := strconv.Atoi(claims.Subject)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidToken
+	}
+	return id, nil
+}
+
+// ParseSigningKey reads a key of an algorithm. The material of an HS256 key
+// is its secret, of at least 32 bytes. The material of RS256 and EdDSA keys
+// is a PEM private key, PKCS #8 or PKCS #1 for RSA, or a PEM public key
+// for a key which only verifies.
+func ParseSigningKey(id string, algorithm string, material []byte) (SigningKey, error) {
+
+	key := SigningKey{ID: id, Algorithm: algorithm}
+	if id == "" {
+		return key, errors.New("a signing key needs a kid")
+	}
+
+	if algorithm == HS256 {
+		if len(material) < 32 {
+			return key, fmt.Errorf("the secret of key %s has less than 32 bytes", id)
+		}
+		key.Secret = material
+		return key, nil
+	}
+	if algorithm != RS256 && algorithm != EdDSA {
+		return key, fmt.Errorf("unknown algorithm %q of key %s, use HS256, RS256 or EdDSA", algorithm, id)
+	}
+
+	block, _ := pem.Decode(material)
+	if block == nil {
+		return key, fmt.Errorf("key %s is not PEM", id)
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		err = fmt.Errorf("unknown PEM block %q", block.Type)
+	}
+	if err != nil {
+		return key, fmt.Errorf("cannot read key %s. %w", id, err)
+	}
+
+	switch parsed := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Private, key.Public = parsed, &parsed.PublicKey
+	case *rsa.PublicKey:
+		key.Public = parsed
+	case ed25519.PrivateKey:
+		key.Private, key.Public = parsed, parsed.Public()
+	case ed25519.PublicKey:
+		key.Public = parsed
+	}
+
+	_, isRSA := key.Public.(*rsa.PublicKey)
+	_, isEd25519 := key.Public.(ed25519.PublicKey)
+	if (algorithm == RS256 && !isRSA) || (algorithm == EdDSA && !isEd25519) {
+		return key, fmt.Errorf("key %s is not an %s key", id, algorithm)
+	}
+
+	return key, nil
+}
+
+// LoadSigningKeys reads keys from a comma-separated list of kid:algorithm:source,
+// such as "2026-10:EdDSA:/etc/keys/2026-10.pem,2026-09:HS256:c2VjcmV0...".
+// The source of an HS256 key is its base64 secret, and of other keys a PEM file.
+func LoadSigningKeys(spec string) ([]SigningKey, error) {
+
+	var keys []SigningKey
+
+	for _, item := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid signing key %q, use kid:algorithm:source", item)
+		}
+
+		var material []byte
+		var err error
+		if parts[1] == HS256 {
+			material, err = base64.StdEncoding.DecodeString(parts[2])
+			if err != nil {
+				material, err = base64.RawURLEncoding.DecodeString(parts[2])
+			}
+		} else {
+			material, err = os.ReadFile(parts[2])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read signing key %s. %w", parts[0], err)
+		}
+
+		key, err := ParseSigningKey(parts[0], parts[1], material)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if len(keys) > 0 && keys[0].Secret == nil && keys[0].Private == nil {
+		return nil, fmt.Errorf("the first signing key %s signs tokens and needs a private key", keys[0].ID)
+	}
+
+	return keys, nil
+}
+
+// sign returns the signature of input.
+func (key SigningKey) sign(input []byte) ([]byte, error) {
+
+	switch key.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write(input)
+		return mac.Sum(nil), nil
+	case RS256:
+		digest := sha256.Sum256(input)
+		return key.Private.Sign(rand.Reader, digest[:], crypto.SHA256)
+	case EdDSA:
+		return key.Private.Sign(rand.Reader, input, crypto.Hash(0))
+	}
+
+	return nil, fmt.Errorf("unknown algorithm %q", key.Algorithm)
+}
+
+// verify reports whether signature is the signature of input.
+func (key SigningKey) verify(input []byte, signature []byte) bool {
+
+	switch key.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write(input)
+		return hmac.Equal(signature, mac.Sum(nil))
+	case RS256:
+		digest := sha256.Sum256(input)
+		public, ok := key.Public.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) == nil
+	case EdDSA:
+		public, ok := key.Public.(ed25519.PublicKey)
+		return ok && ed25519.Verify(public, input, signature)
+	}
+
+	return false
+}
+
+// Issue returns a signed token of a user and its claims.
+func (tokens *Tokens) Issue(user User) (string, Claims, error) {
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", Claims{}, err
+	}
+
+	now := tokens.Now()
+	claims := Claims{
+		Issuer:    tokens.Issuer,
+		Subject:   strconv.Itoa(user.ID),
+		Name:      user.Name,
+		ID:        hex.EncodeToString(jti),
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: now.Add(tokens.TTL).Unix(),
+	}
+
+	token, err := tokens.sign("JWT", claims)
+	if err != nil {
+		return "", Claims{}, err
+	}
+
+	return token, claims, nil
+}
+
+// sign returns a token of the typ having the JSON of payload,
+// signed by the first key.
+func (tokens *Tokens) sign(typ string, payload interface{}) (string, error) {
+
+	if len(tokens.Keys) == 0 {
+		return "", errors.New("there is no signing key")
+	}
+	key := tokens.Keys[0]
+
+	head, err := json.Marshal(header{Algorithm: key.Algorithm, Type: typ, KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	input := encoding.EncodeToString(head) + "." + encoding.EncodeToString(body)
+	signature, err := key.sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+
+	return input + "." + encoding.EncodeToString(signature), nil
+}
+
+// Verify checks the signature and times of a token and returns its claims.
+// The key is chosen by the kid of the token, and must have the alg of the token.
+func (tokens *Tokens) Verify(token string) (Claims, error) {
+
+	var claims Claims
+	head, err := verifyToken(tokens.Keys, token, &claims)
+	// Other types, such as the logins of OIDC, are signed by the same keys.
+	if err != nil || head.Type != "JWT" {
+		return Claims{}, ErrInvalidToken
+	}
+	if tokens.Issuer != "" && claims.Issuer != tokens.Issuer {
+		return Claims{}, ErrInvalidToken
+	}
+	if claims.ExpiresAt == 0 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	if err := checkTimes(tokens.Now(), tokens.Leeway, claims.IssuedAt, claims.NotBefore, claims.ExpiresAt); err != nil {
+		return Claims{}, err
+	}
+
+	return claims, nil
+}
+
+// verifyToken checks the signature of a token by the key of its kid, reads
+// its payload into v and returns its header. A token without a kid is checked
+// by the only key of keys.
+func verifyToken(keys []SigningKey, token string, v interface{}) (header, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return header{}, ErrInvalidToken
+	}
+
+	var head header
+	if err := decodePart(parts[0], &head); err != nil {
+		return header{}, ErrInvalidToken
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return header{}, ErrInvalidToken
+	}
+
+	var key *SigningKey
+	for i := range keys {
+		if keys[i].ID == head.KeyID || (head.KeyID == "" && len(keys) == 1) {
+			key = &keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return head, errUnknownKey
+	}
+	// Checking the alg of the key rather than trusting the header stops
+	// tokens signed with "none" or with a public key as an HMAC secret.
+	if key.Algorithm != head.Algorithm || !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return header{}, ErrInvalidToken
+	}
+
+	if err := decodePart(parts[1], v); err != nil {
+		return header{}, ErrInvalidToken
+	}
+
+	return head, nil
+}
+
+// checkTimes checks the Unix times of a token against now, allowing leeway.
+// Zero nbf and iat are not checked.
+func checkTimes(now time.Time, leeway time.Duration, issuedAt, notBefore, expiresAt int64) error {
+	switch {
+	case !now.Before(time.Unix(expiresAt, 0).Add(leeway)):
+		return ErrTokenExpired
+	case now.Add(leeway).Before(time.Unix(notBefore, 0)),
+		now.Add(leeway).Before(time.Unix(issuedAt, 0)):
+		return ErrTokenNotValidYet
+	}
+	return nil
+}
+
+// decodePart reads the JSON of a part of a token.
+func decodePart(part string, v interface{}) error {
+	data, err := encoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// JWKS returns the public keys verifying tokens. HS256 secrets are not published.
+func (tokens *Tokens) JWKS() JWKSet {
+
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, key := range tokens.Keys {
+		jwk := JWK{ID: key.ID, Algorithm: key.Algorithm, Use: "sig"}
+
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = encoding.EncodeToString(public.N.Bytes())
+			jwk.E = encoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType, jwk.Curve = "OKP", "Ed25519"
+			jwk.X = encoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// ParseJWK returns the key verifying tokens of a public JSON Web Key,
+// such as a key of an identity provider. A key without alg has the
+// algorithm of its type.
+func ParseJWK(jwk JWK) (SigningKey, error) {
+
+	key := SigningKey{ID: jwk.ID, Algorithm: jwk.Algorithm}
+
+	switch {
+	case jwk.KeyType == "RSA" && (jwk.Algorithm == "" || jwk.Algorithm == RS256):
+		n, nErr := encoding.DecodeString(jwk.N)
+		e, eErr := encoding.DecodeString(jwk.E)
+		if nErr != nil || eErr != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return key, fmt.Errorf("invalid RSA key %s", jwk.ID)
+		}
+		key.Algorithm = RS256
+		key.Public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case jwk.KeyType == "OKP" && jwk.Curve == "Ed25519" && (jwk.Algorithm == "" || jwk.Algorithm == EdDSA):
+		x, err := encoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return key, fmt.Errorf("invalid Ed25519 key %s", jwk.ID)
+		}
+		key.Algorithm = EdDSA
+		key.Public = ed25519.PublicKey(x)
+	default:
+		return key, fmt.Errorf("unsupported key %s of type %s and alg %s", jwk.ID, jwk.KeyType, jwk.Algorithm)
+	}
+
+	return key, nil
+}
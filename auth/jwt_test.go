@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func newRSAKey(t *testing.T, id string) SigningKey {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return SigningKey{ID: id, Algorithm: RS256, Private: private, Public: &private.PublicKey}
+}
+
+func newEd25519Key(t *testing.T, id string) SigningKey {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return SigningKey{ID: id, Algorithm: EdDSA, Private: private, Public: public}
+}
+
+func newTokens(keys ...SigningKey) *Tokens {
+	return &Tokens{
+		Keys:   keys,
+		Issuer: "expenses",
+		TTL:    15 * time.Minute,
+		Leeway: time.Minute,
+		Now:    func() time.Time { return testTime },
+	}
+}
+
+// tokenPart decodes a part of a token.
+func tokenPart(token string, i int) map[string]interface{} {
+	var part map[string]interface{}
+	data, _ := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[i])
+	json.Unmarshal(data, &part)
+	return part
+}
+
+func TestTokens(t *testing.T) {
+	for _, key := range []SigningKey{
+		{ID: "hmac", Algorithm: HS256, Secret: testSecret},
+		newRSAKey(t, "rsa"),
+		newEd25519Key(t, "ed"),
+	} {
+		// Arrange
+		tokens := newTokens(key)
+
+		// Act
+		token, issued, err := tokens.Issue(User{ID: 5, Name: "peem"})
+		claims, verifyErr := tokens.Verify(token)
+
+		// Assert
+		assert.NoError(t, err, key.Algorithm)
+		assert.NoError(t, verifyErr, key.Algorithm)
+		assert.Equal(t, issued, claims)
+		assert.Equal(t, "5", claims.Subject)
+		assert.Equal(t, "peem", claims.Name)
+		assert.Equal(t, "expenses", claims.Issuer)
+		assert.Equal(t, testTime.Add(15*time.Minute).Unix(), claims.ExpiresAt)
+		assert.Len(t, claims.ID, 32)
+		assert.Equal(t, map[string]interface{}{"alg": key.Algorithm, "typ": "JWT", "kid": key.ID}, tokenPart(token, 0))
+	}
+}
+
+func TestTokensRotation(t *testing.T) {
+	// Arrange
+	old := newEd25519Key(t, "2026-09")
+	current := newEd25519Key(t, "2026-10")
+
+	oldToken, _, _ := newTokens(old).Issue(User{ID: 5})
+	rotated := newTokens(current, old)
+
+	// Act
+	newToken, _, _ := rotated.Issue(User{ID: 5})
+	_, oldErr := rotated.Verify(oldToken)
+	_, newErr := rotated.Verify(newToken)
+	_, removedErr := newTokens(current).Verify(oldToken)
+
+	// Assert
+	assert.Equal(t, "2026-10", tokenPart(newToken, 0)["kid"])
+	assert.NoError(t, oldErr)
+	assert.NoError(t, newErr)
+	assert.Equal(t, ErrInvalidToken, removedErr)
+}
+
+func TestTokensClockSkew(t *testing.T) {
+	tokens := newTokens(SigningKey{ID: "hmac", Algorithm: HS256, Secret: testSecret})
+	token, _, _ := tokens.Issue(User{ID: 5})
+
+	for _, test := range []struct {
+		elapsed time.Duration
+		err     error
+	}{
+		{-30 * time.Second, nil},
+		{-2 * time.Minute, ErrTokenNotValidYet},
+		{15*time.Minute + 30*time.Second, nil},
+		{16 * time.Minute, ErrTokenExpired},
+	} {
+		tokens.Now = func() time.Time { return testTime.Add(test.elapsed) }
+
+		_, err := tokens.Verify(token)
+
+		assert.Equal(t, test.err, err, test.elapsed)
+	}
+}
+
+func TestTokensRejects(t *testing.T) {
+	// Arrange
+	rsaKey := newRSAKey(t, "rsa")
+	tokens := newTokens(rsaKey)
+	token, _, _ := tokens.Issue(User{ID: 5})
+	parts := strings.Split(token, ".")
+
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	// A token signed with HS256 using the public key of the RS256 key as the secret.
+	public, _ := x509.MarshalPKIXPublicKey(rsaKey.Public)
+	confused := SigningKey{ID: "rsa", Algorithm: HS256, Secret: public}
+	confusedToken, _, _ := newTokens(confused).Issue(User{ID: 5})
+
+	otherIssuer := newTokens(rsaKey)
+	otherIssuer.Issuer = "other"
+	otherToken, _, _ := otherIssuer.Issue(User{ID: 5})
+
+	for _, bad := range []string{
+		"",
+		"a.b",
+		parts[0] + "." + encode(map[string]interface{}{"sub": "1", "exp": testTime.Add(time.Hour).Unix()}) + "." + parts[2],
+		encode(map[string]string{"alg": "none", "kid": "rsa"}) + "." + parts[1] + ".",
+		encode(map[string]string{"alg": "RS256", "kid": "unknown"}) + "." + parts[1] + "." + parts[2],
+		confusedToken,
+		otherToken,
+	} {
+		// Act
+		_, err := tokens.Verify(bad)
+
+		// Assert
+		assert.Equal(t, ErrInvalidToken, err, bad)
+	}
+}
+
+func TestParseSigningKey(t *testing.T) {
+	// Arrange
+	rsaKey := newRSAKey(t, "rsa")
+	edKey := newEd25519Key(t, "ed")
+
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(edKey.Private)
+	edPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey.Private.(*rsa.PrivateKey))})
+	pkix, _ := x509.MarshalPKIXPublicKey(rsaKey.Public)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix})
+
+	// Act
+	ed, edErr := ParseSigningKey("ed", EdDSA, edPEM)
+	rsaParsed, rsaErr := ParseSigningKey("rsa", RS256, rsaPEM)
+	public, publicErr := ParseSigningKey("old", RS256, publicPEM)
+	_, shortErr := ParseSigningKey("hmac", HS256, []byte("secret"))
+	_, wrongErr := ParseSigningKey("ed", RS256, edPEM)
+	_, unknownErr := ParseSigningKey("ed", "ES256", edPEM)
+	_, notPEMErr := ParseSigningKey("ed", EdDSA, []byte("key"))
+
+	// Assert
+	assert.NoError(t, edErr)
+	assert.Equal(t, edKey.Public, ed.Public)
+	assert.NoError(t, rsaErr)
+	assert.Equal(t, rsaKey.Public, rsaParsed.Public)
+	assert.NoError(t, publicErr)
+	assert.Nil(t, public.Private)
+	assert.Error(t, shortErr)
+	assert.Error(t, wrongErr)
+	assert.Error(t, unknownErr)
+	assert.Error(t, notPEMErr)
+}
+
+func TestLoadSigningKeys(t *testing.T) {
+	// Arrange
+	edKey := newEd25519Key(t, "ed")
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(edKey.Private)
+	path := filepath.Join(t.TempDir(), "ed.pem")
+	os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), 0600)
+
+	pkix, _ := x509.MarshalPKIXPublicKey(edKey.Public)
+	publicPath := filepath.Join(t.TempDir(), "public.pem")
+	os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}), 0600)
+
+	secret := base64.StdEncoding.EncodeToString(testSecret)
+
+	// Act
+	keys, err := LoadSigningKeys("2026-10:EdDSA:" + path + ", 2026-09:HS256:" + secret)
+	_, publicFirstErr := LoadSigningKeys("old:EdDSA:" + publicPath)
+	_, invalidErr := LoadSigningKeys("2026-10:EdDSA")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, "2026-10", keys[0].ID)
+	assert.Equal(t, edKey.Public, keys[0].Public)
+	assert.Equal(t, testSecret, keys[1].Secret)
+	assert.Error(t, publicFirstErr)
+	assert.Error(t, invalidErr)
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa")
+	edKey := newEd25519Key(t, "ed")
+	tokens := newTokens(edKey, rsaKey, SigningKey{ID: "hmac", Algorithm: HS256, Secret: testSecret})
+
+	set := tokens.JWKS()
+
+	assert.Len(t, set.Keys, 2)
+	assert.Equal(t, JWK{KeyType: "OKP", ID: "ed", Algorithm: EdDSA, Use: "sig", Curve: "Ed25519",
+		X: base64.RawURLEncoding.EncodeToString(edKey.Public.(ed25519.PublicKey))}, set.Keys[0])
+	assert.Equal(t, "RSA", set.Keys[1].KeyType)
+	assert.Equal(t, "AQAB", set.Keys[1].E)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(rsaKey.Public.(*rsa.PublicKey).N.Bytes()), set.Keys[1].N)
+}
@@ -0,0 +1,99 @@
+// Package auth authenticates the requests of the server.
+//
+// Clients send an API key as "Authorization: Bearer <key>" or in the
+// X-API-Key header. Only the SHA-256 hash of a key is stored, since a key
+// is random enough that a slow password hash would add nothing, and the
+// key itself is shown once when it is issued or rotated.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// KeyPrefix starts every API key, so leaked keys are easy to search for.
+const KeyPrefix = "exp_"
+
+// prefixLength is the length of the start of a key kept to tell keys apart.
+const prefixLength = len(KeyPrefix) + 8
+
+// ErrKeyNotFound is returned by a KeyStore when there is no such key.
+var ErrKeyNotFound = errors.New("api key not found")
+
+type (
+
+	// APIKey is an issued API key. Key is the secret itself,
+	// which is only set in the response issuing or rotating it.
+	APIKey struct {
+		ID         int        `json:"id"`
+		UserID     int        `json:"user_id"`
+		Name       string     `json:"name"`
+		Prefix     string     `json:"prefix"`
+		Key        string     `json:"key,omitempty"`
+		CreatedAt  time.Time  `json:"created_at"`
+		ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+		LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+		RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	}
+
+	// KeyStore stores API keys by the hash of their secret.
+	// The keys listed, rotated and revoked are those of the user of
+	// userID, or of every user when userID is 0.
+	KeyStore interface {
+		// CreateKey inserts a key with the hash of its secret and sets its ID and CreatedAt.
+		CreateKey(ctx context.Context, key *APIKey, hash string) error
+
+		// ListKeys returns the keys, revoked ones included, ordered by ID.
+		ListKeys(ctx context.Context, userID int) ([]APIKey, error)
+
+		// RotateKey replaces the secret of a key which is not revoked.
+		RotateKey(ctx context.Context, userID int, id int, prefix string, hash string) (APIKey, error)
+
+		// RevokeKey sets the RevokedAt of a key which is not revoked.
+		RevokeKey(ctx context.Context, userID int, id int) error
+
+		// KeyByHash returns the key of the hash of a secret, even a revoked or expired one.
+		KeyByHash(ctx context.Context, hash string) (APIKey, error)
+
+		// TouchKey sets the LastUsedAt of a key to now.
+		TouchKey(ctx context.Context, id int) error
+	}
+)
+
+// NewKey returns a random API key, its prefix and its hash.
+func NewKey() (key string, prefix string, hash string, err error) {
+
+	key, err = newSecret(KeyPrefix)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return key, key[:prefixLength], HashKey(key), nil
+}
+
+// newSecret returns a random secret starting with prefix.
+func newSecret(prefix string) (string, error) {
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return prefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashKey returns the hash of a key as it is stored.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Expired reports whether the key is expired at now.
+func (key APIKey) Expired(now time.Time) bool {
+	return key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// PostgresKeyStore is a KeyStore backed by the api_keys table.
+type PostgresKeyStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresKeyStore returns a PostgresKeyStore using db.
+func NewPostgresKeyStore(db *sql.DB) *PostgresKeyStore {
+	return &PostgresKeyStore{DB: db}
+}
+
+const keyColumns = "id, user_id, name, prefix, created_at, expires_at, last_used_at, revoked_at"
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// utc returns a nullable time in UTC.
+func utc(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	u := t.Time.UTC()
+	return &u
+}
+
+func scanKey(row scanner) (APIKey, error) {
+
+	var key APIKey
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return key, ErrKeyNotFound
+	}
+
+	key.CreatedAt = key.CreatedAt.UTC()
+	key.ExpiresAt = utc(expiresAt)
+	key.LastUsedAt = utc(lastUsedAt)
+	key.RevokedAt = utc(revokedAt)
+
+	return key, err
+}
+
+func (store *PostgresKeyStore) CreateKey(ctx context.Context, key *APIKey, hash string) error {
+
+	err := store.DB.QueryRowContext(ctx, `
+		INSERT INTO api_keys (user_id, name, prefix, hash, expires_at) VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, key.UserID, key.Name, key.Prefix, hash, key.ExpiresAt).Scan(&key.ID, &key.CreatedAt)
+	key.CreatedAt = key.CreatedAt.UTC()
+
+	return err
+}
+
+func (store *PostgresKeyStore) ListKeys(ctx context.Context, userID int) ([]APIKey, error) {
+
+	rows, err := store.DB.QueryContext(ctx,
+		"SELECT "+keyColumns+" FROM api_keys WHERE $1 = 0 OR user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (store *PostgresKeyStore) RotateKey(ctx context.Context, userID int, id int, prefix string, hash string) (APIKey, error) {
+	return scanKey(store.DB.QueryRowContext(ctx, `
+		UPDATE api_keys SET prefix = $3, hash = $4
+		WHERE id = $2 AND ($1 = 0 OR user_id = $1) AND revoked_at IS NULL
+		RETURNING `+keyColumns, userID, id, prefix, hash))
+}
+
+func (store *PostgresKeyStore) RevokeKey(ctx context.Context, userID int, id int) error {
+
+	result, err := store.DB.ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = now()
+		WHERE id = $2 AND ($1 = 0 OR user_id = $1) AND revoked_at IS NULL
+	`, userID, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrKeyNotFound
+	}
+
+	return nil
+}
+
+func (store *PostgresKeyStore) KeyByHash(ctx context.Context, hash string) (APIKey, error) {
+	return scanKey(store.DB.QueryRowContext(ctx, "SELECT "+keyColumns+" FROM api_keys WHERE hash = $1", hash))
+}
+
+func (store *PostgresKeyStore) TouchKey(ctx context.Context, id int) error {
+	_, err := store.DB.ExecContext(ctx, "UPDATE api_keys SET last_used_at = now() WHERE id = $1", id)
+	return err
+}
+
+// MemoryKeyStore is a thread-safe KeyStore keeping keys in a slice.
+type MemoryKeyStore struct {
+	mu     sync.RWMutex
+	keys   []APIKey
+	hashes []string
+
+	// Now returns the time keys are created, used and revoked.
+	Now func() time.Time
+}
+
+// NewMemoryKeyStore returns an empty MemoryKeyStore.
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{Now: time.Now}
+}
+
+func (store *MemoryKeyStore) now() *time.Time {
+	now := store.Now().UTC().Truncate(time.Microsecond)
+	return &now
+}
+
+// find returns the index of the key of id of the user of userID, or -1.
+func (store *MemoryKeyStore) find(userID int, id int) int {
+	for i, key := range store.keys {
+		if key.ID == id && (userID == 0 || key.UserID == userID) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (store *MemoryKeyStore) CreateKey(ctx context.Context, key *APIKey, hash string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	key.ID = len(store.keys) + 1
+	key.CreatedAt = *store.now()
+
+	saved := *key
+	saved.Key = ""
+	store.keys = append(store.keys, saved)
+	store.hashes = append(store.hashes, hash)
+
+	return nil
+}
+
+func (store *MemoryKeyStore) ListKeys(ctx context.Context, userID int) ([]APIKey, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var keys []APIKey
+	for _, key := range store.keys {
+		if userID == 0 || key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func (store *MemoryKeyStore) RotateKey(ctx context.Context, userID int, id int, prefix string, hash string) (APIKey, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.find(userID, id)
+	if i < 0 || store.keys[i].RevokedAt != nil {
+		return APIKey{}, ErrKeyNotFound
+	}
+
+	store.keys[i].Prefix = prefix
+	store.hashes[i] = hash
+
+	return store.keys[i], nil
+}
+
+func (store *MemoryKeyStore) RevokeKey(ctx context.Context, userID int, id int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.find(userID, id)
+	if i < 0 || store.keys[i].RevokedAt != nil {
+		return ErrKeyNotFound
+	}
+
+	store.keys[i].RevokedAt = store.now()
+
+	return nil
+}
+
+func (store *MemoryKeyStore) KeyByHash(ctx context.Context, hash string) (APIKey, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for i, stored := range store.hashes {
+		if stored == hash {
+			return store.keys[i], nil
+		}
+	}
+
+	return APIKey{}, ErrKeyNotFound
+}
+
+func (store *MemoryKeyStore) TouchKey(ctx context.Context, id int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if i := store.find(0, id); i >= 0 {
+		store.keys[i].LastUsedAt = store.now()
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTime = time.Date(2026, 9, 15, 5, 30, 0, 0, time.UTC)
+
+func TestNewKey(t *testing.T) {
+	// Act
+	key, prefix, hash, err := NewKey()
+	other, _, _, _ := NewKey()
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, KeyPrefix))
+	assert.Len(t, key, len(KeyPrefix)+32)
+	assert.Equal(t, key[:12], prefix)
+	assert.Equal(t, HashKey(key), hash)
+	assert.Len(t, hash, 64)
+	assert.NotEqual(t, key, other)
+}
+
+func TestAPIKeyExpired(t *testing.T) {
+	expiresAt := testTime
+
+	assert.False(t, APIKey{}.Expired(testTime))
+	assert.False(t, APIKey{ExpiresAt: &expiresAt}.Expired(testTime.Add(-time.Second)))
+	assert.True(t, APIKey{ExpiresAt: &expiresAt}.Expired(testTime))
+}
+
+func TestMemoryKeyStore(t *testing.T) {
+	// Arrange
+	store := NewMemoryKeyStore()
+	store.Now = func() time.Time { return testTime }
+	ctx := context.Background()
+
+	key := APIKey{UserID: 1, Name: "ci", Prefix: "exp_abcdefgh", Key: "exp_abcdefgh..."}
+	other := APIKey{UserID: 2, Name: "other", Prefix: "exp_00000000"}
+
+	// Act
+	store.CreateKey(ctx, &key, "hash1")
+	store.CreateKey(ctx, &other, "hash0")
+	_, rotateOtherErr := store.RotateKey(ctx, 2, key.ID, "exp_ijklmnop", "hash2")
+	rotated, rotateErr := store.RotateKey(ctx, 1, key.ID, "exp_ijklmnop", "hash2")
+	_, oldErr := store.KeyByHash(ctx, "hash1")
+	found, foundErr := store.KeyByHash(ctx, "hash2")
+	store.TouchKey(ctx, key.ID)
+	revokeOtherErr := store.RevokeKey(ctx, 2, key.ID)
+	revokeErr := store.RevokeKey(ctx, 1, key.ID)
+	revokeAgainErr := store.RevokeKey(ctx, 0, key.ID)
+	_, rotateRevokedErr := store.RotateKey(ctx, 1, key.ID, "exp_qrstuvwx", "hash3")
+	keys, _ := store.ListKeys(ctx, 1)
+	all, _ := store.ListKeys(ctx, 0)
+
+	// Assert
+	assert.Equal(t, 1, key.ID)
+	assert.Equal(t, testTime, key.CreatedAt)
+	assert.Equal(t, ErrKeyNotFound, rotateOtherErr)
+	assert.Equal(t, ErrKeyNotFound, revokeOtherErr)
+	assert.NoError(t, rotateErr)
+	assert.Equal(t, "exp_ijklmnop", rotated.Prefix)
+	assert.Equal(t, ErrKeyNotFound, oldErr)
+	assert.NoError(t, foundErr)
+	assert.Equal(t, 1, found.ID)
+	assert.NoError(t, revokeErr)
+	assert.Equal(t, ErrKeyNotFound, revokeAgainErr)
+	assert.Equal(t, ErrKeyNotFound, rotateRevokedErr)
+
+	assert.Len(t, keys, 1)
+	assert.Len(t, all, 2)
+	assert.Equal(t, "", keys[0].Key)
+	assert.Equal(t, &testTime, keys[0].LastUsedAt)
+	assert.Equal(t, &testTime, keys[0].RevokedAt)
+}
+
+func TestPostgresKeyStoreKeyByHash(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	columns := []string{"id", "user_id", "name", "prefix", "created_at", "expires_at", "last_used_at", "revoked_at"}
+	mock.ExpectQuery("SELECT (.+) FROM api_keys WHERE hash = \\$1").
+		WithArgs("hash1").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 1, "ci", "exp_abcdefgh", testTime, testTime, nil, nil))
+	mock.ExpectQuery("SELECT (.+) FROM api_keys WHERE hash = \\$1").
+		WithArgs("hash2").
+		WillReturnRows(sqlmock.NewRows(columns))
+
+	store := NewPostgresKeyStore(db)
+
+	// Act
+	key, err := store.KeyByHash(context.Background(), "hash1")
+	_, missingErr := store.KeyByHash(context.Background(), "hash2")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, APIKey{ID: 3, UserID: 1, Name: "ci", Prefix: "exp_abcdefgh", CreatedAt: testTime, ExpiresAt: &testTime}, key)
+	assert.Equal(t, ErrKeyNotFound, missingErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
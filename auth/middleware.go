@@ -0,0 +1,271 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// identityKey is the key of the Identity in an echo.Context.
+const identityKey = "identity"
+
+// touchInterval is how stale LastUsedAt may get, so a busy key
+// is not written on every request.
+const touchInterval = time.Minute
+
+// Identity is who made an authenticated request.
+type Identity struct {
+	// Subject names the identity, such as api-key:3 or user:5 for a token.
+	Subject string `json:"subject"`
+	Name    string `json:"name"`
+
+	// UserID is the ID of the user of the request, 0 for the bootstrap key.
+	UserID int `json:"user_id,omitempty"`
+
+	// KeyID is the ID of the API key of the request, 0 for the bootstrap key.
+	KeyID int `json:"key_id,omitempty"`
+
+	// Role is the role of the user, which grants the permission to manage users.
+	Role Role `json:"role,omitempty"`
+
+	// WorkspaceID is the ID of the workspace of the request, 0 when the user has none.
+	WorkspaceID int `json:"workspace_id,omitempty"`
+
+	// WorkspaceRole is the role of the user in the workspace of the request,
+	// which grants the other permissions of the request.
+	WorkspaceRole Role `json:"workspace_role,omitempty"`
+}
+
+// Bootstrap reports whether the identity is the bootstrap key,
+// which belongs to no user.
+func (identity Identity) Bootstrap() bool {
+	return identity.Subject == "bootstrap"
+}
+
+// IdentityOf returns the identity the middleware attached to c.
+func IdentityOf(c echo.Context) (Identity, bool) {
+	identity, ok := c.Get(identityKey).(Identity)
+	return identity, ok
+}
+
+// SetIdentity attaches an identity to c.
+func SetIdentity(c echo.Context, identity Identity) {
+	c.Set(identityKey, identity)
+}
+
+// Authenticator checks the API keys and tokens of requests.
+type Authenticator struct {
+	Keys KeyStore
+
+	// Users, when set, gives the identities of users their current role,
+	// so a new role applies to the tokens issued before it.
+	Users UserStore
+
+	// Workspaces, when set, puts requests in the workspace of WorkspaceHeader,
+	// or in the first workspace of their user.
+	Workspaces WorkspaceStore
+
+	// Tokens, when set, verifies the JSON Web Tokens sent instead of API keys.
+	Tokens *Tokens
+
+	// PublicPaths are the route paths which need no authentication,
+	// such as the login.
+	PublicPaths []string
+
+	// BootstrapKey, when set, is accepted as the bootstrap identity
+	// so the first users and keys can be created.
+	BootstrapKey string
+
+	// BootstrapPaths are the starts of the route paths the bootstrap key
+	// may use. Other routes need the key of a user.
+	BootstrapPaths []string
+
+	// Now returns the time keys expire against. Tests may replace it.
+	Now func() time.Time
+}
+
+// requestKey returns the API key or token of a request, from
+// "Authorization: Bearer <key>" or the X-API-Key header.
+func requestKey(c echo.Context) string {
+
+	if key := c.Request().Header.Get("X-API-Key"); key != "" {
+		return strings.TrimSpace(key)
+	}
+
+	scheme, key, found := strings.Cut(c.Request().Header.Get(echo.HeaderAuthorization), " ")
+	if found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(key)
+	}
+
+	return ""
+}
+
+// Authenticate returns the identity of an API key or a token.
+func (authenticator Authenticator) Authenticate(c echo.Context, key string) (Identity, error) {
+
+	if authenticator.BootstrapKey != "" &&
+		subtle.ConstantTimeCompare([]byte(key), []byte(authenticator.BootstrapKey)) == 1 {
+		return Identity{Subject: "bootstrap", Name: "bootstrap"}, nil
+	}
+
+	// A token has three parts separated by dots, which API keys never have.
+	if authenticator.Tokens != nil && strings.Count(key, ".") == 2 {
+		return authenticator.authenticateToken(key)
+	}
+
+	ctx := c.Request().Context()
+
+	apiKey, err := authenticator.Keys.KeyByHash(ctx, HashKey(key))
+	if err == ErrKeyNotFound {
+		return Identity{}, echo.NewHTTPError(http.StatusUnauthorized, "invalid api key.")
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+
+	now := authenticator.Now()
+	switch {
+	case apiKey.RevokedAt != nil:
+		return Identity{}, echo.NewHTTPError(http.StatusUnauthorized, "the api key is revoked.")
+	case apiKey.Expired(now):
+		return Identity{}, echo.NewHTTPError(http.StatusUnauthorized, "the api key is expired.")
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= touchInterval {
+		if err := authenticator.Keys.TouchKey(ctx, apiKey.ID); err != nil {
+			// The request can go on without its use being recorded.
+			log.Println("cannot record the use of api key", apiKey.ID, err)
+		}
+	}
+
+	return Identity{
+		Subject: "api-key:" + strconv.Itoa(apiKey.ID),
+		Name:    apiKey.Name,
+		UserID:  apiKey.UserID,
+		KeyID:   apiKey.ID,
+	}, nil
+}
+
+// authenticateToken returns the identity of the user of a token.
+func (authenticator Authenticator) authenticateToken(token string) (Identity, error) {
+
+	claims, err := authenticator.Tokens.Verify(token)
+	if err == nil {
+		var userID int
+		if userID, err = claims.UserID(); err == nil {
+			return Identity{Subject: "user:" + claims.Subject, Name: claims.Name, UserID: userID}, nil
+		}
+	}
+
+	switch err {
+	case ErrTokenExpired:
+		return Identity{}, echo.NewHTTPError(http.StatusUnauthorized, "the token is expired.")
+	case ErrTokenNotValidYet:
+		return Identity{}, echo.NewHTTPError(http.StatusUnauthorized, "the token is not valid yet.")
+	default:
+		return Identity{}, echo.NewHTTPError(http.StatusUnauthorized, "invalid token.")
+	}
+}
+
+// Middleware rejects requests without a valid API key or token with 401 Unauthorized,
+// and attaches the identity of the key to the context of the others.
+// The IDs of the user and of its workspace are also put in the context of the
+// request, see UserID and WorkspaceID.
+func (authenticator Authenticator) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+
+		for _, path := range authenticator.PublicPaths {
+			if c.Path() == path {
+				return next(c)
+			}
+		}
+
+		key := requestKey(c)
+		if key == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "missing api key.")
+		}
+
+		identity, err := authenticator.Authenticate(c, key)
+		if err != nil {
+			return err
+		}
+
+		if identity.Bootstrap() && !authenticator.bootstrapAllowed(c.Path()) {
+			return echo.NewHTTPError(http.StatusForbidden, "the bootstrap key can only manage users and api keys.")
+		}
+
+		if identity.UserID != 0 && authenticator.Users != nil {
+			user, err := authenticator.Users.GetUser(c.Request().Context(), identity.UserID)
+			if err == ErrUserNotFound {
+				return echo.NewHTTPError(http.StatusUnauthorized, "the user of the request does not exist.")
+			}
+			if err != nil {
+				return err
+			}
+			identity.Role = user.Role
+		}
+
+		if identity.UserID != 0 && authenticator.Workspaces != nil {
+			member, err := authenticator.member(c, identity.UserID)
+			if err != nil {
+				return err
+			}
+			identity.WorkspaceID, identity.WorkspaceRole = member.WorkspaceID, member.Role
+		}
+
+		SetIdentity(c, identity)
+		if identity.UserID != 0 {
+			ctx := WithUser(c.Request().Context(), identity.UserID)
+			if identity.WorkspaceID != 0 {
+				ctx = WithWorkspace(ctx, identity.WorkspaceID)
+			}
+			c.SetRequest(c.Request().WithContext(ctx))
+		}
+
+		return next(c)
+	}
+}
+
+// member returns the membership of the user of userID in the workspace of
+// WorkspaceHeader, or in the first workspace of the user without it.
+// A user without workspaces gets an empty membership.
+func (authenticator Authenticator) member(c echo.Context, userID int) (Membership, error) {
+
+	ctx := c.Request().Context()
+
+	header := c.Request().Header.Get(WorkspaceHeader)
+	if header == "" {
+		member, err := authenticator.Workspaces.FirstMember(ctx, userID)
+		if err == ErrNotMember {
+			return Membership{}, nil
+		}
+		return member, err
+	}
+
+	workspaceID, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil || workspaceID < 1 {
+		return Membership{}, echo.NewHTTPError(http.StatusBadRequest, "invalid "+WorkspaceHeader+" header.")
+	}
+
+	member, err := authenticator.Workspaces.GetMember(ctx, workspaceID, userID)
+	if err == ErrNotMember {
+		return Membership{}, echo.NewHTTPError(http.StatusForbidden, ErrNotMember.Error()+".")
+	}
+
+	return member, err
+}
+
+// bootstrapAllowed reports whether the bootstrap key may use the route of path.
+func (authenticator Authenticator) bootstrapAllowed(path string) bool {
+	for _, prefix := range authenticator.BootstrapPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// request runs a request to path with headers through the middleware
+// and returns the identity it attached to the context.
+func request(authenticator Authenticator, path string, headers map[string]string) (Identity, *httptest.ResponseRecorder) {
+	e := echo.New()
+	var identity Identity
+	handler := func(c echo.Context) error {
+		identity, _ = IdentityOf(c)
+		if UserID(c.Request().Context()) != identity.UserID {
+			return c.NoContent(http.StatusTeapot)
+		}
+		return c.NoContent(http.StatusOK)
+	}
+	e.GET("/expenses", handler, authenticator.Middleware)
+	e.GET("/users", handler, authenticator.Middleware)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return identity, rec
+}
+
+func newAuthenticator(now time.Time) (Authenticator, *MemoryKeyStore) {
+	store := NewMemoryKeyStore()
+	store.Now = func() time.Time { return now }
+	return Authenticator{
+		Keys:           store,
+		BootstrapKey:   "bootstrap-secret",
+		BootstrapPaths: []string{"/users"},
+		Now:            func() time.Time { return now },
+	}, store
+}
+
+func issue(store *MemoryKeyStore, name string, expiresAt *time.Time) (APIKey, string) {
+	key, prefix, hash, _ := NewKey()
+	apiKey := APIKey{UserID: 7, Name: name, Prefix: prefix, ExpiresAt: expiresAt}
+	store.CreateKey(context.Background(), &apiKey, hash)
+	return apiKey, key
+}
+
+func TestMiddleware(t *testing.T) {
+	// Arrange
+	authenticator, store := newAuthenticator(testTime)
+	apiKey, key := issue(store, "ci", nil)
+
+	for _, headers := range []map[string]string{
+		{"Authorization": "Bearer " + key},
+		{"Authorization": "bearer  " + key},
+		{"X-API-Key": key},
+	} {
+		// Act
+		identity, rec := request(authenticator, "/expenses", headers)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, Identity{Subject: "api-key:1", Name: "ci", UserID: 7, KeyID: apiKey.ID}, identity)
+	}
+
+	keys, _ := store.ListKeys(context.Background(), 0)
+	assert.Equal(t, &testTime, keys[0].LastUsedAt)
+}
+
+func TestMiddlewareBootstrapKey(t *testing.T) {
+	authenticator, _ := newAuthenticator(testTime)
+
+	identity, rec := request(authenticator, "/users", map[string]string{"Authorization": "Bearer bootstrap-secret"})
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, Identity{Subject: "bootstrap", Name: "bootstrap"}, identity)
+	assert.True(t, identity.Bootstrap())
+
+	_, rec = request(authenticator, "/expenses", map[string]string{"Authorization": "Bearer bootstrap-secret"})
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	authenticator.BootstrapKey = ""
+	_, rec = request(authenticator, "/expenses", map[string]string{"Authorization": "Bearer "})
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestMiddlewareRejects(t *testing.T) {
+	// Arrange
+	authenticator, store := newAuthenticator(testTime)
+	expiresAt := testTime
+	_, expired := issue(store, "old", &expiresAt)
+	revokedKey, revoked := issue(store, "revoked", nil)
+	store.RevokeKey(context.Background(), 0, revokedKey.ID)
+
+	cases := []struct {
+		headers map[string]string
+		message string
+	}{
+		{map[string]string{}, "missing api key."},
+		{map[string]string{"Authorization": "November 10, 2009"}, "missing api key."},
+		{map[string]string{"Authorization": "Bearer exp_unknown"}, "invalid api key."},
+		{map[string]string{"X-API-Key": expired}, "the api key is expired."},
+		{map[string]string{"X-API-Key": revoked}, "the api key is revoked."},
+	}
+
+	for _, test := range cases {
+		// Act
+		_, rec := request(authenticator, "/expenses", test.headers)
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, test.message)
+		assert.JSONEq(t, `{"message": "`+test.message+`"}`, rec.Body.String())
+	}
+}
+
+func TestMiddlewareTouchesKeyOncePerInterval(t *testing.T) {
+	// Arrange
+	now := testTime
+	store := NewMemoryKeyStore()
+	store.Now = func() time.Time { return now }
+	authenticator := Authenticator{Keys: store, Now: func() time.Time { return now }}
+	_, key := issue(store, "ci", nil)
+
+	var lastUsed []time.Time
+	for _, elapsed := range []time.Duration{0, 30 * time.Second, time.Minute} {
+		// Act
+		now = testTime.Add(elapsed)
+		request(authenticator, "/expenses", map[string]string{"X-API-Key": key})
+
+		keys, _ := store.ListKeys(context.Background(), 0)
+		lastUsed = append(lastUsed, *keys[0].LastUsedAt)
+	}
+
+	// Assert
+	assert.Equal(t, []time.Time{testTime, testTime, testTime.Add(time.Minute)}, lastUsed)
+}
+
+func TestMiddlewareToken(t *testing.T) {
+	// Arrange
+	authenticator, _ := newAuthenticator(testTime)
+	authenticator.Tokens = newTokens(SigningKey{ID: "hmac", Algorithm: HS256, Secret: testSecret})
+	token, _, _ := authenticator.Tokens.Issue(User{ID: 5, Name: "peem"})
+
+	// Act
+	identity, rec := request(authenticator, "/expenses", map[string]string{"Authorization": "Bearer " + token})
+
+	authenticator.Tokens.Now = func() time.Time { return testTime.Add(time.Hour) }
+	_, expiredRec := request(authenticator, "/expenses", map[string]string{"Authorization": "Bearer " + token})
+	_, invalidRec := request(authenticator, "/expenses", map[string]string{"Authorization": "Bearer a.b.c"})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, Identity{Subject: "user:5", Name: "peem", UserID: 5}, identity)
+	assert.Equal(t, http.StatusUnauthorized, expiredRec.Code)
+	assert.Contains(t, expiredRec.Body.String(), "the token is expired.")
+	assert.Equal(t, http.StatusUnauthorized, invalidRec.Code)
+	assert.Contains(t, invalidRec.Body.String(), "invalid token.")
+}
+
+func TestMiddlewarePublicPaths(t *testing.T) {
+	authenticator, _ := newAuthenticator(testTime)
+	authenticator.PublicPaths = []string{"/users"}
+
+	identity, rec := request(authenticator, "/users", nil)
+	_, privateRec := request(authenticator, "/expenses", nil)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, Identity{}, identity)
+	assert.Equal(t, http.StatusUnauthorized, privateRec.Code)
+}
+
+func TestMiddlewareRole(t *testing.T) {
+	// Arrange
+	authenticator, store := newAuthenticator(testTime)
+	users := NewMemoryUserStore()
+	authenticator.Users = users
+	_, key := issue(store, "ci", nil)
+
+	// Act
+	_, missingRec := request(authenticator, "/expenses", map[string]string{"X-API-Key": key})
+
+	for i := 0; i < 7; i++ {
+		users.CreateUser(context.Background(), &User{Name: string(rune('a' + i))}, "")
+	}
+	users.SetRole(context.Background(), 7, Auditor)
+	identity, rec := request(authenticator, "/expenses", map[string]string{"X-API-Key": key})
+
+	// Assert
+	assert.Equal(t, http.StatusUnauthorized, missingRec.Code)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, Auditor, identity.Role)
+}
+
+func TestMiddlewareWorkspace(t *testing.T) {
+	// Arrange
+	authenticator, store := newAuthenticator(testTime)
+	workspaces := NewMemoryWorkspaceStore()
+	authenticator.Workspaces = workspaces
+	_, key := issue(store, "ci", nil)
+
+	// Act
+	_, noneRec := request(authenticator, "/expenses", map[string]string{"X-API-Key": key})
+
+	workspaces.CreateWorkspace(context.Background(), &Workspace{Name: "team"}, 7)
+	workspaces.CreateWorkspace(context.Background(), &Workspace{Name: "other"}, 8)
+	workspaces.CreateInvitation(context.Background(),
+		&Invitation{WorkspaceID: 2, Role: Auditor, ExpiresAt: testTime.Add(time.Hour)}, "hash")
+	workspaces.AcceptInvitation(context.Background(), "hash", 7, testTime)
+
+	first, firstRec := request(authenticator, "/expenses", map[string]string{"X-API-Key": key})
+	second, secondRec := request(authenticator, "/expenses", map[string]string{"X-API-Key": key, WorkspaceHeader: "2"})
+	_, strangerRec := request(authenticator, "/expenses", map[string]string{"X-API-Key": key, WorkspaceHeader: "3"})
+	_, invalidRec := request(authenticator, "/expenses", map[string]string{"X-API-Key": key, WorkspaceHeader: "team"})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, noneRec.Code)
+	assert.Equal(t, http.StatusOK, firstRec.Code)
+	assert.Equal(t, 1, first.WorkspaceID)
+	assert.Equal(t, Admin, first.WorkspaceRole)
+	assert.Equal(t, http.StatusOK, secondRec.Code)
+	assert.Equal(t, 2, second.WorkspaceID)
+	assert.Equal(t, Auditor, second.WorkspaceRole)
+	assert.Equal(t, http.StatusForbidden, strangerRec.Code)
+	assert.Equal(t, http.StatusBadRequest, invalidRec.Code)
+}
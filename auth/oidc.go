@@ -0,0 +1,518 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// loginTTL is how long a user has to log in at the identity provider.
+const loginTTL = 10 * time.Minute
+
+var (
+	// ErrInvalidLogin is returned by Tokens.OpenLogin for a login which is
+	// forged, expired or of another state.
+	ErrInvalidLogin = errors.New("the login expired or was not started here")
+
+	// ErrLoginRejected is returned by OIDC.Exchange when the identity provider
+	// refuses the authorization code, such as for a wrong PKCE verifier.
+	ErrLoginRejected = errors.New("the identity provider rejected the login")
+
+	// ErrInvalidIDToken is returned by OIDC.VerifyIDToken for an ID token
+	// which is not signed by the provider, not for this client or expired.
+	ErrInvalidIDToken = errors.New("invalid id token")
+
+	// ErrAccountNotLinked is returned by ProvisionUser for the first login of an
+	// account named like a user who has to log in and link the account first.
+	ErrAccountNotLinked = errors.New("a user of that name already exists, log in as the user to link the account")
+
+	// ErrAccountLinked is returned by LinkAccount for an account which is
+	// linked to another user.
+	ErrAccountLinked = errors.New("the account is linked to another user")
+)
+
+type (
+
+	// OIDC is the relying party of an OpenID Connect identity provider.
+	// It logs users in with the authorization code flow and PKCE, and verifies
+	// the ID tokens of the provider by the keys of its JWKS. The provider is
+	// discovered on first use from the openid-configuration of Issuer.
+	OIDC struct {
+		Issuer   string
+		ClientID string
+
+		// ClientSecret authenticates a confidential client at the token endpoint.
+		// A public client has none and relies on PKCE.
+		ClientSecret string
+
+		// RedirectURL is the callback the provider redirects users back to.
+		RedirectURL string
+
+		// Scopes are requested with the login, openid, email and profile by default.
+		Scopes []string
+
+		// NameClaim is the claim of the ID token naming the local user of an
+		// account, email by default. An email must be verified.
+		NameClaim string
+
+		// Client makes the requests to the provider, http.DefaultClient by default.
+		Client *http.Client
+
+		// Leeway is how far the clocks of the provider and the server may be apart.
+		Leeway time.Duration
+
+		// Now returns the current time. Tests may replace it.
+		Now func() time.Time
+
+		mu        sync.Mutex
+		discovery *Discovery
+		keys      []SigningKey
+	}
+
+	// Discovery is the part of the openid-configuration of a provider
+	// the relying party uses.
+	Discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+
+	// OIDCLogin is a login started at the identity provider. The browser keeps
+	// it, signed by Tokens.SealLogin, until the provider redirects back.
+	OIDCLogin struct {
+		State     string `json:"state"`
+		Nonce     string `json:"nonce"`
+		Verifier  string `json:"verifier"`
+		ExpiresAt int64  `json:"exp"`
+
+		// UserID is the user who links the account, 0 for a login.
+		UserID int `json:"uid,omitempty"`
+	}
+
+	// IDToken is a verified ID token. Claims has every claim, for NameClaim.
+	IDToken struct {
+		Issuer          string   `json:"iss"`
+		Subject         string   `json:"sub"`
+		Audience        audience `json:"aud"`
+		AuthorizedParty string   `json:"azp,omitempty"`
+		Nonce           string   `json:"nonce,omitempty"`
+		IssuedAt        int64    `json:"iat"`
+		NotBefore       int64    `json:"nbf,omitempty"`
+		ExpiresAt       int64    `json:"exp"`
+
+		Claims map[string]interface{} `json:"-"`
+	}
+
+	// audience is the aud of an ID token, a string or an array of strings.
+	audience []string
+)
+
+func (aud *audience) UnmarshalJSON(data []byte) error {
+
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*aud = audience{one}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(aud))
+}
+
+// randomString returns a random base64url string of n bytes.
+func randomString(n int) (string, error) {
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(data), nil
+}
+
+// NewLogin starts a login with a random state, nonce and PKCE verifier.
+func (oidc *OIDC) NewLogin() (OIDCLogin, error) {
+
+	var login OIDCLogin
+	var err error
+
+	if login.State, err = randomString(24); err != nil {
+		return login, err
+	}
+	if login.Nonce, err = randomString(24); err != nil {
+		return login, err
+	}
+	if login.Verifier, err = randomString(32); err != nil {
+		return login, err
+	}
+	login.ExpiresAt = oidc.Now().Add(loginTTL).Unix()
+
+	return login, nil
+}
+
+// CodeChallenge returns the S256 PKCE challenge of a verifier.
+func CodeChallenge(verifier string) string {
+	digest := sha256.Sum256([]byte(verifier))
+	return encoding.EncodeToString(digest[:])
+}
+
+// SealLogin returns a login signed by the first key, to be kept by the browser.
+func (tokens *Tokens) SealLogin(login OIDCLogin) (string, error) {
+	return tokens.sign("oidc-login", login)
+}
+
+// OpenLogin checks the signature and expiry of a sealed login,
+// and that it has the state the provider redirected back with.
+func (tokens *Tokens) OpenLogin(sealed string, state string) (OIDCLogin, error) {
+
+	var login OIDCLogin
+	head, err := verifyToken(tokens.Keys, sealed, &login)
+	if err != nil || head.Type != "oidc-login" || login.State == "" || login.State != state ||
+		!tokens.Now().Before(time.Unix(login.ExpiresAt, 0)) {
+		return OIDCLogin{}, ErrInvalidLogin
+	}
+
+	return login, nil
+}
+
+// client returns the client making requests to the provider.
+func (oidc *OIDC) client() *http.Client {
+	if oidc.Client != nil {
+		return oidc.Client
+	}
+	return http.DefaultClient
+}
+
+// getJSON reads the JSON response of a GET request to url into v.
+func (oidc *OIDC) getJSON(ctx context.Context, url string, v interface{}) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := oidc.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s responded %s", url, res.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
+
+// Discover returns the openid-configuration of the provider,
+// which is fetched once.
+func (oidc *OIDC) Discover(ctx context.Context) (Discovery, error) {
+	oidc.mu.Lock()
+	defer oidc.mu.Unlock()
+
+	if oidc.discovery != nil {
+		return *oidc.discovery, nil
+	}
+
+	var discovery Discovery
+	if err := oidc.getJSON(ctx, strings.TrimSuffix(oidc.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return discovery, fmt.Errorf("cannot discover the identity provider. %w", err)
+	}
+	// The issuer must be the one configured, or a provider could
+	// vouch for the accounts of another.
+	if discovery.Issuer != oidc.Issuer {
+		return discovery, fmt.Errorf("the identity provider is %s, not %s", discovery.Issuer, oidc.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return discovery, errors.New("the identity provider has no authorization, token or jwks endpoint")
+	}
+
+	oidc.discovery = &discovery
+	return discovery, nil
+}
+
+// AuthCodeURL returns the URL of the provider the browser is redirected to
+// for a login.
+func (oidc *OIDC) AuthCodeURL(ctx context.Context, login OIDCLogin) (string, error) {
+
+	discovery, err := oidc.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := oidc.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {oidc.ClientID},
+		"redirect_uri":          {oidc.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {login.State},
+		"nonce":                 {login.Nonce},
+		"code_challenge":        {CodeChallenge(login.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems the authorization code of a login at the token endpoint
+// and returns its verified ID token.
+func (oidc *OIDC) Exchange(ctx context.Context, code string, login OIDCLogin) (IDToken, error) {
+
+	discovery, err := oidc.Discover(ctx)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {oidc.RedirectURL},
+		"code_verifier": {login.Verifier},
+	}
+	if oidc.ClientSecret == "" {
+		form.Set("client_id", oidc.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return IDToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if oidc.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(oidc.ClientID), url.QueryEscape(oidc.ClientSecret))
+	}
+
+	res, err := oidc.client().Do(req)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("cannot reach the token endpoint. %w", err)
+	}
+	defer res.Body.Close()
+
+	var response struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&response); err != nil {
+		return IDToken{}, fmt.Errorf("cannot read the response of the token endpoint, %s. %w", res.Status, err)
+	}
+
+	switch {
+	case res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnauthorized:
+		return IDToken{}, fmt.Errorf("%w. %s %s", ErrLoginRejected, response.Error, response.ErrorDescription)
+	case res.StatusCode != http.StatusOK:
+		return IDToken{}, fmt.Errorf("the token endpoint responded %s", res.Status)
+	case response.IDToken == "":
+		return IDToken{}, errors.New("the token endpoint responded without an id_token")
+	}
+
+	return oidc.VerifyIDToken(ctx, response.IDToken, login.Nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, times and nonce of an ID token.
+// The keys of the provider are fetched again once for a kid they do not have,
+// so the provider can rotate its keys.
+func (oidc *OIDC) VerifyIDToken(ctx context.Context, raw string, nonce string) (IDToken, error) {
+
+	discovery, err := oidc.Discover(ctx)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	keys, err := oidc.providerKeys(ctx, discovery, false)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	var claims map[string]interface{}
+	_, err = verifyToken(keys, raw, &claims)
+	if err == errUnknownKey {
+		if keys, err = oidc.providerKeys(ctx, discovery, true); err != nil {
+			return IDToken{}, err
+		}
+		_, err = verifyToken(keys, raw, &claims)
+	}
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%w. the signature is not of a key of the identity provider", ErrInvalidIDToken)
+	}
+
+	// The claims are read again into the struct, as they were verified as a map.
+	var token IDToken
+	if err := decodePart(strings.Split(raw, ".")[1], &token); err != nil {
+		return IDToken{}, fmt.Errorf("%w. %v", ErrInvalidIDToken, err)
+	}
+	token.Claims = claims
+
+	switch {
+	case token.Issuer != discovery.Issuer:
+		return IDToken{}, fmt.Errorf("%w. the issuer is %s", ErrInvalidIDToken, token.Issuer)
+	case token.Subject == "":
+		return IDToken{}, fmt.Errorf("%w. there is no subject", ErrInvalidIDToken)
+	case !token.Audience.contains(oidc.ClientID):
+		return IDToken{}, fmt.Errorf("%w. the token is not for this client", ErrInvalidIDToken)
+	case len(token.Audience) > 1 && token.AuthorizedParty != oidc.ClientID,
+		token.AuthorizedParty != "" && token.AuthorizedParty != oidc.ClientID:
+		return IDToken{}, fmt.Errorf("%w. the token is authorized for %s", ErrInvalidIDToken, token.AuthorizedParty)
+	case token.ExpiresAt == 0:
+		return IDToken{}, fmt.Errorf("%w. there is no exp", ErrInvalidIDToken)
+	case token.Nonce != nonce:
+		return IDToken{}, fmt.Errorf("%w. the nonce is wrong", ErrInvalidIDToken)
+	}
+
+	if err := checkTimes(oidc.Now(), oidc.Leeway, token.IssuedAt, token.NotBefore, token.ExpiresAt); err != nil {
+		return IDToken{}, fmt.Errorf("%w. %v", ErrInvalidIDToken, err)
+	}
+
+	return token, nil
+}
+
+// contains reports whether the audience has clientID.
+func (aud audience) contains(clientID string) bool {
+	for _, id := range aud {
+		if id == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// providerKeys returns the keys of the JWKS of the provider, which are
+// fetched on first use and again when refresh is true.
+func (oidc *OIDC) providerKeys(ctx context.Context, discovery Discovery, refresh bool) ([]SigningKey, error) {
+	oidc.mu.Lock()
+	defer oidc.mu.Unlock()
+
+	if oidc.keys != nil && !refresh {
+		return oidc.keys, nil
+	}
+
+	var set JWKSet
+	if err := oidc.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("cannot get the keys of the identity provider. %w", err)
+	}
+
+	keys := []SigningKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of other algorithms, such as ES256, cannot verify tokens here
+		// and are skipped rather than failing the others.
+		if key, err := ParseJWK(jwk); err == nil {
+			keys = append(keys, key)
+		}
+	}
+
+	oidc.keys = keys
+	return keys, nil
+}
+
+// NamedByEmail reports whether users are named by the email of their
+// account, which Name only returns when it is verified.
+func (oidc *OIDC) NamedByEmail() bool {
+	return oidc.NameClaim == "" || oidc.NameClaim == "email"
+}
+
+// Name returns the name of the local user of the account of the token,
+// the NameClaim of the token. An email must be verified, so an account
+// cannot take the name of a user of another email.
+func (oidc *OIDC) Name(token IDToken) (string, error) {
+
+	claim := oidc.NameClaim
+	if claim == "" {
+		claim = "email"
+	}
+
+	name, _ := token.Claims[claim].(string)
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("the id token has no %s", claim)
+	}
+	if claim == "email" && token.Claims["email_verified"] != true {
+		return "", errors.New("the email of the account is not verified")
+	}
+
+	return name, nil
+}
+
+// ProvisionUser returns the user linked to the account of subject at the
+// provider of issuer. On the first login of an account, a user of the given
+// name is created and linked to it. An existing user of that name is only
+// linked when verifiedEmail tells that the name is the verified email of the
+// account and the user has neither a password nor another account, such as
+// a user created for single sign-on. Otherwise it returns ErrAccountNotLinked,
+// and the user has to log in and link the account with LinkAccount.
+func ProvisionUser(ctx context.Context, users UserStore, issuer string, subject string, name string, verifiedEmail bool) (User, error) {
+
+	user, err := users.UserByIdentity(ctx, issuer, subject)
+	if err != ErrUserNotFound {
+		return user, err
+	}
+
+	user, hash, err := users.UserByName(ctx, name)
+	switch err {
+	case nil:
+		linked, err := users.HasIdentity(ctx, user.ID)
+		if err != nil {
+			return User{}, err
+		}
+		if !verifiedEmail || hash != "" || linked {
+			return User{}, ErrAccountNotLinked
+		}
+	case ErrUserNotFound:
+		user = User{Name: name}
+		err = users.CreateUser(ctx, &user, "")
+		// Another first login of the same name may have created it,
+		// which is only linked if it is of the same account.
+		if err == ErrUserExists {
+			if user, err = users.UserByIdentity(ctx, issuer, subject); err == ErrUserNotFound {
+				err = ErrAccountNotLinked
+			}
+			return user, err
+		}
+		if err != nil {
+			return User{}, err
+		}
+	default:
+		return User{}, err
+	}
+
+	return LinkAccount(ctx, users, user.ID, issuer, subject)
+}
+
+// LinkAccount links the account of subject at the provider of issuer to the
+// user of userID, and returns the user. It returns ErrAccountLinked when the
+// account is linked to another user.
+func LinkAccount(ctx context.Context, users UserStore, userID int, issuer string, subject string) (User, error) {
+
+	if err := users.LinkIdentity(ctx, userID, issuer, subject); err != nil {
+		return User{}, err
+	}
+
+	// Linking a linked account does nothing, so read who it is linked to.
+	user, err := users.UserByIdentity(ctx, issuer, subject)
+	if err == nil && user.ID != userID {
+		return User{}, ErrAccountLinked
+	}
+
+	return user, err
+}
@@ -0,0 +1,368 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubIdP is an identity provider served by an httptest server. Its ID tokens
+// are signed by the first of its keys and have the claims of the login plus Claims.
+type stubIdP struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	keys   []SigningKey
+	logins map[string]url.Values
+
+	ClientID     string
+	ClientSecret string
+	Claims       map[string]interface{}
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+
+	idp := &stubIdP{
+		keys:         []SigningKey{newEd25519Key(t, "idp-1")},
+		logins:       map[string]url.Values{},
+		ClientID:     "expenses",
+		ClientSecret: "client-secret",
+		Claims:       map[string]interface{}{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		json.NewEncoder(w).Encode((&Tokens{Keys: idp.keys}).JWKS())
+	})
+	// The user logs in at once, and is redirected back with a code.
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("client_id") != idp.ClientID || query.Get("code_challenge_method") != "S256" {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		code, _ := randomString(16)
+		idp.mu.Lock()
+		idp.logins[code] = query
+		idp.mu.Unlock()
+		http.Redirect(w, r, query.Get("redirect_uri")+"?code="+code+"&state="+query.Get("state"), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		fail := func(status int, code string) {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"error": code})
+		}
+
+		if id, secret, _ := r.BasicAuth(); id != idp.ClientID || secret != idp.ClientSecret {
+			fail(http.StatusUnauthorized, "invalid_client")
+			return
+		}
+
+		idp.mu.Lock()
+		login, ok := idp.logins[r.PostFormValue("code")]
+		delete(idp.logins, r.PostFormValue("code"))
+		idp.mu.Unlock()
+
+		if !ok || r.PostFormValue("grant_type") != "authorization_code" ||
+			r.PostFormValue("redirect_uri") != login.Get("redirect_uri") ||
+			CodeChallenge(r.PostFormValue("code_verifier")) != login.Get("code_challenge") {
+			fail(http.StatusBadRequest, "invalid_grant")
+			return
+		}
+
+		claims := map[string]interface{}{
+			"iss":            idp.URL,
+			"sub":            "248289761001",
+			"aud":            idp.ClientID,
+			"nonce":          login.Get("nonce"),
+			"iat":            testTime.Unix(),
+			"exp":            testTime.Add(time.Hour).Unix(),
+			"email":          "peem@example.com",
+			"email_verified": true,
+		}
+		for name, value := range idp.Claims {
+			claims[name] = value
+		}
+
+		idp.mu.Lock()
+		idToken, _ := (&Tokens{Keys: idp.keys}).sign("JWT", claims)
+		idp.mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "access_token": "at", "token_type": "Bearer"})
+	})
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+// RotateKeys makes the IdP sign with keys[0] and publish only keys.
+func (idp *stubIdP) RotateKeys(keys ...SigningKey) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keys = keys
+}
+
+// newOIDC returns the relying party of idp.
+func newOIDC(idp *stubIdP) *OIDC {
+	return &OIDC{
+		Issuer:       idp.URL,
+		ClientID:     idp.ClientID,
+		ClientSecret: idp.ClientSecret,
+		RedirectURL:  "https://expenses.example.com/auth/oidc/callback",
+		Leeway:       time.Minute,
+		Now:          func() time.Time { return testTime },
+	}
+}
+
+// logIn starts a login, follows the redirect to the IdP and returns
+// the login and the code the IdP redirects back with.
+func logIn(t *testing.T, oidc *OIDC) (OIDCLogin, string) {
+
+	login, err := oidc.NewLogin()
+	assert.NoError(t, err)
+
+	authURL, err := oidc.AuthCodeURL(context.Background(), login)
+	assert.NoError(t, err)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	callback, _ := url.Parse(res.Header.Get("Location"))
+	assert.Equal(t, login.State, callback.Query().Get("state"))
+
+	return login, callback.Query().Get("code")
+}
+
+func TestOIDC(t *testing.T) {
+	// Arrange
+	idp := newStubIdP(t)
+	oidc := newOIDC(idp)
+
+	// Act
+	login, code := logIn(t, oidc)
+	authURL, _ := oidc.AuthCodeURL(context.Background(), login)
+	token, err := oidc.Exchange(context.Background(), code, login)
+	name, nameErr := oidc.Name(token)
+	_, replayErr := oidc.Exchange(context.Background(), code, login)
+
+	// Assert
+	query := func() url.Values { parsed, _ := url.Parse(authURL); return parsed.Query() }()
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, "openid email profile", query.Get("scope"))
+	assert.Equal(t, login.Nonce, query.Get("nonce"))
+	assert.Equal(t, CodeChallenge(login.Verifier), query.Get("code_challenge"))
+	assert.NotContains(t, authURL, login.Verifier)
+
+	assert.NoError(t, err)
+	assert.Equal(t, idp.URL, token.Issuer)
+	assert.Equal(t, "248289761001", token.Subject)
+	assert.Equal(t, login.Nonce, token.Nonce)
+	assert.NoError(t, nameErr)
+	assert.Equal(t, "peem@example.com", name)
+	assert.ErrorIs(t, replayErr, ErrLoginRejected)
+}
+
+func TestOIDCRejectsWrongVerifier(t *testing.T) {
+	idp := newStubIdP(t)
+	oidc := newOIDC(idp)
+	login, code := logIn(t, oidc)
+
+	login.Verifier = "a verifier of another login"
+	_, err := oidc.Exchange(context.Background(), code, login)
+
+	assert.ErrorIs(t, err, ErrLoginRejected)
+}
+
+func TestOIDCRejectsIDTokens(t *testing.T) {
+	for _, claims := range []map[string]interface{}{
+		{"aud": "another-client"},
+		{"aud": []string{"expenses", "another-client"}},
+		{"aud": []string{"expenses", "another-client"}, "azp": "another-client"},
+		{"iss": "https://idp.example.com"},
+		{"nonce": "another nonce"},
+		{"sub": ""},
+		{"exp": testTime.Add(-2 * time.Minute).Unix()},
+		{"iat": testTime.Add(2 * time.Minute).Unix()},
+	} {
+		// Arrange
+		idp := newStubIdP(t)
+		idp.Claims = claims
+		oidc := newOIDC(idp)
+		login, code := logIn(t, oidc)
+
+		// Act
+		_, err := oidc.Exchange(context.Background(), code, login)
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidIDToken, claims)
+	}
+}
+
+func TestOIDCAcceptsClockSkew(t *testing.T) {
+	idp := newStubIdP(t)
+	idp.Claims = map[string]interface{}{
+		"aud": []string{"expenses", "another-client"},
+		"azp": "expenses",
+		"iat": testTime.Add(30 * time.Second).Unix(),
+		"exp": testTime.Add(-30 * time.Second).Unix(),
+	}
+	oidc := newOIDC(idp)
+	login, code := logIn(t, oidc)
+
+	_, err := oidc.Exchange(context.Background(), code, login)
+
+	assert.NoError(t, err)
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	// Arrange
+	idp := newStubIdP(t)
+	oidc := newOIDC(idp)
+	login, code := logIn(t, oidc)
+	_, firstErr := oidc.Exchange(context.Background(), code, login)
+
+	// Act
+	idp.RotateKeys(newRSAKey(t, "idp-2"))
+	login, code = logIn(t, oidc)
+	_, rotatedErr := oidc.Exchange(context.Background(), code, login)
+
+	// An ID token signed by a key the IdP does not publish.
+	idp.mu.Lock()
+	forged, _ := (&Tokens{Keys: []SigningKey{newEd25519Key(t, "idp-2")}}).sign("JWT", map[string]interface{}{})
+	idp.mu.Unlock()
+	_, forgedErr := oidc.VerifyIDToken(context.Background(), forged, "")
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.NoError(t, rotatedErr)
+	assert.ErrorIs(t, forgedErr, ErrInvalidIDToken)
+}
+
+func TestOIDCDiscoveryOfAnotherIssuer(t *testing.T) {
+	idp := newStubIdP(t)
+	oidc := newOIDC(idp)
+	oidc.Issuer = idp.URL + "/tenant"
+
+	_, err := oidc.AuthCodeURL(context.Background(), OIDCLogin{})
+
+	assert.Error(t, err)
+}
+
+func TestOIDCName(t *testing.T) {
+	oidc := &OIDC{}
+
+	_, unverifiedErr := oidc.Name(IDToken{Claims: map[string]interface{}{"email": "peem@example.com"}})
+	oidc.NameClaim = "preferred_username"
+	name, err := oidc.Name(IDToken{Claims: map[string]interface{}{"preferred_username": " peem "}})
+	_, missingErr := oidc.Name(IDToken{Claims: map[string]interface{}{}})
+
+	assert.Error(t, unverifiedErr)
+	assert.NoError(t, err)
+	assert.Equal(t, "peem", name)
+	assert.Error(t, missingErr)
+}
+
+func TestSealLogin(t *testing.T) {
+	// Arrange
+	tokens := newTokens(SigningKey{ID: "hmac", Algorithm: HS256, Secret: testSecret})
+	login := OIDCLogin{State: "state", Nonce: "nonce", Verifier: "verifier", ExpiresAt: testTime.Add(loginTTL).Unix()}
+	accessToken, _, _ := tokens.Issue(User{ID: 5})
+
+	// Act
+	sealed, err := tokens.SealLogin(login)
+	opened, openErr := tokens.OpenLogin(sealed, "state")
+	_, stateErr := tokens.OpenLogin(sealed, "another state")
+	_, accessTokenErr := tokens.OpenLogin(accessToken, "")
+	_, loginAsTokenErr := tokens.Verify(sealed)
+
+	tokens.Now = func() time.Time { return testTime.Add(loginTTL) }
+	_, expiredErr := tokens.OpenLogin(sealed, "state")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, openErr)
+	assert.Equal(t, login, opened)
+	assert.Equal(t, ErrInvalidLogin, stateErr)
+	assert.Equal(t, ErrInvalidLogin, accessTokenErr)
+	assert.Equal(t, ErrInvalidToken, loginAsTokenErr)
+	assert.Equal(t, ErrInvalidLogin, expiredErr)
+}
+
+func TestProvisionUser(t *testing.T) {
+	// Arrange
+	users := NewMemoryUserStore()
+	ctx := context.Background()
+	users.CreateUser(ctx, &User{Name: "somchai@example.com"}, "")
+	users.CreateUser(ctx, &User{Name: "admin@example.com"}, "hash")
+	users.CreateUser(ctx, &User{Name: "linked@example.com"}, "")
+	users.LinkIdentity(ctx, 3, "https://other-idp", "9")
+	users.CreateUser(ctx, &User{Name: "unverified"}, "")
+
+	// Act
+	first, firstErr := ProvisionUser(ctx, users, "https://idp", "1", "peem@example.com", true)
+	again, againErr := ProvisionUser(ctx, users, "https://idp", "1", "renamed@example.com", true)
+	existing, existingErr := ProvisionUser(ctx, users, "https://idp", "2", "somchai@example.com", true)
+	_, passwordErr := ProvisionUser(ctx, users, "https://idp", "3", "admin@example.com", true)
+	_, linkedErr := ProvisionUser(ctx, users, "https://idp", "4", "linked@example.com", true)
+	_, unverifiedErr := ProvisionUser(ctx, users, "https://idp", "5", "unverified", false)
+	list, _ := users.ListUsers(ctx)
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.Equal(t, 5, first.ID)
+	assert.Equal(t, "peem@example.com", first.Name)
+	assert.NoError(t, againErr)
+	assert.Equal(t, first, again)
+	assert.NoError(t, existingErr)
+	assert.Equal(t, 1, existing.ID)
+	assert.Equal(t, ErrAccountNotLinked, passwordErr)
+	assert.Equal(t, ErrAccountNotLinked, linkedErr)
+	assert.Equal(t, ErrAccountNotLinked, unverifiedErr)
+	assert.Len(t, list, 5)
+}
+
+func TestLinkAccount(t *testing.T) {
+	// Arrange
+	users := NewMemoryUserStore()
+	ctx := context.Background()
+	users.CreateUser(ctx, &User{Name: "peem"}, "hash")
+	users.CreateUser(ctx, &User{Name: "somchai"}, "hash")
+
+	// Act
+	linked, linkErr := LinkAccount(ctx, users, 1, "https://idp", "1")
+	again, againErr := LinkAccount(ctx, users, 1, "https://idp", "1")
+	_, otherErr := LinkAccount(ctx, users, 2, "https://idp", "1")
+	provisioned, provisionErr := ProvisionUser(ctx, users, "https://idp", "1", "peem", true)
+
+	// Assert
+	assert.NoError(t, linkErr)
+	assert.Equal(t, 1, linked.ID)
+	assert.NoError(t, againErr)
+	assert.Equal(t, linked, again)
+	assert.Equal(t, ErrAccountLinked, otherErr)
+	assert.NoError(t, provisionErr)
+	assert.Equal(t, linked, provisioned)
+}
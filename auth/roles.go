@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Role is the role of a user, which grants the user permissions.
+type Role string
+
+// The roles of users. A user is a Member unless given another role.
+const (
+	Admin    Role = "admin"
+	Approver Role = "approver"
+	Member   Role = "member"
+	Auditor  Role = "auditor"
+)
+
+// Permission is what a route needs the role of the user of a request to grant.
+type Permission string
+
+// The permissions of routes. Shared resources are the budgets, alert rules,
+// alerts, exchange rates, import profiles and the colors and descriptions
+// of tags, which the members of a workspace share.
+const (
+	// ReadExpenses reads the expenses of the user.
+	ReadExpenses Permission = "expenses:read"
+
+	// WriteExpenses creates, changes and deletes the expenses of the user.
+	WriteExpenses Permission = "expenses:write"
+
+	// ReadAllExpenses makes ReadExpenses read the expenses of every user.
+	ReadAllExpenses Permission = "expenses:read-all"
+
+	// WriteAllExpenses makes WriteExpenses change the expenses of every user.
+	WriteAllExpenses Permission = "expenses:write-all"
+
+	// ReadShared reads the shared resources.
+	ReadShared Permission = "shared:read"
+
+	// WriteShared creates, changes and deletes the shared resources.
+	WriteShared Permission = "shared:write"
+
+	// AcknowledgeAlerts acknowledges the alerts of budgets.
+	AcknowledgeAlerts Permission = "alerts:acknowledge"
+
+	// ManageUsers creates users and assigns their roles.
+	ManageUsers Permission = "users:manage"
+
+	// ManageMembers lists the users of the workspace, issues their API keys,
+	// invites users to the workspace, assigns the roles of its members and removes them.
+	ManageMembers Permission = "members:manage"
+)
+
+// ErrUnknownRole is returned by ParseRole for a name which is not a role.
+var ErrUnknownRole = errors.New("unknown role, use admin, approver, member or auditor")
+
+// RolePermissions are the permissions of each role. Auditors only read,
+// approvers read every expense and manage the shared budgets and their alerts,
+// and only admins change the expenses of others and manage users and members.
+var RolePermissions = map[Role][]Permission{
+	Admin: {ReadExpenses, WriteExpenses, ReadAllExpenses, WriteAllExpenses,
+		ReadShared, WriteShared, AcknowledgeAlerts, ManageUsers, ManageMembers},
+	Approver: {ReadExpenses, WriteExpenses, ReadAllExpenses, ReadShared, WriteShared, AcknowledgeAlerts},
+	Member:   {ReadExpenses, WriteExpenses, ReadShared},
+	Auditor:  {ReadExpenses, ReadAllExpenses, ReadShared},
+}
+
+// ParseRole returns the role of a name.
+func ParseRole(name string) (Role, error) {
+	role := Role(name)
+	if _, ok := RolePermissions[role]; !ok {
+		return "", ErrUnknownRole
+	}
+	return role, nil
+}
+
+// Can reports whether the role grants permission.
+func (role Role) Can(permission Permission) bool {
+	for _, granted := range RolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Can reports whether the identity has permission. The bootstrap key can
+// only manage users, and its handlers only let it create the first user.
+// Managing users needs the role of the user, and the other permissions
+// need its role in the workspace of the request.
+func (identity Identity) Can(permission Permission) bool {
+	switch {
+	case identity.Bootstrap():
+		return permission == ManageUsers
+	case permission == ManageUsers:
+		return identity.Role.Can(permission)
+	default:
+		return identity.WorkspaceID != 0 && identity.WorkspaceRole.Can(permission)
+	}
+}
+
+// allUsersKey is the key of the scope of every user in a context.Context.
+type allUsersKey struct{}
+
+// WithAllUsers returns a copy of ctx in which stores see the expenses of
+// every user of the workspace rather than only those of UserID.
+// New expenses still belong to the user of UserID.
+func WithAllUsers(ctx context.Context) context.Context {
+	return context.WithValue(ctx, allUsersKey{}, true)
+}
+
+// AllUsers reports whether WithAllUsers made ctx see the expenses of every user.
+func AllUsers(ctx context.Context) bool {
+	all, _ := ctx.Value(allUsersKey{}).(bool)
+	return all
+}
+
+// Require returns the middleware of a route needing permission, which
+// responds 403 Forbidden to requests whose identity lacks it or has no
+// workspace. It declares the permission of the route where the route is
+// registered:
+//
+//	e.GET("/expenses/:id", handler.GetExpenseByID, auth.Require(auth.ReadExpenses))
+//
+// A route reading expenses sees the expenses of every user when the role
+// also has ReadAllExpenses, and a route writing them with WriteAllExpenses.
+func Require(permission Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			identity, ok := IdentityOf(c)
+			if ok && !identity.Bootstrap() && permission != ManageUsers && identity.WorkspaceID == 0 {
+				return echo.NewHTTPError(http.StatusForbidden, "the user of the request has no workspace.")
+			}
+			if !ok || !identity.Can(permission) {
+				return echo.NewHTTPError(http.StatusForbidden,
+					"the role of the user has no "+string(permission)+" permission.")
+			}
+
+			if (permission == ReadExpenses && identity.Can(ReadAllExpenses)) ||
+				(permission == WriteExpenses && identity.Can(WriteAllExpenses)) {
+				c.SetRequest(c.Request().WithContext(WithAllUsers(c.Request().Context())))
+			}
+
+			return next(c)
+		}
+	}
+}
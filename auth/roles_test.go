@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRole(t *testing.T) {
+	role, err := ParseRole("auditor")
+	_, unknownErr := ParseRole("owner")
+
+	assert.NoError(t, err)
+	assert.Equal(t, Auditor, role)
+	assert.Equal(t, ErrUnknownRole, unknownErr)
+}
+
+func TestRoleCan(t *testing.T) {
+	assert.True(t, Admin.Can(WriteAllExpenses))
+	assert.True(t, Approver.Can(ReadAllExpenses))
+	assert.False(t, Approver.Can(WriteAllExpenses))
+	assert.True(t, Member.Can(WriteExpenses))
+	assert.False(t, Member.Can(WriteShared))
+	assert.True(t, Auditor.Can(ReadAllExpenses))
+	assert.False(t, Auditor.Can(WriteExpenses))
+	assert.False(t, Role("").Can(ReadExpenses))
+
+	bootstrap := Identity{Subject: "bootstrap"}
+	assert.True(t, bootstrap.Can(ManageUsers))
+	assert.False(t, bootstrap.Can(ReadExpenses))
+}
+
+// requireRequest runs a request of identity through Require(permission)
+// and returns its status and whether the handler saw every user.
+func requireRequest(identity *Identity, permission Permission) (int, bool) {
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/expenses", nil), httptest.NewRecorder())
+	if identity != nil {
+		SetIdentity(c, *identity)
+	}
+
+	var all bool
+	err := Require(permission)(func(c echo.Context) error {
+		all = AllUsers(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})(c)
+
+	if httpErr, ok := err.(*echo.HTTPError); ok {
+		return httpErr.Code, all
+	}
+	return c.Response().Status, all
+}
+
+func TestRequire(t *testing.T) {
+	for _, test := range []struct {
+		role       Role
+		permission Permission
+		status     int
+		all        bool
+	}{
+		{Member, ReadExpenses, http.StatusOK, false},
+		{Member, WriteExpenses, http.StatusOK, false},
+		{Member, WriteShared, http.StatusForbidden, false},
+		{Member, ManageUsers, http.StatusForbidden, false},
+		{Auditor, ReadExpenses, http.StatusOK, true},
+		{Auditor, WriteExpenses, http.StatusForbidden, false},
+		{Approver, ReadExpenses, http.StatusOK, true},
+		{Approver, WriteExpenses, http.StatusOK, false},
+		{Approver, AcknowledgeAlerts, http.StatusOK, false},
+		{Admin, WriteExpenses, http.StatusOK, true},
+		{Admin, ManageUsers, http.StatusOK, false},
+	} {
+		identity := Identity{Subject: "user:1", UserID: 1, Role: test.role, WorkspaceID: 1, WorkspaceRole: test.role}
+		status, all := requireRequest(&identity, test.permission)
+
+		assert.Equal(t, test.status, status, test.role, test.permission)
+		assert.Equal(t, test.all, all, test.role, test.permission)
+	}
+
+	status, _ := requireRequest(nil, ReadExpenses)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, _ = requireRequest(&Identity{Subject: "bootstrap"}, ManageUsers)
+	assert.Equal(t, http.StatusOK, status)
+
+	// Without a workspace, only the role of the user counts.
+	status, _ = requireRequest(&Identity{Subject: "user:1", UserID: 1, Role: Admin}, ReadExpenses)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = requireRequest(&Identity{Subject: "user:1", UserID: 1, Role: Admin}, ManageUsers)
+	assert.Equal(t, http.StatusOK, status)
+
+	// The role in the workspace does not manage users.
+	status, _ = requireRequest(&Identity{Subject: "user:1", UserID: 1, Role: Member, WorkspaceID: 1, WorkspaceRole: Admin}, ManageUsers)
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = requireRequest(&Identity{Subject: "user:1", UserID: 1, Role: Member, WorkspaceID: 1, WorkspaceRole: Admin}, ManageMembers)
+	assert.Equal(t, http.StatusOK, status)
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrUserNotFound is returned by a UserStore when there is no such user.
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrUserExists is returned by a UserStore when a user of the same name exists.
+	ErrUserExists = errors.New("a user of that name exists")
+
+	// ErrWrongPassword is returned by CheckPassword when the password does not match.
+	ErrWrongPassword = errors.New("wrong name or password")
+)
+
+// MinPasswordLength is the minimum number of bytes of a password.
+const MinPasswordLength = 8
+
+// dummyHash is a bcrypt hash of the default cost which CheckPassword compares
+// against when there is no hash, so that unknown users take as long as others.
+const dummyHash = "$2a$10$TklnIX8ie6hRaUo/LBXzSeAbl6CtqIMHvpuwOaBdkniZQUmQNsuqe"
+
+// userKey is the key of the ID of the user in a context.Context.
+type userKey struct{}
+
+type (
+
+	// User owns expenses and API keys, and has a role granting permissions.
+	// BaseCurrency is the ISO 4217 code amounts are converted to for the user,
+	// empty for the default of the server.
+	User struct {
+		ID           int       `json:"id"`
+		Name         string    `json:"name"`
+		Role         Role      `json:"role"`
+		BaseCurrency string    `json:"base_currency,omitempty"`
+		CreatedAt    time.Time `json:"created_at"`
+	}
+
+	// UserStore stores users.
+	UserStore interface {
+		// CreateUser inserts a user with the hash of its password and sets its ID and CreatedAt.
+		// A user without a password hash cannot log in, and a user without a role is a Member.
+		CreateUser(ctx context.Context, user *User, passwordHash string) error
+
+		// SetPassword replaces the password hash of the user of the given ID.
+		SetPassword(ctx context.Context, id int, passwordHash string) error
+
+		// SetRole replaces the role of the user of the given ID and returns the user.
+		SetRole(ctx context.Context, id int, role Role) (User, error)
+
+		// SetBaseCurrency replaces the base currency of the user of the given ID
+		// and returns the user. An empty currency is the default of the server.
+		SetBaseCurrency(ctx context.Context, id int, currency string) (User, error)
+
+		// UserByName returns the user of the given name and its password hash.
+		UserByName(ctx context.Context, name string) (User, string, error)
+
+		// UserByIdentity returns the user linked to the account of subject
+		// at the identity provider of issuer.
+		UserByIdentity(ctx context.Context, issuer string, subject string) (User, error)
+
+		// LinkIdentity links the account of subject at the identity provider
+		// of issuer to the user of the given ID. Linking a linked account does nothing.
+		LinkIdentity(ctx context.Context, userID int, issuer string, subject string) error
+
+		// HasIdentity reports whether an account is linked to the user of the given ID.
+		HasIdentity(ctx context.Context, userID int) (bool, error)
+
+		// ListUsers returns every user ordered by ID.
+		ListUsers(ctx context.Context) ([]User, error)
+
+		// GetUser returns the user of the given ID.
+		GetUser(ctx context.Context, id int) (User, error)
+	}
+)
+
+// WithUser returns a copy of ctx carrying the ID of the user of a request.
+func WithUser(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// UserID returns the ID of the user WithUser put in ctx, or 0 when there is none.
+// Stores scope their queries to the rows of this user.
+func UserID(ctx context.Context) int {
+	id, _ := ctx.Value(userKey{}).(int)
+	return id
+}
+
+// HashPassword returns the bcrypt hash of a password.
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("a password has at least %d characters", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CheckPassword returns ErrWrongPassword unless password has the hash.
+// An empty hash, of an unknown user or one without a password, never matches.
+func CheckPassword(hash string, password string) error {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+		return ErrWrongPassword
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
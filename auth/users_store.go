@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// PostgresUserStore is a UserStore backed by the users table.
+type PostgresUserStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresUserStore returns a PostgresUserStore using db.
+func NewPostgresUserStore(db *sql.DB) *PostgresUserStore {
+	return &PostgresUserStore{DB: db}
+}
+
+// userColumns are the columns scanUser reads.
+const userColumns = "users.id, users.name, users.role, COALESCE(users.base_currency, ''), users.created_at"
+
+func scanUser(row scanner) (User, error) {
+
+	var user User
+
+	err := row.Scan(&user.ID, &user.Name, &user.Role, &user.BaseCurrency, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return user, ErrUserNotFound
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+
+	return user, err
+}
+
+func (store *PostgresUserStore) CreateUser(ctx context.Context, user *User, passwordHash string) error {
+
+	if user.Role == "" {
+		user.Role = Member
+	}
+
+	err := store.DB.QueryRowContext(ctx, `
+		INSERT INTO users (name, role, password_hash) VALUES ($1, $2, NULLIF($3, ''))
+		ON CONFLICT (name) DO NOTHING
+		RETURNING id, created_at
+	`, user.Name, user.Role, passwordHash).Scan(&user.ID, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrUserExists
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+
+	return err
+}
+
+func (store *PostgresUserStore) ListUsers(ctx context.Context) ([]User, error) {
+
+	rows, err := store.DB.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (store *PostgresUserStore) GetUser(ctx context.Context, id int) (User, error) {
+	return scanUser(store.DB.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+}
+
+func (store *PostgresUserStore) SetPassword(ctx context.Context, id int, passwordHash string) error {
+
+	result, err := store.DB.ExecContext(ctx,
+		"UPDATE users SET password_hash = NULLIF($2, '') WHERE id = $1", id, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (store *PostgresUserStore) SetRole(ctx context.Context, id int, role Role) (User, error) {
+	return scanUser(store.DB.QueryRowContext(ctx,
+		"UPDATE users SET role = $2 WHERE id = $1 RETURNING "+userColumns, id, role))
+}
+
+func (store *PostgresUserStore) SetBaseCurrency(ctx context.Context, id int, currency string) (User, error) {
+	return scanUser(store.DB.QueryRowContext(ctx,
+		"UPDATE users SET base_currency = NULLIF($2, '') WHERE id = $1 RETURNING "+userColumns, id, currency))
+}
+
+func (store *PostgresUserStore) UserByName(ctx context.Context, name string) (User, string, error) {
+
+	var user User
+	var hash sql.NullString
+
+	err := store.DB.QueryRowContext(ctx,
+		"SELECT "+userColumns+", password_hash FROM users WHERE name = $1", name).
+		Scan(&user.ID, &user.Name, &user.Role, &user.BaseCurrency, &user.CreatedAt, &hash)
+	if err == sql.ErrNoRows {
+		return User{}, "", ErrUserNotFound
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+
+	return user, hash.String, err
+}
+
+func (store *PostgresUserStore) UserByIdentity(ctx context.Context, issuer string, subject string) (User, error) {
+	return scanUser(store.DB.QueryRowContext(ctx, `
+		SELECT `+userColumns+`
+		FROM user_identities
+		JOIN users ON users.id = user_identities.user_id
+		WHERE user_identities.issuer = $1 AND user_identities.subject = $2
+	`, issuer, subject))
+}
+
+func (store *PostgresUserStore) LinkIdentity(ctx context.Context, userID int, issuer string, subject string) error {
+
+	_, err := store.DB.ExecContext(ctx, `
+		INSERT INTO user_identities (issuer, subject, user_id) VALUES ($1, $2, $3)
+		ON CONFLICT (issuer, subject) DO NOTHING
+	`, issuer, subject, userID)
+
+	return err
+}
+
+func (store *PostgresUserStore) HasIdentity(ctx context.Context, userID int) (bool, error) {
+
+	var linked bool
+	err := store.DB.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM user_identities WHERE user_id = $1)", userID).Scan(&linked)
+
+	return linked, err
+}
+
+// account is the account of a user at an identity provider.
+type account struct {
+	issuer  string
+	subject string
+}
+
+// MemoryUserStore is a thread-safe UserStore keeping users in a slice.
+type MemoryUserStore struct {
+	mu         sync.RWMutex
+	users      []User
+	hashes     []string
+	identities map[account]int
+
+	// Now returns the time users are created.
+	Now func() time.Time
+}
+
+// NewMemoryUserStore returns an empty MemoryUserStore.
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{identities: map[account]int{}, Now: time.Now}
+}
+
+func (store *MemoryUserStore) CreateUser(ctx context.Context, user *User, passwordHash string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, existing := range store.users {
+		if existing.Name == user.Name {
+			return ErrUserExists
+		}
+	}
+
+	if user.Role == "" {
+		user.Role = Member
+	}
+	user.ID = len(store.users) + 1
+	user.CreatedAt = store.Now().UTC().Truncate(time.Microsecond)
+	store.users = append(store.users, *user)
+	store.hashes = append(store.hashes, passwordHash)
+
+	return nil
+}
+
+func (store *MemoryUserStore) ListUsers(ctx context.Context) ([]User, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return append([]User(nil), store.users...), nil
+}
+
+func (store *MemoryUserStore) GetUser(ctx context.Context, id int) (User, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if id < 1 || id > len(store.users) {
+		return User{}, ErrUserNotFound
+	}
+
+	return store.users[id-1], nil
+}
+
+func (store *MemoryUserStore) SetPassword(ctx context.Context, id int, passwordHash string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if id < 1 || id > len(store.users) {
+		return ErrUserNotFound
+	}
+	store.hashes[id-1] = passwordHash
+
+	return nil
+}
+
+func (store *MemoryUserStore) SetRole(ctx context.Context, id int, role Role) (User, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if id < 1 || id > len(store.users) {
+		return User{}, ErrUserNotFound
+	}
+	store.users[id-1].Role = role
+
+	return store.users[id-1], nil
+}
+
+func (store *MemoryUserStore) SetBaseCurrency(ctx context.Context, id int, currency string) (User, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if id < 1 || id > len(store.users) {
+		return User{}, ErrUserNotFound
+	}
+	store.users[id-1].BaseCurrency = currency
+
+	return store.users[id-1], nil
+}
+
+func (store *MemoryUserStore) UserByName(ctx context.Context, name string) (User, string, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for i, user := range store.users {
+		if user.Name == name {
+			return user, store.hashes[i], nil
+		}
+	}
+
+	return User{}, "", ErrUserNotFound
+}
+
+func (store *MemoryUserStore) UserByIdentity(ctx context.Context, issuer string, subject string) (User, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	id, ok := store.identities[account{issuer, subject}]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+
+	return store.users[id-1], nil
+}
+
+func (store *MemoryUserStore) LinkIdentity(ctx context.Context, userID int, issuer string, subject string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if userID < 1 || userID > len(store.users) {
+		return ErrUserNotFound
+	}
+	if _, ok := store.identities[account{issuer, subject}]; !ok {
+		store.identities[account{issuer, subject}] = userID
+	}
+
+	return nil
+}
+
+func (store *MemoryUserStore) HasIdentity(ctx context.Context, userID int) (bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for _, id := range store.identities {
+		if id == userID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestWithUser(t *testing.T) {
+	ctx := context.Background()
+
+	assert.Equal(t, 0, UserID(ctx))
+	assert.Equal(t, 3, UserID(WithUser(ctx, 3)))
+}
+
+func TestMemoryUserStore(t *testing.T) {
+	// Arrange
+	store := NewMemoryUserStore()
+	store.Now = func() time.Time { return testTime }
+	ctx := context.Background()
+
+	user := User{Name: "peem"}
+
+	// Act
+	err := store.CreateUser(ctx, &user, "")
+	existsErr := store.CreateUser(ctx, &User{Name: "peem"}, "")
+	found, foundErr := store.GetUser(ctx, 1)
+	_, missingErr := store.GetUser(ctx, 2)
+	users, _ := store.ListUsers(ctx)
+	passwordErr := store.SetPassword(ctx, 1, "hash")
+	byName, hash, byNameErr := store.UserByName(ctx, "peem")
+	_, _, unknownErr := store.UserByName(ctx, "nobody")
+	approver, roleErr := store.SetRole(ctx, 1, Approver)
+	based, baseErr := store.SetBaseCurrency(ctx, 1, "USD")
+	_, missingBaseErr := store.SetBaseCurrency(ctx, 2, "USD")
+	_, missingRoleErr := store.SetRole(ctx, 2, Approver)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, User{ID: 1, Name: "peem", Role: Member, CreatedAt: testTime}, user)
+	assert.Equal(t, ErrUserExists, existsErr)
+	assert.NoError(t, foundErr)
+	assert.Equal(t, user, found)
+	assert.Equal(t, ErrUserNotFound, missingErr)
+	assert.Equal(t, []User{user}, users)
+	assert.NoError(t, passwordErr)
+	assert.NoError(t, byNameErr)
+	assert.Equal(t, user, byName)
+	assert.Equal(t, "hash", hash)
+	assert.Equal(t, ErrUserNotFound, unknownErr)
+	assert.NoError(t, roleErr)
+	assert.Equal(t, Approver, approver.Role)
+	assert.Equal(t, ErrUserNotFound, missingRoleErr)
+	assert.NoError(t, baseErr)
+	assert.Equal(t, "USD", based.BaseCurrency)
+	assert.Equal(t, ErrUserNotFound, missingBaseErr)
+}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+
+	assert.NoError(t, err)
+	assert.NoError(t, CheckPassword(hash, "correct horse"))
+	assert.Equal(t, ErrWrongPassword, CheckPassword(hash, "wrong horse"))
+	assert.Equal(t, ErrWrongPassword, CheckPassword("", ""))
+	assert.Equal(t, ErrWrongPassword, CheckPassword("", "not the password of anyone"))
+
+	// Unknown users cost as much as a hash of HashPassword.
+	cost, err := bcrypt.Cost([]byte(dummyHash))
+	assert.NoError(t, err)
+	assert.Equal(t, bcrypt.DefaultCost, cost)
+
+	_, err = HashPassword("short")
+	assert.Error(t, err)
+}
+
+func TestPostgresUserStoreCreateUser(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectQuery("INSERT INTO users \\(name, role, password_hash\\) VALUES (.+) ON CONFLICT \\(name\\) DO NOTHING").
+		WithArgs("peem", Member, "hash").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(4, testTime))
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("peem", Member, "").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}))
+
+	store := NewPostgresUserStore(db)
+
+	// Act
+	user := User{Name: "peem"}
+	err = store.CreateUser(context.Background(), &user, "hash")
+	existsErr := store.CreateUser(context.Background(), &User{Name: "peem"}, "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, User{ID: 4, Name: "peem", Role: Member, CreatedAt: testTime}, user)
+	assert.Equal(t, ErrUserExists, existsErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresUserStoreIdentities(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM user_identities JOIN users (.+) WHERE user_identities.issuer = \\$1 AND user_identities.subject = \\$2").
+		WithArgs("https://idp", "1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "base_currency", "created_at"}))
+	mock.ExpectExec("INSERT INTO user_identities \\(issuer, subject, user_id\\) VALUES (.+) ON CONFLICT \\(issuer, subject\\) DO NOTHING").
+		WithArgs("https://idp", "1", 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM user_identities WHERE user_id = \\$1\\)").
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	store := NewPostgresUserStore(db)
+
+	// Act
+	_, notFoundErr := store.UserByIdentity(context.Background(), "https://idp", "1")
+	linkErr := store.LinkIdentity(context.Background(), 4, "https://idp", "1")
+	linked, linkedErr := store.HasIdentity(context.Background(), 4)
+
+	// Assert
+	assert.Equal(t, ErrUserNotFound, notFoundErr)
+	assert.NoError(t, linkErr)
+	assert.NoError(t, linkedErr)
+	assert.True(t, linked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
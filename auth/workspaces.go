@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// InvitationPrefix starts every invitation token, like KeyPrefix starts API keys.
+const InvitationPrefix = "inv_"
+
+// WorkspaceHeader selects the workspace of a request by its ID.
+// Requests without it are in the first workspace the user joined.
+const WorkspaceHeader = "X-Workspace"
+
+var (
+	// ErrNotMember is returned by a WorkspaceStore when the user is not a member of the workspace.
+	ErrNotMember = errors.New("the user is not a member of the workspace")
+
+	// ErrAlreadyMember is returned by AcceptInvitation when the user is a member of the workspace.
+	ErrAlreadyMember = errors.New("the user is already a member of the workspace")
+
+	// ErrLastAdmin is returned by a WorkspaceStore when a change would leave a workspace without an admin.
+	ErrLastAdmin = errors.New("a workspace needs an admin")
+
+	// ErrInvitationNotFound is returned by a WorkspaceStore when there is no such
+	// invitation, or it is accepted or expired.
+	ErrInvitationNotFound = errors.New("invitation not found, accepted or expired")
+)
+
+// workspaceKey is the key of the ID of the workspace in a context.Context.
+type workspaceKey struct{}
+
+type (
+
+	// Workspace isolates the expenses, tags, budgets, alerts, exchange rates
+	// and import profiles of a team from those of the other workspaces.
+	// Role is the role in the workspace of the user listing it.
+	Workspace struct {
+		ID        int       `json:"id"`
+		Name      string    `json:"name"`
+		Role      Role      `json:"role,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+
+	// Membership is a user of a workspace, whose role in the workspace
+	// grants the permissions of its requests there.
+	Membership struct {
+		WorkspaceID int       `json:"workspace_id"`
+		UserID      int       `json:"user_id"`
+		Role        Role      `json:"role"`
+		JoinedAt    time.Time `json:"joined_at"`
+	}
+
+	// Invitation lets the user accepting it join a workspace with a role.
+	// Token is the secret itself, which is only set in the response creating it.
+	Invitation struct {
+		ID          int        `json:"id"`
+		WorkspaceID int        `json:"workspace_id"`
+		Role        Role       `json:"role"`
+		Token       string     `json:"token,omitempty"`
+		InvitedBy   int        `json:"invited_by"`
+		CreatedAt   time.Time  `json:"created_at"`
+		ExpiresAt   time.Time  `json:"expires_at"`
+		AcceptedBy  int        `json:"accepted_by,omitempty"`
+		AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+	}
+
+	// WorkspaceStore stores workspaces, their members and invitations.
+	// Invitations are stored by the hash of their token, like API keys.
+	WorkspaceStore interface {
+		// CreateWorkspace inserts a workspace of which the user of userID is the admin
+		// and sets its ID, Role and CreatedAt.
+		CreateWorkspace(ctx context.Context, workspace *Workspace, userID int) error
+
+		// ListWorkspaces returns the workspaces of the user of userID in the order they were joined.
+		ListWorkspaces(ctx context.Context, userID int) ([]Workspace, error)
+
+		// GetMember returns the membership of the user of userID in a workspace.
+		GetMember(ctx context.Context, workspaceID int, userID int) (Membership, error)
+
+		// FirstMember returns the membership of the user of userID
+		// in the first workspace the user joined.
+		FirstMember(ctx context.Context, userID int) (Membership, error)
+
+		// ListMembers returns the members of a workspace ordered by user ID.
+		ListMembers(ctx context.Context, workspaceID int) ([]Membership, error)
+
+		// SetMemberRole replaces the role of a member and returns the member.
+		SetMemberRole(ctx context.Context, workspaceID int, userID int, role Role) (Membership, error)
+
+		// RemoveMember removes a member from a workspace.
+		RemoveMember(ctx context.Context, workspaceID int, userID int) error
+
+		// CreateInvitation inserts an invitation with the hash of its token and sets its ID and CreatedAt.
+		CreateInvitation(ctx context.Context, invitation *Invitation, hash string) error
+
+		// ListInvitations returns the invitations of a workspace, accepted ones included, ordered by ID.
+		ListInvitations(ctx context.Context, workspaceID int) ([]Invitation, error)
+
+		// RevokeInvitation deletes an invitation of a workspace which is not accepted.
+		RevokeInvitation(ctx context.Context, workspaceID int, id int) error
+
+		// AcceptInvitation makes the user of userID a member of the workspace of the
+		// invitation of the hash of a token, unless it is accepted or expired at now.
+		AcceptInvitation(ctx context.Context, hash string, userID int, now time.Time) (Membership, error)
+	}
+)
+
+// WithWorkspace returns a copy of ctx carrying the ID of the workspace of a request.
+func WithWorkspace(ctx context.Context, workspaceID int) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, workspaceID)
+}
+
+// WorkspaceID returns the ID of the workspace WithWorkspace put in ctx, or 0 when there is none.
+// Stores scope every query to the rows of this workspace.
+func WorkspaceID(ctx context.Context) int {
+	id, _ := ctx.Value(workspaceKey{}).(int)
+	return id
+}
+
+// NewInvitationToken returns a random invitation token and its hash.
+func NewInvitationToken() (token string, hash string, err error) {
+	token, err = newSecret(InvitationPrefix)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashKey(token), nil
+}
+
+// Expired reports whether the invitation is expired at now.
+func (invitation Invitation) Expired(now time.Time) bool {
+	return !now.Before(invitation.ExpiresAt)
+}
@@ -0,0 +1,566 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PostgresWorkspaceStore is a WorkspaceStore backed by the workspaces,
+// workspace_members and workspace_invitations tables.
+type PostgresWorkspaceStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresWorkspaceStore returns a PostgresWorkspaceStore using db.
+func NewPostgresWorkspaceStore(db *sql.DB) *PostgresWorkspaceStore {
+	return &PostgresWorkspaceStore{DB: db}
+}
+
+const (
+	memberColumns     = "workspace_id, user_id, role, joined_at"
+	invitationColumns = "id, workspace_id, role, invited_by, created_at, expires_at, accepted_by, accepted_at"
+)
+
+func scanMember(row scanner) (Membership, error) {
+
+	var member Membership
+
+	err := row.Scan(&member.WorkspaceID, &member.UserID, &member.Role, &member.JoinedAt)
+	if err == sql.ErrNoRows {
+		return member, ErrNotMember
+	}
+	member.JoinedAt = member.JoinedAt.UTC()
+
+	return member, err
+}
+
+func scanInvitation(row scanner) (Invitation, error) {
+
+	var invitation Invitation
+	var acceptedBy sql.NullInt64
+	var acceptedAt sql.NullTime
+
+	err := row.Scan(&invitation.ID, &invitation.WorkspaceID, &invitation.Role, &invitation.InvitedBy,
+		&invitation.CreatedAt, &invitation.ExpiresAt, &acceptedBy, &acceptedAt)
+	if err == sql.ErrNoRows {
+		return invitation, ErrInvitationNotFound
+	}
+
+	invitation.CreatedAt = invitation.CreatedAt.UTC()
+	invitation.ExpiresAt = invitation.ExpiresAt.UTC()
+	invitation.AcceptedBy = int(acceptedBy.Int64)
+	invitation.AcceptedAt = utc(acceptedAt)
+
+	return invitation, err
+}
+
+// leavesNoAdmin reports whether taking the admin role away from the user of
+// userID leaves the members of roles without an admin.
+func leavesNoAdmin(roles map[int]Role, userID int) bool {
+
+	if roles[userID] != Admin {
+		return false
+	}
+
+	for id, role := range roles {
+		if id != userID && role == Admin {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (store *PostgresWorkspaceStore) CreateWorkspace(ctx context.Context, workspace *Workspace, userID int) error {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO workspaces (name) VALUES ($1) RETURNING id, created_at", workspace.Name).
+		Scan(&workspace.ID, &workspace.CreatedAt)
+	if err != nil {
+		return err
+	}
+	workspace.CreatedAt = workspace.CreatedAt.UTC()
+	workspace.Role = Admin
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)",
+		workspace.ID, userID, Admin)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresWorkspaceStore) ListWorkspaces(ctx context.Context, userID int) ([]Workspace, error) {
+
+	rows, err := store.DB.QueryContext(ctx, `
+		SELECT workspaces.id, workspaces.name, workspace_members.role, workspaces.created_at
+		FROM workspace_members
+		JOIN workspaces ON workspaces.id = workspace_members.workspace_id
+		WHERE workspace_members.user_id = $1
+		ORDER BY workspace_members.joined_at, workspaces.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workspaces []Workspace
+
+	for rows.Next() {
+		var workspace Workspace
+		if err := rows.Scan(&workspace.ID, &workspace.Name, &workspace.Role, &workspace.CreatedAt); err != nil {
+			return nil, err
+		}
+		workspace.CreatedAt = workspace.CreatedAt.UTC()
+		workspaces = append(workspaces, workspace)
+	}
+
+	return workspaces, rows.Err()
+}
+
+func (store *PostgresWorkspaceStore) GetMember(ctx context.Context, workspaceID int, userID int) (Membership, error) {
+	return scanMember(store.DB.QueryRowContext(ctx,
+		"SELECT "+memberColumns+" FROM workspace_members WHERE workspace_id = $1 AND user_id = $2",
+		workspaceID, userID))
+}
+
+func (store *PostgresWorkspaceStore) FirstMember(ctx context.Context, userID int) (Membership, error) {
+	return scanMember(store.DB.QueryRowContext(ctx,
+		"SELECT "+memberColumns+" FROM workspace_members WHERE user_id = $1 ORDER BY joined_at, workspace_id LIMIT 1",
+		userID))
+}
+
+func (store *PostgresWorkspaceStore) ListMembers(ctx context.Context, workspaceID int) ([]Membership, error) {
+
+	rows, err := store.DB.QueryContext(ctx,
+		"SELECT "+memberColumns+" FROM workspace_members WHERE workspace_id = $1 ORDER BY user_id", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []Membership
+
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// lockRoles locks the members of a workspace until tx ends and returns their roles,
+// so concurrent changes cannot both take away the role of the last admins.
+func lockRoles(ctx context.Context, tx *sql.Tx, workspaceID int) (map[int]Role, error) {
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT user_id, role FROM workspace_members WHERE workspace_id = $1 FOR UPDATE", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := map[int]Role{}
+
+	for rows.Next() {
+		var userID int
+		var role Role
+		if err := rows.Scan(&userID, &role); err != nil {
+			return nil, err
+		}
+		roles[userID] = role
+	}
+
+	return roles, rows.Err()
+}
+
+func (store *PostgresWorkspaceStore) SetMemberRole(ctx context.Context, workspaceID int, userID int, role Role) (Membership, error) {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Membership{}, err
+	}
+	defer tx.Rollback()
+
+	roles, err := lockRoles(ctx, tx, workspaceID)
+	if err != nil {
+		return Membership{}, err
+	}
+	if role != Admin && leavesNoAdmin(roles, userID) {
+		return Membership{}, ErrLastAdmin
+	}
+
+	member, err := scanMember(tx.QueryRowContext(ctx, `
+		UPDATE workspace_members SET role = $3 WHERE workspace_id = $1 AND user_id = $2
+		RETURNING `+memberColumns, workspaceID, userID, role))
+	if err != nil {
+		return Membership{}, err
+	}
+
+	return member, tx.Commit()
+}
+
+func (store *PostgresWorkspaceStore) RemoveMember(ctx context.Context, workspaceID int, userID int) error {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	roles, err := lockRoles(ctx, tx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if _, ok := roles[userID]; !ok {
+		return ErrNotMember
+	}
+	if leavesNoAdmin(roles, userID) {
+		return ErrLastAdmin
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2", workspaceID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresWorkspaceStore) CreateInvitation(ctx context.Context, invitation *Invitation, hash string) error {
+
+	err := store.DB.QueryRowContext(ctx, `
+		INSERT INTO workspace_invitations (workspace_id, role, hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, invitation.WorkspaceID, invitation.Role, hash, invitation.InvitedBy, invitation.ExpiresAt).
+		Scan(&invitation.ID, &invitation.CreatedAt)
+	invitation.CreatedAt = invitation.CreatedAt.UTC()
+
+	return err
+}
+
+func (store *PostgresWorkspaceStore) ListInvitations(ctx context.Context, workspaceID int) ([]Invitation, error) {
+
+	rows, err := store.DB.QueryContext(ctx,
+		"SELECT "+invitationColumns+" FROM workspace_invitations WHERE workspace_id = $1 ORDER BY id", workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []Invitation
+
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+func (store *PostgresWorkspaceStore) RevokeInvitation(ctx context.Context, workspaceID int, id int) error {
+
+	result, err := store.DB.ExecContext(ctx,
+		"DELETE FROM workspace_invitations WHERE workspace_id = $1 AND id = $2 AND accepted_at IS NULL",
+		workspaceID, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+func (store *PostgresWorkspaceStore) AcceptInvitation(ctx context.Context, hash string, userID int, now time.Time) (Membership, error) {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Membership{}, err
+	}
+	defer tx.Rollback()
+
+	invitation, err := scanInvitation(tx.QueryRowContext(ctx, `
+		SELECT `+invitationColumns+` FROM workspace_invitations
+		WHERE hash = $1 AND accepted_at IS NULL AND expires_at > $2
+		FOR UPDATE
+	`, hash, now))
+	if err != nil {
+		return Membership{}, err
+	}
+
+	member, err := scanMember(tx.QueryRowContext(ctx, `
+		INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+		RETURNING `+memberColumns, invitation.WorkspaceID, userID, invitation.Role))
+	if err == ErrNotMember {
+		return Membership{}, ErrAlreadyMember
+	}
+	if err != nil {
+		return Membership{}, err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE workspace_invitations SET accepted_by = $2, accepted_at = $3 WHERE id = $1",
+		invitation.ID, userID, now)
+	if err != nil {
+		return Membership{}, err
+	}
+
+	return member, tx.Commit()
+}
+
+// MemoryWorkspaceStore is a thread-safe WorkspaceStore keeping workspaces,
+// members and invitations in slices.
+type MemoryWorkspaceStore struct {
+	mu          sync.RWMutex
+	workspaces  []Workspace
+	members     []Membership
+	invitations []Invitation
+	hashes      []string
+
+	// Now returns the time workspaces are created and joined.
+	Now func() time.Time
+}
+
+// NewMemoryWorkspaceStore returns an empty MemoryWorkspaceStore.
+func NewMemoryWorkspaceStore() *MemoryWorkspaceStore {
+	return &MemoryWorkspaceStore{Now: time.Now}
+}
+
+func (store *MemoryWorkspaceStore) now() time.Time {
+	return store.Now().UTC().Truncate(time.Microsecond)
+}
+
+// findMember returns the index of a member in store.members, or -1.
+// The caller must hold store.mu.
+func (store *MemoryWorkspaceStore) findMember(workspaceID int, userID int) int {
+	for i, member := range store.members {
+		if member.WorkspaceID == workspaceID && member.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// roles returns the roles of the members of a workspace. The caller must hold store.mu.
+func (store *MemoryWorkspaceStore) roles(workspaceID int) map[int]Role {
+	roles := map[int]Role{}
+	for _, member := range store.members {
+		if member.WorkspaceID == workspaceID {
+			roles[member.UserID] = member.Role
+		}
+	}
+	return roles
+}
+
+func (store *MemoryWorkspaceStore) CreateWorkspace(ctx context.Context, workspace *Workspace, userID int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	workspace.ID = len(store.workspaces) + 1
+	workspace.CreatedAt = store.now()
+	workspace.Role = Admin
+
+	stored := *workspace
+	stored.Role = ""
+	store.workspaces = append(store.workspaces, stored)
+
+	store.members = append(store.members,
+		Membership{WorkspaceID: workspace.ID, UserID: userID, Role: Admin, JoinedAt: workspace.CreatedAt})
+
+	return nil
+}
+
+func (store *MemoryWorkspaceStore) ListWorkspaces(ctx context.Context, userID int) ([]Workspace, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var workspaces []Workspace
+	for _, member := range store.members {
+		if member.UserID == userID {
+			workspace := store.workspaces[member.WorkspaceID-1]
+			workspace.Role = member.Role
+			workspaces = append(workspaces, workspace)
+		}
+	}
+
+	return workspaces, nil
+}
+
+func (store *MemoryWorkspaceStore) GetMember(ctx context.Context, workspaceID int, userID int) (Membership, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	i := store.findMember(workspaceID, userID)
+	if i < 0 {
+		return Membership{}, ErrNotMember
+	}
+
+	return store.members[i], nil
+}
+
+func (store *MemoryWorkspaceStore) FirstMember(ctx context.Context, userID int) (Membership, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	// Members are appended as they join.
+	for _, member := range store.members {
+		if member.UserID == userID {
+			return member, nil
+		}
+	}
+
+	return Membership{}, ErrNotMember
+}
+
+func (store *MemoryWorkspaceStore) ListMembers(ctx context.Context, workspaceID int) ([]Membership, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var members []Membership
+	for _, member := range store.members {
+		if member.WorkspaceID == workspaceID {
+			members = append(members, member)
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
+
+	return members, nil
+}
+
+func (store *MemoryWorkspaceStore) SetMemberRole(ctx context.Context, workspaceID int, userID int, role Role) (Membership, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.findMember(workspaceID, userID)
+	if i < 0 {
+		return Membership{}, ErrNotMember
+	}
+	if role != Admin && leavesNoAdmin(store.roles(workspaceID), userID) {
+		return Membership{}, ErrLastAdmin
+	}
+
+	store.members[i].Role = role
+
+	return store.members[i], nil
+}
+
+func (store *MemoryWorkspaceStore) RemoveMember(ctx context.Context, workspaceID int, userID int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.findMember(workspaceID, userID)
+	if i < 0 {
+		return ErrNotMember
+	}
+	if leavesNoAdmin(store.roles(workspaceID), userID) {
+		return ErrLastAdmin
+	}
+
+	store.members = append(store.members[:i], store.members[i+1:]...)
+
+	return nil
+}
+
+func (store *MemoryWorkspaceStore) CreateInvitation(ctx context.Context, invitation *Invitation, hash string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	invitation.ID = len(store.invitations) + 1
+	invitation.CreatedAt = store.now()
+
+	stored := *invitation
+	stored.Token = ""
+	store.invitations = append(store.invitations, stored)
+	store.hashes = append(store.hashes, hash)
+
+	return nil
+}
+
+func (store *MemoryWorkspaceStore) ListInvitations(ctx context.Context, workspaceID int) ([]Invitation, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var invitations []Invitation
+	for i, invitation := range store.invitations {
+		if invitation.WorkspaceID == workspaceID && store.hashes[i] != "" {
+			invitations = append(invitations, invitation)
+		}
+	}
+
+	return invitations, nil
+}
+
+func (store *MemoryWorkspaceStore) RevokeInvitation(ctx context.Context, workspaceID int, id int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// A revoked invitation keeps its ID but loses its hash.
+	if id < 1 || id > len(store.invitations) || store.hashes[id-1] == "" {
+		return ErrInvitationNotFound
+	}
+	invitation := store.invitations[id-1]
+	if invitation.WorkspaceID != workspaceID || invitation.AcceptedAt != nil {
+		return ErrInvitationNotFound
+	}
+
+	store.hashes[id-1] = ""
+
+	return nil
+}
+
+func (store *MemoryWorkspaceStore) AcceptInvitation(ctx context.Context, hash string, userID int, now time.Time) (Membership, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for i, invitation := range store.invitations {
+		if hash == "" || store.hashes[i] != hash {
+			continue
+		}
+		if invitation.AcceptedAt != nil || invitation.Expired(now) {
+			break
+		}
+
+		if store.findMember(invitation.WorkspaceID, userID) >= 0 {
+			return Membership{}, ErrAlreadyMember
+		}
+
+		member := Membership{WorkspaceID: invitation.WorkspaceID, UserID: userID, Role: invitation.Role, JoinedAt: store.now()}
+		store.members = append(store.members, member)
+
+		acceptedAt := now.UTC()
+		store.invitations[i].AcceptedBy = userID
+		store.invitations[i].AcceptedAt = &acceptedAt
+
+		return member, nil
+	}
+
+	return Membership{}, ErrInvitationNotFound
+}
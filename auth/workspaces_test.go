@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithWorkspace(t *testing.T) {
+	ctx := context.Background()
+
+	assert.Equal(t, 0, WorkspaceID(ctx))
+	assert.Equal(t, 2, WorkspaceID(WithWorkspace(ctx, 2)))
+}
+
+func TestNewInvitationToken(t *testing.T) {
+	token, hash, err := NewInvitationToken()
+	other, _, _ := NewInvitationToken()
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, InvitationPrefix))
+	assert.Equal(t, HashKey(token), hash)
+	assert.NotEqual(t, token, other)
+}
+
+func TestMemoryWorkspaceStore(t *testing.T) {
+	// Arrange
+	store := NewMemoryWorkspaceStore()
+	store.Now = func() time.Time { return testTime }
+	ctx := context.Background()
+
+	team := Workspace{Name: "team"}
+	contractor := Workspace{Name: "contractor"}
+
+	// Act
+	teamErr := store.CreateWorkspace(ctx, &team, 1)
+	store.CreateWorkspace(ctx, &contractor, 2)
+	first, firstErr := store.FirstMember(ctx, 1)
+	_, strangerErr := store.GetMember(ctx, team.ID, 2)
+
+	invitation := Invitation{WorkspaceID: team.ID, Role: Approver, InvitedBy: 1, ExpiresAt: testTime.Add(time.Hour)}
+	invitationErr := store.CreateInvitation(ctx, &invitation, "hash")
+	joined, acceptErr := store.AcceptInvitation(ctx, "hash", 2, testTime)
+	_, acceptedErr := store.AcceptInvitation(ctx, "hash", 3, testTime)
+	workspaces, _ := store.ListWorkspaces(ctx, 2)
+	members, _ := store.ListMembers(ctx, team.ID)
+	invitations, _ := store.ListInvitations(ctx, team.ID)
+
+	// Assert
+	assert.NoError(t, teamErr)
+	assert.Equal(t, Workspace{ID: 1, Name: "team", Role: Admin, CreatedAt: testTime}, team)
+	assert.NoError(t, firstErr)
+	assert.Equal(t, Membership{WorkspaceID: 1, UserID: 1, Role: Admin, JoinedAt: testTime}, first)
+	assert.Equal(t, ErrNotMember, strangerErr)
+	assert.NoError(t, invitationErr)
+	assert.Equal(t, 1, invitation.ID)
+	assert.NoError(t, acceptErr)
+	assert.Equal(t, Membership{WorkspaceID: 1, UserID: 2, Role: Approver, JoinedAt: testTime}, joined)
+	assert.Equal(t, ErrInvitationNotFound, acceptedErr)
+	assert.Equal(t, []Workspace{
+		{ID: 2, Name: "contractor", Role: Admin, CreatedAt: testTime},
+		{ID: 1, Name: "team", Role: Approver, CreatedAt: testTime},
+	}, workspaces)
+	assert.Equal(t, []Membership{first, joined}, members)
+	assert.Equal(t, 2, invitations[0].AcceptedBy)
+	assert.Equal(t, &testTime, invitations[0].AcceptedAt)
+}
+
+func TestMemoryWorkspaceStoreLastAdmin(t *testing.T) {
+	// Arrange
+	store := NewMemoryWorkspaceStore()
+	ctx := context.Background()
+
+	workspace := Workspace{Name: "team"}
+	store.CreateWorkspace(ctx, &workspace, 1)
+	store.CreateInvitation(ctx, &Invitation{WorkspaceID: 1, Role: Member, ExpiresAt: testTime.Add(time.Hour)}, "hash")
+	store.AcceptInvitation(ctx, "hash", 2, testTime)
+
+	// Act
+	_, demoteErr := store.SetMemberRole(ctx, 1, 1, Member)
+	removeErr := store.RemoveMember(ctx, 1, 1)
+	_, missingErr := store.SetMemberRole(ctx, 1, 3, Member)
+	promoted, promoteErr := store.SetMemberRole(ctx, 1, 2, Admin)
+	_, demoteAgainErr := store.SetMemberRole(ctx, 1, 1, Member)
+	removeMemberErr := store.RemoveMember(ctx, 1, 2)
+
+	// Assert
+	assert.Equal(t, ErrLastAdmin, demoteErr)
+	assert.Equal(t, ErrLastAdmin, removeErr)
+	assert.Equal(t, ErrNotMember, missingErr)
+	assert.NoError(t, promoteErr)
+	assert.Equal(t, Admin, promoted.Role)
+	assert.NoError(t, demoteAgainErr)
+	assert.Equal(t, ErrLastAdmin, removeMemberErr)
+}
+
+func TestMemoryWorkspaceStoreInvitations(t *testing.T) {
+	// Arrange
+	store := NewMemoryWorkspaceStore()
+	ctx := context.Background()
+
+	store.CreateInvitation(ctx, &Invitation{WorkspaceID: 1, Role: Member, ExpiresAt: testTime}, "expired")
+	store.CreateInvitation(ctx, &Invitation{WorkspaceID: 1, Role: Member, ExpiresAt: testTime.Add(time.Hour)}, "revoked")
+
+	// Act
+	_, expiredErr := store.AcceptInvitation(ctx, "expired", 2, testTime)
+	otherWorkspaceErr := store.RevokeInvitation(ctx, 2, 2)
+	revokeErr := store.RevokeInvitation(ctx, 1, 2)
+	_, revokedErr := store.AcceptInvitation(ctx, "revoked", 2, testTime)
+	_, emptyErr := store.AcceptInvitation(ctx, "", 2, testTime)
+	invitations, _ := store.ListInvitations(ctx, 1)
+
+	// Assert
+	assert.Equal(t, ErrInvitationNotFound, expiredErr)
+	assert.Equal(t, ErrInvitationNotFound, otherWorkspaceErr)
+	assert.NoError(t, revokeErr)
+	assert.Equal(t, ErrInvitationNotFound, revokedErr)
+	assert.Equal(t, ErrInvitationNotFound, emptyErr)
+	assert.Len(t, invitations, 1)
+}
+
+func TestPostgresWorkspaceStoreAcceptInvitation(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	invitationColumns := []string{"id", "workspace_id", "role", "invited_by", "created_at", "expires_at", "accepted_by", "accepted_at"}
+	memberColumns := []string{"workspace_id", "user_id", "role", "joined_at"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM workspace_invitations WHERE hash = \\$1 AND accepted_at IS NULL AND expires_at > \\$2 FOR UPDATE").
+		WithArgs("hash", testTime).
+		WillReturnRows(sqlmock.NewRows(invitationColumns).AddRow(4, 2, "approver", 1, testTime, testTime.Add(time.Hour), nil, nil))
+	mock.ExpectQuery("INSERT INTO workspace_members (.+) ON CONFLICT DO NOTHING RETURNING").
+		WithArgs(2, 5, Approver).
+		WillReturnRows(sqlmock.NewRows(memberColumns).AddRow(2, 5, "approver", testTime))
+	mock.ExpectExec("UPDATE workspace_invitations SET accepted_by = \\$2, accepted_at = \\$3 WHERE id = \\$1").
+		WithArgs(4, 5, testTime).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM workspace_invitations").
+		WithArgs("hash", testTime).
+		WillReturnRows(sqlmock.NewRows(invitationColumns).AddRow(4, 2, "approver", 1, testTime, testTime.Add(time.Hour), nil, nil))
+	mock.ExpectQuery("INSERT INTO workspace_members").
+		WithArgs(2, 5, Approver).
+		WillReturnRows(sqlmock.NewRows(memberColumns))
+	mock.ExpectRollback()
+
+	store := NewPostgresWorkspaceStore(db)
+
+	// Act
+	member, err := store.AcceptInvitation(context.Background(), "hash", 5, testTime)
+	_, memberErr := store.AcceptInvitation(context.Background(), "hash", 5, testTime)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, Membership{WorkspaceID: 2, UserID: 5, Role: Approver, JoinedAt: testTime}, member)
+	assert.Equal(t, ErrAlreadyMember, memberErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
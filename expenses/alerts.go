@@ -0,0 +1,471 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// Errors returned by an AlertStore.
+var (
+	ErrAlertRuleNotFound = errors.New("alert rule not found")
+	ErrAlertNotFound     = errors.New("alert not found")
+)
+
+// DefaultThresholds are the thresholds of a rule which does not choose them.
+var DefaultThresholds = []float64{100}
+
+type (
+
+	// AlertRule limits the spending of a tag every week or month.
+	// Each of Thresholds, in percent of Amount, fires an alert once per period.
+	AlertRule struct {
+		ID         int       `json:"id"`
+		Tag        string    `json:"tag"`
+		Period     string    `json:"period"`
+		Amount     Money     `json:"amount"`
+		Currency   string    `json:"currency"`
+		Thresholds []float64 `json:"thresholds"`
+	}
+
+	// Alert tells that the spending of a tag reached a threshold of a rule
+	// in the period starting on PeriodStart. Amount is the limit of the rule
+	// and Spent the spending when the alert fired.
+	Alert struct {
+		ID             int        `json:"id"`
+		RuleID         int        `json:"rule_id"`
+		Tag            string     `json:"tag"`
+		PeriodStart    string     `json:"period_start"`
+		Threshold      float64    `json:"threshold"`
+		Amount         Money      `json:"amount"`
+		Spent          Money      `json:"spent"`
+		Currency       string     `json:"currency"`
+		CreatedAt      time.Time  `json:"created_at"`
+		AcknowledgedAt *time.Time `json:"acknowledged_at,omitempty"`
+	}
+
+	// AlertStore stores alert rules and the alerts they fired.
+	AlertStore interface {
+		// ListAlertRules returns every rule ordered by ID.
+		ListAlertRules(ctx context.Context) ([]AlertRule, error)
+
+		GetAlertRule(ctx context.Context, id int) (AlertRule, error)
+
+		// CreateAlertRule sets the ID of rule.
+		CreateAlertRule(ctx context.Context, rule *AlertRule) error
+
+		UpdateAlertRule(ctx context.Context, rule *AlertRule) error
+
+		// DeleteAlertRule deletes a rule and its alerts.
+		DeleteAlertRule(ctx context.Context, id int) error
+
+		// FireAlert saves alert and sets its ID and CreatedAt unless the threshold
+		// of the rule already fired in the period. It tells whether it was saved.
+		FireAlert(ctx context.Context, alert *Alert) (bool, error)
+
+		// ListAlerts returns the alerts, the latest first,
+		// or only the ones not acknowledged yet.
+		ListAlerts(ctx context.Context, unacknowledged bool) ([]Alert, error)
+
+		// AcknowledgeAlert sets AcknowledgedAt of an alert unless it is already set.
+		AcknowledgeAlert(ctx context.Context, id int) (Alert, error)
+	}
+
+	// AlertEvaluator fires the alerts of the rules of an expense's tags.
+	AlertEvaluator struct {
+		Alerts  AlertStore
+		Reports ReportStore
+	}
+)
+
+// MarshalJSON writes amount with the decimals of currency.
+func (rule AlertRule) MarshalJSON() ([]byte, error) {
+	type plain AlertRule
+	return replaceMembers(plain(rule), map[string]json.RawMessage{"amount": rule.Amount.jsonIn(rule.Currency)})
+}
+
+// UnmarshalJSON reads a rule whose amount has the decimals of its currency.
+func (rule *AlertRule) UnmarshalJSON(data []byte) error {
+
+	type plain AlertRule
+	fields := struct {
+		*plain
+		Amount json.RawMessage `json:"amount"`
+	}{plain: (*plain)(rule)}
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	return rule.Amount.unmarshalIn(fields.Amount, rule.Currency)
+}
+
+// MarshalJSON writes amount and spent with the decimals of currency.
+func (alert Alert) MarshalJSON() ([]byte, error) {
+	type plain Alert
+	return replaceMembers(plain(alert), map[string]json.RawMessage{
+		"amount": alert.Amount.jsonIn(alert.Currency),
+		"spent":  alert.Spent.jsonIn(alert.Currency),
+	})
+}
+
+// UnmarshalJSON reads an alert whose amounts have the decimals of its currency.
+func (alert *Alert) UnmarshalJSON(data []byte) error {
+
+	type plain Alert
+	fields := struct {
+		*plain
+		Amount json.RawMessage `json:"amount"`
+		Spent  json.RawMessage `json:"spent"`
+	}{plain: (*plain)(alert)}
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	if err := alert.Amount.unmarshalIn(fields.Amount, alert.Currency); err != nil {
+		return err
+	}
+	return alert.Spent.unmarshalIn(fields.Spent, alert.Currency)
+}
+
+// normalizeAlertRule checks a rule of a request and fills its defaults.
+// Thresholds are sorted and repeats are dropped.
+func normalizeAlertRule(rule *AlertRule) error {
+
+	if rule.Tag = NormalizeTag(rule.Tag); rule.Tag == "" {
+		return errors.New("tag is required")
+	}
+	if rule.Period == "" {
+		rule.Period = "month"
+	}
+	if !contains(budgetPeriods, rule.Period) {
+		return errors.New("period must be week or month")
+	}
+	if rule.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+
+	var err error
+	if rule.Currency, err = NormalizeCurrency(rule.Currency); err != nil {
+		return err
+	}
+
+	if len(rule.Thresholds) == 0 {
+		rule.Thresholds = DefaultThresholds
+	}
+	var thresholds []float64
+	for _, threshold := range rule.Thresholds {
+		if threshold <= 0 || threshold > 1000 {
+			return fmt.Errorf("invalid threshold %v, use a percent of amount from 0 to 1000", threshold)
+		}
+		if !containsFloat(thresholds, threshold) {
+			thresholds = append(thresholds, threshold)
+		}
+	}
+	sort.Float64s(thresholds)
+	rule.Thresholds = thresholds
+
+	return nil
+}
+
+func containsFloat(values []float64, value float64) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate fires the alerts of the rules of the tags and currency of expense
+// whose thresholds the spending of the period containing expense.SpentAt reached.
+// Periods are in DefaultTimezone. Alert rules are shared, so the spending is
+// that of every member of the workspace. It returns the alerts fired for the first time.
+func (evaluator AlertEvaluator) Evaluate(ctx context.Context, expense Expense) ([]Alert, error) {
+	return evaluator.EvaluateAll(ctx, []Expense{expense})
+}
+
+// EvaluateAll is Evaluate for many expenses written together, such as an import.
+// The rules are listed once, and the spending of a rule is summarized once
+// for every period containing one of the expenses.
+func (evaluator AlertEvaluator) EvaluateAll(ctx context.Context, expenses []Expense) ([]Alert, error) {
+
+	tagged := false
+	for _, expense := range expenses {
+		tagged = tagged || len(expense.Tags) > 0
+	}
+	if !tagged {
+		return nil, nil
+	}
+
+	rules, err := evaluator.Alerts.ListAlertRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var fired []Alert
+
+	for _, rule := range rules {
+		var starts []time.Time
+		for _, expense := range expenses {
+			if !contains(expense.Tags, rule.Tag) || rule.Currency != expense.Currency {
+				continue
+			}
+			start := startOf(expense.SpentAt.In(DefaultTimezone), rule.Period)
+			if !containsTime(starts, start) {
+				starts = append(starts, start)
+			}
+		}
+
+		for _, start := range starts {
+			alerts, err := evaluator.evaluatePeriod(ctx, rule, start)
+			fired = append(fired, alerts...)
+			if err != nil {
+				return fired, err
+			}
+		}
+	}
+
+	return fired, nil
+}
+
+func containsTime(values []time.Time, value time.Time) bool {
+	for _, v := range values {
+		if v.Equal(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluatePeriod fires the alerts of rule whose thresholds the spending
+// of the period from start reached.
+func (evaluator AlertEvaluator) evaluatePeriod(ctx context.Context, rule AlertRule, start time.Time) ([]Alert, error) {
+
+	from, before := start.UTC(), addUnits(start, rule.Period, 1).UTC()
+
+	groups, err := evaluator.Reports.Summarize(auth.WithAllUsers(ctx), SummaryQuery{
+		Filter:   ListQuery{Tags: []string{rule.Tag}, Spent: DateRange{From: &from, Before: &before}},
+		Location: DefaultTimezone,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var spent Money
+	for _, group := range groups {
+		if group.Currency == rule.Currency {
+			spent += group.Total
+		}
+	}
+
+	var fired []Alert
+
+	for _, threshold := range rule.Thresholds {
+		if float64(spent)*100 < float64(rule.Amount)*threshold {
+			continue
+		}
+
+		alert := Alert{
+			RuleID:      rule.ID,
+			Tag:         rule.Tag,
+			PeriodStart: start.Format(DateLayout),
+			Threshold:   threshold,
+			Amount:      rule.Amount,
+			Spent:       spent,
+			Currency:    rule.Currency,
+		}
+		saved, err := evaluator.Alerts.FireAlert(ctx, &alert)
+		if err != nil {
+			return fired, err
+		}
+		if saved {
+			fired = append(fired, alert)
+		}
+	}
+
+	return fired, nil
+}
+
+// evaluateAlerts runs the AlertEvaluator after expenses are written.
+// A failure is logged since the expenses are already saved.
+func (handler Handler) evaluateAlerts(c echo.Context, expenses ...Expense) {
+
+	if handler.Alerts == nil || handler.Reports == nil || len(expenses) == 0 {
+		return
+	}
+
+	evaluator := AlertEvaluator{Alerts: handler.Alerts, Reports: handler.Reports}
+	if _, err := evaluator.EvaluateAll(c.Request().Context(), expenses); err != nil {
+		log.Println("cannot evaluate alert rules.", err)
+	}
+}
+
+// bindAlertRule reads the alert rule of the request's body.
+func bindAlertRule(c echo.Context) (AlertRule, error) {
+
+	var rule AlertRule
+	if err := c.Bind(&rule); err != nil {
+		return rule, errors.New("cannot read request's body. " + err.Error())
+	}
+
+	return rule, normalizeAlertRule(&rule)
+}
+
+// GetAlertRules handles HTTP GET request to list the alert rules.
+func (handler Handler) GetAlertRules(c echo.Context) error {
+
+	rules, err := handler.Alerts.ListAlertRules(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot list alert rules. " + err.Error()})
+	}
+
+	if rules == nil {
+		rules = []AlertRule{}
+	}
+
+	return c.JSON(http.StatusOK, rules)
+}
+
+// GetAlertRuleByID handles HTTP GET request to get an alert rule by ID.
+func (handler Handler) GetAlertRuleByID(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	rule, err := handler.Alerts.GetAlertRule(c.Request().Context(), id)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, rule)
+	case ErrAlertRuleNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot get the alert rule. " + err.Error()})
+	}
+}
+
+// CreateAlertRule handles HTTP POST request to create an alert rule.
+func (handler Handler) CreateAlertRule(c echo.Context) error {
+
+	rule, err := bindAlertRule(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	if err := handler.Alerts.CreateAlertRule(c.Request().Context(), &rule); err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot create the alert rule. " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, rule)
+}
+
+// PutAlertRule handles HTTP PUT request to replace an alert rule by ID.
+// Alerts it already fired are kept.
+func (handler Handler) PutAlertRule(c echo.Context) error {
+
+	rule, err := bindAlertRule(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	if rule.ID, err = parseID(c); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	err = handler.Alerts.UpdateAlertRule(c.Request().Context(), &rule)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, rule)
+	case ErrAlertRuleNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot update the alert rule. " + err.Error()})
+	}
+}
+
+// DeleteAlertRule handles HTTP DELETE request to delete an alert rule
+// and its alerts by ID.
+func (handler Handler) DeleteAlertRule(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	err = handler.Alerts.DeleteAlertRule(c.Request().Context(), id)
+
+	switch err {
+	case nil:
+		return c.NoContent(http.StatusNoContent)
+	case ErrAlertRuleNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot delete the alert rule. " + err.Error()})
+	}
+}
+
+// GetAlerts handles HTTP GET request to list fired alerts, the latest first.
+// acknowledged=false lists only the alerts which are not acknowledged.
+func (handler Handler) GetAlerts(c echo.Context) error {
+
+	var unacknowledged bool
+	switch c.QueryParam("acknowledged") {
+	case "":
+	case "false":
+		unacknowledged = true
+	default:
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"acknowledged can only be false."})
+	}
+
+	alerts, err := handler.Alerts.ListAlerts(c.Request().Context(), unacknowledged)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot list alerts. " + err.Error()})
+	}
+
+	if alerts == nil {
+		alerts = []Alert{}
+	}
+
+	return c.JSON(http.StatusOK, alerts)
+}
+
+// AcknowledgeAlert handles HTTP POST request to acknowledge an alert by ID.
+// Acknowledging it again keeps the first time.
+func (handler Handler) AcknowledgeAlert(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	alert, err := handler.Alerts.AcknowledgeAlert(c.Request().Context(), id)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, alert)
+	case ErrAlertNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot acknowledge the alert. " + err.Error()})
+	}
+}
@@ -0,0 +1,350 @@
+package expenses
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/lib/pq"
+)
+
+const (
+	alertRuleColumns = "id, tag, period, amount, currency, thresholds"
+	alertColumns     = "id, rule_id, tag, to_char(period_start, 'YYYY-MM-DD'), threshold, amount, spent, currency, created_at, acknowledged_at"
+)
+
+func scanAlertRule(row scanner) (AlertRule, error) {
+	var rule AlertRule
+	var thresholds pq.Float64Array
+	err := row.Scan(&rule.ID, &rule.Tag, &rule.Period, &rule.Amount, &rule.Currency, &thresholds)
+	rule.Thresholds = thresholds
+	return rule, err
+}
+
+func scanAlert(row scanner) (Alert, error) {
+	var alert Alert
+	var acknowledgedAt sql.NullTime
+	err := row.Scan(&alert.ID, &alert.RuleID, &alert.Tag, &alert.PeriodStart, &alert.Threshold,
+		&alert.Amount, &alert.Spent, &alert.Currency, &alert.CreatedAt, &acknowledgedAt)
+	alert.CreatedAt = alert.CreatedAt.UTC()
+	if acknowledgedAt.Valid {
+		t := acknowledgedAt.Time.UTC()
+		alert.AcknowledgedAt = &t
+	}
+	return alert, err
+}
+
+// PostgresAlertStore is an AlertStore backed by the alert_rules and alerts tables.
+// Every query is scoped to the workspace of auth.WorkspaceID.
+type PostgresAlertStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresAlertStore returns a PostgresAlertStore using db.
+func NewPostgresAlertStore(db *sql.DB) *PostgresAlertStore {
+	return &PostgresAlertStore{DB: db}
+}
+
+func (store *PostgresAlertStore) ListAlertRules(ctx context.Context) ([]AlertRule, error) {
+
+	rows, err := store.DB.QueryContext(ctx,
+		"SELECT "+alertRuleColumns+" FROM alert_rules WHERE workspace_id = $1 ORDER BY id", auth.WorkspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []AlertRule
+
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (store *PostgresAlertStore) GetAlertRule(ctx context.Context, id int) (AlertRule, error) {
+
+	rule, err := scanAlertRule(store.DB.QueryRowContext(ctx,
+		"SELECT "+alertRuleColumns+" FROM alert_rules WHERE id = $1 AND workspace_id = $2", id, auth.WorkspaceID(ctx)))
+	if err == sql.ErrNoRows {
+		return AlertRule{}, ErrAlertRuleNotFound
+	}
+
+	return rule, err
+}
+
+func (store *PostgresAlertStore) CreateAlertRule(ctx context.Context, rule *AlertRule) error {
+	return store.DB.QueryRowContext(ctx, `
+		INSERT INTO alert_rules (workspace_id, tag, period, amount, currency, thresholds)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, auth.WorkspaceID(ctx), rule.Tag, rule.Period, rule.Amount, rule.Currency, pq.Array(rule.Thresholds)).Scan(&rule.ID)
+}
+
+func (store *PostgresAlertStore) UpdateAlertRule(ctx context.Context, rule *AlertRule) error {
+
+	result, err := store.DB.ExecContext(ctx, `
+		UPDATE alert_rules SET tag = $2, period = $3, amount = $4, currency = $5, thresholds = $6
+		WHERE id = $1 AND workspace_id = $7
+	`, rule.ID, rule.Tag, rule.Period, rule.Amount, rule.Currency, pq.Array(rule.Thresholds), auth.WorkspaceID(ctx))
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAlertRuleNotFound
+	}
+
+	return nil
+}
+
+func (store *PostgresAlertStore) DeleteAlertRule(ctx context.Context, id int) error {
+
+	result, err := store.DB.ExecContext(ctx,
+		"DELETE FROM alert_rules WHERE id = $1 AND workspace_id = $2", id, auth.WorkspaceID(ctx))
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAlertRuleNotFound
+	}
+
+	return nil
+}
+
+func (store *PostgresAlertStore) FireAlert(ctx context.Context, alert *Alert) (bool, error) {
+
+	err := store.DB.QueryRowContext(ctx, `
+		INSERT INTO alerts (workspace_id, rule_id, period_start, threshold, tag, amount, spent, currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (rule_id, period_start, threshold) DO NOTHING
+		RETURNING id, created_at
+	`, auth.WorkspaceID(ctx), alert.RuleID, alert.PeriodStart, alert.Threshold, alert.Tag, alert.Amount, alert.Spent,
+		alert.Currency).
+		Scan(&alert.ID, &alert.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	alert.CreatedAt = alert.CreatedAt.UTC()
+	return true, nil
+}
+
+func (store *PostgresAlertStore) ListAlerts(ctx context.Context, unacknowledged bool) ([]Alert, error) {
+
+	rows, err := store.DB.QueryContext(ctx, `
+		SELECT `+alertColumns+` FROM alerts
+		WHERE workspace_id = $2 AND (NOT $1 OR acknowledged_at IS NULL)
+		ORDER BY id DESC
+	`, unacknowledged, auth.WorkspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []Alert
+
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+func (store *PostgresAlertStore) AcknowledgeAlert(ctx context.Context, id int) (Alert, error) {
+
+	alert, err := scanAlert(store.DB.QueryRowContext(ctx, `
+		UPDATE alerts SET acknowledged_at = COALESCE(acknowledged_at, now())
+		WHERE id = $1 AND workspace_id = $2
+		RETURNING `+alertColumns, id, auth.WorkspaceID(ctx)))
+	if err == sql.ErrNoRows {
+		return Alert{}, ErrAlertNotFound
+	}
+
+	return alert, err
+}
+
+// MemoryAlertStore is a thread-safe AlertStore keeping rules and alerts in slices.
+// Like PostgresAlertStore, it only sees the rules and alerts of the workspace of auth.WorkspaceID.
+type MemoryAlertStore struct {
+	mu          sync.RWMutex
+	lastRuleID  int
+	lastAlertID int
+	rules       []AlertRule
+	alerts      []Alert
+
+	// workspaces are the IDs of the workspaces of the rules, and of their alerts.
+	workspaces map[int]int
+
+	// Now returns the time alerts are fired and acknowledged.
+	Now func() time.Time
+}
+
+// NewMemoryAlertStore returns an empty MemoryAlertStore.
+func NewMemoryAlertStore() *MemoryAlertStore {
+	return &MemoryAlertStore{workspaces: map[int]int{}, Now: time.Now}
+}
+
+func (store *MemoryAlertStore) now() time.Time {
+	return store.Now().UTC().Truncate(time.Microsecond)
+}
+
+// findRule returns the index of the rule of id in the workspace, or -1.
+func (store *MemoryAlertStore) findRule(workspace int, id int) int {
+	for i, rule := range store.rules {
+		if rule.ID == id && store.workspaces[id] == workspace {
+			return i
+		}
+	}
+	return -1
+}
+
+func (store *MemoryAlertStore) ListAlertRules(ctx context.Context) ([]AlertRule, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var rules []AlertRule
+	for _, rule := range store.rules {
+		if store.workspaces[rule.ID] == auth.WorkspaceID(ctx) {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+func (store *MemoryAlertStore) GetAlertRule(ctx context.Context, id int) (AlertRule, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	i := store.findRule(auth.WorkspaceID(ctx), id)
+	if i < 0 {
+		return AlertRule{}, ErrAlertRuleNotFound
+	}
+
+	return store.rules[i], nil
+}
+
+func (store *MemoryAlertStore) CreateAlertRule(ctx context.Context, rule *AlertRule) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.lastRuleID++
+	rule.ID = store.lastRuleID
+	store.rules = append(store.rules, *rule)
+	store.workspaces[rule.ID] = auth.WorkspaceID(ctx)
+
+	return nil
+}
+
+func (store *MemoryAlertStore) UpdateAlertRule(ctx context.Context, rule *AlertRule) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.findRule(auth.WorkspaceID(ctx), rule.ID)
+	if i < 0 {
+		return ErrAlertRuleNotFound
+	}
+
+	store.rules[i] = *rule
+
+	return nil
+}
+
+func (store *MemoryAlertStore) DeleteAlertRule(ctx context.Context, id int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.findRule(auth.WorkspaceID(ctx), id)
+	if i < 0 {
+		return ErrAlertRuleNotFound
+	}
+
+	store.rules = append(store.rules[:i], store.rules[i+1:]...)
+	delete(store.workspaces, id)
+
+	var alerts []Alert
+	for _, alert := range store.alerts {
+		if alert.RuleID != id {
+			alerts = append(alerts, alert)
+		}
+	}
+	store.alerts = alerts
+
+	return nil
+}
+
+func (store *MemoryAlertStore) FireAlert(ctx context.Context, alert *Alert) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, fired := range store.alerts {
+		if fired.RuleID == alert.RuleID && fired.PeriodStart == alert.PeriodStart && fired.Threshold == alert.Threshold {
+			return false, nil
+		}
+	}
+
+	store.lastAlertID++
+	alert.ID = store.lastAlertID
+	alert.CreatedAt = store.now()
+	store.alerts = append(store.alerts, *alert)
+
+	return true, nil
+}
+
+func (store *MemoryAlertStore) ListAlerts(ctx context.Context, unacknowledged bool) ([]Alert, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var alerts []Alert
+	for _, alert := range store.alerts {
+		if store.workspaces[alert.RuleID] == auth.WorkspaceID(ctx) && (!unacknowledged || alert.AcknowledgedAt == nil) {
+			alerts = append(alerts, alert)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].ID > alerts[j].ID })
+
+	return alerts, nil
+}
+
+func (store *MemoryAlertStore) AcknowledgeAlert(ctx context.Context, id int) (Alert, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for i, alert := range store.alerts {
+		if alert.ID == id && store.workspaces[alert.RuleID] == auth.WorkspaceID(ctx) {
+			if alert.AcknowledgedAt == nil {
+				now := store.now()
+				store.alerts[i].AcknowledgedAt = &now
+			}
+			return store.alerts[i], nil
+		}
+	}
+
+	return Alert{}, ErrAlertNotFound
+}
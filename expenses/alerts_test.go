@@ -0,0 +1,268 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAlertHandler() Handler {
+	store := newTestStore()
+	alerts := NewMemoryAlertStore()
+	alerts.Now = func() time.Time { return testTime }
+	return Handler{Store: store, Reports: store, Alerts: alerts}
+}
+
+func listAlerts(t *testing.T, handler Handler, target string) []Alert {
+	c, rec := newIDContext(http.MethodGet, target, "", "")
+	handler.GetAlerts(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var alerts []Alert
+	if err := json.Unmarshal(rec.Body.Bytes(), &alerts); err != nil {
+		t.Fatal(err)
+	}
+	return alerts
+}
+
+func TestAlertsFireOncePerThreshold(t *testing.T) {
+	// Arrange
+	handler := newAlertHandler()
+	c, rec := newIDContext(http.MethodPost, "/alert-rules",
+		`{"tag": "Food", "amount": 100, "thresholds": [100, 80, 80]}`, "")
+	handler.CreateAlertRule(c)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"id": 1, "tag": "food", "period": "month", "amount": 100, "currency": "THB",
+		"thresholds": [80, 100]}`, rec.Body.String())
+
+	var fired [][]float64
+	for _, write := range []struct{ method, body, id string }{
+		{http.MethodPost, `{"title": "rice", "amount": 70, "tags": ["food"]}`, ""},
+		{http.MethodPost, `{"title": "bus", "amount": 50, "tags": ["travel"]}`, ""},
+		{http.MethodPost, `{"title": "coffee", "amount": 50, "currency": "USD", "tags": ["food"]}`, ""},
+		{http.MethodPost, `{"title": "noodles", "amount": 15, "tags": ["food"]}`, ""},
+		{http.MethodPost, `{"title": "water", "amount": 5, "tags": ["food"]}`, ""},
+		{http.MethodPut, `{"title": "noodles", "amount": 40, "tags": ["food"]}`, "4"},
+		// Last month is another period.
+		{http.MethodPost, `{"title": "cake", "amount": 90, "tags": ["food"], "spent_at": "2026-08-31"}`, ""},
+	} {
+		// Act
+		c, rec := newIDContext(write.method, "/expenses", write.body, write.id)
+		if write.method == http.MethodPost {
+			handler.CreateExpense(c)
+		} else {
+			handler.PutExpense(c)
+		}
+		assert.Less(t, rec.Code, 300, write.body)
+
+		var thresholds []float64
+		for _, alert := range listAlerts(t, handler, "/alerts") {
+			thresholds = append(thresholds, alert.Threshold)
+		}
+		fired = append(fired, thresholds)
+	}
+
+	// Assert
+	assert.Equal(t, [][]float64{
+		nil, nil, nil, {80}, {80}, {100, 80}, {80, 100, 80},
+	}, fired)
+
+	alerts := listAlerts(t, handler, "/alerts")
+	assert.Equal(t, Alert{ID: 2, RuleID: 1, Tag: "food", PeriodStart: "2026-09-01", Threshold: 100,
+		Amount: 10000, Spent: 11500, Currency: "THB", CreatedAt: testTime}, alerts[1])
+	assert.Equal(t, "2026-08-01", alerts[0].PeriodStart)
+}
+
+// countingReports counts the summaries of a ReportStore.
+type countingReports struct {
+	ReportStore
+	summaries int
+}
+
+func (reports *countingReports) Summarize(ctx context.Context, query SummaryQuery) ([]SummaryGroup, error) {
+	reports.summaries++
+	return reports.ReportStore.Summarize(ctx, query)
+}
+
+func TestImportEvaluatesAlertsOncePerPeriod(t *testing.T) {
+	// Arrange
+	handler := newImportHandler()
+	reports := &countingReports{ReportStore: handler.Store.(*MemoryStore)}
+	alerts := NewMemoryAlertStore()
+	handler.Reports, handler.Alerts = reports, alerts
+	alerts.CreateAlertRule(context.Background(), &AlertRule{Tag: "food", Period: "month", Amount: 10000,
+		Currency: "THB", Thresholds: []float64{100}})
+	alerts.CreateAlertRule(context.Background(), &AlertRule{Tag: "travel", Period: "month", Amount: 10000,
+		Currency: "THB", Thresholds: []float64{100}})
+
+	body := "title,amount,tags,spent_at\n"
+	for i := 0; i < 50; i++ {
+		body += "rice,10,food,2026-09-15\n"
+	}
+	body += "cake,10,food,2026-08-15\nbus,10,travel,2026-09-15\nbook,10,,2026-09-15\n"
+
+	// Act
+	_, rec := importExpenses(t, handler, "/expenses/import", MIMETextCSV, body)
+	fired, _ := alerts.ListAlerts(context.Background(), false)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 3, reports.summaries)
+	assert.Len(t, fired, 1)
+	assert.Equal(t, "2026-09-01", fired[0].PeriodStart)
+}
+
+func TestAlertsOfWorkspace(t *testing.T) {
+	// Arrange
+	handler := newAlertHandler()
+	c, rec := newIDContext(http.MethodPost, "/alert-rules", `{"tag": "food", "amount": 100, "thresholds": [80]}`, "")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.CreateAlertRule(c)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	// Act
+	c, ourRec := newIDContext(http.MethodPost, "/expenses", `{"title": "rice", "amount": 50, "tags": ["food"]}`, "")
+	asWorkspace(c, 1, 1, auth.Member)
+	handler.CreateExpense(c)
+
+	c, theirRec := newIDContext(http.MethodPost, "/expenses", `{"title": "noodles", "amount": 40, "tags": ["food"]}`, "")
+	asWorkspace(c, 2, 1, auth.Member)
+	handler.CreateExpense(c)
+
+	c, listRec := newIDContext(http.MethodGet, "/alerts", "", "")
+	asWorkspace(c, 1, 1, auth.Member)
+	handler.GetAlerts(c)
+	var alerts []Alert
+	assert.NoError(t, json.Unmarshal(listRec.Body.Bytes(), &alerts))
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, ourRec.Code)
+	assert.Equal(t, http.StatusCreated, theirRec.Code)
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, Money(9000), alerts[0].Spent)
+}
+
+func TestAcknowledgeAlert(t *testing.T) {
+	// Arrange
+	handler := newAlertHandler()
+	handler.Alerts.CreateAlertRule(context.Background(), &AlertRule{
+		Tag: "food", Period: "week", Amount: 1000, Currency: "THB", Thresholds: []float64{50, 100},
+	})
+	c, _ := newIDContext(http.MethodPost, "/expenses", `{"title": "rice", "amount": 10, "tags": ["food"]}`, "")
+	handler.CreateExpense(c)
+	assert.Len(t, listAlerts(t, handler, "/alerts?acknowledged=false"), 2)
+
+	// Act
+	c, rec := newIDContext(http.MethodPost, "/alerts/1/acknowledge", "", "1")
+	handler.AcknowledgeAlert(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id": 1, "rule_id": 1, "tag": "food", "period_start": "2026-09-14", "threshold": 50,
+		"amount": 10, "spent": 10, "currency": "THB",
+		"created_at": "2026-09-15T05:30:00Z", "acknowledged_at": "2026-09-15T05:30:00Z"}`, rec.Body.String())
+
+	unacknowledged := listAlerts(t, handler, "/alerts?acknowledged=false")
+	assert.Len(t, unacknowledged, 1)
+	assert.Equal(t, 2, unacknowledged[0].ID)
+	assert.Len(t, listAlerts(t, handler, "/alerts"), 2)
+
+	c, rec = newIDContext(http.MethodPost, "/alerts/3/acknowledge", "", "3")
+	handler.AcknowledgeAlert(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Deleting the rule deletes its alerts.
+	c, rec = newIDContext(http.MethodDelete, "/alert-rules/1", "", "1")
+	handler.DeleteAlertRule(c)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, listAlerts(t, handler, "/alerts"))
+}
+
+func TestCreateAlertRuleInvalid(t *testing.T) {
+	for _, body := range []string{
+		`{"amount": 100}`,
+		`{"tag": "food", "period": "day", "amount": 100}`,
+		`{"tag": "food", "amount": -1}`,
+		`{"tag": "food", "amount": 100, "currency": "ABC"}`,
+		`{"tag": "food", "amount": 100, "thresholds": [0]}`,
+		`{"tag": "food", "amount": 100, "thresholds": "80"}`,
+	} {
+		c, rec := newIDContext(http.MethodPost, "/alert-rules", body, "")
+		newAlertHandler().CreateAlertRule(c)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+
+	c, rec := newIDContext(http.MethodPut, "/alert-rules/9", `{"tag": "food", "amount": 100}`, "9")
+	newAlertHandler().PutAlertRule(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestPostgresAlertStoreFireAlert(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	query := "INSERT INTO alerts \\(workspace_id, rule_id, period_start, threshold, tag, amount, spent, currency\\) " +
+		"VALUES (.+) ON CONFLICT \\(rule_id, period_start, threshold\\) DO NOTHING RETURNING id, created_at"
+	mock.ExpectQuery(query).
+		WithArgs(0, 1, "2026-09-01", 80.0, "food", 10000, 8500, "THB").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, testTime))
+	mock.ExpectQuery(query).
+		WithArgs(0, 1, "2026-09-01", 80.0, "food", 10000, 9000, "THB").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}))
+
+	store := NewPostgresAlertStore(db)
+	alert := Alert{RuleID: 1, Tag: "food", PeriodStart: "2026-09-01", Threshold: 80, Amount: 10000, Spent: 8500, Currency: "THB"}
+
+	// Act
+	saved, err := store.FireAlert(context.Background(), &alert)
+
+	again := alert
+	again.Spent = 9000
+	savedAgain, errAgain := store.FireAlert(context.Background(), &again)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, saved)
+	assert.Equal(t, 7, alert.ID)
+	assert.Equal(t, testTime, alert.CreatedAt)
+
+	assert.NoError(t, errAgain)
+	assert.False(t, savedAgain)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreExpenseEvaluatesAlerts(t *testing.T) {
+	// Arrange
+	handler := newAlertHandler()
+	handler.Alerts.CreateAlertRule(context.Background(), &AlertRule{Tag: "food", Period: "month", Amount: 10000,
+		Currency: "THB", Thresholds: []float64{100}})
+	handler.Store.Create(context.Background(), &Expense{Title: "rice", Amount: 6000, Currency: "THB",
+		Tags: []string{"food"}, SpentAt: testTime})
+	handler.Store.Delete(context.Background(), 1)
+
+	c, rec := newIDContext(http.MethodPost, "/expenses", `{"title": "noodles", "amount": 50, "tags": ["food"]}`, "")
+	handler.CreateExpense(c)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, listAlerts(t, handler, "/alerts"))
+
+	// Act
+	c, rec = newIDContext(http.MethodPost, "/expenses/1/restore", "", "1")
+	handler.RestoreExpense(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	alerts := listAlerts(t, handler, "/alerts")
+	if assert.Len(t, alerts, 1) {
+		assert.Equal(t, Money(11000), alerts[0].Spent)
+	}
+}
@@ -0,0 +1,182 @@
+package expenses
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// APIKeyRequest is the body of IssueAPIKey. ExpiresAt is an RFC 3339 time
+// or a date, and the key never expires without it. UserID is the user
+// of the key, which only identities managing the members of the workspace
+// choose among its members, and the bootstrap key for the first key.
+type APIKeyRequest struct {
+	Name      string `json:"name"`
+	ExpiresAt string `json:"expires_at"`
+	UserID    int    `json:"user_id"`
+}
+
+// errBootstrapKeys is the response to the bootstrap key using the keys of users.
+const errBootstrapKeys = "the bootstrap key can only issue the first api key."
+
+// IssueAPIKey handles HTTP POST request to issue a new API key for the user
+// of the request, or for a member of the workspace of the request with the
+// auth.ManageMembers permission. The bootstrap key only issues the first key.
+// The response has the key, which is not shown again.
+func (handler Handler) IssueAPIKey(c echo.Context) error {
+
+	var request APIKeyRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+
+	apiKey := auth.APIKey{UserID: auth.UserID(ctx), Name: strings.TrimSpace(request.Name)}
+	if apiKey.Name == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"name is required."})
+	}
+
+	identity, _ := auth.IdentityOf(c)
+
+	switch {
+	case apiKey.UserID == 0 && request.UserID == 0:
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"user_id is required."})
+	case request.UserID == 0 || request.UserID == apiKey.UserID:
+	case identity.Bootstrap():
+		if _, err := handler.Users.GetUser(ctx, request.UserID); err == auth.ErrUserNotFound {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{fmt.Sprintf("user %d does not exist.", request.UserID)})
+		} else if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{"cannot find the user. " + err.Error()})
+		}
+		keys, err := handler.Keys.ListKeys(ctx, 0)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{"cannot list api keys. " + err.Error()})
+		}
+		if len(keys) > 0 {
+			return c.JSON(http.StatusForbidden, ErrorResponse{errBootstrapKeys})
+		}
+		apiKey.UserID = request.UserID
+	case !identity.Can(auth.ManageMembers):
+		return c.JSON(http.StatusForbidden, ErrorResponse{"cannot issue api keys of another user."})
+	default:
+		if _, err := handler.Workspaces.GetMember(ctx, auth.WorkspaceID(ctx), request.UserID); err == auth.ErrNotMember {
+			return c.JSON(http.StatusForbidden,
+				ErrorResponse{fmt.Sprintf("user %d is not a member of the workspace.", request.UserID)})
+		} else if err != nil {
+			return c.JSON(http.StatusInternalServerError, ErrorResponse{"cannot find the member. " + err.Error()})
+		}
+		apiKey.UserID = request.UserID
+	}
+
+	if request.ExpiresAt != "" {
+		expiresAt, err := ParseTime(request.ExpiresAt, DefaultTimezone)
+		if err == nil && !expiresAt.After(time.Now()) {
+			err = errors.New("it is in the past")
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid expires_at. " + err.Error()})
+		}
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	key, prefix, hash, err := auth.NewKey()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"cannot generate a key. " + err.Error()})
+	}
+	apiKey.Prefix = prefix
+
+	if err := handler.Keys.CreateKey(ctx, &apiKey, hash); err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot create the api key. " + err.Error()})
+	}
+	apiKey.Key = key
+
+	return c.JSON(http.StatusCreated, apiKey)
+}
+
+// GetAPIKeys handles HTTP GET request to list the API keys of the user
+// without their secrets.
+func (handler Handler) GetAPIKeys(c echo.Context) error {
+
+	ctx := c.Request().Context()
+	if auth.UserID(ctx) == 0 {
+		return c.JSON(http.StatusForbidden, ErrorResponse{errBootstrapKeys})
+	}
+	keys, err := handler.Keys.ListKeys(ctx, auth.UserID(ctx))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot list api keys. " + err.Error()})
+	}
+	if keys == nil {
+		keys = []auth.APIKey{}
+	}
+
+	return c.JSON(http.StatusOK, keys)
+}
+
+// RotateAPIKey handles HTTP POST request to replace the secret of an API key.
+// The old secret stops working and the response has the new one.
+func (handler Handler) RotateAPIKey(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	key, prefix, hash, err := auth.NewKey()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"cannot generate a key. " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	if auth.UserID(ctx) == 0 {
+		return c.JSON(http.StatusForbidden, ErrorResponse{errBootstrapKeys})
+	}
+
+	apiKey, err := handler.Keys.RotateKey(ctx, auth.UserID(ctx), id, prefix, hash)
+
+	switch err {
+	case nil:
+		apiKey.Key = key
+		return c.JSON(http.StatusOK, apiKey)
+	case auth.ErrKeyNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot rotate the api key. " + err.Error()})
+	}
+}
+
+// RevokeAPIKey handles HTTP DELETE request to revoke an API key by ID.
+// A revoked key stays in the list of keys.
+func (handler Handler) RevokeAPIKey(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	if auth.UserID(ctx) == 0 {
+		return c.JSON(http.StatusForbidden, ErrorResponse{errBootstrapKeys})
+	}
+
+	err = handler.Keys.RevokeKey(ctx, auth.UserID(ctx), id)
+
+	switch err {
+	case nil:
+		return c.NoContent(http.StatusNoContent)
+	case auth.ErrKeyNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot revoke the api key. " + err.Error()})
+	}
+}
@@ -0,0 +1,182 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func issueAPIKey(t *testing.T, handler Handler, userID int, body string) (auth.APIKey, int) {
+	c, rec := newIDContext(http.MethodPost, "/api-keys", body, "")
+	if userID == 0 {
+		asBootstrap(c)
+	}
+	asUser(c, userID)
+	handler.IssueAPIKey(c)
+
+	var key auth.APIKey
+	if err := json.Unmarshal(rec.Body.Bytes(), &key); err != nil {
+		t.Fatal(err)
+	}
+	return key, rec.Code
+}
+
+func TestAPIKeys(t *testing.T) {
+	// Arrange
+	handler := Handler{Keys: auth.NewMemoryKeyStore()}
+
+	// Act
+	issued, status := issueAPIKey(t, handler, 1, `{"name": " ci ", "expires_at": "2999-01-01"}`)
+	issueAPIKey(t, handler, 2, `{"name": "other"}`)
+
+	c, otherRec := newIDContext(http.MethodDelete, "/api-keys/1", "", "1")
+	asUser(c, 2)
+	handler.RevokeAPIKey(c)
+
+	c, rec := newIDContext(http.MethodPost, "/api-keys/1/rotate", "", "1")
+	asUser(c, 1)
+	handler.RotateAPIKey(c)
+	var rotated auth.APIKey
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &rotated))
+
+	c, revokeRec := newIDContext(http.MethodDelete, "/api-keys/1", "", "1")
+	asUser(c, 1)
+	handler.RevokeAPIKey(c)
+
+	c, listRec := newIDContext(http.MethodGet, "/api-keys", "", "")
+	asUser(c, 1)
+	handler.GetAPIKeys(c)
+	var keys []auth.APIKey
+	assert.NoError(t, json.Unmarshal(listRec.Body.Bytes(), &keys))
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "ci", issued.Name)
+	assert.Equal(t, 1, issued.UserID)
+	assert.Equal(t, http.StatusNotFound, otherRec.Code)
+	assert.True(t, strings.HasPrefix(issued.Key, auth.KeyPrefix))
+	assert.Equal(t, issued.Key[:len(issued.Prefix)], issued.Prefix)
+	assert.Equal(t, "2999-01-01T00:00:00Z", issued.ExpiresAt.Format("2006-01-02T15:04:05Z07:00"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, rotated.ID)
+	assert.NotEqual(t, issued.Key, rotated.Key)
+	assert.Equal(t, rotated.Key[:len(rotated.Prefix)], rotated.Prefix)
+
+	assert.Equal(t, http.StatusNoContent, revokeRec.Code)
+	assert.Equal(t, http.StatusOK, listRec.Code)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, "", keys[0].Key)
+	assert.NotNil(t, keys[0].RevokedAt)
+	assert.NotContains(t, listRec.Body.String(), rotated.Key)
+}
+
+func TestAPIKeysErrors(t *testing.T) {
+	for _, body := range []string{
+		`{"name": ""}`,
+		`{"name": "ci", "expires_at": "tomorrow"}`,
+		`{"name": "ci", "expires_at": "2001-01-01"}`,
+		`{"name": 1}`,
+	} {
+		_, status := issueAPIKey(t, Handler{Keys: auth.NewMemoryKeyStore()}, 1, body)
+
+		assert.Equal(t, http.StatusBadRequest, status, body)
+	}
+
+	_, status := issueAPIKey(t, Handler{Keys: auth.NewMemoryKeyStore()}, 1, `{"name": "ci", "user_id": 2}`)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	handler := Handler{Keys: auth.NewMemoryKeyStore(), Users: auth.NewMemoryUserStore()}
+
+	c, rec := newIDContext(http.MethodPost, "/api-keys/1/rotate", "", "1")
+	asUser(c, 1)
+	handler.RotateAPIKey(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = newIDContext(http.MethodDelete, "/api-keys/1", "", "1")
+	asUser(c, 1)
+	handler.RevokeAPIKey(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = newIDContext(http.MethodDelete, "/api-keys/x", "", "x")
+	handler.RevokeAPIKey(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAPIKeysOfBootstrapKey(t *testing.T) {
+	// Arrange
+	users := auth.NewMemoryUserStore()
+	users.CreateUser(context.Background(), &auth.User{Name: "peem"}, "")
+	handler := Handler{Keys: auth.NewMemoryKeyStore(), Users: users}
+
+	// Act
+	_, missingStatus := issueAPIKey(t, handler, 0, `{"name": "ci"}`)
+	_, unknownStatus := issueAPIKey(t, handler, 0, `{"name": "ci", "user_id": 2}`)
+	issued, status := issueAPIKey(t, handler, 0, `{"name": "ci", "user_id": 1}`)
+	_, secondStatus := issueAPIKey(t, handler, 0, `{"name": "ci", "user_id": 1}`)
+
+	c, listRec := newIDContext(http.MethodGet, "/api-keys", "", "")
+	asBootstrap(c)
+	handler.GetAPIKeys(c)
+
+	c, rotateRec := newIDContext(http.MethodPost, "/api-keys/1/rotate", "", "1")
+	asBootstrap(c)
+	handler.RotateAPIKey(c)
+
+	c, revokeRec := newIDContext(http.MethodDelete, "/api-keys/1", "", "1")
+	asBootstrap(c)
+	handler.RevokeAPIKey(c)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, missingStatus)
+	assert.Equal(t, http.StatusBadRequest, unknownStatus)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, 1, issued.UserID)
+	assert.Equal(t, http.StatusForbidden, secondStatus)
+	assert.Equal(t, http.StatusForbidden, listRec.Code)
+	assert.Equal(t, http.StatusForbidden, rotateRec.Code)
+	assert.NotContains(t, rotateRec.Body.String(), auth.KeyPrefix)
+	assert.Equal(t, http.StatusForbidden, revokeRec.Code)
+}
+
+func TestAPIKeysOfAdmin(t *testing.T) {
+	// Arrange
+	users := auth.NewMemoryUserStore()
+	users.CreateUser(context.Background(), &auth.User{Name: "admin", Role: auth.Admin}, "")
+	users.CreateUser(context.Background(), &auth.User{Name: "peem"}, "")
+	users.CreateUser(context.Background(), &auth.User{Name: "contractor"}, "")
+	workspaces := auth.NewMemoryWorkspaceStore()
+	handler := Handler{Keys: auth.NewMemoryKeyStore(), Users: users, Workspaces: workspaces}
+
+	ctx := context.Background()
+	workspaces.CreateWorkspace(ctx, &auth.Workspace{Name: "team"}, 1)
+	workspaces.CreateWorkspace(ctx, &auth.Workspace{Name: "contractor"}, 3)
+	workspaces.CreateInvitation(ctx, &auth.Invitation{WorkspaceID: 1, Role: auth.Member, ExpiresAt: testTime}, "hash")
+	workspaces.AcceptInvitation(ctx, "hash", 2, testTime.Add(-1))
+
+	// Act
+	c, rec := newIDContext(http.MethodPost, "/api-keys", `{"name": "ci", "user_id": 2}`, "")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.IssueAPIKey(c)
+	var issued auth.APIKey
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &issued))
+
+	c, otherRec := newIDContext(http.MethodPost, "/api-keys", `{"name": "ci", "user_id": 3}`, "")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.IssueAPIKey(c)
+
+	c, memberRec := newIDContext(http.MethodPost, "/api-keys", `{"name": "ci", "user_id": 1}`, "")
+	asWorkspace(c, 2, 1, auth.Member)
+	handler.IssueAPIKey(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 2, issued.UserID)
+	assert.Equal(t, http.StatusForbidden, otherRec.Code)
+	assert.Equal(t, http.StatusForbidden, memberRec.Code)
+}
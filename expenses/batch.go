@@ -0,0 +1,215 @@
+package expenses
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MaxBatchOperations is the maximum number of operations of a batch.
+const MaxBatchOperations = 1000
+
+// The operations of a batch.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// The modes of a batch. An atomic batch saves every operation or none,
+// and a best effort batch saves the operations which succeed.
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+type (
+
+	// BatchOperation is an operation of a batch. Create takes Expense,
+	// update takes ID and Expense, and delete takes ID.
+	BatchOperation struct {
+		Op      string   `json:"op"`
+		ID      int      `json:"id,omitempty"`
+		Expense *Expense `json:"expense,omitempty"`
+	}
+
+	// BatchRequest is the body of BatchExpenses. Mode is atomic by default.
+	BatchRequest struct {
+		Mode       string           `json:"mode"`
+		Operations []BatchOperation `json:"operations"`
+	}
+
+	// BatchResult is the result of an operation of a batch, at Index in the request.
+	// Status is the status code the operation would have on its own.
+	BatchResult struct {
+		Index   int      `json:"index"`
+		Op      string   `json:"op"`
+		Status  int      `json:"status"`
+		ID      int      `json:"id,omitempty"`
+		Expense *Expense `json:"expense,omitempty"`
+		Error   string   `json:"error,omitempty"`
+	}
+
+	// BatchResponse is the response of BatchExpenses.
+	BatchResponse struct {
+		Mode      string        `json:"mode"`
+		Succeeded int           `json:"succeeded"`
+		Failed    int           `json:"failed"`
+		Results   []BatchResult `json:"results"`
+	}
+)
+
+// checkBatchOperation normalizes the expense of an operation, and returns
+// why the operation is invalid.
+func checkBatchOperation(operation *BatchOperation) error {
+
+	switch operation.Op {
+	case BatchCreate, BatchUpdate:
+		if operation.Expense == nil {
+			return fmt.Errorf("%s needs an expense", operation.Op)
+		}
+	case BatchDelete:
+		if operation.Expense != nil {
+			return errors.New("delete takes only an id")
+		}
+	default:
+		return fmt.Errorf("unknown op %q, use create, update or delete", operation.Op)
+	}
+
+	switch {
+	case operation.Op == BatchCreate && operation.ID != 0:
+		return errors.New("create cannot have an id")
+	case operation.Op != BatchCreate && operation.ID <= 0:
+		return fmt.Errorf("%s needs the id of an expense", operation.Op)
+	}
+
+	if operation.Expense != nil {
+		currency, err := NormalizeCurrency(operation.Expense.Currency)
+		if err != nil {
+			return err
+		}
+		operation.Expense.Currency = currency
+		operation.Expense.Tags = NormalizeTags(operation.Expense.Tags)
+		operation.Expense.ID = operation.ID
+		if err := checkText(*operation.Expense); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BatchExpenses handles HTTP POST request to create, update and delete
+// many expenses at once. It responds with the result of each operation.
+// In atomic mode every operation is saved or none is, and the response is
+// 422 Unprocessable Entity when one fails, where the operations which did not fail
+// have status 424 Failed Dependency. In best effort mode the operations
+// which succeed are saved and the response is 200 OK.
+func (handler Handler) BatchExpenses(c echo.Context) error {
+
+	var request BatchRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+	}
+
+	switch request.Mode {
+	case "":
+		request.Mode = BatchAtomic
+	case BatchAtomic, BatchBestEffort:
+	default:
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"mode must be atomic or best_effort."})
+	}
+	atomic := request.Mode == BatchAtomic
+
+	if len(request.Operations) == 0 || len(request.Operations) > MaxBatchOperations {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{fmt.Sprintf("a batch has from 1 to %d operations.", MaxBatchOperations)})
+	}
+
+	response := BatchResponse{Mode: request.Mode, Results: make([]BatchResult, len(request.Operations))}
+
+	// valid are the operations to run and their indexes in the request.
+	var valid []BatchOperation
+	var indexes []int
+	for i := range request.Operations {
+		operation := &request.Operations[i]
+		response.Results[i] = BatchResult{Index: i, Op: operation.Op, ID: operation.ID}
+
+		if err := checkBatchOperation(operation); err != nil {
+			response.Results[i].Status = http.StatusBadRequest
+			response.Results[i].Error = err.Error()
+			continue
+		}
+		valid = append(valid, *operation)
+		indexes = append(indexes, i)
+	}
+
+	// An atomic batch with an invalid operation is not run at all.
+	run := len(valid) > 0 && (!atomic || len(valid) == len(request.Operations))
+
+	var errs []error
+	if run {
+		var err error
+		errs, err = handler.Store.Batch(c.Request().Context(), valid, atomic)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError,
+				ErrorResponse{"cannot run the batch. " + err.Error()})
+		}
+	}
+
+	for j, operation := range valid {
+		result := &response.Results[indexes[j]]
+
+		switch {
+		case !run:
+		case errs[j] == ErrNotFound:
+			result.Status = http.StatusNotFound
+			result.Error = errs[j].Error()
+		case errs[j] != nil:
+			result.Status = http.StatusInternalServerError
+			result.Error = errs[j].Error()
+		case operation.Op == BatchDelete:
+			result.Status = http.StatusNoContent
+		default:
+			result.Status = http.StatusOK
+			if operation.Op == BatchCreate {
+				result.Status = http.StatusCreated
+			}
+			result.ID = operation.Expense.ID
+			result.Expense = operation.Expense
+		}
+	}
+
+	for _, result := range response.Results {
+		if result.Status >= 300 {
+			response.Failed++
+		}
+	}
+
+	if atomic && response.Failed > 0 {
+		for i, operation := range request.Operations {
+			if result := &response.Results[i]; result.Status < 300 {
+				*result = BatchResult{Index: i, Op: operation.Op, ID: operation.ID,
+					Status: http.StatusFailedDependency, Error: "not saved since another operation failed"}
+			}
+		}
+		response.Failed = len(response.Results)
+		return c.JSON(http.StatusUnprocessableEntity, response)
+	}
+
+	var written []Expense
+	for _, result := range response.Results {
+		if result.Status < 300 {
+			response.Succeeded++
+			if result.Expense != nil {
+				written = append(written, *result.Expense)
+			}
+		}
+	}
+	handler.evaluateAlerts(c, written...)
+
+	return c.JSON(http.StatusOK, response)
+}
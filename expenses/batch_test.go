@@ -0,0 +1,222 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBatchHandler() Handler {
+	store := newTestStore()
+	for _, title := range []string{"rice", "bus"} {
+		store.Create(context.Background(), &Expense{Title: title, Amount: 5000, Currency: "THB"})
+	}
+	return Handler{Store: store}
+}
+
+func batchExpenses(t *testing.T, handler Handler, body string) (BatchResponse, int) {
+	c, rec := newIDContext(http.MethodPost, "/expenses/batch", body, "")
+	handler.BatchExpenses(c)
+
+	var response BatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response, rec.Code
+}
+
+func statuses(response BatchResponse) []int {
+	var statuses []int
+	for _, result := range response.Results {
+		statuses = append(statuses, result.Status)
+	}
+	return statuses
+}
+
+func TestBatchExpensesAtomic(t *testing.T) {
+	// Arrange
+	handler := newBatchHandler()
+	body := `{"operations": [
+		{"op": "create", "expense": {"title": "smoothie", "amount": 79, "tags": ["Food"]}},
+		{"op": "update", "id": 1, "expense": {"title": "fried rice", "amount": 60, "currency": "usd"}},
+		{"op": "delete", "id": 2},
+		{"op": "create", "expense": {"title": "coffee", "amount": 4.5}}
+	]}`
+
+	// Act
+	response, status := batchExpenses(t, handler, body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, BatchAtomic, response.Mode)
+	assert.Equal(t, 4, response.Succeeded)
+	assert.Equal(t, 0, response.Failed)
+	assert.Equal(t, []int{http.StatusCreated, http.StatusOK, http.StatusNoContent, http.StatusCreated}, statuses(response))
+	assert.Equal(t, 3, response.Results[0].ID)
+	assert.Equal(t, []string{"food"}, response.Results[0].Expense.Tags)
+	assert.Equal(t, "USD", response.Results[1].Expense.Currency)
+	assert.Equal(t, 2, response.Results[2].ID)
+	assert.Nil(t, response.Results[2].Expense)
+	assert.Equal(t, 4, response.Results[3].ID)
+
+	expenses, _ := handler.Store.List(context.Background(), ListQuery{Sort: "id", Limit: 10})
+	var titles []string
+	for _, expense := range expenses {
+		titles = append(titles, expense.Title)
+	}
+	assert.Equal(t, []string{"fried rice", "smoothie", "coffee"}, titles)
+}
+
+func TestBatchExpensesAtomicFailure(t *testing.T) {
+	for _, body := range []string{
+		// The store finds that expense 9 does not exist.
+		`{"operations": [{"op": "create", "expense": {"title": "smoothie", "amount": 79}},
+			{"op": "delete", "id": 9}, {"op": "delete", "id": 2}]}`,
+		// The operation is invalid, so the batch is not run.
+		`{"mode": "atomic", "operations": [{"op": "create", "expense": {"title": "smoothie", "amount": 79}},
+			{"op": "create", "expense": {"title": "bus", "amount": 10, "currency": "ABC"}}, {"op": "delete", "id": 2}]}`,
+	} {
+		// Arrange
+		handler := newBatchHandler()
+
+		// Act
+		response, status := batchExpenses(t, handler, body)
+
+		// Assert
+		assert.Equal(t, http.StatusUnprocessableEntity, status)
+		assert.Equal(t, 0, response.Succeeded)
+		assert.Equal(t, 3, response.Failed)
+		assert.Equal(t, http.StatusFailedDependency, response.Results[0].Status)
+		assert.Equal(t, 0, response.Results[0].ID)
+		assert.Nil(t, response.Results[0].Expense)
+		assert.Contains(t, []int{http.StatusNotFound, http.StatusBadRequest}, response.Results[1].Status)
+		assert.NotEmpty(t, response.Results[1].Error)
+		assert.Equal(t, http.StatusFailedDependency, response.Results[2].Status)
+
+		expenses, _ := handler.Store.List(context.Background(), ListQuery{Sort: "id", Limit: 10})
+		assert.Len(t, expenses, 2)
+
+		// The IDs of the rolled back expenses are not taken.
+		expense := Expense{Title: "tea", Amount: 100, Currency: "THB"}
+		handler.Store.Create(context.Background(), &expense)
+		assert.Equal(t, 3, expense.ID)
+	}
+}
+
+func TestBatchExpensesBestEffort(t *testing.T) {
+	// Arrange
+	handler := newBatchHandler()
+	body := `{"mode": "best_effort", "operations": [
+		{"op": "create", "expense": {"title": "smoothie", "amount": 79}},
+		{"op": "delete", "id": 9},
+		{"op": "update", "id": 1},
+		{"op": "archive", "id": 1},
+		{"op": "delete", "id": 2},
+		{"op": "update", "id": 2, "expense": {"title": "bus", "amount": 10}}
+	]}`
+
+	// Act
+	response, status := batchExpenses(t, handler, body)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 2, response.Succeeded)
+	assert.Equal(t, 4, response.Failed)
+	assert.Equal(t, []int{http.StatusCreated, http.StatusNotFound, http.StatusBadRequest, http.StatusBadRequest,
+		http.StatusNoContent, http.StatusNotFound}, statuses(response))
+	assert.Equal(t, "update needs an expense", response.Results[2].Error)
+
+	expenses, _ := handler.Store.List(context.Background(), ListQuery{Sort: "id", Limit: 10})
+	assert.Len(t, expenses, 2)
+}
+
+func TestBatchExpensesInvalid(t *testing.T) {
+	for i, body := range []string{
+		`{"operations": []}`,
+		`{"mode": "fast", "operations": [{"op": "delete", "id": 1}]}`,
+		`{"operations": [{"op": "delete", "id": "1"}]}`,
+		`{"operations": [` + strings.Repeat(`{"op": "delete", "id": 1},`, MaxBatchOperations) + `{"op": "delete", "id": 1}]}`,
+	} {
+		_, status := batchExpenses(t, newBatchHandler(), body)
+
+		assert.Equal(t, http.StatusBadRequest, status, i)
+	}
+}
+
+func TestPostgresStoreBatch(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO expenses (.+) VALUES \\(\\$1, \\$2, (.+)\\), \\(\\$1, \\$2, \\$9, (.+)\\) RETURNING").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "spent_at", "created_at", "updated_at"}).
+			AddRow(7, testTime, testTime, testTime).
+			AddRow(8, testTime, testTime, testTime))
+	mock.ExpectExec("UPDATE expenses SET deleted_at=now\\(\\) WHERE id=\\$1 AND workspace_id=\\$2 AND \\(owner_id=\\$3 OR \\$4\\) AND deleted_at IS NULL").
+		WithArgs(9, 0, 0, false).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	operations := []BatchOperation{
+		{Op: BatchCreate, Expense: &Expense{Title: "rice", Amount: 5000, Currency: "THB"}},
+		{Op: BatchDelete, ID: 9},
+		{Op: BatchCreate, Expense: &Expense{Title: "bus", Amount: 1500, Currency: "THB"}},
+	}
+
+	// Act
+	errs, err := NewPostgresStore(db).Batch(context.Background(), operations, true)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []error{nil, ErrNotFound, nil}, errs)
+	assert.Equal(t, 7, operations[0].Expense.ID)
+	assert.Equal(t, 8, operations[2].Expense.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStoreBatchBestEffort(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	updateErr := errors.New("deadlock detected")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO expenses (.+) VALUES \\(\\$1, \\$2, \\$3, (.+)\\) RETURNING").
+		WithArgs(0, 0, "bus", int64(1500), "", sqlmock.AnyArg(), "THB", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "spent_at", "created_at", "updated_at"}).
+			AddRow(7, testTime, testTime, testTime))
+	mock.ExpectExec("SAVEPOINT batch_operation").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("UPDATE expenses SET (.+) RETURNING").WillReturnError(updateErr)
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT batch_operation").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT batch_operation").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE expenses SET deleted_at=now\\(\\)").
+		WithArgs(9, 0, 0, false).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT batch_operation").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	operations := []BatchOperation{
+		{Op: BatchUpdate, ID: 3, Expense: &Expense{ID: 3, Title: "taxi", Amount: 9000, Currency: "THB"}},
+		{Op: BatchDelete, ID: 9},
+		{Op: BatchCreate, Expense: &Expense{Title: "bus", Amount: 1500, Currency: "THB"}},
+	}
+
+	// Act
+	errs, err := NewPostgresStore(db).Batch(context.Background(), operations, false)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []error{updateErr, nil, nil}, errs)
+	assert.Equal(t, 7, operations[2].Expense.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,412 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// Errors returned by a BudgetStore.
+var (
+	ErrBudgetNotFound = errors.New("budget not found")
+	ErrBudgetExists   = errors.New("a budget of that tag, period and currency already exists")
+)
+
+// budgetPeriods are the periods of a budget.
+var budgetPeriods = []string{"week", "month"}
+
+type (
+
+	// Budget is the amount which can be spent on a tag every week or month.
+	// With Rollover, the unused amount of a period is added to the next one.
+	Budget struct {
+		ID       int    `json:"id"`
+		Tag      string `json:"tag"`
+		Period   string `json:"period"`
+		Amount   Money  `json:"amount"`
+		Currency string `json:"currency"`
+		Rollover bool   `json:"rollover"`
+
+		// StartsOn is the first day of the first period of the budget,
+		// from which unused amounts roll over.
+		StartsOn string `json:"starts_on"`
+	}
+
+	// BudgetStatus is how much of a budget is left in the period containing
+	// the time of the request. Available is Amount and the unused amount
+	// rolled over from the previous periods.
+	BudgetStatus struct {
+		Budget
+
+		PeriodStart string  `json:"period_start"`
+		PeriodEnd   string  `json:"period_end"`
+		RolledOver  Money   `json:"rolled_over"`
+		Available   Money   `json:"available"`
+		Spent       Money   `json:"spent"`
+		Remaining   Money   `json:"remaining"`
+		Percent     float64 `json:"percent"`
+		Overspent   bool    `json:"overspent"`
+	}
+
+	// BudgetStore stores budgets. There is at most one budget of a tag,
+	// period and currency.
+	BudgetStore interface {
+		// ListBudgets returns every budget ordered by tag, period and currency.
+		ListBudgets(ctx context.Context) ([]Budget, error)
+
+		GetBudget(ctx context.Context, id int) (Budget, error)
+
+		// CreateBudget sets the ID of budget.
+		// It returns ErrBudgetExists when the tag already has a budget
+		// of that period and currency.
+		CreateBudget(ctx context.Context, budget *Budget) error
+
+		UpdateBudget(ctx context.Context, budget *Budget) error
+		DeleteBudget(ctx context.Context, id int) error
+	}
+)
+
+// MarshalJSON writes amount with the decimals of currency.
+func (budget Budget) MarshalJSON() ([]byte, error) {
+	type plain Budget
+	return replaceMembers(plain(budget), map[string]json.RawMessage{"amount": budget.Amount.jsonIn(budget.Currency)})
+}
+
+// UnmarshalJSON reads a budget whose amount has the decimals of its currency.
+func (budget *Budget) UnmarshalJSON(data []byte) error {
+
+	type plain Budget
+	fields := struct {
+		*plain
+		Amount json.RawMessage `json:"amount"`
+	}{plain: (*plain)(budget)}
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	return budget.Amount.unmarshalIn(fields.Amount, budget.Currency)
+}
+
+// budgetStatusFields are the fields BudgetStatus adds to Budget with their
+// amounts undecoded, since BudgetStatus would encode with the methods of Budget.
+type budgetStatusFields struct {
+	PeriodStart string          `json:"period_start"`
+	PeriodEnd   string          `json:"period_end"`
+	RolledOver  json.RawMessage `json:"rolled_over"`
+	Available   json.RawMessage `json:"available"`
+	Spent       json.RawMessage `json:"spent"`
+	Remaining   json.RawMessage `json:"remaining"`
+	Percent     float64         `json:"percent"`
+	Overspent   bool            `json:"overspent"`
+}
+
+// MarshalJSON writes the fields of the budget followed by those of the status,
+// with the amounts in the decimals of the currency.
+func (status BudgetStatus) MarshalJSON() ([]byte, error) {
+
+	budget, err := json.Marshal(status.Budget)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := json.Marshal(budgetStatusFields{
+		PeriodStart: status.PeriodStart,
+		PeriodEnd:   status.PeriodEnd,
+		RolledOver:  status.RolledOver.jsonIn(status.Currency),
+		Available:   status.Available.jsonIn(status.Currency),
+		Spent:       status.Spent.jsonIn(status.Currency),
+		Remaining:   status.Remaining.jsonIn(status.Currency),
+		Percent:     status.Percent,
+		Overspent:   status.Overspent,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Join the two objects.
+	return append(append(budget[:len(budget)-1], ','), fields[1:]...), nil
+}
+
+// UnmarshalJSON reads a budget status whose amounts have the decimals of its currency.
+func (status *BudgetStatus) UnmarshalJSON(data []byte) error {
+
+	if err := json.Unmarshal(data, &status.Budget); err != nil {
+		return err
+	}
+
+	var fields budgetStatusFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	status.PeriodStart, status.PeriodEnd = fields.PeriodStart, fields.PeriodEnd
+	status.Percent, status.Overspent = fields.Percent, fields.Overspent
+
+	for _, amount := range []struct {
+		data json.RawMessage
+		to   *Money
+	}{
+		{fields.RolledOver, &status.RolledOver},
+		{fields.Available, &status.Available},
+		{fields.Spent, &status.Spent},
+		{fields.Remaining, &status.Remaining},
+	} {
+		if err := amount.to.unmarshalIn(amount.data, status.Currency); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// normalizeBudget checks a budget of a request and fills its defaults.
+// StartsOn is moved to the start of its period, and is the current one by default.
+func normalizeBudget(budget *Budget, now time.Time) error {
+
+	if budget.Tag = NormalizeTag(budget.Tag); budget.Tag == "" {
+		return errors.New("tag is required")
+	}
+	if !contains(budgetPeriods, budget.Period) {
+		return errors.New("period must be week or month")
+	}
+	if budget.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+
+	var err error
+	if budget.Currency, err = NormalizeCurrency(budget.Currency); err != nil {
+		return err
+	}
+
+	start := now.In(DefaultTimezone)
+	if budget.StartsOn != "" {
+		if start, err = time.ParseInLocation(DateLayout, budget.StartsOn, DefaultTimezone); err != nil {
+			return errors.New("starts_on must be a date such as 2026-09-01")
+		}
+	}
+	budget.StartsOn = startOf(start, budget.Period).Format(DateLayout)
+
+	return nil
+}
+
+// bindBudget reads the budget of the request's body.
+func bindBudget(c echo.Context) (Budget, error) {
+
+	var budget Budget
+	if err := c.Bind(&budget); err != nil {
+		return budget, errors.New("cannot read request's body. " + err.Error())
+	}
+
+	return budget, normalizeBudget(&budget, time.Now())
+}
+
+// GetBudgets handles HTTP GET request to list the budgets.
+func (handler Handler) GetBudgets(c echo.Context) error {
+
+	budgets, err := handler.Budgets.ListBudgets(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot list budgets. " + err.Error()})
+	}
+
+	if budgets == nil {
+		budgets = []Budget{}
+	}
+
+	return c.JSON(http.StatusOK, budgets)
+}
+
+// GetBudgetByID handles HTTP GET request to get a budget by ID.
+func (handler Handler) GetBudgetByID(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	budget, err := handler.Budgets.GetBudget(c.Request().Context(), id)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, budget)
+	case ErrBudgetNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot get the budget. " + err.Error()})
+	}
+}
+
+// CreateBudget handles HTTP POST request to create a budget.
+func (handler Handler) CreateBudget(c echo.Context) error {
+
+	budget, err := bindBudget(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	err = handler.Budgets.CreateBudget(c.Request().Context(), &budget)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusCreated, budget)
+	case ErrBudgetExists:
+		return c.JSON(http.StatusConflict, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot create the budget. " + err.Error()})
+	}
+}
+
+// PutBudget handles HTTP PUT request to replace a budget by ID.
+func (handler Handler) PutBudget(c echo.Context) error {
+
+	budget, err := bindBudget(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	if budget.ID, err = parseID(c); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	err = handler.Budgets.UpdateBudget(c.Request().Context(), &budget)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, budget)
+	case ErrBudgetNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	case ErrBudgetExists:
+		return c.JSON(http.StatusConflict, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot update the budget. " + err.Error()})
+	}
+}
+
+// DeleteBudget handles HTTP DELETE request to delete a budget by ID.
+func (handler Handler) DeleteBudget(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	err = handler.Budgets.DeleteBudget(c.Request().Context(), id)
+
+	switch err {
+	case nil:
+		return c.NoContent(http.StatusNoContent)
+	case ErrBudgetNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot delete the budget. " + err.Error()})
+	}
+}
+
+// budgetStatus computes the status of budget in the period starting at current
+// from the totals spent in each period, keyed by the first day of the period.
+// Unused amounts of the periods from first roll over when the budget has Rollover.
+func budgetStatus(budget Budget, first, current time.Time, spent map[string]Money) BudgetStatus {
+
+	status := BudgetStatus{
+		Budget:      budget,
+		PeriodStart: current.Format(DateLayout),
+		PeriodEnd:   addUnits(current, budget.Period, 1).AddDate(0, 0, -1).Format(DateLayout),
+	}
+
+	if budget.Rollover {
+		for period := first; period.Before(current); period = addUnits(period, budget.Period, 1) {
+			unused := budget.Amount + status.RolledOver - spent[period.Format(DateLayout)]
+			status.RolledOver = 0
+			if unused > 0 {
+				status.RolledOver = unused
+			}
+		}
+	}
+
+	status.Available = budget.Amount + status.RolledOver
+	status.Spent = spent[status.PeriodStart]
+	status.Remaining = status.Available - status.Spent
+	status.Overspent = status.Remaining < 0
+	status.Percent = math.Round(float64(status.Spent)*1000/float64(status.Available)) / 10
+
+	return status
+}
+
+// GetBudgetStatus handles HTTP GET request to get how much is spent and left
+// of every budget in its current period. Periods are in the timezone of tz.
+// date chooses the period containing another day than today. Budgets are
+// shared, so the spending is that of every member of the workspace.
+func (handler Handler) GetBudgetStatus(c echo.Context) error {
+
+	location, err := ParseTimezone(c.QueryParam("tz"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	now := time.Now()
+	if date := c.QueryParam("date"); date != "" {
+		if now, err = ParseTime(date, location); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+		}
+	}
+
+	ctx := c.Request().Context()
+
+	budgets, err := handler.Budgets.ListBudgets(ctx)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot list budgets. " + err.Error()})
+	}
+
+	statuses := []BudgetStatus{}
+
+	for _, budget := range budgets {
+		current := startOf(now.In(location), budget.Period)
+		end := addUnits(current, budget.Period, 1).UTC()
+
+		first := current
+		if budget.Rollover {
+			startsOn, err := time.ParseInLocation(DateLayout, budget.StartsOn, location)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError,
+					ErrorResponse{"invalid start of a budget. " + err.Error()})
+			}
+			if startsOn = startOf(startsOn, budget.Period); startsOn.Before(current) {
+				first = startsOn
+			}
+		}
+		from := first.UTC()
+
+		groups, err := handler.Reports.Summarize(auth.WithAllUsers(ctx), SummaryQuery{
+			Filter:   ListQuery{Tags: []string{budget.Tag}, Spent: DateRange{From: &from, Before: &end}},
+			GroupBy:  []string{budget.Period},
+			Location: location,
+		})
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError,
+				ErrorResponse{"cannot compute the budget status. " + err.Error()})
+		}
+
+		spent := map[string]Money{}
+		for _, group := range groups {
+			if group.Currency == budget.Currency {
+				spent[group.Period] += group.Total
+			}
+		}
+
+		statuses = append(statuses, budgetStatus(budget, first, current, spent))
+	}
+
+	return c.JSON(http.StatusOK, statuses)
+}
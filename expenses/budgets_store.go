@@ -0,0 +1,281 @@
+package expenses
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"sync"
+
+	"github.com/PeemPeimn/assessment/auth"
+)
+
+const budgetColumns = "id, tag, period, amount, currency, rollover, to_char(starts_on, 'YYYY-MM-DD')"
+
+func scanBudget(row scanner) (Budget, error) {
+	var budget Budget
+	err := row.Scan(&budget.ID, &budget.Tag, &budget.Period, &budget.Amount, &budget.Currency,
+		&budget.Rollover, &budget.StartsOn)
+	return budget, err
+}
+
+// PostgresBudgetStore is a BudgetStore backed by the budgets table.
+// Every query is scoped to the workspace of auth.WorkspaceID.
+type PostgresBudgetStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresBudgetStore returns a PostgresBudgetStore using db.
+func NewPostgresBudgetStore(db *sql.DB) *PostgresBudgetStore {
+	return &PostgresBudgetStore{DB: db}
+}
+
+// budgetExists tells whether another budget than budget of the workspace has its tag, period and currency.
+func budgetExists(ctx context.Context, db queryer, budget *Budget) (bool, error) {
+
+	var exists bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM budgets
+			WHERE workspace_id = $1 AND tag = $2 AND period = $3 AND currency = $4 AND id <> $5
+		)
+	`, auth.WorkspaceID(ctx), budget.Tag, budget.Period, budget.Currency, budget.ID).Scan(&exists)
+
+	return exists, err
+}
+
+func (store *PostgresBudgetStore) ListBudgets(ctx context.Context) ([]Budget, error) {
+
+	rows, err := store.DB.QueryContext(ctx,
+		"SELECT "+budgetColumns+" FROM budgets WHERE workspace_id = $1 ORDER BY tag, period, currency",
+		auth.WorkspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []Budget
+
+	for rows.Next() {
+		budget, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	}
+
+	return budgets, rows.Err()
+}
+
+func (store *PostgresBudgetStore) GetBudget(ctx context.Context, id int) (Budget, error) {
+
+	budget, err := scanBudget(store.DB.QueryRowContext(ctx,
+		"SELECT "+budgetColumns+" FROM budgets WHERE id = $1 AND workspace_id = $2", id, auth.WorkspaceID(ctx)))
+	if err == sql.ErrNoRows {
+		return Budget{}, ErrBudgetNotFound
+	}
+
+	return budget, err
+}
+
+func (store *PostgresBudgetStore) CreateBudget(ctx context.Context, budget *Budget) error {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exists, err := budgetExists(ctx, tx, budget)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrBudgetExists
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO budgets (workspace_id, tag, period, amount, currency, rollover, starts_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, auth.WorkspaceID(ctx), budget.Tag, budget.Period, budget.Amount, budget.Currency, budget.Rollover, budget.StartsOn).Scan(&budget.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresBudgetStore) UpdateBudget(ctx context.Context, budget *Budget) error {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	exists, err := budgetExists(ctx, tx, budget)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrBudgetExists
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE budgets SET tag = $2, period = $3, amount = $4, currency = $5, rollover = $6, starts_on = $7
+		WHERE id = $1 AND workspace_id = $8
+	`, budget.ID, budget.Tag, budget.Period, budget.Amount, budget.Currency, budget.Rollover, budget.StartsOn,
+		auth.WorkspaceID(ctx))
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrBudgetNotFound
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresBudgetStore) DeleteBudget(ctx context.Context, id int) error {
+
+	result, err := store.DB.ExecContext(ctx,
+		"DELETE FROM budgets WHERE id = $1 AND workspace_id = $2", id, auth.WorkspaceID(ctx))
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrBudgetNotFound
+	}
+
+	return nil
+}
+
+// MemoryBudgetStore is a thread-safe BudgetStore keeping budgets in a slice.
+// Like PostgresBudgetStore, it only sees the budgets of the workspace of auth.WorkspaceID.
+type MemoryBudgetStore struct {
+	mu      sync.RWMutex
+	lastID  int
+	budgets []Budget
+
+	// workspaces are the IDs of the workspaces of the budgets.
+	workspaces map[int]int
+}
+
+// NewMemoryBudgetStore returns an empty MemoryBudgetStore.
+func NewMemoryBudgetStore() *MemoryBudgetStore {
+	return &MemoryBudgetStore{workspaces: map[int]int{}}
+}
+
+// find returns the index of the budget of id in the workspace, or -1.
+func (store *MemoryBudgetStore) find(workspace int, id int) int {
+	for i, budget := range store.budgets {
+		if budget.ID == id && store.workspaces[id] == workspace {
+			return i
+		}
+	}
+	return -1
+}
+
+// exists tells whether another budget than budget of the workspace has its tag, period and currency.
+func (store *MemoryBudgetStore) exists(workspace int, budget *Budget) bool {
+	for _, other := range store.budgets {
+		if store.workspaces[other.ID] == workspace && other.ID != budget.ID && other.Tag == budget.Tag &&
+			other.Period == budget.Period && other.Currency == budget.Currency {
+			return true
+		}
+	}
+	return false
+}
+
+func (store *MemoryBudgetStore) ListBudgets(ctx context.Context) ([]Budget, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var budgets []Budget
+	for _, budget := range store.budgets {
+		if store.workspaces[budget.ID] == auth.WorkspaceID(ctx) {
+			budgets = append(budgets, budget)
+		}
+	}
+	sort.Slice(budgets, func(i, j int) bool {
+		a, b := budgets[i], budgets[j]
+		if a.Tag != b.Tag {
+			return a.Tag < b.Tag
+		}
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		return a.Currency < b.Currency
+	})
+
+	return budgets, nil
+}
+
+func (store *MemoryBudgetStore) GetBudget(ctx context.Context, id int) (Budget, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	i := store.find(auth.WorkspaceID(ctx), id)
+	if i < 0 {
+		return Budget{}, ErrBudgetNotFound
+	}
+
+	return store.budgets[i], nil
+}
+
+func (store *MemoryBudgetStore) CreateBudget(ctx context.Context, budget *Budget) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.exists(auth.WorkspaceID(ctx), budget) {
+		return ErrBudgetExists
+	}
+
+	store.lastID++
+	budget.ID = store.lastID
+	store.budgets = append(store.budgets, *budget)
+	store.workspaces[budget.ID] = auth.WorkspaceID(ctx)
+
+	return nil
+}
+
+func (store *MemoryBudgetStore) UpdateBudget(ctx context.Context, budget *Budget) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.find(auth.WorkspaceID(ctx), budget.ID)
+	if i < 0 {
+		return ErrBudgetNotFound
+	}
+	if store.exists(auth.WorkspaceID(ctx), budget) {
+		return ErrBudgetExists
+	}
+
+	store.budgets[i] = *budget
+
+	return nil
+}
+
+func (store *MemoryBudgetStore) DeleteBudget(ctx context.Context, id int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	i := store.find(auth.WorkspaceID(ctx), id)
+	if i < 0 {
+		return ErrBudgetNotFound
+	}
+
+	store.budgets = append(store.budgets[:i], store.budgets[i+1:]...)
+	delete(store.workspaces, id)
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateBudget(t *testing.T) {
+	// Arrange
+	body := `{"tag": " Food ", "period": "month", "amount": 5000, "rollover": true, "starts_on": "2026-08-15"}`
+	handler := Handler{Budgets: NewMemoryBudgetStore()}
+
+	// Act
+	c, rec := newIDContext(http.MethodPost, "/budgets", body, "")
+	handler.CreateBudget(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"id": 1, "tag": "food", "period": "month", "amount": 5000, "currency": "THB",
+		"rollover": true, "starts_on": "2026-08-01"}`, rec.Body.String())
+
+	// The tag already has a monthly budget in THB.
+	c, rec = newIDContext(http.MethodPost, "/budgets", body, "")
+	handler.CreateBudget(c)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestBudgetCRUD(t *testing.T) {
+	// Arrange
+	store := NewMemoryBudgetStore()
+	handler := Handler{Budgets: store}
+	for _, budget := range []Budget{
+		{Tag: "food", Period: "month", Amount: 500000, Currency: "THB", StartsOn: "2026-09-01"},
+		{Tag: "bills", Period: "week", Amount: 100000, Currency: "THB", StartsOn: "2026-09-14"},
+	} {
+		store.CreateBudget(context.Background(), &budget)
+	}
+
+	// Act & Assert
+	c, rec := newIDContext(http.MethodPut, "/budgets/2",
+		`{"tag": "food", "period": "month", "amount": 10, "starts_on": "2026-09-01"}`, "2")
+	handler.PutBudget(c)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	c, rec = newIDContext(http.MethodPut, "/budgets/2",
+		`{"tag": "bills", "period": "month", "amount": 10, "currency": "usd", "starts_on": "2026-09-20"}`, "2")
+	handler.PutBudget(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id": 2, "tag": "bills", "period": "month", "amount": 10, "currency": "USD",
+		"rollover": false, "starts_on": "2026-09-01"}`, rec.Body.String())
+
+	c, rec = newIDContext(http.MethodGet, "/budgets", "", "")
+	handler.GetBudgets(c)
+	var budgets []Budget
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &budgets))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, budgets, 2)
+	assert.Equal(t, "bills", budgets[0].Tag)
+
+	c, rec = newIDContext(http.MethodDelete, "/budgets/1", "", "1")
+	handler.DeleteBudget(c)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	c, rec = newIDContext(http.MethodGet, "/budgets/1", "", "1")
+	handler.GetBudgetByID(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = newIDContext(http.MethodDelete, "/budgets/1", "", "1")
+	handler.DeleteBudget(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestCreateBudgetInvalid(t *testing.T) {
+	for _, body := range []string{
+		`{"period": "month", "amount": 10}`,
+		`{"tag": "food", "period": "year", "amount": 10}`,
+		`{"tag": "food", "period": "month", "amount": 0}`,
+		`{"tag": "food", "period": "month", "amount": 10, "currency": "ABC"}`,
+		`{"tag": "food", "period": "month", "amount": 10, "starts_on": "September"}`,
+		`{"tag": "food", "period": "month", "amount": "ten"}`,
+	} {
+		c, rec := newIDContext(http.MethodPost, "/budgets", body, "")
+		Handler{Budgets: NewMemoryBudgetStore()}.CreateBudget(c)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestGetBudgetStatus(t *testing.T) {
+	// Arrange
+	budgets := NewMemoryBudgetStore()
+	for _, budget := range []Budget{
+		{Tag: "food", Period: "month", Amount: 10000, Currency: "THB", Rollover: true, StartsOn: "2026-08-01"},
+		{Tag: "food", Period: "month", Amount: 1000, Currency: "USD", StartsOn: "2026-08-01"},
+		{Tag: "work", Period: "week", Amount: 5000, Currency: "THB", Rollover: true, StartsOn: "2026-09-07"},
+	} {
+		budgets.CreateBudget(context.Background(), &budget)
+	}
+	store := newSummaryStore()
+	handler := Handler{Store: store, Reports: store, Budgets: budgets}
+
+	// Act
+	c, rec := newIDContext(http.MethodGet, "/budgets/status?date=2026-09-20", "", "")
+	handler.GetBudgetStatus(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var got []BudgetStatus
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+
+	type row struct {
+		tag, currency, start, end               string
+		rolledOver, available, spent, remaining Money
+		percent                                 float64
+		overspent                               bool
+	}
+	var rows []row
+	for _, status := range got {
+		rows = append(rows, row{status.Tag, status.Currency, status.PeriodStart, status.PeriodEnd,
+			status.RolledOver, status.Available, status.Spent, status.Remaining, status.Percent, status.Overspent})
+	}
+
+	// The 21 left in August roll over to September, and the week of the 7th
+	// rolls over its 50 to the week of the 14th.
+	assert.Equal(t, []row{
+		{"food", "THB", "2026-09-01", "2026-09-30", 2100, 12100, 11000, 1100, 90.9, false},
+		{"food", "USD", "2026-09-01", "2026-09-30", 0, 1000, 450, 550, 45, false},
+		{"work", "THB", "2026-09-14", "2026-09-20", 5000, 10000, 6000, 4000, 60, false},
+	}, rows)
+
+	// In Bangkok, the smoothie of August was spent in September.
+	c, rec = newIDContext(http.MethodGet, "/budgets/status?date=2026-09-20&tz=Asia/Bangkok", "", "")
+	handler.GetBudgetStatus(c)
+	got = nil
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+
+	assert.Equal(t, Money(10000), got[0].RolledOver)
+	assert.Equal(t, Money(18900), got[0].Spent)
+	assert.Equal(t, Money(1100), got[0].Remaining)
+
+	// Without rollover, September is overspent.
+	budgets.UpdateBudget(context.Background(), &Budget{ID: 1, Tag: "food", Period: "month", Amount: 10000,
+		Currency: "THB", StartsOn: "2026-08-01"})
+	c, rec = newIDContext(http.MethodGet, "/budgets/status?date=2026-09-20", "", "")
+	handler.GetBudgetStatus(c)
+	got = nil
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+
+	assert.Equal(t, Money(0), got[0].RolledOver)
+	assert.Equal(t, Money(-1000), got[0].Remaining)
+	assert.Equal(t, 110.0, got[0].Percent)
+	assert.True(t, got[0].Overspent)
+}
+
+func TestBudgetStatusOfWorkspace(t *testing.T) {
+	// Arrange
+	budgets := NewMemoryBudgetStore()
+	store := NewMemoryStore()
+	handler := Handler{Store: store, Reports: store, Budgets: budgets}
+
+	ours := auth.WithWorkspace(auth.WithUser(context.Background(), 1), 1)
+	theirs := auth.WithWorkspace(auth.WithUser(context.Background(), 2), 1)
+	spentAt := time.Date(2026, 9, 10, 0, 0, 0, 0, time.UTC)
+	budgets.CreateBudget(ours, &Budget{Tag: "food", Period: "month", Amount: 10000, Currency: "THB", StartsOn: "2026-09-01"})
+	store.Create(ours, &Expense{Title: "rice", Amount: 3000, Currency: "THB", Tags: []string{"food"}, SpentAt: spentAt})
+	store.Create(theirs, &Expense{Title: "noodles", Amount: 4000, Currency: "THB", Tags: []string{"food"}, SpentAt: spentAt})
+
+	// Act
+	c, rec := newIDContext(http.MethodGet, "/budgets/status?date=2026-09-20", "", "")
+	asWorkspace(c, 1, 1, auth.Member)
+	handler.GetBudgetStatus(c)
+
+	// Assert
+	var got []BudgetStatus
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, got, 1)
+	assert.Equal(t, Money(7000), got[0].Spent)
+	assert.Equal(t, Money(3000), got[0].Remaining)
+}
+
+func TestBudgetStatusRollover(t *testing.T) {
+	budget := Budget{Tag: "food", Period: "month", Amount: 1000, Currency: "THB", Rollover: true}
+	first := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	current := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	// Overspending June does not take from July.
+	got := budgetStatus(budget, first, current, map[string]Money{
+		"2026-06-01": 1500, "2026-07-01": 400, "2026-08-01": 1100, "2026-09-01": 500,
+	})
+
+	assert.Equal(t, Money(500), got.RolledOver)
+	assert.Equal(t, Money(1500), got.Available)
+	assert.Equal(t, Money(1000), got.Remaining)
+	assert.Equal(t, 33.3, got.Percent)
+
+	budget.Rollover = false
+	got = budgetStatus(budget, current, current, map[string]Money{"2026-09-01": 500})
+
+	assert.Equal(t, Money(0), got.RolledOver)
+	assert.Equal(t, Money(500), got.Remaining)
+}
+
+func TestPostgresBudgetStoreCreateBudget(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS \\( SELECT 1 FROM budgets WHERE workspace_id = \\$1 AND tag = \\$2 AND period = \\$3 AND currency = \\$4 AND id <> \\$5 \\)").
+		WithArgs(0, "food", "month", "THB", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("INSERT INTO budgets \\(workspace_id, tag, period, amount, currency, rollover, starts_on\\)").
+		WithArgs(0, "food", "month", 500000, "THB", true, "2026-09-01").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(0, "food", "month", "THB", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	store := NewPostgresBudgetStore(db)
+
+	// Act
+	budget := Budget{Tag: "food", Period: "month", Amount: 500000, Currency: "THB", Rollover: true, StartsOn: "2026-09-01"}
+	err = store.CreateBudget(context.Background(), &budget)
+
+	duplicate := Budget{Tag: "food", Period: "month", Amount: 100, Currency: "THB", StartsOn: "2026-09-01"}
+	duplicateErr := store.CreateBudget(context.Background(), &duplicate)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, budget.ID)
+	assert.Equal(t, ErrBudgetExists, duplicateErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,12 +2,12 @@ package expenses
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
@@ -15,15 +15,6 @@ import (
 func TestCreateExpense(t *testing.T) {
 
 	// Arrange
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-
-	newsMockRows := sqlmock.NewRows([]string{"id"}).AddRow(1)
-
-	mock.ExpectQuery("INSERT INTO expenses .*").WithArgs().WillReturnRows(newsMockRows)
-
 	e := echo.New()
 
 	mockJson := []byte(`{
@@ -33,7 +24,7 @@ func TestCreateExpense(t *testing.T) {
 	  "tags": ["food", "beverage"]
 		}`)
 
-	expected := `{"id":1,"title":"smoothie","amount":79,"note":"abcd","tags":["food","beverage"]}`
+	expected := `{"id":1,"title":"smoothie","amount":79,"note":"abcd","tags":["food","beverage"],"currency":"THB",` + testTimes + `}`
 
 	req := httptest.NewRequest(http.MethodPost, "/expenses", bytes.NewBuffer(mockJson))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -42,7 +33,7 @@ func TestCreateExpense(t *testing.T) {
 	c.SetParamNames("id")
 	c.SetParamValues("1")
 
-	handler := Handler{DB: db}
+	handler := Handler{Store: newTestStore()}
 
 	// Act
 	handler.CreateExpense(c)
@@ -54,20 +45,11 @@ func TestCreateExpense(t *testing.T) {
 
 func TestCreateExpenseFailCase(t *testing.T) {
 	// Arrange
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-
-	newsMockRows := sqlmock.NewRows([]string{"id"}).AddRow(1)
-
-	mock.ExpectQuery("INSERT INTO expenses .*").WithArgs().WillReturnRows(newsMockRows)
-
 	e := echo.New()
 
 	mockJson := []byte(`{
 		"title": "smoothie",
-	  "amount": "79",
+	  "amount": "seventy-nine",
 	  "note": "abcd",
 	  "tags": ["food", "beverage"]
 		}`)
@@ -77,7 +59,7 @@ func TestCreateExpenseFailCase(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	handler := Handler{DB: db}
+	handler := Handler{Store: NewMemoryStore()}
 
 	// Act
 	handler.CreateExpense(c)
@@ -85,3 +67,56 @@ func TestCreateExpenseFailCase(t *testing.T) {
 	// Assert
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
+
+func TestWriteExpenseNULText(t *testing.T) {
+	// Arrange
+	handler := newBatchHandler()
+	body := `{"title": "rice\u0000", "amount": 50}`
+	writes := map[string]func() int{
+		"create": func() int {
+			c, rec := newIDContext(http.MethodPost, "/expenses", body, "")
+			handler.CreateExpense(c)
+			return rec.Code
+		},
+		"put": func() int {
+			c, rec := newIDContext(http.MethodPut, "/expenses/1", body, "1")
+			handler.PutExpense(c)
+			return rec.Code
+		},
+	}
+
+	for name, write := range writes {
+		// Act
+		code := write()
+
+		// Assert
+		assert.Equal(t, http.StatusBadRequest, code, name)
+	}
+
+	// Act
+	rec := patchExpense(handler.Store, MIMEMergePatch, `{"note": "\u0000"}`)
+
+	// Assert
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	// Act
+	response, code := batchExpenses(t, handler, `{"mode": "best_effort", "operations": [
+		{"op": "create", "expense": {"title": "rice", "amount": 50, "tags": ["food\u0000"]}}]}`)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []int{http.StatusBadRequest}, statuses(response))
+
+	// Act
+	result, importRec := importExpenses(t, handler, "/expenses/import", MIMETextCSV, "title,amount\n\"rice\x00\",50\n")
+
+	// Assert
+	assert.Equal(t, http.StatusUnprocessableEntity, importRec.Code)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, "title", result.Errors[0].Column)
+	}
+
+	expenses, _ := handler.Store.List(context.Background(), ListQuery{Sort: "id", Limit: 10})
+	assert.Equal(t, "rice", expenses[0].Title)
+	assert.Len(t, expenses, 2)
+}
@@ -0,0 +1,72 @@
+package expenses
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultCurrency is used for expenses created without a currency
+// and as the base currency when a request does not choose one.
+// The server sets it from the DEFAULT_CURRENCY environment variable.
+var DefaultCurrency = "THB"
+
+// currencies are the active ISO 4217 currency codes.
+var currencies = map[string]bool{}
+
+func init() {
+	for _, code := range strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB
+		BRL BSD BTN BWP BYN BZD CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP
+		DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF
+		IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT LAK
+		LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN
+		NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF
+		SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND
+		TOP TRY TTD TWD TZS UAH UGX USD UYU UZS VES VND VUV WST XAF XCD XOF XPF YER
+		ZAR ZMW ZWL`) {
+		currencies[code] = true
+	}
+}
+
+// defaultExponent is the exponent of most currencies, whose minor unit is a hundredth.
+const defaultExponent = 2
+
+// exponents are the ISO 4217 exponents of the currencies whose
+// minor unit is not a hundredth of the major unit.
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Exponent returns the number of decimals of a currency,
+// such as 2 for THB, 0 for JPY and 3 for KWD.
+// An empty code is DefaultCurrency.
+func Exponent(code string) int {
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		code = DefaultCurrency
+	}
+
+	if exponent, ok := exponents[code]; ok {
+		return exponent
+	}
+	return defaultExponent
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 code and checks that it exists.
+// An empty code becomes DefaultCurrency.
+func NormalizeCurrency(code string) (string, error) {
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return DefaultCurrency, nil
+	}
+
+	if !currencies[code] {
+		return "", fmt.Errorf("unknown currency %q", code)
+	}
+
+	return code, nil
+}
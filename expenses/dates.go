@@ -0,0 +1,194 @@
+package expenses
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultTimezone is the timezone of dates without a time
+// and of periods when a request does not choose one.
+// The server sets it from the DEFAULT_TIMEZONE environment variable.
+var DefaultTimezone = time.UTC
+
+// timestampLayout formats times in UTC with a fixed number of digits,
+// so formatted times sort as strings. Postgres keeps microseconds.
+const timestampLayout = "2006-01-02T15:04:05.000000Z"
+
+// periods are the names of periods read by PeriodRange.
+var periods = []string{
+	"today", "yesterday", "this_week", "last_week",
+	"this_month", "last_month", "this_year", "last_year",
+}
+
+// DateRange selects times from From until before Before.
+// A nil bound is open.
+type DateRange struct {
+	From   *time.Time
+	Before *time.Time
+}
+
+// Contains reports whether t is in the range.
+func (r DateRange) Contains(t time.Time) bool {
+	return (r.From == nil || !t.Before(*r.From)) && (r.Before == nil || t.Before(*r.Before))
+}
+
+// ParseTimezone loads an IANA timezone such as Asia/Bangkok.
+// An empty name is DefaultTimezone.
+func ParseTimezone(name string) (*time.Location, error) {
+
+	if name == "" {
+		return DefaultTimezone, nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+
+	return location, nil
+}
+
+// ParseTime reads an RFC 3339 time, or a date which is midnight in location,
+// and returns it in UTC with microseconds.
+func ParseTime(value string, location *time.Location) (time.Time, error) {
+
+	value = strings.TrimSpace(value)
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		t, err = time.ParseInLocation(DateLayout, value, location)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 or YYYY-MM-DD", value)
+	}
+
+	return t.UTC().Truncate(time.Microsecond), nil
+}
+
+// startOf returns the start of the day, week (from Monday), month or year
+// of t in the location of t.
+func startOf(t time.Time, unit string) time.Time {
+
+	year, month, day := t.Date()
+
+	switch unit {
+	case "week":
+		day -= (int(t.Weekday()) + 6) % 7
+	case "month":
+		day = 1
+	case "year":
+		month, day = time.January, 1
+	}
+
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// addUnits adds n days, weeks, months or years to t.
+// t should be the start of a unit so months do not overflow.
+func addUnits(t time.Time, unit string, n int) time.Time {
+	switch unit {
+	case "week":
+		return t.AddDate(0, 0, 7*n)
+	case "month":
+		return t.AddDate(0, n, 0)
+	case "year":
+		return t.AddDate(n, 0, 0)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+// PeriodRange returns the range of a period such as this_month or last_week
+// containing now, or the period before it, in location.
+// Weeks start on Monday.
+func PeriodRange(name string, now time.Time, location *time.Location) (DateRange, error) {
+
+	if !contains(periods, name) {
+		return DateRange{}, fmt.Errorf("unknown period %q, use one of %s", name, strings.Join(periods, ", "))
+	}
+
+	unit := name[strings.Index(name, "_")+1:]
+	back := 0
+	switch name {
+	case "today":
+		unit = "day"
+	case "yesterday":
+		unit, back = "day", -1
+	default:
+		if strings.HasPrefix(name, "last_") {
+			back = -1
+		}
+	}
+
+	start := addUnits(startOf(now.In(location), unit), unit, back)
+	from, before := start.UTC(), addUnits(start, unit, 1).UTC()
+
+	return DateRange{From: &from, Before: &before}, nil
+}
+
+// dateRange returns the range of a year (2026), a month (2026-09) or a day
+// (2026-09-15) in location.
+func dateRange(value string, location *time.Location) (DateRange, error) {
+
+	for _, layout := range []struct{ layout, unit string }{
+		{DateLayout, "day"}, {"2006-01", "month"}, {"2006", "year"},
+	} {
+		if start, err := time.ParseInLocation(layout.layout, value, location); err == nil {
+			from, before := start.UTC(), addUnits(start, layout.unit, 1).UTC()
+			return DateRange{From: &from, Before: &before}, nil
+		}
+	}
+
+	return DateRange{}, errors.New("invalid date, use YYYY, YYYY-MM or YYYY-MM-DD")
+}
+
+// ParseDateRange reads the query parameters selecting expenses by their spent_at:
+// period (see PeriodRange), or from and to, which are RFC 3339 times or dates.
+// to excludes its time, and a date includes its whole day.
+// Dates and periods are in the IANA timezone of tz, DefaultTimezone by default,
+// which is also returned.
+func ParseDateRange(c echo.Context) (DateRange, *time.Location, error) {
+
+	var r DateRange
+
+	location, err := ParseTimezone(c.QueryParam("tz"))
+	if err != nil {
+		return r, nil, err
+	}
+
+	if period := c.QueryParam("period"); period != "" {
+		if c.QueryParam("from") != "" || c.QueryParam("to") != "" {
+			return r, nil, errors.New("period cannot be used with from or to")
+		}
+		r, err = PeriodRange(period, time.Now(), location)
+		return r, location, err
+	}
+
+	if from := c.QueryParam("from"); from != "" {
+		t, err := ParseTime(from, location)
+		if err != nil {
+			return r, nil, errors.New("invalid from. " + err.Error())
+		}
+		r.From = &t
+	}
+
+	if to := c.QueryParam("to"); to != "" {
+		t, err := ParseTime(to, location)
+		if err != nil {
+			return r, nil, errors.New("invalid to. " + err.Error())
+		}
+		if _, err := time.Parse(DateLayout, strings.TrimSpace(to)); err == nil {
+			t = t.In(location).AddDate(0, 0, 1).UTC()
+		}
+		r.Before = &t
+	}
+
+	if r.From != nil && r.Before != nil && !r.From.Before(*r.Before) {
+		return r, nil, errors.New("from must be before to")
+	}
+
+	return r, location, nil
+}
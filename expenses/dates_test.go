@@ -0,0 +1,143 @@
+package expenses
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	bangkok, _ := ParseTimezone("Asia/Bangkok")
+
+	got, err := ParseTime("2026-09-15T12:30:00.1234567+07:00", time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 9, 15, 5, 30, 0, 123456000, time.UTC), got)
+
+	got, err = ParseTime("2026-09-15", bangkok)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 9, 14, 17, 0, 0, 0, time.UTC), got)
+
+	_, err = ParseTime("15/09/2026", time.UTC)
+	assert.Error(t, err)
+
+	_, err = ParseTimezone("Asia/Atlantis")
+	assert.Error(t, err)
+}
+
+func TestPeriodRange(t *testing.T) {
+	bangkok, _ := ParseTimezone("Asia/Bangkok")
+
+	// Thursday the 1st of October in UTC is already Friday the 2nd in Bangkok.
+	now := time.Date(2026, 10, 1, 18, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		period   string
+		location *time.Location
+		from     time.Time
+		before   time.Time
+	}{
+		{"today", time.UTC, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC)},
+		{"today", bangkok, time.Date(2026, 10, 1, 17, 0, 0, 0, time.UTC), time.Date(2026, 10, 2, 17, 0, 0, 0, time.UTC)},
+		{"yesterday", time.UTC, time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"this_week", time.UTC, time.Date(2026, 9, 28, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)},
+		{"last_week", time.UTC, time.Date(2026, 9, 21, 0, 0, 0, 0, time.UTC), time.Date(2026, 9, 28, 0, 0, 0, 0, time.UTC)},
+		{"this_month", time.UTC, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"this_month", bangkok, time.Date(2026, 9, 30, 17, 0, 0, 0, time.UTC), time.Date(2026, 10, 31, 17, 0, 0, 0, time.UTC)},
+		{"last_month", time.UTC, time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"last_year", time.UTC, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range cases {
+		got, err := PeriodRange(test.period, now, test.location)
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.from, *got.From, test.period)
+		assert.Equal(t, test.before, *got.Before, test.period)
+	}
+
+	_, err := PeriodRange("next_month", now, time.UTC)
+	assert.Error(t, err)
+}
+
+func TestCreateExpenseSpentAt(t *testing.T) {
+	// Arrange
+	body := []byte(`{"title": "smoothie", "amount": 79, "spent_at": "2026-09-15"}`)
+	req := httptest.NewRequest(http.MethodPost, "/expenses", bytes.NewBuffer(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	store := newTestStore()
+	handler := Handler{Store: store}
+
+	// Act
+	handler.CreateExpense(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"spent_at":"2026-09-15T00:00:00Z","created_at":"2026-09-15T05:30:00Z"`)
+
+	// A PUT without spent_at keeps it.
+	expense := Expense{ID: 1, Title: "latte"}
+	assert.NoError(t, store.Update(context.Background(), &expense))
+	assert.Equal(t, time.Date(2026, 9, 15, 0, 0, 0, 0, time.UTC), expense.SpentAt)
+}
+
+func TestCreateExpenseInvalidSpentAt(t *testing.T) {
+	body := []byte(`{"title": "smoothie", "amount": 79, "spent_at": "yesterday"}`)
+	req := httptest.NewRequest(http.MethodPost, "/expenses", bytes.NewBuffer(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	handler := Handler{Store: NewMemoryStore()}
+	handler.CreateExpense(c)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetAllExpensesSpentRange(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	for _, spentAt := range []string{
+		"2026-08-31T23:00:00Z", // the 1st of September in Bangkok
+		"2026-09-15T05:30:00Z",
+		"2026-09-30T18:00:00Z", // the 1st of October in Bangkok
+		"2026-10-02T00:00:00Z",
+	} {
+		spent, _ := time.Parse(time.RFC3339, spentAt)
+		store.Create(context.Background(), &Expense{Title: "latte", SpentAt: spent})
+	}
+
+	cases := map[string][]int{
+		"from=2026-09-01&to=2026-09-30":                         {2, 3},
+		"from=2026-09-01&to=2026-09-30&tz=Asia/Bangkok":         {1, 2},
+		"from=2026-09-15T05:30:00Z&to=2026-10-02T00:00:00Z":     {2, 3},
+		"q=" + url.QueryEscape("date:2026-09 -date:2026-09-15"): {3},
+		"q=date>2026-09&tz=Asia/Bangkok":                        {3, 4},
+		"sort=-spent_at&limit=2":                                {4, 3},
+	}
+
+	for query, expected := range cases {
+		ids, rec := listExpenses(t, store, "/expenses?"+query)
+
+		assert.Equal(t, http.StatusOK, rec.Code, query)
+		assert.Equal(t, expected, ids, query)
+	}
+
+	for _, query := range []string{
+		"tz=Mars/Olympus", "from=yesterday", "from=2026-10-01&to=2026-09-01",
+		"period=next_week", "period=this_month&from=2026-09-01",
+	} {
+		_, rec := listExpenses(t, store, "/expenses?"+query)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
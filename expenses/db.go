@@ -1,12 +1,15 @@
 package expenses
 
 import (
+	"context"
 	"database/sql"
 	"log"
 
+	"github.com/PeemPeimn/assessment/migrations"
 	_ "github.com/lib/pq"
 )
 
+// InitDB connects to the database and applies pending migrations.
 func InitDB(url string) *sql.DB {
 
 	db, err := sql.Open("postgres", url)
@@ -15,19 +18,19 @@ func InitDB(url string) *sql.DB {
 		log.Fatal("Cannot connect to the database.", err)
 	}
 
-	createStatement := `
-	CREATE TABLE IF NOT EXISTS expenses (
-		id SERIAL PRIMARY KEY,
-		title TEXT,
-		amount FLOAT,
-		note TEXT,
-		tags TEXT[]
-	);
-	`
-	_, err = db.Exec(createStatement)
+	all, err := migrations.Default()
+	if err != nil {
+		log.Fatal("Cannot load the migrations.", err)
+	}
+
+	applied, err := migrations.NewMigrator(db, all).Up(context.Background())
 
 	if err != nil {
-		log.Fatal("Cannot create the table.", err)
+		log.Fatal("Cannot migrate the database.", err)
+	}
+
+	for _, migration := range applied {
+		log.Printf("applied migration %04d_%s.", migration.Version, migration.Name)
 	}
 
 	return db
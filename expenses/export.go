@@ -0,0 +1,241 @@
+package expenses
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MIMETextCSV is the media type of CSV exports.
+const MIMETextCSV = "text/csv"
+
+// exportColumns are the columns of a CSV export, in their default order.
+var exportColumns = []string{"id", "spent_at", "title", "amount", "currency", "tags", "note", "created_at", "updated_at"}
+
+// exportFlushRows is the number of rows written between flushes of the response.
+const exportFlushRows = 100
+
+// CSVOptions chooses the format of a CSV export.
+type CSVOptions struct {
+	Columns   []string
+	Delimiter rune
+
+	// TagSeparator joins the tags of an expense in the tags column.
+	TagSeparator string
+
+	// BOM starts the file with a UTF-8 byte order mark,
+	// so Excel does not read it in a legacy encoding.
+	BOM bool
+
+	// Location is the timezone of the times.
+	Location *time.Location
+}
+
+// ParseCSVOptions reads the query parameters of ExportExpenses:
+// columns (such as id,title,amount), delimiter (a character or tab),
+// tag_separator (| by default) and bom (true or false).
+func ParseCSVOptions(c echo.Context, location *time.Location) (CSVOptions, error) {
+
+	options := CSVOptions{Columns: exportColumns, Delimiter: ',', TagSeparator: "|", Location: location}
+
+	if columns := c.QueryParam("columns"); columns != "" {
+		options.Columns = nil
+		for _, column := range strings.Split(columns, ",") {
+			column = strings.TrimSpace(column)
+			if !contains(exportColumns, column) {
+				return options, fmt.Errorf("unknown column %q, use %s", column, strings.Join(exportColumns, ", "))
+			}
+			options.Columns = append(options.Columns, column)
+		}
+	}
+
+	if delimiter := c.QueryParam("delimiter"); delimiter != "" {
+		if delimiter == "tab" {
+			delimiter = "\t"
+		}
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+			return options, errors.New("delimiter must be one character other than a quote or a line break, or tab")
+		}
+		options.Delimiter = r
+	}
+
+	if separator, ok := c.QueryParams()["tag_separator"]; ok {
+		options.TagSeparator = separator[0]
+	}
+
+	if bom := c.QueryParam("bom"); bom != "" {
+		var err error
+		if options.BOM, err = strconv.ParseBool(bom); err != nil {
+			return options, errors.New("bom must be true or false")
+		}
+	}
+
+	return options, nil
+}
+
+// formulaPrefixes are the first characters which make a spreadsheet
+// read a cell as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeFormula prefixes text which starts like a formula with a quote,
+// so that spreadsheets show it as text rather than run it. Text starting
+// with a quote gets one more, so that unescapeFormula gives it back.
+func escapeFormula(text string) string {
+
+	if text != "" && strings.ContainsRune(formulaPrefixes+"'", rune(text[0])) {
+		return "'" + text
+	}
+
+	return text
+}
+
+// unescapeFormula removes the quote added by escapeFormula.
+func unescapeFormula(text string) string {
+
+	if len(text) > 1 && text[0] == '\'' && strings.ContainsRune(formulaPrefixes+"'", rune(text[1])) {
+		return text[1:]
+	}
+
+	return text
+}
+
+// record returns the fields of the columns of expense.
+// Text fields which start like a formula are escaped by escapeFormula.
+func (options CSVOptions) record(expense Expense) []string {
+
+	record := make([]string, len(options.Columns))
+
+	for i, column := range options.Columns {
+		switch column {
+		case "id":
+			record[i] = strconv.Itoa(expense.ID)
+		case "spent_at":
+			record[i] = expense.SpentAt.In(options.Location).Format(time.RFC3339)
+		case "title":
+			record[i] = escapeFormula(expense.Title)
+		case "amount":
+			record[i] = expense.Amount.Format(expense.Currency)
+		case "currency":
+			record[i] = expense.Currency
+		case "tags":
+			record[i] = escapeFormula(strings.Join(expense.Tags, options.TagSeparator))
+		case "note":
+			record[i] = escapeFormula(expense.Note)
+		case "created_at":
+			record[i] = expense.CreatedAt.In(options.Location).Format(time.RFC3339)
+		case "updated_at":
+			record[i] = expense.UpdatedAt.In(options.Location).Format(time.RFC3339)
+		}
+	}
+
+	return record
+}
+
+// acceptsCSV reports whether the Accept header of the request
+// prefers CSV to JSON, by the order of the media types.
+func acceptsCSV(c echo.Context) bool {
+
+	for _, accepted := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ",") {
+		mediaType, _, err := mime.ParseMediaType(accepted)
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case MIMETextCSV:
+			return true
+		case echo.MIMEApplicationJSON:
+			return false
+		}
+	}
+
+	return false
+}
+
+// ExportExpenses handles HTTP GET request to download the expenses matching
+// the filters of ParseFilters as CSV, in the order of sort. Rows are written
+// while they are read from the store. See ParseCSVOptions for the format.
+func (handler Handler) ExportExpenses(c echo.Context) error {
+
+	query, location, err := ParseFilters(c)
+	if err == nil {
+		query.Sort = "id"
+		err = parseSort(c, &query)
+	}
+
+	var syntaxError *SyntaxError
+	if errors.As(err, &syntaxError) {
+		return c.JSON(http.StatusBadRequest, syntaxError)
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	options, err := ParseCSVOptions(c, location)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	response := c.Response()
+	writer := csv.NewWriter(response)
+	writer.Comma = options.Delimiter
+
+	// The header row is written with the first expense, or after the last one
+	// when there is none, so an error before it can still be a JSON response.
+	rows := 0
+	start := func() error {
+		response.Header().Set(echo.HeaderContentType, MIMETextCSV+"; charset=utf-8")
+		response.Header().Set(echo.HeaderContentDisposition, `attachment; filename="expenses.csv"`)
+		response.WriteHeader(http.StatusOK)
+		if options.BOM {
+			if _, err := response.Write([]byte("\ufeff")); err != nil {
+				return err
+			}
+		}
+		return writer.Write(options.Columns)
+	}
+
+	err = handler.Store.Stream(c.Request().Context(), query, func(expense Expense) error {
+		if rows == 0 {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		rows++
+
+		if err := writer.Write(options.record(expense)); err != nil {
+			return err
+		}
+		if rows%exportFlushRows == 0 {
+			writer.Flush()
+			response.Flush()
+		}
+		return writer.Error()
+	})
+
+	if err != nil && rows == 0 {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot export expenses. " + err.Error()})
+	}
+	if err != nil {
+		// The status is already sent, so the error can only cut the file short.
+		return err
+	}
+
+	if rows == 0 {
+		if err := start(); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,168 @@
+package expenses
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newExportStore() *MemoryStore {
+	store := newTestStore()
+	for _, expense := range []Expense{
+		{Title: "smoothie", Amount: 7900, Currency: "THB", Tags: []string{"food", "beverage"},
+			Note: `night market, "big" cup`, SpentAt: time.Date(2026, 9, 14, 18, 0, 0, 0, time.UTC)},
+		{Title: "bus", Amount: 1550, Currency: "THB"},
+	} {
+		store.Create(context.Background(), &expense)
+	}
+	return store
+}
+
+func exportExpenses(store ExpenseStore, target string, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set(echo.HeaderAccept, accept)
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	handler := Handler{Store: store}
+	if accept != "" {
+		handler.GetAllExpenses(c)
+	} else {
+		handler.ExportExpenses(c)
+	}
+
+	return rec
+}
+
+func TestExportExpenses(t *testing.T) {
+	// Act
+	rec := exportExpenses(newExportStore(), "/expenses/export.csv", "")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `attachment; filename="expenses.csv"`, rec.Header().Get(echo.HeaderContentDisposition))
+	assert.Equal(t, "id,spent_at,title,amount,currency,tags,note,created_at,updated_at\n"+
+		`1,2026-09-14T18:00:00Z,smoothie,79.00,THB,food|beverage,"night market, ""big"" cup",2026-09-15T05:30:00Z,2026-09-15T05:30:00Z`+"\n"+
+		"2,2026-09-15T05:30:00Z,bus,15.50,THB,,,2026-09-15T05:30:00Z,2026-09-15T05:30:00Z\n",
+		rec.Body.String())
+}
+
+func TestExportExpensesOptions(t *testing.T) {
+	// Act
+	rec := exportExpenses(newExportStore(),
+		"/expenses/export.csv?columns=spent_at,title,tags,amount&delimiter=%3B&tag_separator=,%20&bom=true&tz=Asia/Bangkok&sort=-amount",
+		"")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "\ufeffspent_at;title;tags;amount\n"+
+		"2026-09-15T01:00:00+07:00;smoothie;food, beverage;79.00\n"+
+		"2026-09-15T12:30:00+07:00;bus;;15.50\n",
+		rec.Body.String())
+}
+
+func TestExportExpensesFormulas(t *testing.T) {
+	// Arrange
+	store := newTestStore()
+	for _, title := range []string{"=HYPERLINK(\"http://evil\")", "+1", "-2", "@SUM(A1)", "\tcmd", "\rcmd", "a=b"} {
+		store.Create(context.Background(), &Expense{Title: title, Amount: -1550, Currency: "THB", Tags: []string{"=tag"}})
+	}
+
+	// Act
+	rec := exportExpenses(store, "/expenses/export.csv?columns=title,amount,tags", "")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "title,amount,tags\n"+
+		`"'=HYPERLINK(""http://evil"")",-15.50,'=tag`+"\n"+
+		"'+1,-15.50,'=tag\n"+
+		"'-2,-15.50,'=tag\n"+
+		"'@SUM(A1),-15.50,'=tag\n"+
+		"'\tcmd,-15.50,'=tag\n"+
+		"\"'\rcmd\",-15.50,'=tag\n"+
+		"a=b,-15.50,'=tag\n",
+		rec.Body.String())
+}
+
+func TestExportExpensesEmpty(t *testing.T) {
+	rec := exportExpenses(newExportStore(), "/expenses/export.csv?columns=id,title&delimiter=tab&min_amount=100", "")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "id\ttitle\n", rec.Body.String())
+}
+
+func TestGetAllExpensesAcceptCSV(t *testing.T) {
+	cases := map[string]string{
+		"text/csv":                      "id,title\n2,bus\n",
+		"text/csv;q=0.9, */*":           "id,title\n2,bus\n",
+		"application/json, text/csv":    `[{"id":2`,
+		"text/html, application/json":   `[{"id":2`,
+		"text/plain, text/csv; q=0.5":   "id,title\n2,bus\n",
+		"application/json;charset=utf8": `[{"id":2`,
+	}
+
+	for accept, expected := range cases {
+		rec := exportExpenses(newExportStore(), "/expenses?columns=id,title&tags_match=any&title=bus", accept)
+
+		assert.Equal(t, http.StatusOK, rec.Code, accept)
+		assert.Contains(t, rec.Body.String(), expected, accept)
+	}
+}
+
+func TestExportExpensesErrors(t *testing.T) {
+	for _, query := range []string{
+		"columns=id,secret",
+		"delimiter=ab",
+		`delimiter="`,
+		"bom=maybe",
+		"sort=tags",
+		"q=amount>x",
+	} {
+		rec := exportExpenses(newExportStore(), "/expenses/export.csv?"+query, "")
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestPostgresStoreStream(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM expenses WHERE workspace_id = \\$1 AND \\(owner_id = \\$2 OR \\$3\\) AND deleted_at IS NULL ORDER BY spent_at DESC, id DESC").
+		WithArgs(0, 0, false).
+		WillReturnRows(expenseRows().
+			AddRow(2, "bus", 1550, "", "{}", "THB", testTime, testTime, testTime, nil).
+			AddRow(1, "smoothie", 7900, "", "{food}", "THB", testTime, testTime, testTime, nil).
+			AddRow(3, "rice", 5000, "", "{}", "THB", testTime, testTime, testTime, nil))
+
+	store := NewPostgresStore(db)
+	stop := errors.New("stop")
+
+	// Act
+	var ids []int
+	err = store.Stream(context.Background(), ListQuery{Sort: "spent_at", Descending: true}, func(expense Expense) error {
+		ids = append(ids, expense.ID)
+		if len(ids) == 2 {
+			return stop
+		}
+		return nil
+	})
+
+	// Assert
+	assert.Equal(t, stop, err)
+	assert.Equal(t, []int{2, 1}, ids)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
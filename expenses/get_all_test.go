@@ -1,33 +1,26 @@
 package expenses
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGetAllExpenses(t *testing.T) {
 	// Arrange
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
+	store := newTestStore()
+	store.Create(context.Background(),
+		&Expense{Title: "smoothie", Amount: 7900, Note: "unit_test", Tags: []string{"food", "beverage"}, Currency: "THB"})
+	store.Create(context.Background(),
+		&Expense{Title: "latte", Amount: 8800, Note: "unit_test", Tags: []string{"coffee", "drink"}, Currency: "THB"})
 
-	newsMockRows := sqlmock.
-		NewRows([]string{"id", "title", "amount", "note", "tags"}).
-		AddRow(1, "smoothie", 79, "unit_test", `{food,beverage}`).
-		AddRow(2, "latte", 88, "unit_test", `{coffee,drink}`)
-
-	expected := `[{"id":1,"title":"smoothie","amount":79,"note":"unit_test","tags":["food","beverage"]},{"id":2,"title":"latte","amount":88,"note":"unit_test","tags":["coffee","drink"]}]`
-
-	mock.ExpectPrepare("SELECT (.+) FROM expenses").
-		ExpectQuery().
-		WillReturnRows(newsMockRows)
+	expected := `[{"id":1,"title":"smoothie","amount":79,"note":"unit_test","tags":["food","beverage"],"currency":"THB",` + testTimes + `},` +
+		`{"id":2,"title":"latte","amount":88,"note":"unit_test","tags":["coffee","drink"],"currency":"THB",` + testTimes + `}]`
 
 	req := httptest.NewRequest(http.MethodGet, "/expenses", nil)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -36,7 +29,7 @@ func TestGetAllExpenses(t *testing.T) {
 	e := echo.New()
 	c := e.NewContext(req, rec)
 
-	handler := Handler{DB: db}
+	handler := Handler{Store: store}
 
 	// Act
 	handler.GetAllExpenses(c)
@@ -48,18 +41,6 @@ func TestGetAllExpenses(t *testing.T) {
 
 func TestGetAllExpensesEmpty(t *testing.T) {
 	// Arrange
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-
-	newsMockRows := sqlmock.
-		NewRows([]string{"id", "title", "amount", "note", "tags"})
-
-	mock.ExpectPrepare("SELECT (.+) FROM expenses").
-		ExpectQuery().
-		WillReturnRows(newsMockRows)
-
 	req := httptest.NewRequest(http.MethodGet, "/expenses", nil)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
@@ -67,7 +48,7 @@ func TestGetAllExpensesEmpty(t *testing.T) {
 	e := echo.New()
 	c := e.NewContext(req, rec)
 
-	handler := Handler{DB: db}
+	handler := Handler{Store: NewMemoryStore()}
 
 	// Act
 	handler.GetAllExpenses(c)
@@ -1,33 +1,23 @@
 package expenses
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGetExpenseByID(t *testing.T) {
 	// Arrange
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
+	store := newTestStore()
+	store.Create(context.Background(),
+		&Expense{Title: "smoothie", Amount: 7900, Note: "unit_test", Tags: []string{"food", "beverage"}, Currency: "THB"})
 
-	newsMockRows := sqlmock.
-		NewRows([]string{"id", "title", "amount", "note", "tags"}).
-		AddRow(1, "smoothie", 79, "unit_test", `{food,beverage}`)
-
-	expected := `{"id":1,"title":"smoothie","amount":79,"note":"unit_test","tags":["food","beverage"]}`
-
-	mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id=?").
-		ExpectQuery().
-		WithArgs("1").
-		WillReturnRows(newsMockRows)
+	expected := `{"id":1,"title":"smoothie","amount":79,"note":"unit_test","tags":["food","beverage"],"currency":"THB",` + testTimes + `}`
 
 	req := httptest.NewRequest(http.MethodGet, "/expenses/1", nil)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -38,7 +28,7 @@ func TestGetExpenseByID(t *testing.T) {
 	c.SetParamNames("id")
 	c.SetParamValues("1")
 
-	handler := Handler{DB: db}
+	handler := Handler{Store: store}
 
 	// Act
 	handler.GetExpenseByID(c)
@@ -50,19 +40,6 @@ func TestGetExpenseByID(t *testing.T) {
 
 func TestGetExpenseByIDNotFound(t *testing.T) {
 	// Arrange
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-
-	// Expect no rows
-	newsMockRows := sqlmock.NewRows([]string{"id", "title", "amount", "note", "tags"})
-
-	mock.ExpectPrepare("SELECT (.+) FROM expenses WHERE id=?").
-		ExpectQuery().
-		WithArgs("1").
-		WillReturnRows(newsMockRows)
-
 	req := httptest.NewRequest(http.MethodGet, "/expenses/1", nil)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
@@ -72,11 +49,30 @@ func TestGetExpenseByIDNotFound(t *testing.T) {
 	c.SetParamNames("id")
 	c.SetParamValues("1")
 
-	handler := Handler{DB: db}
+	handler := Handler{Store: NewMemoryStore()}
+
+	// Act
+	handler.GetExpenseByID(c)
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetExpenseByIDInvalidID(t *testing.T) {
+	// Arrange
+	req := httptest.NewRequest(http.MethodGet, "/expenses/abc", nil)
+	rec := httptest.NewRecorder()
+
+	e := echo.New()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("abc")
+
+	handler := Handler{Store: NewMemoryStore()}
 
 	// Act
 	handler.GetExpenseByID(c)
 
 	// Assert
-	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
@@ -1,20 +1,40 @@
 package expenses
 
 import (
-	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/PeemPeimn/assessment/auth"
 	"github.com/labstack/echo/v4"
-	"github.com/lib/pq"
 )
 
 type (
 
-	// Handler contains a DB connection
-	// and has handling method for requests.
+	// Handler contains the stores of expenses, exchange rates, tags, reports,
+	// budgets, alerts, import profiles, users, API keys and workspaces, the issuer of tokens
+	// and the identity provider of single sign-on, and has handling method for requests.
+	// Writes of expenses evaluate the alert rules when Alerts is set.
 	Handler struct {
-		DB *sql.DB
+		Store   ExpenseStore
+		Rates   RateStore
+		Tags    TagStore
+		Reports ReportStore
+		Budgets BudgetStore
+		Alerts  AlertStore
+
+		Profiles ImportProfileStore
+		Users    auth.UserStore
+		Keys     auth.KeyStore
+
+		Workspaces auth.WorkspaceStore
+
+		Tokens *auth.Tokens
+		OIDC   *auth.OIDC
 	}
 
 	// Expense is a struct used to represent an expense JSON response.
@@ -22,9 +42,33 @@ type (
 	Expense struct {
 		ID     int      `json:"id"`
 		Title  string   `json:"title"`
-		Amount int      `json:"amount"`
+		Amount Money    `json:"amount"`
 		Note   string   `json:"note"`
 		Tags   []string `json:"tags"`
+
+		// Currency is the ISO 4217 code of Amount.
+		Currency string `json:"currency"`
+
+		// SpentAt is when the money was spent, now when it is not given.
+		// CreatedAt and UpdatedAt are set by the store. All are in UTC.
+		SpentAt   time.Time `json:"spent_at"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+
+		// AmountInBase is Amount converted to BaseCurrency.
+		// It is only set when a request asks for a base currency.
+		AmountInBase *Money `json:"amount_in_base,omitempty"`
+		BaseCurrency string `json:"base_currency,omitempty"`
+
+		// DeletedAt is set while the expense is in the trash.
+		DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	}
+
+	// ExpensePage is the response of GetAllExpenses with envelope=true.
+	// NextCursor is the cursor of the next page, empty on the last page.
+	ExpensePage struct {
+		Items      []Expense `json:"items"`
+		NextCursor string    `json:"next_cursor,omitempty"`
 	}
 
 	// ErrorResponse represents an error in a JSON response.
@@ -33,6 +77,89 @@ type (
 	}
 )
 
+// MarshalJSON writes amount with the decimals of currency
+// and amount_in_base with those of base_currency.
+func (expense Expense) MarshalJSON() ([]byte, error) {
+
+	type plain Expense
+	amounts := map[string]json.RawMessage{"amount": expense.Amount.jsonIn(expense.Currency)}
+	if expense.AmountInBase != nil {
+		amounts["amount_in_base"] = expense.AmountInBase.jsonIn(expense.BaseCurrency)
+	}
+
+	return replaceMembers(plain(expense), amounts)
+}
+
+// UnmarshalJSON reads an expense whose amount has the decimals of its currency
+// and whose spent_at is an RFC 3339 time or a date, which is midnight in DefaultTimezone.
+func (expense *Expense) UnmarshalJSON(data []byte) error {
+
+	type plain Expense
+	fields := struct {
+		*plain
+		SpentAt      string          `json:"spent_at"`
+		Amount       json.RawMessage `json:"amount"`
+		AmountInBase json.RawMessage `json:"amount_in_base"`
+	}{plain: (*plain)(expense)}
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	if err := expense.Amount.unmarshalIn(fields.Amount, expense.Currency); err != nil {
+		return err
+	}
+	if fields.AmountInBase != nil && string(fields.AmountInBase) != "null" {
+		expense.AmountInBase = new(Money)
+		if err := expense.AmountInBase.unmarshalIn(fields.AmountInBase, expense.BaseCurrency); err != nil {
+			return err
+		}
+	}
+
+	expense.SpentAt = time.Time{}
+	if fields.SpentAt != "" {
+		spentAt, err := ParseTime(fields.SpentAt, DefaultTimezone)
+		if err != nil {
+			return errors.New("invalid spent_at. " + err.Error())
+		}
+		expense.SpentAt = spentAt
+	}
+
+	return nil
+}
+
+// invalidText returns the first field of expense among title, note and tags
+// which has a NUL character, which Postgres cannot store, or "" when none has.
+func invalidText(expense Expense) string {
+
+	switch {
+	case strings.ContainsRune(expense.Title, 0):
+		return "title"
+	case strings.ContainsRune(expense.Note, 0):
+		return "note"
+	}
+	for _, tag := range expense.Tags {
+		if strings.ContainsRune(tag, 0) {
+			return "tags"
+		}
+	}
+
+	return ""
+}
+
+// checkText returns an error when a text of expense has a NUL character.
+func checkText(expense Expense) error {
+	if field := invalidText(expense); field != "" {
+		return fmt.Errorf("%s cannot contain NUL characters", field)
+	}
+	return nil
+}
+
+// parseID reads the "id" path parameter as an integer.
+func parseID(c echo.Context) (int, error) {
+	return strconv.Atoi(c.Param("id"))
+}
+
 // CreateExpensesHandler handles HTTP POST request to create a new expense.
 // This function receives echo.Context as a parameter
 // and returns a JSON response with status code.
@@ -47,17 +174,21 @@ func (handler Handler) CreateExpense(c echo.Context) error {
 			ErrorResponse{Message: "cannot unmarshal request's body. " + err.Error()})
 	}
 
-	row := handler.DB.QueryRow(`
-		INSERT INTO expenses (title, amount, note, tags) 
-		values ($1, $2, $3, $4) 
-		RETURNING id
-	`, expense.Title, expense.Amount, expense.Note, pq.Array(expense.Tags))
+	expense.Currency, err = NormalizeCurrency(expense.Currency)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+	}
+	expense.Tags = NormalizeTags(expense.Tags)
+	if err := checkText(expense); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+	}
 
-	err = row.Scan(&expense.ID)
+	err = handler.Store.Create(c.Request().Context(), &expense)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError,
 			ErrorResponse{Message: "cannot create the expense. " + err.Error()})
 	}
+	handler.evaluateAlerts(c, expense)
 
 	return c.JSON(http.StatusCreated, expense)
 }
@@ -67,46 +198,26 @@ func (handler Handler) CreateExpense(c echo.Context) error {
 // and returns a JSON response with status code.
 func (handler Handler) GetExpenseByID(c echo.Context) error {
 
-	id := c.Param("id")
-
-	stmt, err := handler.DB.
-		Prepare("SELECT id, title, amount, note, tags FROM expenses WHERE id=$1")
-
+	id, err := parseID(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError,
-			ErrorResponse{"cannot prepare query statement. " + err.Error()})
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"invalid id. " + err.Error()})
 	}
 
-	row := stmt.QueryRow(id)
-
-	var expense Expense
-
-	// Postgres driver returns an array as []uint8
-	var tags []uint8
-
-	err = row.Scan(&expense.ID, &expense.Title, &expense.Amount, &expense.Note, &tags)
-
-	// Convert []uint8 to []string
-	// by converting []uint8 to bytes then
-	// trimming {}, lastly split by ","
-	tagsString := string([]byte(tags))
-	// log.Println(tagsString)
-
-	if tagsString == "{}" || tagsString == "" {
-		expense.Tags = nil
-	} else {
-		tagsString = strings.Trim(tagsString, "{}")
-		expense.Tags = strings.Split(tagsString, ",")
-	}
+	expense, err := handler.Store.Get(c.Request().Context(), id)
 
 	switch err {
 
-	case sql.ErrNoRows:
-		return c.JSON(http.StatusInternalServerError,
+	case ErrNotFound:
+		return c.JSON(http.StatusNotFound,
 			ErrorResponse{"cannot find the expense of that id. " + err.Error()})
 
 	case nil:
-		return c.JSON(http.StatusOK, expense)
+		list := []Expense{expense}
+		if err := handler.convertToBase(c, list); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+		}
+		return c.JSON(http.StatusOK, list[0])
 
 	default:
 		return c.JSON(http.StatusInternalServerError,
@@ -128,92 +239,105 @@ func (handler Handler) PutExpense(c echo.Context) error {
 			ErrorResponse{Message: "cannot read request's body. " + err.Error()})
 	}
 
-	id := c.Param("id")
-
-	stmt, err := handler.DB.Prepare(`
-		UPDATE expenses 
-		SET title=$2, amount=$3, note=$4, tags=$5  
-		WHERE id = $1
-		RETURNING id, title, amount, note, tags`)
-
+	expense.ID, err = parseID(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError,
-			ErrorResponse{Message: "cannot prepare update one user statement. " + err.Error()})
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{Message: "invalid id. " + err.Error()})
 	}
 
-	row := stmt.QueryRow(id, expense.Title, expense.Amount, expense.Note, pq.Array(expense.Tags))
-
-	// Postgres driver returns an array as []uint8
-	var tags []uint8
+	expense.Currency, err = NormalizeCurrency(expense.Currency)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+	}
+	expense.Tags = NormalizeTags(expense.Tags)
+	if err := checkText(expense); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{Message: err.Error()})
+	}
 
-	err = row.Scan(&expense.ID, &expense.Title, &expense.Amount, &expense.Note, &tags)
+	err = handler.Store.Update(c.Request().Context(), &expense)
+	if err == ErrNotFound {
+		return c.JSON(http.StatusNotFound,
+			ErrorResponse{Message: "cannot find the expense of that id. " + err.Error()})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError,
 			ErrorResponse{Message: "cannot update user. " + err.Error()})
 	}
-
-	tagsString := string([]byte(tags))
-
-	if tagsString == "{}" || tagsString == "" {
-		expense.Tags = nil
-	} else {
-		tagsString = strings.Trim(tagsString, "{}")
-		expense.Tags = strings.Split(tagsString, ",")
-	}
+	handler.evaluateAlerts(c, expense)
 
 	return c.JSON(http.StatusOK, expense)
 }
 
-// GetAllExpenses handles HTTP GET request to get all expenses.
+// GetAllExpenses handles HTTP GET request to get a page of expenses.
+// See ParseListQuery for the filters. When there are more expenses
+// the response has a Link header with rel="next" and an X-Next-Cursor header.
+// With envelope=true the expenses are the items of an ExpensePage,
+// which also has the next cursor. A request accepting text/csv rather than JSON gets every matching expense
+// from ExportExpenses instead.
 // This function receives echo.Context as a parameter
 // and returns a JSON response with status code
 func (handler Handler) GetAllExpenses(c echo.Context) error {
 
-	stmt, err := handler.DB.
-		Prepare("SELECT id, title, amount, note, tags FROM expenses")
+	if acceptsCSV(c) {
+		return handler.ExportExpenses(c)
+	}
+
+	query, err := ParseListQuery(c)
 
+	var syntaxError *SyntaxError
+	if errors.As(err, &syntaxError) {
+		return c.JSON(http.StatusBadRequest, syntaxError)
+	}
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError,
-			ErrorResponse{"cannot prepare query statement. " + err.Error()})
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
 	}
 
-	rows, err := stmt.Query()
+	var envelope bool
+	if value := c.QueryParam("envelope"); value != "" {
+		if envelope, err = strconv.ParseBool(value); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"envelope must be true or false"})
+		}
+	}
+
+	// Ask for one more expense to know whether there is a next page.
+	limit := query.Limit
+	query.Limit++
 
+	expenses, err := handler.Store.List(c.Request().Context(), query)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError,
 			ErrorResponse{"cannot query expenses. " + err.Error()})
 	}
 
-	var expenses []Expense
-
-	// Iterate all rows
-	for rows.Next() {
-		var expense Expense
-
-		// Postgres driver returns an array as []uint8
-		var tags []uint8
-
-		err = rows.Scan(&expense.ID, &expense.Title, &expense.Amount, &expense.Note, &tags)
-
-		// Convert []uint8 to []string
-		// by converting []uint8 to bytes then
-		// trimming {}, lastly split by ","
-		tagsString := string([]byte(tags))
+	var cursor string
+	if len(expenses) > limit {
+		expenses = expenses[:limit]
+		cursor = query.cursorOf(expenses[limit-1]).Encode()
+		setNextLink(c, cursor)
+	}
 
-		if tagsString == "{}" || tagsString == "" {
-			expense.Tags = nil
-		} else {
-			tagsString = strings.Trim(tagsString, "{}")
-			expense.Tags = strings.Split(tagsString, ",")
-		}
+	if err := handler.convertToBase(c, expenses); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
 
-		if err != nil {
-			return c.JSON(http.StatusInternalServerError,
-				ErrorResponse{"cannot scan result into variable. " + err.Error()})
+	if envelope {
+		if expenses == nil {
+			expenses = []Expense{}
 		}
-
-		expenses = append(expenses, expense)
+		return c.JSON(http.StatusOK, ExpensePage{Items: expenses, NextCursor: cursor})
 	}
 
 	return c.JSON(http.StatusOK, expenses)
 }
+
+// setNextLink sets the headers pointing to the page after cursor.
+func setNextLink(c echo.Context, cursor string) {
+
+	next := *c.Request().URL
+	params := next.Query()
+	params.Set("cursor", cursor)
+	next.RawQuery = params.Encode()
+
+	c.Response().Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	c.Response().Header().Set("X-Next-Cursor", cursor)
+}
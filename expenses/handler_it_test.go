@@ -4,25 +4,62 @@ package expenses
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"testing"
 
+	"github.com/PeemPeimn/assessment/auth"
 	"github.com/labstack/echo/v4"
 	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
-const url = "postgresql://root:root@db/it-db?sslmode=disable"
+const databaseURL = "postgresql://root:root@db/it-db?sslmode=disable"
+
+// itUser returns the ID of the user owning the expenses of the integration tests.
+func itUser(t *testing.T, db *sql.DB) int {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO users (name) VALUES ('integration')
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	`).Scan(&id)
+	if err != nil {
+		t.Fatal("cannot create the user of the tests. " + err.Error())
+	}
+	return id
+}
+
+// itWorkspace returns the ID of the workspace of the expenses of the
+// integration tests, of which the user of owner is a member.
+func itWorkspace(t *testing.T, db *sql.DB, owner int) int {
+	ctx := context.Background()
+	workspaces := auth.NewPostgresWorkspaceStore(db)
+
+	member, err := workspaces.FirstMember(ctx, owner)
+	if err == auth.ErrNotMember {
+		workspace := auth.Workspace{Name: "integration"}
+		err = workspaces.CreateWorkspace(ctx, &workspace, owner)
+		member.WorkspaceID = workspace.ID
+	}
+	if err != nil {
+		t.Fatal("cannot create the workspace of the tests. " + err.Error())
+	}
+	return member.WorkspaceID
+}
 
 func TestITCreateExpense(t *testing.T) {
 
 	// Arrange
-	db := InitDB(url)
+	db := InitDB(databaseURL)
 
-	handler := Handler{DB: db}
+	handler := Handler{Store: NewPostgresStore(db)}
+	owner := itUser(t, db)
+	workspace := itWorkspace(t, db, owner)
 
 	e := echo.New()
 
@@ -37,8 +74,9 @@ func TestITCreateExpense(t *testing.T) {
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	c.SetRequest(req.WithContext(auth.WithWorkspace(auth.WithUser(req.Context(), owner), workspace)))
 
-	expected := Expense{0, "latte", 99, "integration_create", []string{"coffee", "beverage"}}
+	expected := Expense{ID: 0, Title: "latte", Amount: 9900, Note: "integration_create", Tags: []string{"coffee", "beverage"}, Currency: "THB"}
 	got := Expense{}
 
 	// Act
@@ -61,23 +99,26 @@ func TestITCreateExpense(t *testing.T) {
 func TestITGetExpenseByID(t *testing.T) {
 
 	// Arrange
-	db := InitDB(url)
+	db := InitDB(databaseURL)
 
-	handler := Handler{DB: db}
+	handler := Handler{Store: NewPostgresStore(db)}
+	owner := itUser(t, db)
+	workspace := itWorkspace(t, db, owner)
 
 	req := httptest.NewRequest(http.MethodGet, "/expenses", nil)
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	e := echo.New()
 	c := e.NewContext(req, rec)
+	c.SetRequest(req.WithContext(auth.WithWorkspace(auth.WithUser(req.Context(), owner), workspace)))
 
-	mockExpense := Expense{0, "latte", 99, "integration_getID", []string{"coffee", "beverage"}}
+	mockExpense := Expense{ID: 0, Title: "latte", Amount: 9900, Note: "integration_getID", Tags: []string{"coffee", "beverage"}, Currency: "THB"}
 
-	row := handler.DB.QueryRow(`
-		INSERT INTO expenses (title, amount, note, tags) 
-		values ($1, $2, $3, $4) 
+	row := db.QueryRow(`
+		INSERT INTO expenses (workspace_id, owner_id, title, amount, note, tags)
+		values ($1, $2, $3, $4, $5, $6)
 		RETURNING id
-	`, mockExpense.Title, mockExpense.Amount, mockExpense.Note, pq.Array(mockExpense.Tags))
+	`, workspace, owner, mockExpense.Title, mockExpense.Amount, mockExpense.Note, pq.Array(mockExpense.Tags))
 
 	err := row.Scan(&mockExpense.ID)
 	if err != nil {
@@ -88,7 +129,7 @@ func TestITGetExpenseByID(t *testing.T) {
 	c.SetParamNames("id")
 	c.SetParamValues(strconv.Itoa(mockExpense.ID))
 
-	expected := Expense{mockExpense.ID, "latte", 99, "integration_getID", []string{"coffee", "beverage"}}
+	expected := Expense{ID: mockExpense.ID, Title: "latte", Amount: 9900, Note: "integration_getID", Tags: []string{"coffee", "beverage"}, Currency: "THB"}
 	got := Expense{}
 
 	// Act
@@ -111,9 +152,11 @@ func TestITGetExpenseByID(t *testing.T) {
 func TestITPutExpense(t *testing.T) {
 
 	// Arrange
-	db := InitDB(url)
+	db := InitDB(databaseURL)
 
-	handler := Handler{DB: db}
+	handler := Handler{Store: NewPostgresStore(db)}
+	owner := itUser(t, db)
+	workspace := itWorkspace(t, db, owner)
 
 	mockJson := []byte(`{
 		"title": "latte",
@@ -127,14 +170,15 @@ func TestITPutExpense(t *testing.T) {
 	rec := httptest.NewRecorder()
 	e := echo.New()
 	c := e.NewContext(req, rec)
+	c.SetRequest(req.WithContext(auth.WithWorkspace(auth.WithUser(req.Context(), owner), workspace)))
 
-	mockExpense := Expense{1, "mocha", 99, "mock_put", []string{"abcd", "efgh"}}
+	mockExpense := Expense{ID: 1, Title: "mocha", Amount: 9900, Note: "mock_put", Tags: []string{"abcd", "efgh"}, Currency: "THB"}
 
-	row := handler.DB.QueryRow(`
-		INSERT INTO expenses (title, amount, note, tags) 
-		values ($1, $2, $3, $4) 
+	row := db.QueryRow(`
+		INSERT INTO expenses (workspace_id, owner_id, title, amount, note, tags)
+		values ($1, $2, $3, $4, $5, $6)
 		RETURNING id
-	`, mockExpense.Title, mockExpense.Amount, mockExpense.Note, pq.Array(mockExpense.Tags))
+	`, workspace, owner, mockExpense.Title, mockExpense.Amount, mockExpense.Note, pq.Array(mockExpense.Tags))
 
 	err := row.Scan(&mockExpense.ID)
 	if err != nil {
@@ -145,7 +189,7 @@ func TestITPutExpense(t *testing.T) {
 	c.SetParamNames("id")
 	c.SetParamValues(strconv.Itoa(mockExpense.ID))
 
-	expected := Expense{mockExpense.ID, "latte", 99, "integration_put", []string{"coffee", "beverage"}}
+	expected := Expense{ID: mockExpense.ID, Title: "latte", Amount: 9900, Note: "integration_put", Tags: []string{"coffee", "beverage"}, Currency: "THB"}
 	got := Expense{}
 
 	// Act
@@ -167,11 +211,13 @@ func TestITPutExpense(t *testing.T) {
 func TestITGetAllExpenses(t *testing.T) {
 
 	// Arrange
-	db := InitDB(url)
+	db := InitDB(databaseURL)
 
-	handler := Handler{DB: db}
+	handler := Handler{Store: NewPostgresStore(db)}
+	owner := itUser(t, db)
+	workspace := itWorkspace(t, db, owner)
 
-	_, err := handler.DB.Exec("DELETE FROM expenses")
+	_, err := db.Exec("DELETE FROM expenses")
 	if err != nil {
 		t.Fatal("cannot clear database for testing. " + err.Error())
 	}
@@ -181,19 +227,20 @@ func TestITGetAllExpenses(t *testing.T) {
 	rec := httptest.NewRecorder()
 	e := echo.New()
 	c := e.NewContext(req, rec)
+	c.SetRequest(req.WithContext(auth.WithWorkspace(auth.WithUser(req.Context(), owner), workspace)))
 
 	mockExpenses := []Expense{
-		{0, "mocha", 99, "mock_get", []string{"abcd", "efgh"}},
-		{0, "latte", 88, "mock_get", []string{"ijkl", "mnop"}},
-		{0, "espresso", 77, "mock_get", []string{"qrst", "uvwx"}},
+		{ID: 0, Title: "mocha", Amount: 9900, Note: "mock_get", Tags: []string{"abcd", "efgh"}, Currency: "THB"},
+		{ID: 0, Title: "latte", Amount: 8800, Note: "mock_get", Tags: []string{"ijkl", "mnop"}, Currency: "THB"},
+		{ID: 0, Title: "espresso", Amount: 7700, Note: "mock_get", Tags: []string{"qrst", "uvwx"}, Currency: "THB"},
 	}
 
 	for i := range mockExpenses {
-		row := handler.DB.QueryRow(`
-			INSERT INTO expenses (title, amount, note, tags) 
-			values ($1, $2, $3, $4) 
+		row := db.QueryRow(`
+			INSERT INTO expenses (workspace_id, owner_id, title, amount, note, tags)
+			values ($1, $2, $3, $4, $5, $6)
 			RETURNING id
-		`, mockExpenses[i].Title, mockExpenses[i].Amount, mockExpenses[i].Note, pq.Array(mockExpenses[i].Tags))
+		`, workspace, owner, mockExpenses[i].Title, mockExpenses[i].Amount, mockExpenses[i].Note, pq.Array(mockExpenses[i].Tags))
 
 		err := row.Scan(&mockExpenses[i].ID)
 		if err != nil {
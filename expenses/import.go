@@ -0,0 +1,567 @@
+package expenses
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MaxImportRows is the maximum number of rows of an imported file.
+const MaxImportRows = 10000
+
+// ErrImportProfileNotFound is returned by an ImportProfileStore.
+var ErrImportProfileNotFound = errors.New("import profile not found")
+
+// dateTokens turn a date format such as DD/MM/YYYY into a layout of package time.
+// Longer tokens come first so YYYY is not read as two YY.
+var dateTokens = strings.NewReplacer(
+	"YYYY", "2006", "YY", "06", "MM", "01", "DD", "02", "HH", "15", "mm", "04", "ss", "05", "M", "1", "D", "2",
+)
+
+type (
+
+	// ImportColumns names the columns of the fields of an expense in an imported file,
+	// by their header or, in a file without header, by their position from 1.
+	// Only title and amount are required.
+	ImportColumns struct {
+		Title    string `json:"title"`
+		Amount   string `json:"amount"`
+		Date     string `json:"date,omitempty"`
+		Tags     string `json:"tags,omitempty"`
+		Note     string `json:"note,omitempty"`
+		Currency string `json:"currency,omitempty"`
+	}
+
+	// ImportProfile tells how to read the CSV files of a bank or spreadsheet.
+	ImportProfile struct {
+		Name    string        `json:"name"`
+		Columns ImportColumns `json:"columns"`
+
+		// SkipRows is the number of lines before the header,
+		// or before the first expense when NoHeader is set.
+		NoHeader  bool   `json:"no_header,omitempty"`
+		SkipRows  int    `json:"skip_rows,omitempty"`
+		Delimiter string `json:"delimiter,omitempty"`
+
+		// DateFormats are tried in order, such as DD/MM/YYYY or YYYY-MM-DD HH:mm.
+		// Without them, dates are RFC 3339 times or YYYY-MM-DD.
+		// Dates without offset are in Timezone, DefaultTimezone by default.
+		DateFormats []string `json:"date_formats,omitempty"`
+		Timezone    string   `json:"timezone,omitempty"`
+
+		// DecimalSeparator is . or , and the other one separates thousands.
+		DecimalSeparator string `json:"decimal_separator,omitempty"`
+
+		// Negate flips the sign of amounts, for statements listing spending as negative.
+		Negate bool `json:"negate,omitempty"`
+
+		TagSeparator string `json:"tag_separator,omitempty"`
+
+		// Currency is the currency of rows without a currency column,
+		// DefaultCurrency by default.
+		Currency string `json:"currency,omitempty"`
+
+		// lenient ignores the mapped columns missing from the header but title and amount.
+		lenient bool
+
+		// unescape removes the quote ExportExpenses adds to text starting like a formula.
+		unescape bool
+	}
+
+	// ImportRowError is an invalid row of an imported file.
+	// Row is the line of the row in the file.
+	ImportRowError struct {
+		Row     int    `json:"row"`
+		Column  string `json:"column,omitempty"`
+		Message string `json:"message"`
+	}
+
+	// ImportResult is the response of ImportExpenses.
+	ImportResult struct {
+		DryRun   bool             `json:"dry_run"`
+		Rows     int              `json:"rows"`
+		Imported int              `json:"imported"`
+		Errors   []ImportRowError `json:"errors"`
+		Expenses []Expense        `json:"expenses"`
+	}
+
+	// ImportProfileStore stores import profiles by name.
+	ImportProfileStore interface {
+		// ListImportProfiles returns every profile ordered by name.
+		ListImportProfiles(ctx context.Context) ([]ImportProfile, error)
+
+		GetImportProfile(ctx context.Context, name string) (ImportProfile, error)
+
+		// PutImportProfile creates or replaces the profile of profile.Name.
+		// It tells whether the profile was created.
+		PutImportProfile(ctx context.Context, profile ImportProfile) (bool, error)
+
+		DeleteImportProfile(ctx context.Context, name string) error
+	}
+)
+
+// DefaultImportProfile reads files with the header of ExportExpenses.
+func DefaultImportProfile() ImportProfile {
+	return ImportProfile{
+		Columns: ImportColumns{
+			Title: "title", Amount: "amount", Date: "spent_at", Tags: "tags", Note: "note", Currency: "currency",
+		},
+		Delimiter:        ",",
+		DecimalSeparator: ".",
+		TagSeparator:     "|",
+		lenient:          true,
+		unescape:         true,
+	}
+}
+
+// Normalize checks a profile and fills its defaults.
+func (profile *ImportProfile) Normalize() error {
+
+	profile.Name = strings.TrimSpace(profile.Name)
+	if profile.Name == "" {
+		return errors.New("name is required")
+	}
+	if profile.Columns.Title == "" || profile.Columns.Amount == "" {
+		return errors.New("the title and amount columns are required")
+	}
+	if profile.NoHeader {
+		for _, column := range profile.columns() {
+			if n, err := strconv.Atoi(column); column != "" && (err != nil || n < 1) {
+				return fmt.Errorf("column %q must be a position from 1 in a file without header", column)
+			}
+		}
+	}
+	if profile.SkipRows < 0 {
+		return errors.New("skip_rows cannot be negative")
+	}
+
+	if profile.Delimiter == "" {
+		profile.Delimiter = ","
+	}
+	if profile.Delimiter == "tab" {
+		profile.Delimiter = "\t"
+	}
+	if r, size := utf8.DecodeRuneInString(profile.Delimiter); size != len(profile.Delimiter) || r == '"' ||
+		r == '\r' || r == '\n' || r == utf8.RuneError {
+		return errors.New("delimiter must be one character other than a quote or a line break, or tab")
+	}
+
+	for _, format := range profile.DateFormats {
+		if !strings.Contains(format, "YY") || !strings.Contains(format, "M") || !strings.Contains(format, "D") {
+			return fmt.Errorf("date format %q must have YYYY or YY, MM or M and DD or D", format)
+		}
+	}
+	if _, err := ParseTimezone(profile.Timezone); err != nil {
+		return err
+	}
+
+	switch profile.DecimalSeparator {
+	case "":
+		profile.DecimalSeparator = "."
+	case ".", ",":
+	default:
+		return errors.New("decimal_separator must be . or ,")
+	}
+
+	if profile.TagSeparator == "" {
+		profile.TagSeparator = "|"
+	}
+
+	if profile.Currency != "" {
+		currency, err := NormalizeCurrency(profile.Currency)
+		if err != nil {
+			return err
+		}
+		profile.Currency = currency
+	}
+
+	return nil
+}
+
+// columns returns the mapped columns of the profile.
+func (profile ImportProfile) columns() []string {
+	c := profile.Columns
+	return []string{c.Title, c.Amount, c.Date, c.Tags, c.Note, c.Currency}
+}
+
+// parseAmount reads an amount of currency such as 1,234.50, 1.234,50 or (12.50).
+func (profile ImportProfile) parseAmount(value string, currency string) (Money, error) {
+
+	s := strings.TrimSpace(value)
+	negative := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+	if negative {
+		s = s[1 : len(s)-1]
+	}
+
+	thousands := ","
+	if profile.DecimalSeparator == "," {
+		thousands = "."
+	}
+	s = strings.NewReplacer(thousands, "", " ", "", "\u00a0", "", "'", "").Replace(s)
+	s = strings.Replace(s, profile.DecimalSeparator, ".", 1)
+
+	amount, err := ParseMoney(s, currency, DefaultRounding)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative != profile.Negate {
+		amount = -amount
+	}
+
+	return amount, nil
+}
+
+// parseDate reads a date with the formats of the profile.
+func (profile ImportProfile) parseDate(value string) (time.Time, error) {
+
+	location, _ := ParseTimezone(profile.Timezone)
+	if len(profile.DateFormats) == 0 {
+		return ParseTime(value, location)
+	}
+
+	value = strings.TrimSpace(value)
+	for _, format := range profile.DateFormats {
+		if t, err := time.ParseInLocation(dateTokens.Replace(format), value, location); err == nil {
+			return t.UTC().Truncate(time.Microsecond), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q, use %s", value, strings.Join(profile.DateFormats, " or "))
+}
+
+// ParseImport reads the expenses of a CSV file with profile into a result
+// listing the valid expenses and the errors of the invalid rows.
+// An error is returned when the file cannot be read with the profile at all.
+func ParseImport(r io.Reader, profile ImportProfile) (ImportResult, error) {
+
+	reader := csv.NewReader(r)
+	reader.Comma, _ = utf8.DecodeRuneInString(profile.Delimiter)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var result ImportResult
+
+	// read returns the next record, or nil at the end of the file.
+	read := func() ([]string, error) {
+		for {
+			record, err := reader.Read()
+			var parseError *csv.ParseError
+			if errors.As(err, &parseError) {
+				result.Rows++
+				result.Errors = append(result.Errors, ImportRowError{Row: parseError.Line, Message: parseError.Err.Error()})
+				continue
+			}
+			if err == io.EOF {
+				return nil, nil
+			}
+			return record, err
+		}
+	}
+
+	for i := 0; i < profile.SkipRows; i++ {
+		if record, err := read(); record == nil {
+			return ImportResult{}, fmt.Errorf("the file has fewer than %d rows to skip. %v", profile.SkipRows, err)
+		}
+	}
+
+	// index finds the position of each mapped column, or -1.
+	index := map[string]int{}
+	if profile.NoHeader {
+		for _, column := range profile.columns() {
+			n, _ := strconv.Atoi(column)
+			index[column] = n - 1
+		}
+	} else {
+		header, err := read()
+		if header == nil {
+			return ImportResult{}, fmt.Errorf("cannot read the header. %v", err)
+		}
+		positions := map[string]int{}
+		for i, name := range header {
+			if i == 0 {
+				name = strings.TrimPrefix(name, "\ufeff")
+			}
+			positions[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for _, column := range profile.columns() {
+			if column == "" {
+				continue
+			}
+			i, ok := positions[strings.ToLower(strings.TrimSpace(column))]
+			if !ok {
+				if !profile.lenient || column == profile.Columns.Title || column == profile.Columns.Amount {
+					return ImportResult{}, fmt.Errorf("the header has no %q column", column)
+				}
+				i = -1
+			}
+			index[column] = i
+		}
+	}
+
+	for {
+		record, err := read()
+		if err != nil {
+			return ImportResult{}, err
+		}
+		if record == nil {
+			break
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		if result.Rows++; result.Rows > MaxImportRows {
+			return ImportResult{}, fmt.Errorf("the file has more than %d rows", MaxImportRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		field := func(column string) string {
+			if i, ok := index[column]; ok && i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		fail := func(column string, message string) {
+			result.Errors = append(result.Errors, ImportRowError{Row: line, Column: column, Message: message})
+		}
+
+		text := func(column string) string {
+			if profile.unescape {
+				return unescapeFormula(field(column))
+			}
+			return field(column)
+		}
+
+		expense := Expense{
+			Title: text(profile.Columns.Title),
+			Note:  text(profile.Columns.Note),
+			Tags:  NormalizeTags(strings.Split(text(profile.Columns.Tags), profile.TagSeparator)),
+		}
+		valid := true
+
+		if expense.Title == "" {
+			fail(profile.Columns.Title, "title is required")
+			valid = false
+		}
+
+		currency := field(profile.Columns.Currency)
+		if currency == "" {
+			currency = profile.Currency
+		}
+
+		if expense.Amount, err = profile.parseAmount(field(profile.Columns.Amount), currency); err != nil {
+			fail(profile.Columns.Amount, err.Error())
+			valid = false
+		}
+
+		if date := field(profile.Columns.Date); date != "" {
+			if expense.SpentAt, err = profile.parseDate(date); err != nil {
+				fail(profile.Columns.Date, err.Error())
+				valid = false
+			}
+		}
+
+		if expense.Currency, err = NormalizeCurrency(currency); err != nil {
+			fail(profile.Columns.Currency, err.Error())
+			valid = false
+		}
+
+		if field := invalidText(expense); field != "" {
+			columns := map[string]string{"title": profile.Columns.Title, "note": profile.Columns.Note, "tags": profile.Columns.Tags}
+			fail(columns[field], field+" cannot contain NUL characters")
+			valid = false
+		}
+
+		if valid {
+			result.Expenses = append(result.Expenses, expense)
+		}
+	}
+
+	return result, nil
+}
+
+// importFile returns the file of an import request: the file field
+// of a multipart form or a body of one of mediaTypes.
+func importFile(c echo.Context, mediaTypes ...string) (io.ReadCloser, error) {
+
+	contentType := c.Request().Header.Get(echo.HeaderContentType)
+
+	if strings.HasPrefix(contentType, echo.MIMEMultipartForm) {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, errors.New("the form has no file. " + err.Error())
+		}
+		return header.Open()
+	}
+
+	for _, mediaType := range mediaTypes {
+		if strings.HasPrefix(contentType, mediaType) {
+			return c.Request().Body, nil
+		}
+	}
+
+	return nil, errors.New("upload the file as a multipart form or with Content-Type " + strings.Join(mediaTypes, " or "))
+}
+
+// parseDryRun reads the dry_run query parameter of an import.
+func parseDryRun(c echo.Context) (bool, error) {
+
+	value := c.QueryParam("dry_run")
+	if value == "" {
+		return false, nil
+	}
+
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.New("dry_run must be true or false")
+	}
+
+	return dryRun, nil
+}
+
+// ImportExpenses handles HTTP POST request to import expenses from a CSV file.
+// The profile query parameter chooses a saved ImportProfile, and without it
+// the file has the header of ExportExpenses. Either every row is imported,
+// or none is and the response lists the invalid rows. With dry_run=true
+// nothing is imported and the response has the expenses that would be.
+func (handler Handler) ImportExpenses(c echo.Context) error {
+
+	dryRun, err := parseDryRun(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	ctx := c.Request().Context()
+
+	profile := DefaultImportProfile()
+	if name := c.QueryParam("profile"); name != "" {
+		profile, err = handler.Profiles.GetImportProfile(ctx, name)
+		switch err {
+		case nil:
+		case ErrImportProfileNotFound:
+			return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError,
+				ErrorResponse{"cannot get the import profile. " + err.Error()})
+		}
+	}
+
+	file, err := importFile(c, MIMETextCSV)
+	if err != nil {
+		return c.JSON(http.StatusUnsupportedMediaType, ErrorResponse{err.Error()})
+	}
+	defer file.Close()
+
+	result, err := ParseImport(file, profile)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"cannot read the file. " + err.Error()})
+	}
+	result.DryRun = dryRun
+	if result.Errors == nil {
+		result.Errors = []ImportRowError{}
+	}
+	if result.Expenses == nil {
+		result.Expenses = []Expense{}
+	}
+
+	switch {
+	case dryRun:
+		return c.JSON(http.StatusOK, result)
+	case len(result.Errors) > 0:
+		result.Expenses = []Expense{}
+		return c.JSON(http.StatusUnprocessableEntity, result)
+	}
+
+	if err := handler.Store.CreateAll(ctx, result.Expenses); err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot import the expenses. " + err.Error()})
+	}
+	handler.evaluateAlerts(c, result.Expenses...)
+	result.Imported = len(result.Expenses)
+
+	return c.JSON(http.StatusCreated, result)
+}
+
+// GetImportProfiles handles HTTP GET request to list the import profiles.
+func (handler Handler) GetImportProfiles(c echo.Context) error {
+
+	profiles, err := handler.Profiles.ListImportProfiles(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot list import profiles. " + err.Error()})
+	}
+
+	if profiles == nil {
+		profiles = []ImportProfile{}
+	}
+
+	return c.JSON(http.StatusOK, profiles)
+}
+
+// GetImportProfile handles HTTP GET request to get an import profile by name.
+func (handler Handler) GetImportProfile(c echo.Context) error {
+
+	profile, err := handler.Profiles.GetImportProfile(c.Request().Context(), c.Param("name"))
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, profile)
+	case ErrImportProfileNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot get the import profile. " + err.Error()})
+	}
+}
+
+// PutImportProfile handles HTTP PUT request to create or replace
+// the import profile of a name.
+func (handler Handler) PutImportProfile(c echo.Context) error {
+
+	var profile ImportProfile
+	if err := c.Bind(&profile); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot read request's body. " + err.Error()})
+	}
+
+	profile.Name = c.Param("name")
+	if err := profile.Normalize(); err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	created, err := handler.Profiles.PutImportProfile(c.Request().Context(), profile)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot save the import profile. " + err.Error()})
+	}
+
+	if created {
+		return c.JSON(http.StatusCreated, profile)
+	}
+	return c.JSON(http.StatusOK, profile)
+}
+
+// DeleteImportProfile handles HTTP DELETE request to delete an import profile by name.
+func (handler Handler) DeleteImportProfile(c echo.Context) error {
+
+	err := handler.Profiles.DeleteImportProfile(c.Request().Context(), c.Param("name"))
+
+	switch err {
+	case nil:
+		return c.NoContent(http.StatusNoContent)
+	case ErrImportProfileNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot delete the import profile. " + err.Error()})
+	}
+}
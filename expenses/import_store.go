@@ -0,0 +1,168 @@
+package expenses
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/PeemPeimn/assessment/auth"
+)
+
+// PostgresImportProfileStore is an ImportProfileStore backed by the import_profiles table.
+// Every query is scoped to the workspace of auth.WorkspaceID.
+type PostgresImportProfileStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresImportProfileStore returns a PostgresImportProfileStore using db.
+func NewPostgresImportProfileStore(db *sql.DB) *PostgresImportProfileStore {
+	return &PostgresImportProfileStore{DB: db}
+}
+
+func scanImportProfile(row scanner) (ImportProfile, error) {
+	var profile ImportProfile
+	var document []byte
+	if err := row.Scan(&document); err != nil {
+		return profile, err
+	}
+	err := json.Unmarshal(document, &profile)
+	return profile, err
+}
+
+func (store *PostgresImportProfileStore) ListImportProfiles(ctx context.Context) ([]ImportProfile, error) {
+
+	rows, err := store.DB.QueryContext(ctx,
+		"SELECT profile FROM import_profiles WHERE workspace_id = $1 ORDER BY name", auth.WorkspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []ImportProfile
+
+	for rows.Next() {
+		profile, err := scanImportProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, rows.Err()
+}
+
+func (store *PostgresImportProfileStore) GetImportProfile(ctx context.Context, name string) (ImportProfile, error) {
+
+	profile, err := scanImportProfile(store.DB.QueryRowContext(ctx,
+		"SELECT profile FROM import_profiles WHERE workspace_id = $1 AND name = $2", auth.WorkspaceID(ctx), name))
+	if err == sql.ErrNoRows {
+		return ImportProfile{}, ErrImportProfileNotFound
+	}
+
+	return profile, err
+}
+
+func (store *PostgresImportProfileStore) PutImportProfile(ctx context.Context, profile ImportProfile) (bool, error) {
+
+	document, err := json.Marshal(profile)
+	if err != nil {
+		return false, err
+	}
+
+	// xmax is 0 for a row inserted rather than updated.
+	var created bool
+	err = store.DB.QueryRowContext(ctx, `
+		INSERT INTO import_profiles (workspace_id, name, profile) VALUES ($1, $2, $3)
+		ON CONFLICT (workspace_id, name) DO UPDATE SET profile = EXCLUDED.profile
+		RETURNING xmax = 0
+	`, auth.WorkspaceID(ctx), profile.Name, document).Scan(&created)
+
+	return created, err
+}
+
+func (store *PostgresImportProfileStore) DeleteImportProfile(ctx context.Context, name string) error {
+
+	result, err := store.DB.ExecContext(ctx,
+		"DELETE FROM import_profiles WHERE workspace_id = $1 AND name = $2", auth.WorkspaceID(ctx), name)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrImportProfileNotFound
+	}
+
+	return nil
+}
+
+// MemoryImportProfileStore is a thread-safe ImportProfileStore keeping the profiles
+// of each workspace in a map.
+type MemoryImportProfileStore struct {
+	mu       sync.RWMutex
+	profiles map[int]map[string]ImportProfile
+}
+
+// NewMemoryImportProfileStore returns an empty MemoryImportProfileStore.
+func NewMemoryImportProfileStore() *MemoryImportProfileStore {
+	return &MemoryImportProfileStore{profiles: map[int]map[string]ImportProfile{}}
+}
+
+func (store *MemoryImportProfileStore) ListImportProfiles(ctx context.Context) ([]ImportProfile, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var profiles []ImportProfile
+	for _, profile := range store.profiles[auth.WorkspaceID(ctx)] {
+		profiles = append(profiles, profile)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+
+	return profiles, nil
+}
+
+func (store *MemoryImportProfileStore) GetImportProfile(ctx context.Context, name string) (ImportProfile, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	profile, ok := store.profiles[auth.WorkspaceID(ctx)][name]
+	if !ok {
+		return ImportProfile{}, ErrImportProfileNotFound
+	}
+
+	return profile, nil
+}
+
+func (store *MemoryImportProfileStore) PutImportProfile(ctx context.Context, profile ImportProfile) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	profiles, ok := store.profiles[auth.WorkspaceID(ctx)]
+	if !ok {
+		profiles = map[string]ImportProfile{}
+		store.profiles[auth.WorkspaceID(ctx)] = profiles
+	}
+
+	_, exists := profiles[profile.Name]
+	profiles[profile.Name] = profile
+
+	return !exists, nil
+}
+
+func (store *MemoryImportProfileStore) DeleteImportProfile(ctx context.Context, name string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	profiles := store.profiles[auth.WorkspaceID(ctx)]
+	if _, ok := profiles[name]; !ok {
+		return ErrImportProfileNotFound
+	}
+	delete(profiles, name)
+
+	return nil
+}
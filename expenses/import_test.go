@@ -0,0 +1,304 @@
+package expenses
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newImportHandler() Handler {
+	store := newTestStore()
+	profiles := NewMemoryImportProfileStore()
+	profiles.PutImportProfile(context.Background(), ImportProfile{
+		Name:             "kbank",
+		Columns:          ImportColumns{Title: "2", Amount: "3", Date: "1"},
+		NoHeader:         true,
+		SkipRows:         2,
+		Delimiter:        ";",
+		DateFormats:      []string{"DD/MM/YYYY", "D/M/YY"},
+		Timezone:         "Asia/Bangkok",
+		DecimalSeparator: ",",
+		Negate:           true,
+		TagSeparator:     "|",
+	})
+	return Handler{Store: store, Profiles: profiles}
+}
+
+func importExpenses(t *testing.T, handler Handler, target string, contentType string, body string) (ImportResult, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+
+	handler.ImportExpenses(echo.New().NewContext(req, rec))
+
+	var result ImportResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result, rec
+}
+
+func TestImportExpensesRoundTrip(t *testing.T) {
+	// Arrange
+	handler := newImportHandler()
+	exported := exportExpenses(newExportStore(), "/expenses/export.csv?bom=true", "").Body.String()
+
+	// Act
+	result, rec := importExpenses(t, handler, "/expenses/import", MIMETextCSV, exported)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 2, result.Rows)
+	assert.Equal(t, 2, result.Imported)
+	assert.Empty(t, result.Errors)
+
+	expenses, _ := handler.Store.List(context.Background(), ListQuery{Sort: "id", Limit: 10})
+	assert.Len(t, expenses, 2)
+	assert.Equal(t, Expense{ID: 1, Title: "smoothie", Amount: 7900, Currency: "THB", Tags: []string{"food", "beverage"},
+		Note: `night market, "big" cup`, SpentAt: time.Date(2026, 9, 14, 18, 0, 0, 0, time.UTC),
+		CreatedAt: testTime, UpdatedAt: testTime}, expenses[0])
+	assert.Equal(t, "bus", expenses[1].Title)
+	assert.Equal(t, Money(1550), expenses[1].Amount)
+}
+
+func TestImportExpensesFormulas(t *testing.T) {
+	// Arrange
+	handler := newImportHandler()
+	store := newTestStore()
+	store.Create(context.Background(), &Expense{Title: "=1+1", Amount: 7900, Currency: "THB", Tags: []string{"-work"}, Note: "'@home"})
+	exported := exportExpenses(store, "/expenses/export.csv", "").Body.String()
+
+	// Act
+	_, rec := importExpenses(t, handler, "/expenses/import", MIMETextCSV, exported)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	expenses, _ := handler.Store.List(context.Background(), ListQuery{Sort: "id", Limit: 10})
+	assert.Len(t, expenses, 1)
+	assert.Equal(t, "=1+1", expenses[0].Title)
+	assert.Equal(t, []string{"-work"}, expenses[0].Tags)
+	assert.Equal(t, "'@home", expenses[0].Note)
+}
+
+func TestImportExpensesProfile(t *testing.T) {
+	// Arrange
+	body := "Statement of account\n" +
+		"Date;Description;Amount\n" +
+		"14/09/2026;smoothie;-1.234,50\n" +
+		"\n" +
+		"1/9/26;refund;15,00\n" +
+		"2/9/26;fee;(2,00)\n" +
+		"3/9/26;'+66 transfer;10,00\n"
+
+	// Act
+	result, rec := importExpenses(t, newImportHandler(), "/expenses/import?profile=kbank", "text/csv; charset=utf-8", body)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 4, result.Imported)
+	assert.Equal(t, "smoothie", result.Expenses[0].Title)
+	assert.Equal(t, Money(123450), result.Expenses[0].Amount)
+	assert.Equal(t, time.Date(2026, 9, 13, 17, 0, 0, 0, time.UTC), result.Expenses[0].SpentAt)
+	assert.Equal(t, "THB", result.Expenses[0].Currency)
+	assert.Equal(t, Money(-1500), result.Expenses[1].Amount)
+	assert.Equal(t, time.Date(2026, 8, 31, 17, 0, 0, 0, time.UTC), result.Expenses[1].SpentAt)
+	assert.Equal(t, Money(200), result.Expenses[2].Amount)
+	assert.Equal(t, "'+66 transfer", result.Expenses[3].Title)
+}
+
+func TestImportExpensesRowErrors(t *testing.T) {
+	body := "title,amount,spent_at,currency\n" +
+		"rice,50,2026-09-01,THB\n" +
+		",20,,\n" +
+		"bus,abc,2026-13-01,ABC\n" +
+		"\"broken,1\n"
+
+	expectedErrors := []ImportRowError{
+		{Row: 3, Column: "title", Message: "title is required"},
+		{Row: 4, Column: "amount", Message: `invalid amount "abc"`},
+		{Row: 4, Column: "spent_at"},
+		{Row: 4, Column: "currency"},
+		{Row: 5},
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		// Arrange
+		handler := newImportHandler()
+		target := "/expenses/import"
+		if dryRun {
+			target += "?dry_run=true"
+		}
+
+		// Act
+		result, rec := importExpenses(t, handler, target, MIMETextCSV, body)
+
+		// Assert
+		if dryRun {
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Len(t, result.Expenses, 1)
+			assert.Equal(t, "rice", result.Expenses[0].Title)
+			assert.Equal(t, 0, result.Expenses[0].ID)
+		} else {
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+			assert.Empty(t, result.Expenses)
+		}
+		assert.Equal(t, dryRun, result.DryRun)
+		assert.Equal(t, 4, result.Rows)
+		assert.Equal(t, 0, result.Imported)
+
+		assert.Len(t, result.Errors, len(expectedErrors))
+		for i, expected := range expectedErrors {
+			if i < len(result.Errors) {
+				assert.Equal(t, expected.Row, result.Errors[i].Row)
+				assert.Equal(t, expected.Column, result.Errors[i].Column)
+				assert.NotEmpty(t, result.Errors[i].Message)
+			}
+		}
+
+		expenses, _ := handler.Store.List(context.Background(), ListQuery{Sort: "id", Limit: 10})
+		assert.Empty(t, expenses)
+	}
+}
+
+func TestImportExpensesMultipart(t *testing.T) {
+	// Arrange
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, _ := form.CreateFormFile("file", "expenses.csv")
+	file.Write([]byte("Title,Amount\nrice,50\n"))
+	form.Close()
+
+	// Act
+	result, rec := importExpenses(t, newImportHandler(), "/expenses/import", form.FormDataContentType(), body.String())
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 1, result.Imported)
+	assert.Equal(t, testTime, result.Expenses[0].SpentAt)
+}
+
+func TestImportExpensesErrors(t *testing.T) {
+	cases := []struct {
+		target, contentType, body string
+		status                    int
+	}{
+		{"/expenses/import?profile=scb", MIMETextCSV, "title,amount\n", http.StatusNotFound},
+		{"/expenses/import?dry_run=maybe", MIMETextCSV, "title,amount\n", http.StatusBadRequest},
+		{"/expenses/import", MIMETextCSV, "title,note\nrice,\n", http.StatusBadRequest},
+		{"/expenses/import", MIMETextCSV, "", http.StatusBadRequest},
+		{"/expenses/import?profile=kbank", MIMETextCSV, "one line\n", http.StatusBadRequest},
+		{"/expenses/import", echo.MIMEApplicationJSON, `[{"title": "rice"}]`, http.StatusUnsupportedMediaType},
+	}
+
+	for _, test := range cases {
+		_, rec := importExpenses(t, newImportHandler(), test.target, test.contentType, test.body)
+
+		assert.Equal(t, test.status, rec.Code, test.target+" "+test.body)
+	}
+}
+
+func TestImportProfiles(t *testing.T) {
+	// Arrange
+	handler := newImportHandler()
+	profile := `{"columns": {"title": "Payee", "amount": "Amount"}, "delimiter": "tab", "currency": "usd"}`
+
+	// Act
+	c, rec := newIDContext(http.MethodPut, "/import-profiles/chase", profile, "")
+	c.SetParamNames("name")
+	c.SetParamValues("chase")
+	handler.PutImportProfile(c)
+
+	c, recAgain := newIDContext(http.MethodPut, "/import-profiles/chase", profile, "")
+	c.SetParamNames("name")
+	c.SetParamValues("chase")
+	handler.PutImportProfile(c)
+
+	c, recList := newIDContext(http.MethodGet, "/import-profiles", "", "")
+	handler.GetImportProfiles(c)
+
+	c, recDelete := newIDContext(http.MethodDelete, "/import-profiles/kbank", "", "")
+	c.SetParamNames("name")
+	c.SetParamValues("kbank")
+	handler.DeleteImportProfile(c)
+
+	c, recGet := newIDContext(http.MethodGet, "/import-profiles/kbank", "", "")
+	c.SetParamNames("name")
+	c.SetParamValues("kbank")
+	handler.GetImportProfile(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.JSONEq(t, `{"name": "chase", "columns": {"title": "Payee", "amount": "Amount"},
+		"delimiter": "\t", "decimal_separator": ".", "tag_separator": "|", "currency": "USD"}`, rec.Body.String())
+	assert.Equal(t, http.StatusOK, recAgain.Code)
+
+	var profiles []ImportProfile
+	assert.NoError(t, json.Unmarshal(recList.Body.Bytes(), &profiles))
+	assert.Len(t, profiles, 2)
+	assert.Equal(t, "chase", profiles[0].Name)
+
+	assert.Equal(t, http.StatusNoContent, recDelete.Code)
+	assert.Equal(t, http.StatusNotFound, recGet.Code)
+}
+
+func TestPutImportProfileInvalid(t *testing.T) {
+	for _, body := range []string{
+		`{"columns": {"title": "Payee"}}`,
+		`{"columns": {"title": "Payee", "amount": "Amount"}, "no_header": true}`,
+		`{"columns": {"title": "1", "amount": "2"}, "skip_rows": -1}`,
+		`{"columns": {"title": "Payee", "amount": "Amount"}, "delimiter": ";;"}`,
+		`{"columns": {"title": "Payee", "amount": "Amount"}, "date_formats": ["HH:mm"]}`,
+		`{"columns": {"title": "Payee", "amount": "Amount"}, "timezone": "Mars/Olympus"}`,
+		`{"columns": {"title": "Payee", "amount": "Amount"}, "decimal_separator": "'"}`,
+		`{"columns": {"title": "Payee", "amount": "Amount"}, "currency": "ABC"}`,
+		`{"columns": "Payee,Amount"}`,
+	} {
+		c, rec := newIDContext(http.MethodPut, "/import-profiles/chase", body, "")
+		c.SetParamNames("name")
+		c.SetParamValues("chase")
+		newImportHandler().PutImportProfile(c)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestPostgresStoreCreateAll(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO expenses \\(workspace_id, owner_id, title, amount, note, tags, currency, spent_at\\) "+
+		"VALUES \\(\\$1, \\$2, \\$3, (.+)\\), \\(\\$1, \\$2, \\$9, (.+)\\) RETURNING id").
+		WithArgs(0, 0, "rice", 5000, "", sqlmock.AnyArg(), "THB", nil, "bus", 1500, "", sqlmock.AnyArg(), "THB", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "spent_at", "created_at", "updated_at"}).
+			AddRow(7, testTime, testTime, testTime).
+			AddRow(8, testTime, testTime, testTime))
+	mock.ExpectCommit()
+
+	expenses := []Expense{
+		{Title: "rice", Amount: 5000, Currency: "THB"},
+		{Title: "bus", Amount: 1500, Currency: "THB"},
+	}
+
+	// Act
+	err = NewPostgresStore(db).CreateAll(context.Background(), expenses)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 7, expenses[0].ID)
+	assert.Equal(t, 8, expenses[1].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
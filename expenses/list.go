@@ -0,0 +1,301 @@
+package expenses
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Limits of the number of expenses in one page of GetAllExpenses.
+const (
+	DefaultPageLimit = 100
+	MaxPageLimit     = 1000
+)
+
+// sortColumns are the columns expenses can be sorted by.
+var sortColumns = map[string]bool{
+	"id": true, "title": true, "amount": true, "note": true, "currency": true,
+	"spent_at": true, "created_at": true, "updated_at": true,
+}
+
+type (
+
+	// ListQuery selects, orders and pages the expenses returned by ExpenseStore.List.
+	ListQuery struct {
+		// MinAmount and MaxAmount are in minor units of maxExponent,
+		// compared with the amounts of every currency by Money.Rescale.
+		MinAmount *Money
+		MaxAmount *Money
+
+		// Tags keeps expenses having any of the tags,
+		// or all of them when MatchAllTags is set.
+		Tags         []string
+		MatchAllTags bool
+
+		// Title keeps expenses whose title contains it, ignoring case.
+		Title string
+
+		// Spent keeps expenses spent in the range.
+		Spent DateRange
+
+		// Search keeps expenses matching a search query made by ParseSearch.
+		Search *SearchQuery
+
+		// Sort is one of sortColumns. Ties are ordered by ID.
+		Sort       string
+		Descending bool
+
+		// After continues the listing after the last expense of a previous page.
+		After *Cursor
+
+		// Limit is the maximum number of expenses, 0 means no limit.
+		Limit int
+	}
+
+	// Cursor is the position of an expense in a sorted listing.
+	// Value is the sort column of the expense formatted by sortValue.
+	Cursor struct {
+		Sort       string `json:"s"`
+		Descending bool   `json:"d,omitempty"`
+		Value      string `json:"v"`
+		ID         int    `json:"id"`
+	}
+)
+
+// sortValue formats the sort column of expense for a Cursor.
+// Amounts are rescaled by Money.Rescale so they sort like the amount filters
+// compare them, and times have a fixed layout so they sort as strings.
+func sortValue(expense Expense, column string) string {
+	switch column {
+	case "title":
+		return expense.Title
+	case "amount":
+		return strconv.FormatInt(int64(expense.Amount.Rescale(expense.Currency)), 10)
+	case "note":
+		return expense.Note
+	case "currency":
+		return expense.Currency
+	case "spent_at":
+		return expense.SpentAt.UTC().Format(timestampLayout)
+	case "created_at":
+		return expense.CreatedAt.UTC().Format(timestampLayout)
+	case "updated_at":
+		return expense.UpdatedAt.UTC().Format(timestampLayout)
+	}
+	return strconv.Itoa(expense.ID)
+}
+
+// Encode returns the cursor as an opaque URL-safe string.
+func (cursor Cursor) Encode() string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads a cursor made by Cursor.Encode.
+func DecodeCursor(s string) (*Cursor, error) {
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || !sortColumns[cursor.Sort] {
+		return nil, errors.New("invalid cursor")
+	}
+
+	return &cursor, nil
+}
+
+// cursorOf returns the cursor continuing after expense.
+func (query ListQuery) cursorOf(expense Expense) Cursor {
+	return Cursor{
+		Sort:       query.Sort,
+		Descending: query.Descending,
+		Value:      sortValue(expense, query.Sort),
+		ID:         expense.ID,
+	}
+}
+
+// ParseFilters reads the query parameters filtering expenses:
+// min_amount, max_amount, tags (comma separated), tags_match (any or all),
+// title, the spent dates read by ParseDateRange
+// and q (a search query read by ParseSearchInLocation).
+// It also returns the timezone of the dates.
+// An invalid q returns a *SyntaxError.
+func ParseFilters(c echo.Context) (ListQuery, *time.Location, error) {
+
+	var query ListQuery
+
+	spent, location, err := ParseDateRange(c)
+	if err != nil {
+		return query, nil, err
+	}
+	query.Spent = spent
+
+	for name, target := range map[string]**Money{"min_amount": &query.MinAmount, "max_amount": &query.MaxAmount} {
+		if value := c.QueryParam(name); value != "" {
+			amount, err := parseMinorUnits(value, maxExponent, RoundHalfUp)
+			if err != nil {
+				return query, nil, fmt.Errorf("invalid %s. %s", name, err.Error())
+			}
+			*target = &amount
+		}
+	}
+
+	if tags := c.QueryParam("tags"); tags != "" {
+		query.Tags = NormalizeTags(strings.Split(tags, ","))
+	}
+
+	switch c.QueryParam("tags_match") {
+	case "", "any":
+	case "all":
+		query.MatchAllTags = true
+	default:
+		return query, nil, errors.New("tags_match must be any or all")
+	}
+
+	query.Title = c.QueryParam("title")
+
+	if q := c.QueryParam("q"); q != "" {
+		search, err := ParseSearchInLocation(q, location)
+		if err != nil {
+			return query, nil, err
+		}
+		query.Search = search
+	}
+
+	return query, location, nil
+}
+
+// ParseListQuery reads the query parameters of GetAllExpenses:
+// the filters read by ParseFilters, limit, cursor
+// and sort (a column, "-" prefixed for descending).
+// An invalid q returns a *SyntaxError.
+func ParseListQuery(c echo.Context) (ListQuery, error) {
+
+	query, _, err := ParseFilters(c)
+	if err != nil {
+		return query, err
+	}
+	query.Sort = "id"
+	query.Limit = DefaultPageLimit
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxPageLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", MaxPageLimit)
+		}
+		query.Limit = n
+	}
+
+	if err := parseSort(c, &query); err != nil {
+		return query, err
+	}
+
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		after, err := DecodeCursor(cursor)
+		if err != nil {
+			return query, err
+		}
+		if after.Sort != query.Sort || after.Descending != query.Descending {
+			return query, errors.New("the cursor belongs to a different sort")
+		}
+		query.After = after
+	}
+
+	return query, nil
+}
+
+// parseSort reads sort, a column "-" prefixed for descending, into query.
+func parseSort(c echo.Context, query *ListQuery) error {
+
+	if sort := c.QueryParam("sort"); sort != "" {
+		query.Descending = strings.HasPrefix(sort, "-")
+		query.Sort = strings.TrimPrefix(sort, "-")
+		if !sortColumns[query.Sort] {
+			return fmt.Errorf("cannot sort by %q", query.Sort)
+		}
+	}
+
+	return nil
+}
+
+// Matches reports whether expense passes the filters of the query.
+// It is used by stores which cannot filter in SQL.
+func (query ListQuery) Matches(expense Expense) bool {
+
+	if query.MinAmount != nil && expense.Amount.Rescale(expense.Currency) < *query.MinAmount {
+		return false
+	}
+	if query.MaxAmount != nil && expense.Amount.Rescale(expense.Currency) > *query.MaxAmount {
+		return false
+	}
+
+	if query.Title != "" &&
+		!strings.Contains(strings.ToLower(expense.Title), strings.ToLower(query.Title)) {
+		return false
+	}
+
+	if len(query.Tags) > 0 {
+		has := map[string]bool{}
+		for _, tag := range expense.Tags {
+			has[tag] = true
+		}
+		matched := 0
+		for _, tag := range query.Tags {
+			if has[tag] {
+				matched++
+			}
+		}
+		if matched == 0 || (query.MatchAllTags && matched < len(query.Tags)) {
+			return false
+		}
+	}
+
+	if !query.Spent.Contains(expense.SpentAt) {
+		return false
+	}
+
+	if query.Search != nil && !query.Search.Match(expense) {
+		return false
+	}
+
+	if query.After != nil && !query.Less(*query.After, query.cursorOf(expense)) {
+		return false
+	}
+
+	return true
+}
+
+// Less reports whether the cursor a comes before b in the order of the query.
+func (query ListQuery) Less(a Cursor, b Cursor) bool {
+
+	cmp := 0
+	if query.Sort == "id" || query.Sort == "amount" {
+		x, _ := strconv.ParseInt(a.Value, 10, 64)
+		y, _ := strconv.ParseInt(b.Value, 10, 64)
+		if x < y {
+			cmp = -1
+		} else if x > y {
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(a.Value, b.Value)
+	}
+
+	if cmp == 0 {
+		cmp = a.ID - b.ID
+	}
+	if query.Descending {
+		cmp = -cmp
+	}
+
+	return cmp < 0
+}
@@ -0,0 +1,249 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/PeemPeimn/assessment/migrations"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newListStore() *MemoryStore {
+	store := NewMemoryStore()
+	for _, expense := range []Expense{
+		{Title: "Smoothie", Amount: 7900, Tags: []string{"food", "beverage"}},
+		{Title: "latte", Amount: 8800, Tags: []string{"coffee", "beverage"}},
+		{Title: "rent", Amount: 1500000, Tags: []string{"home"}},
+		{Title: "smoothie bowl", Amount: 12000, Tags: []string{"food"}},
+		{Title: "espresso", Amount: 7900, Tags: []string{"coffee"}},
+	} {
+		expense.Currency = "THB"
+		store.Create(context.Background(), &expense)
+	}
+	return store
+}
+
+func listExpenses(t *testing.T, store ExpenseStore, target string) ([]int, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+
+	e := echo.New()
+	c := e.NewContext(req, rec)
+
+	handler := Handler{Store: store}
+	handler.GetAllExpenses(c)
+
+	// Errors are objects rather than lists of expenses.
+	var expenses []Expense
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &expenses); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := []int{}
+	for _, expense := range expenses {
+		ids = append(ids, expense.ID)
+	}
+	return ids, rec
+}
+
+func TestGetAllExpensesFilters(t *testing.T) {
+	cases := map[string][]int{
+		"/expenses":                                   {1, 2, 3, 4, 5},
+		"/expenses?min_amount=80":                     {2, 3, 4},
+		"/expenses?min_amount=79&max_amount=88":       {1, 2, 5},
+		"/expenses?tags=food,coffee":                  {1, 2, 4, 5},
+		"/expenses?tags=food,beverage&tags_match=all": {1},
+		"/expenses?title=SMOOTHIE":                    {1, 4},
+		"/expenses?sort=-amount":                      {3, 4, 2, 5, 1},
+		"/expenses?sort=amount":                       {1, 5, 2, 4, 3},
+		"/expenses?sort=title&title=s":                {1, 5, 4},
+	}
+
+	store := newListStore()
+
+	for target, expected := range cases {
+		t.Run(target, func(t *testing.T) {
+			ids, rec := listExpenses(t, store, target)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, expected, ids)
+		})
+	}
+}
+
+func TestGetAllExpensesPages(t *testing.T) {
+	// Arrange
+	store := newListStore()
+	target := "/expenses?sort=-amount&limit=2"
+	var pages [][]int
+
+	// Act
+	for target != "" {
+		ids, rec := listExpenses(t, store, target)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		pages = append(pages, ids)
+
+		target = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			target = regexp.MustCompile(`<(.+)>; rel="next"`).FindStringSubmatch(link)[1]
+			assert.NotEmpty(t, rec.Header().Get("X-Next-Cursor"))
+		}
+	}
+
+	// Assert
+	assert.Equal(t, [][]int{{3, 4}, {2, 5}, {1}}, pages)
+}
+
+func TestGetAllExpensesEnvelope(t *testing.T) {
+	// Arrange
+	store := newListStore()
+	target := "/expenses?sort=-amount&limit=2&envelope=true"
+	var pages [][]int
+	var cursors []string
+
+	// Act
+	for {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		Handler{Store: store}.GetAllExpenses(c)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var page ExpensePage
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		ids := []int{}
+		for _, expense := range page.Items {
+			ids = append(ids, expense.ID)
+		}
+		pages = append(pages, ids)
+		cursors = append(cursors, page.NextCursor)
+
+		if page.NextCursor == "" {
+			break
+		}
+		assert.Equal(t, page.NextCursor, rec.Header().Get("X-Next-Cursor"))
+		target = "/expenses?sort=-amount&limit=2&envelope=true&cursor=" + url.QueryEscape(page.NextCursor)
+	}
+
+	// Assert
+	assert.Equal(t, [][]int{{3, 4}, {2, 5}, {1}}, pages)
+	assert.NotEmpty(t, cursors[0])
+	assert.NotEmpty(t, cursors[1])
+}
+
+func TestGetAllExpensesEmptyEnvelope(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/expenses?envelope=true", nil)
+	rec := httptest.NewRecorder()
+	Handler{Store: NewMemoryStore()}.GetAllExpenses(echo.New().NewContext(req, rec))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"items":[]}`, strings.TrimSpace(rec.Body.String()))
+}
+
+func TestGetAllExpensesSortAmountCurrencies(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	for _, expense := range []Expense{
+		{Title: "ramen", Amount: 1000, Currency: "JPY"},
+		{Title: "tea", Amount: 5000, Currency: "THB"},
+		{Title: "water", Amount: 1000, Currency: "THB"},
+	} {
+		store.Create(context.Background(), &expense)
+	}
+
+	// Act
+	ids, rec := listExpenses(t, store, "/expenses?sort=amount")
+	pages := [][]int{}
+	for target := "/expenses?sort=-amount&limit=1"; target != ""; {
+		page, rec := listExpenses(t, store, target)
+		pages = append(pages, page)
+		target = ""
+		if link := rec.Header().Get("Link"); link != "" {
+			target = regexp.MustCompile(`<(.+)>; rel="next"`).FindStringSubmatch(link)[1]
+		}
+	}
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []int{3, 2, 1}, ids)
+	assert.Equal(t, [][]int{{1}, {2}, {3}}, pages)
+}
+
+func TestRescaledAmountIndex(t *testing.T) {
+	all, err := migrations.Default()
+	assert.NoError(t, err)
+
+	space := regexp.MustCompile(`\s+`)
+	for _, migration := range all {
+		if migration.Name == "rescaled_amount_index" {
+			assert.Contains(t, space.ReplaceAllString(migration.Up, " "), rescaledAmountSQL)
+			return
+		}
+	}
+	t.Error("no rescaled_amount_index migration")
+}
+
+func TestGetAllExpensesInvalidQuery(t *testing.T) {
+	cursor := Cursor{Sort: "amount", Value: "7900", ID: 1}.Encode()
+
+	for _, target := range []string{
+		"/expenses?limit=0",
+		"/expenses?limit=5000",
+		"/expenses?min_amount=abc",
+		"/expenses?tags_match=some",
+		"/expenses?sort=password",
+		"/expenses?cursor=abc",
+		"/expenses?sort=title&cursor=" + cursor,
+		"/expenses?envelope=maybe",
+	} {
+		_, rec := listExpenses(t, NewMemoryStore(), target)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
+
+func TestPostgresStoreListQuery(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	minAmount := Money(79000)
+	query := ListQuery{
+		MinAmount:    &minAmount,
+		Title:        "50%",
+		Tags:         []string{"food", "coffee"},
+		MatchAllTags: true,
+		Sort:         "amount",
+		Descending:   true,
+		After:        &Cursor{Sort: "amount", Descending: true, Value: "8800", ID: 2},
+		Limit:        11,
+	}
+
+	mock.ExpectQuery("SELECT "+expenseColumns+" FROM expenses"+
+		" WHERE workspace_id = $1 AND (owner_id = $2 OR $3) AND deleted_at IS NULL AND "+rescaledAmountSQL+" >= $4 AND title ILIKE '%' || $5 || '%'"+
+		" AND tags @> $6 AND ("+rescaledAmountSQL+", id) < ($7, $8)"+
+		" ORDER BY "+rescaledAmountSQL+" DESC, id DESC LIMIT $9").
+		WithArgs(0, 5, false, 79000, `50\%`, `{"food","coffee"}`, "8800", 2, 11).
+		WillReturnRows(expenseRows())
+
+	store := NewPostgresStore(db)
+
+	// Act
+	_, err = store.List(auth.WithUser(context.Background(), 5), query)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
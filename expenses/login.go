@@ -0,0 +1,84 @@
+package expenses
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+
+	// LoginRequest is the body of Login.
+	LoginRequest struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+
+	// TokenResponse is the response of Login. ExpiresIn is in seconds.
+	TokenResponse struct {
+		AccessToken string    `json:"access_token"`
+		TokenType   string    `json:"token_type"`
+		ExpiresIn   int64     `json:"expires_in"`
+		ExpiresAt   time.Time `json:"expires_at"`
+	}
+)
+
+// Login handles HTTP POST request to exchange the name and password
+// of a user for a signed token, sent as "Authorization: Bearer <token>".
+// It responds with 404 Not Found when tokens are not configured.
+func (handler Handler) Login(c echo.Context) error {
+
+	if handler.Tokens == nil {
+		return c.JSON(http.StatusNotFound, ErrorResponse{"tokens are not configured."})
+	}
+
+	var request LoginRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+	}
+
+	user, hash, err := handler.Users.UserByName(c.Request().Context(), request.Name)
+	if err != nil && err != auth.ErrUserNotFound {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot find the user. " + err.Error()})
+	}
+
+	// An unknown user is checked against an empty hash, so it fails
+	// with the same message and in about the same time as a wrong password.
+	if err := auth.CheckPassword(hash, request.Password); err != nil {
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{err.Error() + "."})
+	}
+
+	return handler.respondToken(c, user)
+}
+
+// respondToken responds with a new token of user.
+func (handler Handler) respondToken(c echo.Context, user auth.User) error {
+
+	token, claims, err := handler.Tokens.Issue(user)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot issue a token. " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   claims.ExpiresAt - claims.IssuedAt,
+		ExpiresAt:   time.Unix(claims.ExpiresAt, 0).UTC(),
+	})
+}
+
+// GetJWKS handles HTTP GET request to get the public keys verifying tokens
+// as a JSON Web Key Set.
+func (handler Handler) GetJWKS(c echo.Context) error {
+
+	if handler.Tokens == nil {
+		return c.JSON(http.StatusOK, auth.JWKSet{Keys: []auth.JWK{}})
+	}
+
+	return c.JSON(http.StatusOK, handler.Tokens.JWKS())
+}
@@ -0,0 +1,108 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLoginHandler() Handler {
+	users := auth.NewMemoryUserStore()
+	hash, _ := auth.HashPassword("correct horse")
+	users.CreateUser(context.Background(), &auth.User{Name: "peem"}, hash)
+	users.CreateUser(context.Background(), &auth.User{Name: "keys-only"}, "")
+
+	return Handler{
+		Users: users,
+		Tokens: &auth.Tokens{
+			Keys:   []auth.SigningKey{{ID: "2026-10", Algorithm: auth.HS256, Secret: []byte("0123456789abcdef0123456789abcdef")}},
+			TTL:    15 * time.Minute,
+			Leeway: time.Minute,
+			Now:    func() time.Time { return testTime },
+		},
+	}
+}
+
+func TestLogin(t *testing.T) {
+	// Arrange
+	handler := newLoginHandler()
+
+	// Act
+	c, rec := newIDContext(http.MethodPost, "/auth/login", `{"name": "peem", "password": "correct horse"}`, "")
+	handler.Login(c)
+	var response TokenResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	claims, err := handler.Tokens.Verify(response.AccessToken)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Bearer", response.TokenType)
+	assert.Equal(t, int64(900), response.ExpiresIn)
+	assert.Equal(t, testTime.Add(15*time.Minute), response.ExpiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", claims.Subject)
+	assert.Equal(t, "peem", claims.Name)
+}
+
+func TestLoginErrors(t *testing.T) {
+	handler := newLoginHandler()
+
+	for _, body := range []string{
+		`{"name": "peem", "password": "wrong password"}`,
+		`{"name": "nobody", "password": "correct horse"}`,
+		`{"name": "keys-only", "password": ""}`,
+	} {
+		c, rec := newIDContext(http.MethodPost, "/auth/login", body, "")
+		handler.Login(c)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, body)
+		assert.Contains(t, rec.Body.String(), "wrong name or password.", body)
+	}
+
+	handler.Tokens = nil
+	c, rec := newIDContext(http.MethodPost, "/auth/login", `{"name": "peem", "password": "correct horse"}`, "")
+	handler.Login(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestGetJWKS(t *testing.T) {
+	handler := newLoginHandler()
+
+	c, rec := newIDContext(http.MethodGet, "/.well-known/jwks.json", "", "")
+	handler.GetJWKS(c)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"keys": []}`, rec.Body.String())
+}
+
+func TestSetPassword(t *testing.T) {
+	// Arrange
+	handler := newLoginHandler()
+
+	// Act
+	c, rec := newIDContext(http.MethodPut, "/users/me/password", `{"password": "battery staple"}`, "")
+	asUser(c, 2)
+	handler.SetPassword(c)
+
+	c, shortRec := newIDContext(http.MethodPut, "/users/me/password", `{"password": "short"}`, "")
+	asUser(c, 2)
+	handler.SetPassword(c)
+
+	c, noUserRec := newIDContext(http.MethodPut, "/users/me/password", `{"password": "battery staple"}`, "")
+	asBootstrap(c)
+	handler.SetPassword(c)
+
+	c, loginRec := newIDContext(http.MethodPost, "/auth/login", `{"name": "keys-only", "password": "battery staple"}`, "")
+	handler.Login(c)
+
+	// Assert
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, http.StatusBadRequest, shortRec.Code)
+	assert.Equal(t, http.StatusNotFound, noUserRec.Code)
+	assert.Equal(t, http.StatusOK, loginRec.Code)
+}
@@ -0,0 +1,417 @@
+package expenses
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+)
+
+// MemoryStore is a thread-safe ExpenseStore, TagStore and ReportStore keeping expenses in a map.
+// It is meant for local development and unit tests. Like PostgresStore, it only
+// sees the expenses of the workspace of auth.WorkspaceID and of the user of
+// auth.UserID, or of every user of the workspace with auth.AllUsers.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	lastID   int
+	expenses map[int]Expense
+
+	// owners are the workspaces and users owning the expenses.
+	owners map[int]owner
+
+	// tags are the colors and descriptions of tags.
+	tags map[tagKey]Tag
+
+	// imported are the IDs of the expenses of imported bank transactions.
+	imported map[importedKey]int
+
+	// Now returns the current time. Tests may replace it.
+	Now func() time.Time
+}
+
+// owner is the workspace and the user owning an expense.
+type owner struct {
+	workspace int
+	user      int
+}
+
+// scope is whose expenses a request sees: those of its owner,
+// or of every user of its workspace with all.
+type scope struct {
+	owner
+	all bool
+}
+
+// scopeOf returns the scope of the request of ctx.
+func scopeOf(ctx context.Context) scope {
+	return scope{owner: owner{auth.WorkspaceID(ctx), auth.UserID(ctx)}, all: auth.AllUsers(ctx)}
+}
+
+// has reports whether the scope sees the expenses of owner.
+func (scope scope) has(owner owner) bool {
+	return scope.workspace == owner.workspace && (scope.all || scope.user == owner.user)
+}
+
+// tagKey is a tag of a workspace.
+type tagKey struct {
+	workspace int
+	name      string
+}
+
+// importedKey is a bank transaction imported by a user of a workspace.
+type importedKey struct {
+	owner         owner
+	transactionID string
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		expenses: map[int]Expense{},
+		owners:   map[int]owner{},
+		tags:     map[tagKey]Tag{},
+		imported: map[importedKey]int{},
+		Now:      time.Now,
+	}
+}
+
+// clone copies the tags so callers cannot modify stored expenses.
+func clone(expense Expense) Expense {
+	if expense.Tags != nil {
+		expense.Tags = append([]string(nil), expense.Tags...)
+	}
+	if len(expense.Tags) == 0 {
+		expense.Tags = nil
+	}
+	return expense
+}
+
+// now returns the current time the way Postgres stores it,
+// in UTC with microseconds.
+func (store *MemoryStore) now() time.Time {
+	return store.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (store *MemoryStore) Create(ctx context.Context, expense *Expense) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.create(scopeOf(ctx).owner, expense)
+
+	return nil
+}
+
+func (store *MemoryStore) CreateAll(ctx context.Context, expenses []Expense) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	owner := scopeOf(ctx).owner
+	for i := range expenses {
+		store.create(owner, &expenses[i])
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) Batch(ctx context.Context, operations []BatchOperation, atomic bool) ([]error, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	scope := scopeOf(ctx)
+
+	// Keep the expenses as they are to put them back when an atomic batch fails.
+	lastID := store.lastID
+	saved := make(map[int]Expense, len(store.expenses))
+	for id, expense := range store.expenses {
+		saved[id] = expense
+	}
+
+	for _, operation := range operations {
+		if operation.Op == BatchCreate {
+			store.create(scope.owner, operation.Expense)
+		}
+	}
+
+	errs := make([]error, len(operations))
+	failed := false
+
+	for i, operation := range operations {
+		switch operation.Op {
+		case BatchUpdate:
+			errs[i] = store.update(scope, operation.Expense)
+		case BatchDelete:
+			errs[i] = store.delete(scope, operation.ID)
+		}
+		failed = failed || errs[i] != nil
+	}
+
+	if atomic && failed {
+		for id := lastID + 1; id <= store.lastID; id++ {
+			delete(store.owners, id)
+		}
+		store.expenses, store.lastID = saved, lastID
+	}
+
+	return errs, nil
+}
+
+func (store *MemoryStore) Imported(ctx context.Context, transactionIDs []string) (map[string]bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	owner := scopeOf(ctx).owner
+
+	imported := map[string]bool{}
+	for _, id := range transactionIDs {
+		if _, ok := store.imported[importedKey{owner, id}]; ok {
+			imported[id] = true
+		}
+	}
+
+	return imported, nil
+}
+
+func (store *MemoryStore) CreateImported(ctx context.Context, expenses []Expense, transactionIDs []string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	owner := scopeOf(ctx).owner
+
+	for i := range expenses {
+		key := importedKey{owner, transactionIDs[i]}
+		if _, ok := store.imported[key]; ok {
+			expenses[i].ID = 0
+			continue
+		}
+		store.create(owner, &expenses[i])
+		store.imported[key] = expenses[i].ID
+	}
+
+	return nil
+}
+
+// create inserts an expense of the owner while the store is locked.
+func (store *MemoryStore) create(owner owner, expense *Expense) {
+	store.lastID++
+	expense.ID = store.lastID
+	store.owners[expense.ID] = owner
+	expense.CreatedAt = store.now()
+	expense.UpdatedAt = expense.CreatedAt
+	if expense.SpentAt.IsZero() {
+		expense.SpentAt = expense.CreatedAt
+	}
+	expense.SpentAt = expense.SpentAt.UTC().Truncate(time.Microsecond)
+	expense.DeletedAt = nil
+	store.expenses[expense.ID] = clone(*expense)
+}
+
+// find returns the expense of id if the scope sees it. The caller must hold store.mu.
+func (store *MemoryStore) find(scope scope, id int) (Expense, bool) {
+	expense, ok := store.expenses[id]
+	if !ok || !scope.has(store.owners[id]) {
+		return Expense{}, false
+	}
+	return expense, true
+}
+
+func (store *MemoryStore) Get(ctx context.Context, id int) (Expense, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	expense, ok := store.find(scopeOf(ctx), id)
+	if !ok || expense.DeletedAt != nil {
+		return Expense{}, ErrNotFound
+	}
+
+	return clone(expense), nil
+}
+
+func (store *MemoryStore) Update(ctx context.Context, expense *Expense) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.update(scopeOf(ctx), expense)
+}
+
+// update replaces an expense the scope sees while the store is locked.
+func (store *MemoryStore) update(scope scope, expense *Expense) error {
+	existing, ok := store.find(scope, expense.ID)
+	if !ok || existing.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	if expense.SpentAt.IsZero() {
+		expense.SpentAt = existing.SpentAt
+	}
+	expense.SpentAt = expense.SpentAt.UTC().Truncate(time.Microsecond)
+	expense.CreatedAt = existing.CreatedAt
+	expense.UpdatedAt = store.now()
+	expense.DeletedAt = nil
+	store.expenses[expense.ID] = clone(*expense)
+	*expense = clone(*expense)
+
+	return nil
+}
+
+func (store *MemoryStore) Modify(ctx context.Context, id int, apply func(expense *Expense) error) (Expense, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	existing, ok := store.find(scopeOf(ctx), id)
+	if !ok || existing.DeletedAt != nil {
+		return Expense{}, ErrNotFound
+	}
+
+	expense := clone(existing)
+	if err := apply(&expense); err != nil {
+		return Expense{}, err
+	}
+	expense.ID = id
+	if expense.SpentAt.IsZero() {
+		expense.SpentAt = existing.SpentAt
+	}
+	expense.SpentAt = expense.SpentAt.UTC().Truncate(time.Microsecond)
+	expense.CreatedAt = existing.CreatedAt
+	expense.UpdatedAt = store.now()
+	expense.DeletedAt = nil
+
+	store.expenses[id] = clone(expense)
+
+	return clone(expense), nil
+}
+
+func (store *MemoryStore) List(ctx context.Context, query ListQuery) ([]Expense, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	scope := scopeOf(ctx)
+
+	var expenses []Expense
+	for id, expense := range store.expenses {
+		if scope.has(store.owners[id]) && expense.DeletedAt == nil && query.Matches(expense) {
+			expenses = append(expenses, clone(expense))
+		}
+	}
+
+	if query.Sort == "" {
+		query.Sort = "id"
+	}
+	sort.Slice(expenses, func(i, j int) bool {
+		return query.Less(query.cursorOf(expenses[i]), query.cursorOf(expenses[j]))
+	})
+
+	if query.Limit > 0 && len(expenses) > query.Limit {
+		expenses = expenses[:query.Limit]
+	}
+
+	return expenses, nil
+}
+
+func (store *MemoryStore) Stream(ctx context.Context, query ListQuery, fn func(expense Expense) error) error {
+
+	expenses, err := store.List(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	for _, expense := range expenses {
+		if err := fn(expense); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (store *MemoryStore) Search(ctx context.Context, text string, limit int) ([]SearchResult, error) {
+
+	expenses, err := store.List(ctx, ListQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	return RankExpenses(expenses, text, limit), nil
+}
+
+func (store *MemoryStore) Delete(ctx context.Context, id int) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.delete(scopeOf(ctx), id)
+}
+
+// delete moves an expense the scope sees to the trash while the store is locked.
+func (store *MemoryStore) delete(scope scope, id int) error {
+	expense, ok := store.find(scope, id)
+	if !ok || expense.DeletedAt != nil {
+		return ErrNotFound
+	}
+
+	now := store.now()
+	expense.DeletedAt = &now
+	store.expenses[id] = expense
+
+	return nil
+}
+
+func (store *MemoryStore) ListTrash(ctx context.Context) ([]Expense, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	scope := scopeOf(ctx)
+
+	var expenses []Expense
+	for id, expense := range store.expenses {
+		if scope.has(store.owners[id]) && expense.DeletedAt != nil {
+			expenses = append(expenses, clone(expense))
+		}
+	}
+
+	sort.Slice(expenses, func(i, j int) bool {
+		if !expenses[i].DeletedAt.Equal(*expenses[j].DeletedAt) {
+			return expenses[i].DeletedAt.After(*expenses[j].DeletedAt)
+		}
+		return expenses[i].ID < expenses[j].ID
+	})
+
+	return expenses, nil
+}
+
+func (store *MemoryStore) Restore(ctx context.Context, id int) (Expense, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	expense, ok := store.find(scopeOf(ctx), id)
+	if !ok || expense.DeletedAt == nil {
+		return Expense{}, ErrNotFound
+	}
+
+	expense.DeletedAt = nil
+	store.expenses[id] = expense
+
+	return clone(expense), nil
+}
+
+// Purge removes the deleted expenses of every user of every workspace.
+func (store *MemoryStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	purged := 0
+	for id, expense := range store.expenses {
+		if expense.DeletedAt != nil && expense.DeletedAt.Before(before) {
+			delete(store.expenses, id)
+			delete(store.owners, id)
+			purged++
+		}
+	}
+	for key, id := range store.imported {
+		if _, ok := store.expenses[id]; !ok {
+			delete(store.imported, key)
+		}
+	}
+
+	return purged, nil
+}
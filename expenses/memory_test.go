@@ -0,0 +1,61 @@
+package expenses
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	// Arrange
+	store := newTestStore()
+	ctx := context.Background()
+	expense := Expense{Title: "smoothie", Amount: 7900, Tags: []string{"food"}, Currency: "THB"}
+
+	// Act & Assert
+	assert.NoError(t, store.Create(ctx, &expense))
+	assert.Equal(t, 1, expense.ID)
+
+	// Modifying the caller's copy must not change the stored expense.
+	expense.Tags[0] = "changed"
+	got, err := store.Get(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"food"}, got.Tags)
+
+	got.Title = "latte"
+	assert.NoError(t, store.Update(ctx, &got))
+
+	list, err := store.List(ctx, ListQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []Expense{{ID: 1, Title: "latte", Amount: 7900, Tags: []string{"food"}, Currency: "THB",
+		SpentAt: testTime, CreatedAt: testTime, UpdatedAt: testTime}}, list)
+
+	assert.NoError(t, store.Delete(ctx, 1))
+	assert.Equal(t, ErrNotFound, store.Delete(ctx, 1))
+	_, err = store.Get(ctx, 1)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, store.Update(ctx, &got))
+}
+
+func TestMemoryStoreConcurrentCreate(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	var wg sync.WaitGroup
+
+	// Act
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			store.Create(context.Background(), &Expense{Title: "latte"})
+		}()
+	}
+	wg.Wait()
+
+	// Assert
+	list, _ := store.List(context.Background(), ListQuery{})
+	assert.Len(t, list, 50)
+	assert.Equal(t, 50, list[49].ID)
+}
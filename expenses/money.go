@@ -0,0 +1,288 @@
+package expenses
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in the minor units of its currency, which have the
+// ISO 4217 exponent of the currency: 79.50 baht is Money(7950), 1000 yen is
+// Money(1000) and 1.005 dinars is Money(1005). It is stored as BIGINT to
+// avoid float rounding.
+type Money int64
+
+// maxExponent is the largest exponent of the currencies. Amounts of different
+// currencies are compared in the minor units of an exponent of maxExponent.
+const maxExponent = 3
+
+// Rounding tells how an amount with more decimals than its currency is rounded.
+type Rounding string
+
+const (
+	// RoundHalfUp rounds halves away from zero, 0.125 becomes 0.13.
+	RoundHalfUp Rounding = "half_up"
+	// RoundHalfEven rounds halves to the even neighbour, 0.125 becomes 0.12.
+	RoundHalfEven Rounding = "half_even"
+	// RoundDown truncates toward zero.
+	RoundDown Rounding = "down"
+	// RoundUp rounds away from zero.
+	RoundUp Rounding = "up"
+	// RoundReject refuses amounts with more decimals than their currency.
+	RoundReject Rounding = "reject"
+)
+
+// DefaultRounding is used when decoding Money from JSON.
+// The server sets it from the MONEY_ROUNDING environment variable.
+var DefaultRounding = RoundHalfUp
+
+// ErrTooPrecise is returned by RoundReject for amounts that need rounding.
+var ErrTooPrecise = errors.New("amount has more decimals than its currency")
+
+// decimal matches plain decimal numbers with an optional short exponent.
+var decimal = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d{1,3})?$`)
+
+// ParseRounding returns the Rounding of the given name.
+func ParseRounding(name string) (Rounding, error) {
+	switch rounding := Rounding(name); rounding {
+	case RoundHalfUp, RoundHalfEven, RoundDown, RoundUp, RoundReject:
+		return rounding, nil
+	}
+	return "", fmt.Errorf("unknown rounding %q", name)
+}
+
+// ParseMoney parses a decimal amount such as "79.5" or "-1e3" exactly,
+// rounding it to the minor units of currency with the given rounding.
+// An empty currency is DefaultCurrency.
+func ParseMoney(s string, currency string, rounding Rounding) (Money, error) {
+	return parseMinorUnits(s, Exponent(currency), rounding)
+}
+
+// parseMinorUnits parses a decimal amount to minor units of the given exponent.
+func parseMinorUnits(s string, exponent int, rounding Rounding) (Money, error) {
+
+	s = strings.TrimSpace(s)
+	if !decimal.MatchString(s) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	amount, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	amount.Mul(amount, new(big.Rat).SetInt(scale(exponent)))
+
+	money, err := round(amount, rounding)
+	if err == errOverflow || err == nil && (money > maxMinorUnits(exponent) || money < -maxMinorUnits(exponent)) {
+		return 0, fmt.Errorf("amount %q is too large", s)
+	}
+
+	return money, err
+}
+
+// scale returns the number of minor units of the given exponent in a major unit.
+func scale(exponent int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+}
+
+var errOverflow = errors.New("amount is too large")
+
+// maxMinorUnits is the largest amount in minor units of exponent
+// which Rescale can turn into minor units of maxExponent.
+func maxMinorUnits(exponent int) Money {
+	return Money(math.MaxInt64 / scale(maxExponent-exponent).Int64())
+}
+
+// round rounds an amount of minor units to a whole Money.
+func round(amount *big.Rat, rounding Rounding) (Money, error) {
+
+	quotient, remainder := new(big.Int).QuoRem(amount.Num(), amount.Denom(), new(big.Int))
+
+	if remainder.Sign() != 0 {
+		// Compare twice the remainder against the denominator to find halves.
+		half := new(big.Int).Abs(remainder)
+		half.Mul(half, big.NewInt(2))
+		cmp := half.Cmp(amount.Denom())
+
+		away := false
+		switch rounding {
+		case RoundHalfUp:
+			away = cmp >= 0
+		case RoundHalfEven:
+			away = cmp > 0 || (cmp == 0 && quotient.Bit(0) == 1)
+		case RoundDown:
+			away = false
+		case RoundUp:
+			away = true
+		case RoundReject:
+			return 0, ErrTooPrecise
+		default:
+			return 0, fmt.Errorf("unknown rounding %q", rounding)
+		}
+
+		if away {
+			quotient.Add(quotient, big.NewInt(int64(amount.Sign())))
+		}
+	}
+
+	if !quotient.IsInt64() {
+		return 0, errOverflow
+	}
+
+	return Money(quotient.Int64()), nil
+}
+
+// Convert multiplies an amount of currency from by an exchange rate to an
+// amount of currency to, whose minor units may have another exponent.
+// The result is rounded with DefaultRounding, or half up when it is RoundReject
+// since converted amounts almost never fit the decimals of a currency exactly.
+func (m Money) Convert(rate *big.Rat, from string, to string) (Money, error) {
+
+	rounding := DefaultRounding
+	if rounding == RoundReject {
+		rounding = RoundHalfUp
+	}
+
+	amount := new(big.Rat).SetInt64(int64(m))
+	amount.Mul(amount, rate)
+	amount.Mul(amount, new(big.Rat).SetInt(scale(Exponent(to))))
+	amount.Quo(amount, new(big.Rat).SetInt(scale(Exponent(from))))
+
+	return round(amount, rounding)
+}
+
+// Rescale returns the amount in minor units of maxExponent,
+// so that amounts of different currencies can be compared.
+// Parsed amounts are small enough not to overflow.
+func (m Money) Rescale(currency string) Money {
+	return m * Money(scale(maxExponent-Exponent(currency)).Int64())
+}
+
+// Format formats the amount with the decimals of currency,
+// such as "79.50" for THB, "1000" for JPY and "1.005" for KWD.
+func (m Money) Format(currency string) string {
+
+	sign := ""
+	value := uint64(m)
+	if m < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	exponent := Exponent(currency)
+	if exponent == 0 {
+		return fmt.Sprintf("%s%d", sign, value)
+	}
+
+	units := scale(exponent).Uint64()
+	return fmt.Sprintf("%s%d.%0*d", sign, value/units, exponent, value%units)
+}
+
+// String formats the amount with the decimals of DefaultCurrency.
+func (m Money) String() string {
+	return m.Format(DefaultCurrency)
+}
+
+// jsonIn encodes the amount as a JSON number with the decimals of currency
+// without trailing zeros, so 7900 baht is 79 and 7950 baht is 79.5.
+func (m Money) jsonIn(currency string) json.RawMessage {
+	s := m.Format(currency)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return json.RawMessage(s)
+}
+
+// MarshalJSON encodes the amount with the decimals of DefaultCurrency.
+// Structs having a currency encode their amounts with its decimals.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return m.jsonIn(DefaultCurrency), nil
+}
+
+// unmarshalIn decodes a JSON number such as 79.5 or a string such as "79.50"
+// to minor units of currency, rounded with DefaultRounding.
+// An absent or null amount leaves m unchanged.
+func (m *Money) unmarshalIn(data []byte, currency string) error {
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	s := string(data)
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return err
+		}
+		s = unquoted
+	} else if !json.Valid(data) {
+		return fmt.Errorf("invalid amount %s", s)
+	}
+
+	amount, err := ParseMoney(s, currency, DefaultRounding)
+	if err != nil {
+		return err
+	}
+
+	*m = amount
+	return nil
+}
+
+// UnmarshalJSON decodes the amount with the decimals of DefaultCurrency.
+// Structs having a currency decode their amounts with its decimals.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	return m.unmarshalIn(data, DefaultCurrency)
+}
+
+// replaceMembers encodes the struct v as a JSON object, replacing the
+// members named in values by them. The members keep the order of the fields,
+// which a struct shadowing the fields of v would not.
+func replaceMembers(v interface{}, values map[string]json.RawMessage) ([]byte, error) {
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+
+		name := token.(string)
+		if replaced, ok := values[name]; ok {
+			value = replaced
+		}
+
+		if buffer.Len() > 1 {
+			buffer.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+
+	return buffer.Bytes(), nil
+}
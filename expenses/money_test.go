@@ -0,0 +1,158 @@
+package expenses
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		input    string
+		currency string
+		rounding Rounding
+		expected Money
+	}{
+		{"79", "THB", RoundHalfUp, 7900},
+		{"79.5", "THB", RoundHalfUp, 7950},
+		{"79.50", "THB", RoundHalfUp, 7950},
+		{"0.1", "THB", RoundHalfUp, 10},
+		{"-12.34", "THB", RoundHalfUp, -1234},
+		{"1e3", "THB", RoundHalfUp, 100000},
+		{".5", "THB", RoundHalfUp, 50},
+		{"0.125", "THB", RoundHalfUp, 13},
+		{"0.125", "THB", RoundHalfEven, 12},
+		{"0.135", "THB", RoundHalfEven, 14},
+		{"-0.125", "THB", RoundHalfUp, -13},
+		{"0.129", "THB", RoundDown, 12},
+		{"-0.129", "THB", RoundDown, -12},
+		{"0.121", "THB", RoundUp, 13},
+		{"-0.121", "THB", RoundUp, -13},
+		{"1000", "JPY", RoundHalfUp, 1000},
+		{"12.5", "jpy", RoundHalfUp, 13},
+		{"12.34", "JPY", RoundDown, 12},
+		{"1.005", "KWD", RoundHalfUp, 1005},
+		{"1.0005", "KWD", RoundHalfEven, 1000},
+		{"79.5", "", RoundHalfUp, 7950},
+		{"9223372036854775", "JPY", RoundHalfUp, 9223372036854775},
+		{"-9223372036854775.80", "THB", RoundHalfUp, -922337203685477580},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input+" "+c.currency+"/"+string(c.rounding), func(t *testing.T) {
+			got, err := ParseMoney(c.input, c.currency, c.rounding)
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected, got)
+		})
+	}
+}
+
+func TestParseMoneyInvalid(t *testing.T) {
+	for _, input := range []string{"", "abc", "1/2", "0x10", "1e1000", "1.2.3", "99999999999999999999"} {
+		_, err := ParseMoney(input, "THB", RoundHalfUp)
+		assert.Error(t, err, input)
+	}
+
+	// Amounts whose Rescale would overflow are too large.
+	for currency, input := range map[string]string{"JPY": "1e16", "THB": "-9223372036854775.81", "KWD": "9223372036854775.808"} {
+		_, err := ParseMoney(input, currency, RoundHalfUp)
+		assert.Error(t, err, input)
+	}
+
+	_, err := ParseMoney("0.125", "THB", RoundReject)
+	assert.Equal(t, ErrTooPrecise, err)
+
+	_, err = ParseMoney("12.34", "JPY", RoundReject)
+	assert.Equal(t, ErrTooPrecise, err)
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var expense Expense
+
+	err := json.Unmarshal([]byte(`{"amount": "79.50"}`), &expense)
+	assert.NoError(t, err)
+	assert.Equal(t, Money(7950), expense.Amount)
+
+	err = json.Unmarshal([]byte(`{"amount": 79.5}`), &expense)
+	assert.NoError(t, err)
+	assert.Equal(t, Money(7950), expense.Amount)
+
+	err = json.Unmarshal([]byte(`{"amount": true}`), &expense)
+	assert.Error(t, err)
+
+	for amount, expected := range map[Money]string{7900: "79", 7950: "79.5", 7905: "79.05", 0: "0", -50: "-0.5", -1000: "-10", -1234: "-12.34"} {
+		got, err := json.Marshal(amount)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(got))
+	}
+
+	assert.Equal(t, "-0.50", Money(-50).String())
+	assert.Equal(t, "-12.34", Money(-1234).String())
+}
+
+func TestMoneyInCurrency(t *testing.T) {
+	var expense Expense
+
+	err := json.Unmarshal([]byte(`{"amount": 1.005, "currency": "KWD"}`), &expense)
+	assert.NoError(t, err)
+	assert.Equal(t, Money(1005), expense.Amount)
+
+	err = json.Unmarshal([]byte(`{"amount": "1000", "currency": "jpy"}`), &expense)
+	assert.NoError(t, err)
+	assert.Equal(t, Money(1000), expense.Amount)
+
+	DefaultRounding = RoundReject
+	err = json.Unmarshal([]byte(`{"amount": 12.34, "currency": "JPY"}`), &expense)
+	DefaultRounding = RoundHalfUp
+	assert.Equal(t, ErrTooPrecise, err)
+
+	data, err := json.Marshal(Expense{Amount: 1005, Currency: "KWD", AmountInBase: new(Money), BaseCurrency: "JPY"})
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"amount":1.005,"note"`)
+	assert.Contains(t, string(data), `"amount_in_base":0,"base_currency":"JPY"`)
+
+	status := BudgetStatus{Budget: Budget{Tag: "food", Amount: 5000, Currency: "JPY"}, Spent: 1005, Remaining: 3995}
+	data, err = json.Marshal(status)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"amount":5000,"currency":"JPY"`)
+	assert.Contains(t, string(data), `"starts_on":"","period_start":""`)
+	assert.Contains(t, string(data), `"spent":1005,"remaining":3995`)
+
+	var decoded BudgetStatus
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, status, decoded)
+
+	assert.Equal(t, "1000", Money(1000).Format("JPY"))
+	assert.Equal(t, "-1.005", Money(-1005).Format("KWD"))
+	assert.Equal(t, "79.50", Money(7950).Format("THB"))
+	assert.Equal(t, Money(1005), Money(1005).Rescale("KWD"))
+	assert.Equal(t, Money(79500), Money(7950).Rescale("THB"))
+	assert.Equal(t, Money(1000000), Money(1000).Rescale("JPY"))
+}
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		amount   Money
+		rate     string
+		from, to string
+		expected Money
+	}{
+		{450, "35.5", "USD", "THB", 15975},
+		{1000, "0.24", "JPY", "THB", 24000},
+		{1005, "3.25", "KWD", "USD", 327},
+		{10000, "0.0069", "THB", "JPY", 1},
+		{10000, "0.308", "USD", "KWD", 30800},
+	}
+
+	for _, c := range cases {
+		rate, _ := new(big.Rat).SetString(c.rate)
+
+		got, err := c.amount.Convert(rate, c.from, c.to)
+
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, got, c.from+" to "+c.to)
+	}
+}
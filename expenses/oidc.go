@@ -0,0 +1,166 @@
+package expenses
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// loginCookie is the cookie keeping the sealed login between StartOIDCLogin
+// and the callback of the identity provider.
+const loginCookie = "oidc_login"
+
+// oidcConfigured reports whether single sign-on is configured.
+func (handler Handler) oidcConfigured() bool {
+	return handler.OIDC != nil && handler.Tokens != nil
+}
+
+// setLoginCookie sets the cookie of a sealed login for maxAge.
+// A negative maxAge deletes the cookie.
+func (handler Handler) setLoginCookie(c echo.Context, sealed string, maxAge time.Duration) {
+	c.SetCookie(&http.Cookie{
+		Name:     loginCookie,
+		Value:    sealed,
+		Path:     "/auth/oidc",
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   strings.HasPrefix(handler.OIDC.RedirectURL, "https://"),
+		HttpOnly: true,
+		// Lax cookies are sent with the redirect of the provider back to the callback.
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// OIDCLinkResponse is the response of LinkOIDC.
+type OIDCLinkResponse struct {
+	URL string `json:"url"`
+}
+
+// startLogin starts a login at the identity provider, which links the account
+// to the user of userID, or logs in when it is 0. It sets the cookie of the
+// login and returns the URL of the provider, or the status of its error.
+func (handler Handler) startLogin(c echo.Context, userID int) (string, int, error) {
+
+	login, err := handler.OIDC.NewLogin()
+	if err != nil {
+		return "", http.StatusInternalServerError, errors.New("cannot start the login. " + err.Error())
+	}
+	login.UserID = userID
+
+	authURL, err := handler.OIDC.AuthCodeURL(c.Request().Context(), login)
+	if err != nil {
+		return "", http.StatusBadGateway, errors.New("cannot reach the identity provider. " + err.Error())
+	}
+
+	sealed, err := handler.Tokens.SealLogin(login)
+	if err != nil {
+		return "", http.StatusInternalServerError, errors.New("cannot start the login. " + err.Error())
+	}
+
+	handler.setLoginCookie(c, sealed, time.Unix(login.ExpiresAt, 0).Sub(handler.OIDC.Now()))
+
+	return authURL, http.StatusOK, nil
+}
+
+// StartOIDCLogin handles HTTP GET request to log in with the identity provider.
+// It redirects to the provider, which redirects back to OIDCCallback.
+// It responds with 404 Not Found when single sign-on is not configured.
+func (handler Handler) StartOIDCLogin(c echo.Context) error {
+
+	if !handler.oidcConfigured() {
+		return c.JSON(http.StatusNotFound, ErrorResponse{"single sign-on is not configured."})
+	}
+
+	authURL, status, err := handler.startLogin(c, 0)
+	if err != nil {
+		return c.JSON(status, ErrorResponse{err.Error()})
+	}
+
+	return c.Redirect(http.StatusFound, authURL)
+}
+
+// LinkOIDC handles HTTP POST request to link an account of the identity
+// provider to the user of the request. It responds with the URL of the
+// provider for the browser to open, which redirects back to OIDCCallback.
+func (handler Handler) LinkOIDC(c echo.Context) error {
+
+	if !handler.oidcConfigured() {
+		return c.JSON(http.StatusNotFound, ErrorResponse{"single sign-on is not configured."})
+	}
+
+	userID := auth.UserID(c.Request().Context())
+	if userID == 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"the request has no user."})
+	}
+
+	authURL, status, err := handler.startLogin(c, userID)
+	if err != nil {
+		return c.JSON(status, ErrorResponse{err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, OIDCLinkResponse{authURL})
+}
+
+// OIDCCallback handles HTTP GET request of the identity provider redirecting
+// the user back with an authorization code. It provisions the user of the
+// account on its first login, or links the account to the user who started
+// the login with LinkOIDC, and responds with a token like Login.
+func (handler Handler) OIDCCallback(c echo.Context) error {
+
+	if !handler.oidcConfigured() {
+		return c.JSON(http.StatusNotFound, ErrorResponse{"single sign-on is not configured."})
+	}
+
+	if reason := c.QueryParam("error"); reason != "" {
+		return c.JSON(http.StatusUnauthorized,
+			ErrorResponse{strings.TrimSpace("the identity provider refused the login. " + reason + " " + c.QueryParam("error_description"))})
+	}
+
+	cookie, err := c.Cookie(loginCookie)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{auth.ErrInvalidLogin.Error() + "."})
+	}
+	// The login is used once.
+	handler.setLoginCookie(c, "", -time.Second)
+
+	login, err := handler.Tokens.OpenLogin(cookie.Value, c.QueryParam("state"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error() + "."})
+	}
+
+	ctx := c.Request().Context()
+
+	token, err := handler.OIDC.Exchange(ctx, c.QueryParam("code"), login)
+	switch {
+	case err == nil:
+	case errors.Is(err, auth.ErrLoginRejected), errors.Is(err, auth.ErrInvalidIDToken):
+		return c.JSON(http.StatusUnauthorized, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusBadGateway,
+			ErrorResponse{"cannot reach the identity provider. " + err.Error()})
+	}
+
+	var user auth.User
+	if login.UserID != 0 {
+		user, err = auth.LinkAccount(ctx, handler.Users, login.UserID, token.Issuer, token.Subject)
+	} else {
+		var name string
+		if name, err = handler.OIDC.Name(token); err != nil {
+			return c.JSON(http.StatusForbidden, ErrorResponse{"cannot name the user. " + err.Error()})
+		}
+		user, err = auth.ProvisionUser(ctx, handler.Users, token.Issuer, token.Subject, name, handler.OIDC.NamedByEmail())
+	}
+
+	switch err {
+	case nil:
+		return handler.respondToken(c, user)
+	case auth.ErrAccountNotLinked, auth.ErrAccountLinked:
+		return c.JSON(http.StatusConflict, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot provision the user. " + err.Error()})
+	}
+}
@@ -0,0 +1,241 @@
+package expenses
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newStubIdP serves an identity provider which logs in the account
+// peem@example.com at once.
+func newStubIdP(t *testing.T) *httptest.Server {
+
+	public, private, _ := ed25519.GenerateKey(rand.Reader)
+	challenges := map[string]url.Values{}
+
+	var idp *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(auth.Discovery{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		tokens := auth.Tokens{Keys: []auth.SigningKey{{ID: "idp", Algorithm: auth.EdDSA, Public: public}}}
+		json.NewEncoder(w).Encode(tokens.JWKS())
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		challenges["code-1"] = r.URL.Query()
+		http.Redirect(w, r, r.URL.Query().Get("redirect_uri")+"?code=code-1&state="+r.URL.Query().Get("state"), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		login := challenges[r.PostFormValue("code")]
+		if login == nil || auth.CodeChallenge(r.PostFormValue("code_verifier")) != login.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		encode := func(v interface{}) string {
+			data, _ := json.Marshal(v)
+			return base64.RawURLEncoding.EncodeToString(data)
+		}
+		input := encode(map[string]string{"alg": "EdDSA", "kid": "idp"}) + "." + encode(map[string]interface{}{
+			"iss": idp.URL, "sub": "248289761001", "aud": "expenses", "nonce": login.Get("nonce"),
+			"iat": testTime.Unix(), "exp": testTime.Add(time.Hour).Unix(),
+			"email": "peem@example.com", "email_verified": true,
+		})
+		idToken := input + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(private, []byte(input)))
+
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+	})
+
+	idp = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+
+	return idp
+}
+
+func newOIDCHandler(idp *httptest.Server) Handler {
+	handler := newLoginHandler()
+	handler.OIDC = &auth.OIDC{
+		Issuer:      idp.URL,
+		ClientID:    "expenses",
+		RedirectURL: "https://expenses.example.com/auth/oidc/callback",
+		Leeway:      time.Minute,
+		Now:         func() time.Time { return testTime },
+	}
+	return handler
+}
+
+// oidcLogin starts a login with handler, follows the redirect to the IdP
+// and returns the callback request the IdP redirects back with.
+func oidcLogin(t *testing.T, handler Handler) *http.Request {
+
+	c, rec := newIDContext(http.MethodGet, "/auth/oidc/login", "", "")
+	handler.StartOIDCLogin(c)
+	assert.Equal(t, http.StatusFound, rec.Code)
+
+	return followIdP(t, rec.Header().Get(echo.HeaderLocation), rec.Result().Cookies())
+}
+
+// followIdP opens the URL of the IdP and returns the callback request
+// the IdP redirects back with, carrying the cookies of the login.
+func followIdP(t *testing.T, authURL string, cookies []*http.Cookie) *http.Request {
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	callback := httptest.NewRequest(http.MethodGet, res.Header.Get(echo.HeaderLocation), nil)
+	for _, cookie := range cookies {
+		callback.AddCookie(cookie)
+	}
+
+	return callback
+}
+
+func TestOIDCLogin(t *testing.T) {
+	// Arrange
+	idp := newStubIdP(t)
+	handler := newOIDCHandler(idp)
+
+	// Act
+	c, startRec := newIDContext(http.MethodGet, "/auth/oidc/login", "", "")
+	handler.StartOIDCLogin(c)
+
+	rec := httptest.NewRecorder()
+	handler.OIDCCallback(echo.New().NewContext(oidcLogin(t, handler), rec))
+	var response TokenResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	claims, verifyErr := handler.Tokens.Verify(response.AccessToken)
+
+	againRec := httptest.NewRecorder()
+	handler.OIDCCallback(echo.New().NewContext(oidcLogin(t, handler), againRec))
+
+	users, _ := handler.Users.ListUsers(c.Request().Context())
+
+	// Assert
+	cookie := startRec.Result().Cookies()[0]
+	assert.Equal(t, "oidc_login", cookie.Name)
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, 600, cookie.MaxAge)
+	assert.Contains(t, startRec.Header().Get(echo.HeaderLocation), idp.URL+"/authorize?")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Bearer", response.TokenType)
+	assert.NoError(t, verifyErr)
+	assert.Equal(t, "3", claims.Subject)
+	assert.Equal(t, "peem@example.com", claims.Name)
+	assert.Equal(t, http.StatusOK, againRec.Code)
+	assert.Len(t, users, 3)
+}
+
+func TestOIDCLink(t *testing.T) {
+	// Arrange
+	idp := newStubIdP(t)
+	handler := newOIDCHandler(idp)
+	hash, _ := auth.HashPassword("correct horse")
+	handler.Users.CreateUser(context.Background(), &auth.User{Name: "peem@example.com"}, hash)
+
+	// Act
+	conflictRec := httptest.NewRecorder()
+	handler.OIDCCallback(echo.New().NewContext(oidcLogin(t, handler), conflictRec))
+
+	c, linkRec := newIDContext(http.MethodPost, "/auth/oidc/link", "", "")
+	asUser(c, 3)
+	handler.LinkOIDC(c)
+	var link OIDCLinkResponse
+	assert.NoError(t, json.Unmarshal(linkRec.Body.Bytes(), &link))
+
+	rec := httptest.NewRecorder()
+	handler.OIDCCallback(echo.New().NewContext(followIdP(t, link.URL, linkRec.Result().Cookies()), rec))
+	var response TokenResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	claims, _ := handler.Tokens.Verify(response.AccessToken)
+
+	loginRec := httptest.NewRecorder()
+	handler.OIDCCallback(echo.New().NewContext(oidcLogin(t, handler), loginRec))
+
+	c, otherRec := newIDContext(http.MethodPost, "/auth/oidc/link", "", "")
+	asUser(c, 1)
+	handler.LinkOIDC(c)
+	assert.NoError(t, json.Unmarshal(otherRec.Body.Bytes(), &link))
+	takenRec := httptest.NewRecorder()
+	handler.OIDCCallback(echo.New().NewContext(followIdP(t, link.URL, otherRec.Result().Cookies()), takenRec))
+
+	users, _ := handler.Users.ListUsers(context.Background())
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, conflictRec.Code)
+	assert.Equal(t, http.StatusOK, linkRec.Code)
+	assert.Contains(t, link.URL, idp.URL+"/authorize?")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "3", claims.Subject)
+	assert.Equal(t, http.StatusOK, loginRec.Code)
+	assert.Equal(t, http.StatusConflict, takenRec.Code)
+	assert.Len(t, users, 3)
+}
+
+func TestOIDCCallbackErrors(t *testing.T) {
+	idp := newStubIdP(t)
+	handler := newOIDCHandler(idp)
+
+	// Without the cookie of the login.
+	callback := oidcLogin(t, handler)
+	callback.Header.Del("Cookie")
+	rec := httptest.NewRecorder()
+	handler.OIDCCallback(echo.New().NewContext(callback, rec))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// With the state of another login.
+	callback = oidcLogin(t, handler)
+	query := callback.URL.Query()
+	query.Set("state", "another state")
+	callback.URL.RawQuery = query.Encode()
+	rec = httptest.NewRecorder()
+	handler.OIDCCallback(echo.New().NewContext(callback, rec))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// With a code the IdP did not issue.
+	callback = oidcLogin(t, handler)
+	query = callback.URL.Query()
+	query.Set("code", "code-2")
+	callback.URL.RawQuery = query.Encode()
+	rec = httptest.NewRecorder()
+	handler.OIDCCallback(echo.New().NewContext(callback, rec))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	c, rec := newIDContext(http.MethodGet, "/auth/oidc/callback?error=access_denied", "", "")
+	handler.OIDCCallback(c)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Contains(t, rec.Body.String(), "access_denied")
+
+	handler.OIDC = nil
+	c, rec = newIDContext(http.MethodGet, "/auth/oidc/login", "", "")
+	handler.StartOIDCLogin(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = newIDContext(http.MethodPost, "/auth/oidc/link", "", "")
+	asUser(c, 1)
+	handler.LinkOIDC(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
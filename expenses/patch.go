@@ -0,0 +1,543 @@
+package expenses
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Media types of the patch formats accepted by PatchExpense.
+const (
+	MIMEMergePatch = "application/merge-patch+json"
+	MIMEJSONPatch  = "application/json-patch+json"
+)
+
+// PatchError is returned when a patch is valid but cannot be applied,
+// such as a path that does not exist or a failed test operation.
+type PatchError struct {
+	Message string
+}
+
+func (err *PatchError) Error() string {
+	return err.Message
+}
+
+func patchErrorf(format string, args ...interface{}) error {
+	return &PatchError{fmt.Sprintf(format, args...)}
+}
+
+// PatchOperation is one operation of a JSON Patch (RFC 6902).
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// expenseDocument holds the fields of an expense which can be patched.
+// Amount has the decimals of Currency, which the patch may change.
+type expenseDocument struct {
+	Title    string          `json:"title"`
+	Amount   json.RawMessage `json:"amount"`
+	Note     string          `json:"note"`
+	Tags     []string        `json:"tags"`
+	Currency string          `json:"currency"`
+	SpentAt  string          `json:"spent_at"`
+}
+
+// decodeJSON decodes with json.Number so amounts stay exact.
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected data after the JSON value")
+	}
+
+	return value, nil
+}
+
+// applyDocument runs patch on the patchable fields of expense.
+func applyDocument(expense *Expense, patch func(doc interface{}) (interface{}, error)) error {
+
+	// Empty tags are [] rather than null so JSON Patch can add to them.
+	tags := expense.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	data, _ := json.Marshal(expenseDocument{
+		Title:    expense.Title,
+		Amount:   expense.Amount.jsonIn(expense.Currency),
+		Note:     expense.Note,
+		Tags:     tags,
+		Currency: expense.Currency,
+		SpentAt:  expense.SpentAt.UTC().Format(time.RFC3339Nano),
+	})
+	doc, _ := decodeJSON(data)
+
+	doc, err := patch(doc)
+	if err != nil {
+		return err
+	}
+
+	data, _ = json.Marshal(doc)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var patched expenseDocument
+	if err := decoder.Decode(&patched); err != nil {
+		return patchErrorf("the patched expense is invalid. %s", err.Error())
+	}
+
+	currency, err := NormalizeCurrency(patched.Currency)
+	if err != nil {
+		return patchErrorf("%s", err.Error())
+	}
+	var amount Money
+	if err := amount.unmarshalIn(patched.Amount, currency); err != nil {
+		return patchErrorf("invalid amount. %s", err.Error())
+	}
+	spentAt, err := ParseTime(patched.SpentAt, DefaultTimezone)
+	if err != nil {
+		return patchErrorf("invalid spent_at. %s", err.Error())
+	}
+
+	expense.Title = patched.Title
+	expense.Amount = amount
+	expense.Note = patched.Note
+	expense.Tags = NormalizeTags(patched.Tags)
+	expense.Currency = currency
+	expense.SpentAt = spentAt
+
+	if err := checkText(*expense); err != nil {
+		return patchErrorf("%s", err.Error())
+	}
+
+	return nil
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to target.
+func MergePatch(target interface{}, patch interface{}) interface{} {
+
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = MergePatch(targetObject[name], value)
+		}
+	}
+
+	return targetObject
+}
+
+// ParseJSONPatch reads and checks the operations of a JSON Patch.
+func ParseJSONPatch(data []byte) ([]PatchOperation, error) {
+
+	var operations []PatchOperation
+	if err := json.Unmarshal(data, &operations); err != nil {
+		return nil, err
+	}
+
+	for i, operation := range operations {
+		switch operation.Op {
+		case "add", "replace", "test":
+			if len(operation.Value) == 0 {
+				return nil, fmt.Errorf("operation %d: %s requires a value", i, operation.Op)
+			}
+		case "move", "copy":
+			if _, err := parsePointer(operation.From); err != nil {
+				return nil, fmt.Errorf("operation %d: %s", i, err.Error())
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("operation %d: unknown op %q", i, operation.Op)
+		}
+		if _, err := parsePointer(operation.Path); err != nil {
+			return nil, fmt.Errorf("operation %d: %s", i, err.Error())
+		}
+	}
+
+	return operations, nil
+}
+
+// ApplyJSONPatch applies the operations to doc in order.
+// When an operation fails the returned error is a *PatchError.
+func ApplyJSONPatch(doc interface{}, operations []PatchOperation) (interface{}, error) {
+
+	for i, operation := range operations {
+		var err error
+		doc, err = applyOperation(doc, operation)
+		if err != nil {
+			return nil, patchErrorf("operation %d (%s %s): %s", i, operation.Op, operation.Path, err.Error())
+		}
+	}
+
+	return doc, nil
+}
+
+func applyOperation(doc interface{}, operation PatchOperation) (interface{}, error) {
+
+	path, _ := parsePointer(operation.Path)
+
+	var value interface{}
+	if len(operation.Value) > 0 {
+		var err error
+		if value, err = decodeJSON(operation.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	switch operation.Op {
+
+	case "add":
+		return addValue(doc, path, value)
+
+	case "remove":
+		doc, _, err := removeValue(doc, path)
+		return doc, err
+
+	case "replace":
+		doc, _, err := removeValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+
+	case "move":
+		from, _ := parsePointer(operation.From)
+		if strings.HasPrefix(operation.Path+"/", operation.From+"/") && operation.Path != operation.From {
+			return nil, errors.New("cannot move a value into itself")
+		}
+		doc, moved, err := removeValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, moved)
+
+	case "copy":
+		from, _ := parsePointer(operation.From)
+		copied, err := getValue(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		data, _ := json.Marshal(copied)
+		copied, _ = decodeJSON(data)
+		return addValue(doc, path, copied)
+
+	case "test":
+		current, err := getValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(current, value) {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("unknown op %q", operation.Op)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid path %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token. "-" is the end of the array when allowed.
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	limit := length - 1
+	if allowEnd {
+		limit = length
+	}
+	if index > limit {
+		return 0, fmt.Errorf("array index %d is out of range", index)
+	}
+
+	return index, nil
+}
+
+func getValue(doc interface{}, path []string) (interface{}, error) {
+
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%q does not exist", token)
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[index]
+		default:
+			return nil, fmt.Errorf("%q does not exist", token)
+		}
+	}
+
+	return doc, nil
+}
+
+// addValue sets the value at path, inserting into arrays, and returns the new document.
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := getValue(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+
+	case map[string]interface{}:
+		node[last] = value
+		return doc, nil
+
+	case []interface{}:
+		index, err := arrayIndex(last, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		node = append(node, nil)
+		copy(node[index+1:], node[index:])
+		node[index] = value
+		return setValue(doc, path[:len(path)-1], node)
+	}
+
+	return nil, fmt.Errorf("cannot add to %q", strings.Join(path[:len(path)-1], "/"))
+}
+
+// removeValue deletes the value at path and returns the new document and the removed value.
+func removeValue(doc interface{}, path []string) (interface{}, interface{}, error) {
+
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	parent, err := getValue(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+
+	case map[string]interface{}:
+		value, ok := node[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("%q does not exist", last)
+		}
+		delete(node, last)
+		return doc, value, nil
+
+	case []interface{}:
+		index, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[index]
+		node = append(node[:index:index], node[index+1:]...)
+		doc, err = setValue(doc, path[:len(path)-1], node)
+		return doc, value, err
+	}
+
+	return nil, nil, fmt.Errorf("%q does not exist", last)
+}
+
+// setValue replaces the value at an existing path, used after resizing arrays.
+func setValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := getValue(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+	case []interface{}:
+		index, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = value
+	}
+
+	return doc, nil
+}
+
+// jsonEqual compares decoded JSON values, numbers by their exact value.
+func jsonEqual(a interface{}, b interface{}) bool {
+
+	switch a := a.(type) {
+
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okX := new(big.Rat).SetString(a.String())
+		y, okY := new(big.Rat).SetString(b.String())
+		return okX && okY && x.Cmp(y) == 0
+
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for name, value := range a {
+			other, ok := b[name]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// PatchExpense handles HTTP PATCH request to change some fields of an expense.
+// The body is a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902)
+// chosen by Content-Type. The patch is applied in a single transaction.
+func (handler Handler) PatchExpense(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"cannot read request's body. " + err.Error()})
+	}
+
+	var patch func(doc interface{}) (interface{}, error)
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+
+	switch mediaType {
+
+	case MIMEMergePatch:
+		mergePatch, err := decodeJSON(body)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid merge patch. " + err.Error()})
+		}
+		if _, ok := mergePatch.(map[string]interface{}); !ok {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"a merge patch must be a JSON object."})
+		}
+		patch = func(doc interface{}) (interface{}, error) {
+			return MergePatch(doc, mergePatch), nil
+		}
+
+	case MIMEJSONPatch:
+		operations, err := ParseJSONPatch(body)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid JSON patch. " + err.Error()})
+		}
+		patch = func(doc interface{}) (interface{}, error) {
+			return ApplyJSONPatch(doc, operations)
+		}
+
+	default:
+		return c.JSON(http.StatusUnsupportedMediaType,
+			ErrorResponse{"Content-Type must be " + MIMEMergePatch + " or " + MIMEJSONPatch + "."})
+	}
+
+	expense, err := handler.Store.Modify(c.Request().Context(), id, func(expense *Expense) error {
+		return applyDocument(expense, patch)
+	})
+
+	var patchError *PatchError
+
+	switch {
+
+	case err == nil:
+		handler.evaluateAlerts(c, expense)
+		return c.JSON(http.StatusOK, expense)
+
+	case err == ErrNotFound:
+		return c.JSON(http.StatusNotFound,
+			ErrorResponse{"cannot find the expense of that id. " + err.Error()})
+
+	case errors.As(err, &patchError):
+		return c.JSON(http.StatusUnprocessableEntity,
+			ErrorResponse{"cannot apply the patch. " + err.Error()})
+
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot update the expense. " + err.Error()})
+	}
+}
@@ -0,0 +1,128 @@
+package expenses
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func patchExpense(store ExpenseStore, contentType string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/expenses/1", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+
+	e := echo.New()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	handler := Handler{Store: store}
+	handler.PatchExpense(c)
+
+	return rec
+}
+
+func newPatchStore() *MemoryStore {
+	store := newTestStore()
+	store.Create(context.Background(), &Expense{
+		Title: "smoothie", Amount: 7900, Note: "before", Tags: []string{"food", "beverage"}, Currency: "THB",
+	})
+	return store
+}
+
+func TestPatchExpenseMergePatch(t *testing.T) {
+	// Arrange
+	store := newPatchStore()
+
+	// Act
+	rec := patchExpense(store, MIMEMergePatch, `{"note": "after", "amount": "80.25"}`)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	got, _ := store.Get(context.Background(), 1)
+	assert.Equal(t, Expense{
+		ID: 1, Title: "smoothie", Amount: 8025, Note: "after", Tags: []string{"food", "beverage"}, Currency: "THB",
+		SpentAt: testTime, CreatedAt: testTime, UpdatedAt: testTime,
+	}, got)
+}
+
+func TestPatchExpenseMergePatchRemovesTags(t *testing.T) {
+	// Arrange
+	store := newPatchStore()
+
+	// Act
+	rec := patchExpense(store, MIMEMergePatch, `{"tags": null}`)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	got, _ := store.Get(context.Background(), 1)
+	assert.Nil(t, got.Tags)
+	assert.Equal(t, "before", got.Note)
+}
+
+func TestPatchExpenseJSONPatch(t *testing.T) {
+	// Arrange
+	store := newPatchStore()
+
+	// Act
+	rec := patchExpense(store, MIMEJSONPatch, `[
+		{"op": "test", "path": "/amount", "value": 79.00},
+		{"op": "remove", "path": "/tags/0"},
+		{"op": "add", "path": "/tags/-", "value": "smoothie"},
+		{"op": "add", "path": "/tags/0", "value": "drink"},
+		{"op": "copy", "from": "/title", "path": "/note"},
+		{"op": "replace", "path": "/currency", "value": "usd"}
+	]`)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	got, _ := store.Get(context.Background(), 1)
+	assert.Equal(t, Expense{
+		ID: 1, Title: "smoothie", Amount: 7900, Note: "smoothie",
+		Tags: []string{"drink", "beverage", "smoothie"}, Currency: "USD",
+		SpentAt: testTime, CreatedAt: testTime, UpdatedAt: testTime,
+	}, got)
+}
+
+func TestPatchExpenseFailures(t *testing.T) {
+	cases := map[string]struct {
+		contentType string
+		body        string
+		code        int
+	}{
+		"unsupported media type": {echo.MIMEApplicationJSON, `{"note": "x"}`, http.StatusUnsupportedMediaType},
+		"merge patch not object": {MIMEMergePatch, `["note"]`, http.StatusBadRequest},
+		"unknown op":             {MIMEJSONPatch, `[{"op": "swap", "path": "/note"}]`, http.StatusBadRequest},
+		"missing value":          {MIMEJSONPatch, `[{"op": "add", "path": "/note"}]`, http.StatusBadRequest},
+		"failed test":            {MIMEJSONPatch, `[{"op": "test", "path": "/title", "value": "latte"}]`, http.StatusUnprocessableEntity},
+		"missing path":           {MIMEJSONPatch, `[{"op": "remove", "path": "/tags/5"}]`, http.StatusUnprocessableEntity},
+		"read only field":        {MIMEJSONPatch, `[{"op": "add", "path": "/id", "value": 2}]`, http.StatusUnprocessableEntity},
+		"invalid amount":         {MIMEMergePatch, `{"amount": "lots"}`, http.StatusUnprocessableEntity},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			store := newPatchStore()
+
+			// Act
+			rec := patchExpense(store, tc.contentType, tc.body)
+
+			// Assert
+			assert.Equal(t, tc.code, rec.Code)
+			got, _ := store.Get(context.Background(), 1)
+			assert.Equal(t, "before", got.Note, "a failed patch must not change the expense")
+		})
+	}
+}
+
+func TestPatchExpenseNotFound(t *testing.T) {
+	rec := patchExpense(NewMemoryStore(), MIMEMergePatch, `{"note": "after"}`)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
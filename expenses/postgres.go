@@ -0,0 +1,638 @@
+package expenses
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/lib/pq"
+)
+
+// PostgresStore is an ExpenseStore, TagStore and ReportStore backed by the expenses and tags tables.
+// Every query is scoped to the workspace of auth.WorkspaceID and to the expenses
+// of the user of auth.UserID, or of every user of the workspace with auth.AllUsers.
+type PostgresStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresStore returns a PostgresStore using db.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+// expenseColumns are the columns read by scanExpense.
+const expenseColumns = "id, title, amount, note, tags, currency, spent_at, created_at, updated_at, deleted_at"
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanExpense scans a row of expenseColumns followed by the extra columns.
+// Postgres returns an empty array as "{}", which is turned into nil tags.
+func scanExpense(row scanner, extra ...interface{}) (Expense, error) {
+	var expense Expense
+	var tags pq.StringArray
+	var deletedAt sql.NullTime
+
+	dest := append([]interface{}{&expense.ID, &expense.Title, &expense.Amount, &expense.Note, &tags,
+		&expense.Currency, &expense.SpentAt, &expense.CreatedAt, &expense.UpdatedAt, &deletedAt}, extra...)
+
+	err := row.Scan(dest...)
+	if err != nil {
+		return Expense{}, err
+	}
+
+	if len(tags) > 0 {
+		expense.Tags = []string(tags)
+	}
+	if deletedAt.Valid {
+		deleted := deletedAt.Time.UTC()
+		expense.DeletedAt = &deleted
+	}
+	expense.SpentAt = expense.SpentAt.UTC()
+	expense.CreatedAt = expense.CreatedAt.UTC()
+	expense.UpdatedAt = expense.UpdatedAt.UTC()
+
+	return expense, nil
+}
+
+// nullTime is NULL for the zero time.
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func insertExpense(ctx context.Context, db queryer, expense *Expense) error {
+
+	row := db.QueryRowContext(ctx, `
+		INSERT INTO expenses (workspace_id, owner_id, title, amount, note, tags, currency, spent_at)
+		values ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, now()))
+		RETURNING id, spent_at, created_at, updated_at
+	`, auth.WorkspaceID(ctx), auth.UserID(ctx), expense.Title, expense.Amount, expense.Note, pq.Array(expense.Tags), expense.Currency, nullTime(expense.SpentAt))
+
+	err := row.Scan(&expense.ID, &expense.SpentAt, &expense.CreatedAt, &expense.UpdatedAt)
+	expense.SpentAt = expense.SpentAt.UTC()
+	expense.CreatedAt = expense.CreatedAt.UTC()
+	expense.UpdatedAt = expense.UpdatedAt.UTC()
+
+	return err
+}
+
+func (store *PostgresStore) Create(ctx context.Context, expense *Expense) error {
+	return insertExpense(ctx, store.DB, expense)
+}
+
+// insertRows is the number of rows of a multi-row INSERT, which keeps
+// its parameters below the limit of 65535.
+const insertRows = 1000
+
+// insertExpenses inserts expenses with multi-row INSERTs and sets their IDs.
+// Postgres returns the rows in the order of VALUES.
+func insertExpenses(ctx context.Context, db queryer, expenses []Expense) error {
+
+	for start := 0; start < len(expenses); start += insertRows {
+		chunk := expenses[start:]
+		if len(chunk) > insertRows {
+			chunk = chunk[:insertRows]
+		}
+
+		var args []interface{}
+		arg := placeholders(&args)
+		workspace, owner := arg(auth.WorkspaceID(ctx)), arg(auth.UserID(ctx))
+		values := make([]string, len(chunk))
+		for i, expense := range chunk {
+			values[i] = fmt.Sprintf("(%s, %s, %s, %s, %s, %s, %s, COALESCE(%s::timestamptz, now()))", workspace, owner,
+				arg(expense.Title), arg(expense.Amount), arg(expense.Note), arg(pq.Array(expense.Tags)),
+				arg(expense.Currency), arg(nullTime(expense.SpentAt)))
+		}
+
+		rows, err := db.QueryContext(ctx, `
+			INSERT INTO expenses (workspace_id, owner_id, title, amount, note, tags, currency, spent_at)
+			VALUES `+strings.Join(values, ", ")+`
+			RETURNING id, spent_at, created_at, updated_at
+		`, args...)
+		if err != nil {
+			return err
+		}
+
+		i := 0
+		for ; rows.Next() && i < len(chunk); i++ {
+			expense := &chunk[i]
+			if err := rows.Scan(&expense.ID, &expense.SpentAt, &expense.CreatedAt, &expense.UpdatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			expense.SpentAt = expense.SpentAt.UTC()
+			expense.CreatedAt = expense.CreatedAt.UTC()
+			expense.UpdatedAt = expense.UpdatedAt.UTC()
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if i != len(chunk) {
+			return fmt.Errorf("inserted %d of %d expenses", i, len(chunk))
+		}
+	}
+
+	return nil
+}
+
+func (store *PostgresStore) CreateAll(ctx context.Context, expenses []Expense) error {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertExpenses(ctx, tx, expenses); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresStore) Batch(ctx context.Context, operations []BatchOperation, atomic bool) ([]error, error) {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// The handler checks the expenses, so that the insert of every create
+	// does not fail for one of them.
+	var created []Expense
+	for _, operation := range operations {
+		if operation.Op == BatchCreate {
+			created = append(created, *operation.Expense)
+		}
+	}
+	if err := insertExpenses(ctx, tx, created); err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, len(operations))
+	failed := false
+
+	for i, operation := range operations {
+		if operation.Op == BatchCreate {
+			*operation.Expense, created = created[0], created[1:]
+			continue
+		}
+
+		// In best effort mode an operation runs in a savepoint, so that
+		// its failure does not abort the transaction of the others.
+		if !atomic {
+			if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_operation"); err != nil {
+				return nil, err
+			}
+		}
+
+		switch operation.Op {
+		case BatchUpdate:
+			errs[i] = updateExpense(ctx, tx, operation.Expense)
+		case BatchDelete:
+			errs[i] = deleteExpense(ctx, tx, operation.ID)
+		}
+
+		switch {
+		case atomic && errs[i] == ErrNotFound:
+			failed = true
+		case atomic && errs[i] != nil:
+			return nil, errs[i]
+		case errs[i] != nil:
+			if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_operation"); err != nil {
+				return nil, err
+			}
+		default:
+			if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_operation"); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if atomic && failed {
+		return errs, nil
+	}
+
+	return errs, tx.Commit()
+}
+
+func (store *PostgresStore) Imported(ctx context.Context, transactionIDs []string) (map[string]bool, error) {
+
+	rows, err := store.DB.QueryContext(ctx,
+		"SELECT transaction_id FROM imported_transactions WHERE workspace_id = $1 AND owner_id = $2 AND transaction_id = ANY($3)",
+		auth.WorkspaceID(ctx), auth.UserID(ctx), pq.Array(transactionIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	imported := map[string]bool{}
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		imported[id] = true
+	}
+
+	return imported, rows.Err()
+}
+
+func (store *PostgresStore) CreateImported(ctx context.Context, expenses []Expense, transactionIDs []string) error {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range expenses {
+		// Claiming the transaction first makes a concurrent import of it wait,
+		// and then skip it once this one commits.
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO imported_transactions (workspace_id, owner_id, transaction_id) VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING
+		`, auth.WorkspaceID(ctx), auth.UserID(ctx), transactionIDs[i])
+		if err != nil {
+			return err
+		}
+		claimed, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if claimed == 0 {
+			expenses[i].ID = 0
+			continue
+		}
+
+		if err := insertExpense(ctx, tx, &expenses[i]); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx,
+			"UPDATE imported_transactions SET expense_id = $1 WHERE workspace_id = $2 AND owner_id = $3 AND transaction_id = $4",
+			expenses[i].ID, auth.WorkspaceID(ctx), auth.UserID(ctx), transactionIDs[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresStore) Get(ctx context.Context, id int) (Expense, error) {
+
+	row := store.DB.QueryRowContext(ctx,
+		"SELECT "+expenseColumns+" FROM expenses WHERE id=$1 AND workspace_id=$2 AND (owner_id=$3 OR $4) AND deleted_at IS NULL",
+		id, auth.WorkspaceID(ctx), auth.UserID(ctx), auth.AllUsers(ctx))
+
+	expense, err := scanExpense(row)
+	if err == sql.ErrNoRows {
+		return Expense{}, ErrNotFound
+	}
+
+	return expense, err
+}
+
+func (store *PostgresStore) Update(ctx context.Context, expense *Expense) error {
+	return updateExpense(ctx, store.DB, expense)
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func updateExpense(ctx context.Context, db queryer, expense *Expense) error {
+
+	row := db.QueryRowContext(ctx, `
+		UPDATE expenses
+		SET title=$2, amount=$3, note=$4, tags=$5, currency=$6,
+			spent_at=COALESCE($7, spent_at), updated_at=now()
+		WHERE id = $1 AND workspace_id = $8 AND (owner_id = $9 OR $10) AND deleted_at IS NULL
+		RETURNING `+expenseColumns,
+		expense.ID, expense.Title, expense.Amount, expense.Note, pq.Array(expense.Tags), expense.Currency,
+		nullTime(expense.SpentAt), auth.WorkspaceID(ctx), auth.UserID(ctx), auth.AllUsers(ctx))
+
+	updated, err := scanExpense(row)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	*expense = updated
+	return nil
+}
+
+func (store *PostgresStore) Modify(ctx context.Context, id int, apply func(expense *Expense) error) (Expense, error) {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Expense{}, err
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx,
+		"SELECT "+expenseColumns+" FROM expenses WHERE id=$1 AND workspace_id=$2 AND (owner_id=$3 OR $4) AND deleted_at IS NULL FOR UPDATE",
+		id, auth.WorkspaceID(ctx), auth.UserID(ctx), auth.AllUsers(ctx))
+
+	expense, err := scanExpense(row)
+	if err == sql.ErrNoRows {
+		return Expense{}, ErrNotFound
+	}
+	if err != nil {
+		return Expense{}, err
+	}
+
+	if err := apply(&expense); err != nil {
+		return Expense{}, err
+	}
+	expense.ID = id
+
+	if err := updateExpense(ctx, tx, &expense); err != nil {
+		return Expense{}, err
+	}
+
+	return expense, tx.Commit()
+}
+
+// rescaledAmountSQL is Money.Rescale of the amount of an expense in SQL.
+// It is NUMERIC so that amounts stored before they were bounded cannot overflow.
+var rescaledAmountSQL = func() string {
+
+	codes := map[int][]string{}
+	for code, exponent := range exponents {
+		codes[exponent] = append(codes[exponent], "'"+code+"'")
+	}
+
+	cases := "amount::numeric * CASE"
+	for exponent := 0; exponent <= maxExponent; exponent++ {
+		if len(codes[exponent]) > 0 {
+			sort.Strings(codes[exponent])
+			cases += fmt.Sprintf(" WHEN currency IN (%s) THEN %s", strings.Join(codes[exponent], ", "), scale(maxExponent-exponent))
+		}
+	}
+	return "(" + cases + fmt.Sprintf(" ELSE %s END)", scale(maxExponent-defaultExponent))
+}()
+
+// scopeSQL returns the SQL condition keeping the expenses the request of ctx sees,
+// those of its workspace and of its user or of every user with auth.AllUsers.
+// arg adds a parameter and returns its placeholder.
+func scopeSQL(ctx context.Context, arg func(interface{}) string) string {
+	return "workspace_id = " + arg(auth.WorkspaceID(ctx)) +
+		" AND (owner_id = " + arg(auth.UserID(ctx)) + " OR " + arg(auth.AllUsers(ctx)) + ")"
+}
+
+// filterSQL returns the SQL conditions of the filters of the query,
+// keeping the expenses the request of ctx sees which are not deleted.
+// arg adds a parameter and returns its placeholder.
+func (query ListQuery) filterSQL(ctx context.Context, arg func(interface{}) string) []string {
+
+	where := []string{scopeSQL(ctx, arg), "deleted_at IS NULL"}
+
+	if query.MinAmount != nil {
+		where = append(where, rescaledAmountSQL+" >= "+arg(*query.MinAmount))
+	}
+	if query.MaxAmount != nil {
+		where = append(where, rescaledAmountSQL+" <= "+arg(*query.MaxAmount))
+	}
+
+	if query.Title != "" {
+		where = append(where, "title ILIKE '%' || "+arg(escapeLike(query.Title))+" || '%'")
+	}
+
+	if len(query.Tags) > 0 {
+		operator := "&&"
+		if query.MatchAllTags {
+			operator = "@>"
+		}
+		where = append(where, "tags "+operator+" "+arg(pq.Array(query.Tags)))
+	}
+
+	if query.Spent.From != nil {
+		where = append(where, "spent_at >= "+arg(*query.Spent.From))
+	}
+	if query.Spent.Before != nil {
+		where = append(where, "spent_at < "+arg(*query.Spent.Before))
+	}
+
+	if query.Search != nil {
+		where = append(where, "("+query.Search.SQL(arg)+")")
+	}
+
+	return where
+}
+
+// placeholders returns a function adding parameters to args
+// and returning their placeholders.
+func placeholders(args *[]interface{}) func(interface{}) string {
+	return func(value interface{}) string {
+		*args = append(*args, value)
+		return fmt.Sprintf("$%d", len(*args))
+	}
+}
+
+// listSQL returns the statement and arguments selecting the expenses
+// the request of ctx sees matching query.
+func (query ListQuery) listSQL(ctx context.Context) (string, []interface{}) {
+
+	var args []interface{}
+	arg := placeholders(&args)
+	where := query.filterSQL(ctx, arg)
+
+	column := query.Sort
+	if !sortColumns[column] {
+		column = "id"
+	}
+	if column == "amount" {
+		column = rescaledAmountSQL
+	}
+	direction, comparison := "ASC", ">"
+	if query.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if query.After != nil {
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			column, comparison, arg(query.After.Value), arg(query.After.ID)))
+	}
+
+	statement := "SELECT " + expenseColumns + " FROM expenses WHERE " + strings.Join(where, " AND ") +
+		fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction)
+
+	if query.Limit > 0 {
+		statement += " LIMIT " + arg(query.Limit)
+	}
+
+	return statement, args
+}
+
+func (store *PostgresStore) List(ctx context.Context, query ListQuery) ([]Expense, error) {
+	statement, args := query.listSQL(ctx)
+	return store.query(ctx, statement, args...)
+}
+
+func (store *PostgresStore) Stream(ctx context.Context, query ListQuery, fn func(expense Expense) error) error {
+
+	statement, args := query.listSQL(ctx)
+
+	rows, err := store.DB.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		expense, err := scanExpense(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(expense); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (store *PostgresStore) Search(ctx context.Context, text string, limit int) ([]SearchResult, error) {
+
+	// Full-text matches rank by ts_rank and misspelled words by trigram word similarity.
+	rows, err := store.DB.QueryContext(ctx, `
+		WITH q AS (SELECT websearch_to_tsquery('simple', $1) AS query)
+		SELECT `+expenseColumns+`,
+			ts_rank(search_vector, q.query) + word_similarity($1, search_text) AS rank,
+			ts_headline('simple', translate(coalesce(title, ''), '`+headlineStart+headlineStop+`', ''), q.query,
+				'StartSel=`+headlineStart+`, StopSel=`+headlineStop+`, HighlightAll=true'),
+			ts_headline('simple', translate(coalesce(note, ''), '`+headlineStart+headlineStop+`', ''), q.query,
+				'StartSel=`+headlineStart+`, StopSel=`+headlineStop+`, MaxFragments=2, MinWords=5, MaxWords=20')
+		FROM expenses, q
+		WHERE workspace_id = $3 AND (owner_id = $4 OR $5) AND deleted_at IS NULL
+			AND (search_vector @@ q.query OR $1 <% search_text)
+		ORDER BY rank DESC, id
+		LIMIT $2
+	`, text, limit, auth.WorkspaceID(ctx), auth.UserID(ctx), auth.AllUsers(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+
+	for rows.Next() {
+		var result SearchResult
+		var err error
+
+		result.Expense, err = scanExpense(rows, &result.Rank, &result.Highlights.Title, &result.Highlights.Note)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Highlights.Title = escapeHeadline(result.Highlights.Title)
+		result.Highlights.Note = escapeHeadline(result.Highlights.Note)
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// query returns the expenses of a query selecting expenseColumns.
+func (store *PostgresStore) query(ctx context.Context, query string, args ...interface{}) ([]Expense, error) {
+
+	rows, err := store.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expenses []Expense
+
+	for rows.Next() {
+		expense, err := scanExpense(rows)
+		if err != nil {
+			return nil, err
+		}
+		expenses = append(expenses, expense)
+	}
+
+	return expenses, rows.Err()
+}
+
+func (store *PostgresStore) Delete(ctx context.Context, id int) error {
+	return deleteExpense(ctx, store.DB, id)
+}
+
+func deleteExpense(ctx context.Context, db queryer, id int) error {
+
+	result, err := db.ExecContext(ctx,
+		"UPDATE expenses SET deleted_at=now() WHERE id=$1 AND workspace_id=$2 AND (owner_id=$3 OR $4) AND deleted_at IS NULL",
+		id, auth.WorkspaceID(ctx), auth.UserID(ctx), auth.AllUsers(ctx))
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (store *PostgresStore) ListTrash(ctx context.Context) ([]Expense, error) {
+	return store.query(ctx,
+		"SELECT "+expenseColumns+" FROM expenses WHERE workspace_id=$1 AND (owner_id=$2 OR $3) AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, id",
+		auth.WorkspaceID(ctx), auth.UserID(ctx), auth.AllUsers(ctx))
+}
+
+func (store *PostgresStore) Restore(ctx context.Context, id int) (Expense, error) {
+
+	row := store.DB.QueryRowContext(ctx, `
+		UPDATE expenses SET deleted_at=NULL
+		WHERE id=$1 AND workspace_id=$2 AND (owner_id=$3 OR $4) AND deleted_at IS NOT NULL
+		RETURNING `+expenseColumns, id, auth.WorkspaceID(ctx), auth.UserID(ctx), auth.AllUsers(ctx))
+
+	expense, err := scanExpense(row)
+	if err == sql.ErrNoRows {
+		return Expense{}, ErrNotFound
+	}
+
+	return expense, err
+}
+
+// Purge removes the deleted expenses of every user of every workspace.
+func (store *PostgresStore) Purge(ctx context.Context, before time.Time) (int, error) {
+
+	result, err := store.DB.ExecContext(ctx,
+		"DELETE FROM expenses WHERE deleted_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
@@ -0,0 +1,225 @@
+package expenses
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+// expenseRowsColumns returns the names of expenseColumns.
+func expenseRowsColumns() []string {
+	return strings.Split(expenseColumns, ", ")
+}
+
+// testTime is the spent_at, created_at and updated_at of mock rows
+// and the time of memory stores made by newTestStore.
+var testTime = time.Date(2026, 9, 15, 5, 30, 0, 0, time.UTC)
+
+// testTimes are the JSON fields of an expense created at testTime.
+const testTimes = `"spent_at":"2026-09-15T05:30:00Z","created_at":"2026-09-15T05:30:00Z","updated_at":"2026-09-15T05:30:00Z"`
+
+// newTestStore returns a MemoryStore whose clock stops at testTime.
+func newTestStore() *MemoryStore {
+	store := NewMemoryStore()
+	store.Now = func() time.Time { return testTime }
+	return store
+}
+
+// expenseRows returns mock rows with the columns of expenseColumns.
+func expenseRows() *sqlmock.Rows {
+	return sqlmock.NewRows(expenseRowsColumns())
+}
+
+func TestPostgresStoreCreate(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectQuery("INSERT INTO expenses .*").
+		WithArgs(0, 0, "smoothie", 7900, "abcd", `{"food","beverage"}`, "THB", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "spent_at", "created_at", "updated_at"}).
+			AddRow(1, testTime, testTime, testTime))
+
+	store := NewPostgresStore(db)
+	expense := Expense{Title: "smoothie", Amount: 7900, Note: "abcd", Tags: []string{"food", "beverage"}, Currency: "THB"}
+
+	// Act
+	err = store.Create(context.Background(), &expense)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expense.ID)
+	assert.Equal(t, testTime, expense.SpentAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStoreGet(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	newsMockRows := expenseRows().
+		AddRow(1, "smoothie", 7900, "unit_test", `{food,beverage}`, "THB", testTime, testTime, testTime, nil)
+
+	mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id=\\$1 AND workspace_id=\\$2 AND \\(owner_id=\\$3 OR \\$4\\) AND deleted_at IS NULL").
+		WithArgs(1, 2, 5, false).
+		WillReturnRows(newsMockRows)
+
+	store := NewPostgresStore(db)
+
+	// Act
+	got, err := store.Get(auth.WithWorkspace(auth.WithUser(context.Background(), 5), 2), 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, Expense{ID: 1, Title: "smoothie", Amount: 7900, Note: "unit_test", Tags: []string{"food", "beverage"}, Currency: "THB",
+		SpentAt: testTime, CreatedAt: testTime, UpdatedAt: testTime}, got)
+}
+
+func TestPostgresStoreGetNotFound(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id=?").
+		WithArgs(1, 0, 0, false).
+		WillReturnRows(expenseRows())
+
+	store := NewPostgresStore(db)
+
+	// Act
+	_, err = store.Get(context.Background(), 1)
+
+	// Assert
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestPostgresStoreUpdateNotFound(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectQuery("UPDATE expenses (.+) WHERE (.+) RETURNING (.+)").
+		WillReturnRows(expenseRows())
+
+	store := NewPostgresStore(db)
+
+	// Act
+	err = store.Update(context.Background(), &Expense{ID: 1, Title: "smoothie"})
+
+	// Assert
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestPostgresStoreList(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	newsMockRows := expenseRows().
+		AddRow(1, "smoothie", 7900, "unit_test", `{food,beverage}`, "THB", testTime, testTime, testTime, nil).
+		AddRow(2, "latte", 8800, "unit_test", `{}`, "THB", testTime, testTime, testTime, nil)
+
+	mock.ExpectQuery("SELECT (.+) FROM expenses").WillReturnRows(newsMockRows)
+
+	store := NewPostgresStore(db)
+
+	// Act
+	got, err := store.List(context.Background(), ListQuery{})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []Expense{
+		{ID: 1, Title: "smoothie", Amount: 7900, Note: "unit_test", Tags: []string{"food", "beverage"}, Currency: "THB",
+			SpentAt: testTime, CreatedAt: testTime, UpdatedAt: testTime},
+		{ID: 2, Title: "latte", Amount: 8800, Note: "unit_test", Tags: nil, Currency: "THB",
+			SpentAt: testTime, CreatedAt: testTime, UpdatedAt: testTime},
+	}, got)
+}
+
+func TestPostgresStoreDeleteNotFound(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectExec("UPDATE expenses SET deleted_at=now\\(\\) WHERE id=(.+) AND workspace_id=(.+) AND \\(owner_id=(.+) OR (.+)\\) AND deleted_at IS NULL").
+		WithArgs(1, 0, 0, false).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	store := NewPostgresStore(db)
+
+	// Act
+	err = store.Delete(context.Background(), 1)
+
+	// Assert
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestPostgresStorePurge(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	before := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec("DELETE FROM expenses WHERE deleted_at < ?").
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	store := NewPostgresStore(db)
+
+	// Act
+	purged, err := store.Purge(context.Background(), before)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, purged)
+}
+
+func TestPostgresStoreModify(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM expenses WHERE id=(.+) FOR UPDATE").
+		WithArgs(1, 0, 0, false).
+		WillReturnRows(expenseRows().AddRow(1, "smoothie", 7900, "before", `{food}`, "THB", testTime, testTime, testTime, nil))
+	mock.ExpectQuery("UPDATE expenses (.+) WHERE (.+) RETURNING (.+)").
+		WithArgs(1, "smoothie", 7900, "after", `{"food"}`, "THB", testTime, 0, 0, false).
+		WillReturnRows(expenseRows().AddRow(1, "smoothie", 7900, "after", `{food}`, "THB", testTime, testTime, testTime, nil))
+	mock.ExpectCommit()
+
+	store := NewPostgresStore(db)
+
+	// Act
+	got, err := store.Modify(context.Background(), 1, func(expense *Expense) error {
+		expense.Note = "after"
+		return nil
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "after", got.Note)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,31 +2,23 @@ package expenses
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
-	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestPutExpense(t *testing.T) {
 	// Arrange
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
+	store := newTestStore()
+	store.Create(context.Background(),
+		&Expense{Title: "latte", Amount: 7900, Note: "before_put", Tags: []string{"coffee"}, Currency: "THB"})
 
-	handler := Handler{DB: db}
-
-	newsMockRows := sqlmock.
-		NewRows([]string{"id", "title", "amount", "note", "tags"}).
-		AddRow(1, "smoothie", 99, "unit_test", `{put_test,beverage}`)
-
-	mock.ExpectPrepare("UPDATE expenses (.+) WHERE (.+) RETURNING (.+)").
-		ExpectQuery().WithArgs().WillReturnRows(newsMockRows)
+	handler := Handler{Store: store}
 
 	mockJson := []byte(`{
 		"title": "smoothie",
@@ -35,7 +27,7 @@ func TestPutExpense(t *testing.T) {
 		"tags": ["put_test", "beverage"]
 		}`)
 
-	expected := `{"id":1,"title":"smoothie","amount":99,"note":"unit_test","tags":["put_test","beverage"]}`
+	expected := `{"id":1,"title":"smoothie","amount":99,"note":"unit_test","tags":["put_test","beverage"],"currency":"THB",` + testTimes + `}`
 
 	req := httptest.NewRequest(http.MethodPut, "/expenses/1", bytes.NewBuffer(mockJson))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -57,18 +49,7 @@ func TestPutExpense(t *testing.T) {
 
 func TestPutExpenseNotFound(t *testing.T) {
 	// Arrange
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-
-	handler := Handler{DB: db}
-
-	newsMockRows := sqlmock.
-		NewRows([]string{"id", "title", "amount", "note", "tags"})
-
-	mock.ExpectPrepare("UPDATE expenses (.+) WHERE (.+) RETURNING (.+)").
-		ExpectQuery().WithArgs().WillReturnRows(newsMockRows)
+	handler := Handler{Store: NewMemoryStore()}
 
 	mockJson := []byte(`{
 		"title": "smoothie",
@@ -90,6 +71,6 @@ func TestPutExpenseNotFound(t *testing.T) {
 	handler.PutExpense(c)
 
 	// Assert
-	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 
 }
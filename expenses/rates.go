@@ -0,0 +1,385 @@
+package expenses
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// DateLayout is the layout of dates without time in requests and responses.
+const DateLayout = "2006-01-02"
+
+// ErrNoRate is returned when there is no exchange rate for a currency pair.
+var ErrNoRate = errors.New("no exchange rate")
+
+// The decimals and integer digits of a rate, as stored in NUMERIC(24, 10).
+const (
+	rateDecimals = 10
+	rateDigits   = 14
+)
+
+type (
+
+	// ExchangeRate tells that one unit of Currency is worth Rate units of Base
+	// from Date until the next rate of the same pair.
+	ExchangeRate struct {
+		Currency string  `json:"currency"`
+		Base     string  `json:"base"`
+		Date     string  `json:"date"`
+		Rate     Decimal `json:"rate"`
+	}
+
+	// Decimal is an exact decimal number such as an exchange rate.
+	// It is decoded from a JSON number or string and encoded as a JSON number.
+	Decimal string
+
+	// RateStore stores exchange rates.
+	RateStore interface {
+		// UpsertRates inserts rates or replaces the rate of the same pair and date.
+		UpsertRates(ctx context.Context, rates []ExchangeRate) error
+
+		// ListRates returns the rates of currency, or every rate when it is empty,
+		// ordered by currency, base and date.
+		ListRates(ctx context.Context, currency string) ([]ExchangeRate, error)
+
+		// FindRate returns the latest rate of the pair effective on date.
+		FindRate(ctx context.Context, currency string, base string, date time.Time) (*big.Rat, error)
+	}
+)
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(bytes.TrimSpace(data))
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return err
+		}
+		s = unquoted
+	}
+	value, ok := Decimal(s).Rat()
+	if !ok {
+		return fmt.Errorf("invalid decimal %s", s)
+	}
+	*d = Decimal(decimalString(value))
+	return nil
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	value, ok := d.Rat()
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", string(d))
+	}
+	return []byte(decimalString(value)), nil
+}
+
+// decimalString formats value, which has a finite decimal expansion,
+// exactly and without trailing zeros, such as 0.5 for .5 or 5e-1.
+func decimalString(value *big.Rat) string {
+	decimals := 0
+	for scaled := new(big.Rat).Set(value); !scaled.IsInt(); decimals++ {
+		scaled.Mul(scaled, big.NewRat(10, 1))
+	}
+	return value.FloatString(decimals)
+}
+
+// Rat returns the exact value of the decimal.
+func (d Decimal) Rat() (*big.Rat, bool) {
+	if !decimal.MatchString(string(d)) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(string(d))
+}
+
+// Validate normalizes the currencies and checks the date and rate.
+func (rate *ExchangeRate) Validate() error {
+
+	var err error
+
+	if rate.Currency, err = NormalizeCurrency(rate.Currency); err != nil {
+		return err
+	}
+	if rate.Base, err = NormalizeCurrency(rate.Base); err != nil {
+		return err
+	}
+	if rate.Currency == rate.Base {
+		return fmt.Errorf("currency and base are both %s", rate.Currency)
+	}
+
+	if _, err := time.Parse(DateLayout, rate.Date); err != nil {
+		return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", rate.Date)
+	}
+
+	value, ok := rate.Rate.Rat()
+	if !ok || value.Sign() <= 0 {
+		return fmt.Errorf("invalid rate %q, expected a positive number", rate.Rate)
+	}
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(rateDecimals), nil)
+	if !new(big.Rat).Mul(value, new(big.Rat).SetInt(scale)).IsInt() {
+		return fmt.Errorf("invalid rate %q, it has more than %d decimals", rate.Rate, rateDecimals)
+	}
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(rateDigits), nil)
+	if value.Cmp(new(big.Rat).SetInt(limit)) >= 0 {
+		return fmt.Errorf("invalid rate %q, it has more than %d integer digits", rate.Rate, rateDigits)
+	}
+	rate.Rate = Decimal(decimalString(value))
+
+	return nil
+}
+
+// ParseRatesCSV reads exchange rates from CSV with a header row.
+// The date, currency and rate columns are required. The base column defaults
+// to DefaultCurrency and the optional unit column divides the rate,
+// as central banks quote some currencies per 100 units.
+func ParseRatesCSV(r io.Reader) ([]ExchangeRate, error) {
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the header. %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"date", "currency", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing %q column", name)
+		}
+	}
+
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rates []ExchangeRate
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rate := ExchangeRate{
+			Currency: column(record, "currency"),
+			Base:     column(record, "base"),
+			Date:     column(record, "date"),
+			Rate:     Decimal(column(record, "rate")),
+		}
+
+		if unit := column(record, "unit"); unit != "" && unit != "1" {
+			value, ok := rate.Rate.Rat()
+			units, err := strconv.Atoi(unit)
+			if !ok || err != nil || units <= 0 {
+				return nil, fmt.Errorf("line %d: invalid rate %q per %q units", line, rate.Rate, unit)
+			}
+			value.Quo(value, big.NewRat(int64(units), 1))
+			rate.Rate = Decimal(value.FloatString(10))
+		}
+
+		if err := rate.Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		rates = append(rates, rate)
+	}
+
+	return rates, nil
+}
+
+// findPairRate looks for the rate of the pair or of the inverse pair.
+func findPairRate(ctx context.Context, rates RateStore, from string, to string, date time.Time) (*big.Rat, error) {
+
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	rate, err := rates.FindRate(ctx, from, to, date)
+	if err != ErrNoRate {
+		return rate, err
+	}
+
+	rate, err = rates.FindRate(ctx, to, from, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return rate.Inv(rate), nil
+}
+
+// LookupRate returns the rate converting from one currency to another on date.
+// It uses the pair itself, its inverse, or a cross rate through DefaultCurrency.
+func LookupRate(ctx context.Context, rates RateStore, from string, to string, date time.Time) (*big.Rat, error) {
+
+	rate, err := findPairRate(ctx, rates, from, to, date)
+	if err != ErrNoRate {
+		return rate, err
+	}
+
+	toPivot, err := findPairRate(ctx, rates, from, DefaultCurrency, date)
+	if err != nil {
+		return nil, err
+	}
+	fromPivot, err := findPairRate(ctx, rates, DefaultCurrency, to, date)
+	if err != nil {
+		return nil, err
+	}
+
+	return toPivot.Mul(toPivot, fromPivot), nil
+}
+
+// baseCurrency returns the currency of the base query parameter
+// and whether amounts should be converted at all. Without it, amounts are
+// converted to the base currency of the user when the user has one.
+// An empty base parameter means the base currency of the user, or DefaultCurrency.
+func (handler Handler) baseCurrency(c echo.Context) (string, bool, error) {
+
+	if code := c.QueryParam("base"); code != "" {
+		base, err := NormalizeCurrency(code)
+		return base, true, err
+	}
+
+	var preferred string
+	if ctx := c.Request().Context(); handler.Users != nil && auth.UserID(ctx) != 0 {
+		user, err := handler.Users.GetUser(ctx, auth.UserID(ctx))
+		if err != nil && err != auth.ErrUserNotFound {
+			return "", false, err
+		}
+		preferred = user.BaseCurrency
+	}
+
+	switch {
+	case preferred != "":
+		return preferred, true, nil
+	case c.QueryParams().Has("base"):
+		return DefaultCurrency, true, nil
+	default:
+		return "", false, nil
+	}
+}
+
+// convertToBase sets AmountInBase of every expense when the request asks for it,
+// using the rate effective on the UTC date of its SpentAt.
+// Expenses without a known rate are left without AmountInBase.
+func (handler Handler) convertToBase(c echo.Context, expenses []Expense) error {
+
+	base, ok, err := handler.baseCurrency(c)
+	if err != nil || !ok {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	cache := map[string]*big.Rat{}
+
+	for i := range expenses {
+		expense := &expenses[i]
+		expense.BaseCurrency = base
+
+		date := expense.SpentAt.UTC()
+		if date.IsZero() {
+			date = time.Now().UTC()
+		}
+
+		key := expense.Currency + " " + date.Format(DateLayout)
+		rate, ok := cache[key]
+		if !ok {
+			rate, err = LookupRate(ctx, handler.Rates, expense.Currency, base, date)
+			if err != nil && err != ErrNoRate {
+				return err
+			}
+			cache[key] = rate
+		}
+		if rate == nil {
+			continue
+		}
+
+		converted, err := expense.Amount.Convert(rate, expense.Currency, base)
+		if err != nil {
+			return err
+		}
+		expense.AmountInBase = &converted
+	}
+
+	return nil
+}
+
+// GetExchangeRates handles HTTP GET request to list exchange rates,
+// optionally only of the currency query parameter.
+func (handler Handler) GetExchangeRates(c echo.Context) error {
+
+	currency := c.QueryParam("currency")
+	if currency != "" {
+		var err error
+		if currency, err = NormalizeCurrency(currency); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+		}
+	}
+
+	rates, err := handler.Rates.ListRates(c.Request().Context(), currency)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot query exchange rates. " + err.Error()})
+	}
+	if rates == nil {
+		rates = []ExchangeRate{}
+	}
+
+	return c.JSON(http.StatusOK, rates)
+}
+
+// UpsertExchangeRates handles HTTP POST request to insert or replace exchange rates.
+// The body is a JSON array of rates or, with Content-Type text/csv,
+// a CSV file as read by ParseRatesCSV.
+func (handler Handler) UpsertExchangeRates(c echo.Context) error {
+
+	var rates []ExchangeRate
+
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		var err error
+		rates, err = ParseRatesCSV(c.Request().Body)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest,
+				ErrorResponse{"cannot read the CSV. " + err.Error()})
+		}
+	} else {
+		if err := json.NewDecoder(c.Request().Body).Decode(&rates); err != nil {
+			return c.JSON(http.StatusBadRequest,
+				ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+		}
+		for i := range rates {
+			if err := rates[i].Validate(); err != nil {
+				return c.JSON(http.StatusBadRequest,
+					ErrorResponse{fmt.Sprintf("rate %d: %s", i, err.Error())})
+			}
+		}
+	}
+
+	err := handler.Rates.UpsertRates(c.Request().Context(), rates)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot save exchange rates. " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, rates)
+}
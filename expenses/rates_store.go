@@ -0,0 +1,181 @@
+package expenses
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+)
+
+// PostgresRateStore is a RateStore backed by the exchange_rates table.
+// Every query is scoped to the workspace of auth.WorkspaceID.
+type PostgresRateStore struct {
+	DB *sql.DB
+}
+
+// NewPostgresRateStore returns a PostgresRateStore using db.
+func NewPostgresRateStore(db *sql.DB) *PostgresRateStore {
+	return &PostgresRateStore{DB: db}
+}
+
+func (store *PostgresRateStore) UpsertRates(ctx context.Context, rates []ExchangeRate) error {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, rate := range rates {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO exchange_rates (workspace_id, currency, base, effective_on, rate)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (workspace_id, currency, base, effective_on) DO UPDATE SET rate = EXCLUDED.rate
+		`, auth.WorkspaceID(ctx), rate.Currency, rate.Base, rate.Date, string(rate.Rate))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (store *PostgresRateStore) ListRates(ctx context.Context, currency string) ([]ExchangeRate, error) {
+
+	rows, err := store.DB.QueryContext(ctx, `
+		SELECT currency, base, to_char(effective_on, 'YYYY-MM-DD'), rate::TEXT
+		FROM exchange_rates
+		WHERE workspace_id = $2 AND ($1 = '' OR currency = $1)
+		ORDER BY currency, base, effective_on
+	`, currency, auth.WorkspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []ExchangeRate
+
+	for rows.Next() {
+		var rate ExchangeRate
+		if err := rows.Scan(&rate.Currency, &rate.Base, &rate.Date, &rate.Rate); err != nil {
+			return nil, err
+		}
+		// NUMERIC keeps trailing zeros of its scale, such as 35.1200000000.
+		if strings.Contains(string(rate.Rate), ".") {
+			rate.Rate = Decimal(strings.TrimRight(strings.TrimRight(string(rate.Rate), "0"), "."))
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, rows.Err()
+}
+
+func (store *PostgresRateStore) FindRate(ctx context.Context, currency string, base string, date time.Time) (*big.Rat, error) {
+
+	var value string
+
+	err := store.DB.QueryRowContext(ctx, `
+		SELECT rate::TEXT FROM exchange_rates
+		WHERE workspace_id = $4 AND currency = $1 AND base = $2 AND effective_on <= $3
+		ORDER BY effective_on DESC
+		LIMIT 1
+	`, currency, base, date.Format(DateLayout), auth.WorkspaceID(ctx)).Scan(&value)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrNoRate
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rate, _ := new(big.Rat).SetString(value)
+	return rate, nil
+}
+
+// MemoryRateStore is a thread-safe RateStore keeping the rates of each workspace in a slice.
+type MemoryRateStore struct {
+	mu    sync.RWMutex
+	rates map[int][]ExchangeRate
+}
+
+// NewMemoryRateStore returns an empty MemoryRateStore.
+func NewMemoryRateStore() *MemoryRateStore {
+	return &MemoryRateStore{rates: map[int][]ExchangeRate{}}
+}
+
+func (store *MemoryRateStore) UpsertRates(ctx context.Context, rates []ExchangeRate) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	stored := store.rates[auth.WorkspaceID(ctx)]
+
+	for _, rate := range rates {
+		replaced := false
+		for i, existing := range stored {
+			if existing.Currency == rate.Currency && existing.Base == rate.Base && existing.Date == rate.Date {
+				stored[i] = rate
+				replaced = true
+			}
+		}
+		if !replaced {
+			stored = append(stored, rate)
+		}
+	}
+
+	store.rates[auth.WorkspaceID(ctx)] = stored
+
+	sort.Slice(stored, func(i, j int) bool {
+		a, b := stored[i], stored[j]
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		if a.Base != b.Base {
+			return a.Base < b.Base
+		}
+		return a.Date < b.Date
+	})
+
+	return nil
+}
+
+func (store *MemoryRateStore) ListRates(ctx context.Context, currency string) ([]ExchangeRate, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var rates []ExchangeRate
+	for _, rate := range store.rates[auth.WorkspaceID(ctx)] {
+		if currency == "" || rate.Currency == currency {
+			rates = append(rates, rate)
+		}
+	}
+
+	return rates, nil
+}
+
+func (store *MemoryRateStore) FindRate(ctx context.Context, currency string, base string, date time.Time) (*big.Rat, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	day := date.Format(DateLayout)
+
+	// Rates are sorted by date, so the last match is the latest effective one.
+	var found *ExchangeRate
+	stored := store.rates[auth.WorkspaceID(ctx)]
+	for i, rate := range stored {
+		if rate.Currency == currency && rate.Base == base && rate.Date <= day {
+			found = &stored[i]
+		}
+	}
+
+	if found == nil {
+		return nil, ErrNoRate
+	}
+
+	rate, _ := found.Rate.Rat()
+	return rate, nil
+}
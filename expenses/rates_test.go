@@ -0,0 +1,260 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRatesCSV(t *testing.T) {
+	// Arrange
+	input := "\ufeffDate,Currency,Unit,Rate\n" +
+		"2026-10-01,usd,1,35.5\n" +
+		"2026-10-01,JPY,100,23.10\n"
+
+	// Act
+	got, err := ParseRatesCSV(strings.NewReader(input))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []ExchangeRate{
+		{Currency: "USD", Base: "THB", Date: "2026-10-01", Rate: "35.5"},
+		{Currency: "JPY", Base: "THB", Date: "2026-10-01", Rate: "0.231"},
+	}, got)
+}
+
+func TestParseRatesCSVInvalid(t *testing.T) {
+	cases := map[string]string{
+		"missing column":   "date,currency\n2026-10-01,USD\n",
+		"unknown currency": "date,currency,rate\n2026-10-01,ABC,1\n",
+		"invalid date":     "date,currency,rate\n01/10/2026,USD,1\n",
+		"negative rate":    "date,currency,rate\n2026-10-01,USD,-1\n",
+		"same currency":    "date,currency,base,rate\n2026-10-01,USD,USD,1\n",
+		"too precise":      "date,currency,rate\n2026-10-01,USD,0.00000000001\n",
+	}
+
+	for name, input := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseRatesCSV(strings.NewReader(input))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLookupRate(t *testing.T) {
+	// Arrange
+	rates := NewMemoryRateStore()
+	rates.UpsertRates(context.Background(), []ExchangeRate{
+		{Currency: "USD", Base: "THB", Date: "2026-10-01", Rate: "35"},
+		{Currency: "USD", Base: "THB", Date: "2026-10-10", Rate: "36"},
+		{Currency: "EUR", Base: "THB", Date: "2026-10-01", Rate: "40"},
+	})
+	date := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		from, to string
+		expected *big.Rat
+	}{
+		{"USD", "THB", big.NewRat(35, 1)},
+		{"THB", "USD", big.NewRat(1, 35)},
+		{"EUR", "USD", big.NewRat(40, 35)},
+		{"THB", "THB", big.NewRat(1, 1)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.from+c.to, func(t *testing.T) {
+			// Act
+			got, err := LookupRate(context.Background(), rates, c.from, c.to, date)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, c.expected.String(), got.String())
+		})
+	}
+
+	later, _ := LookupRate(context.Background(), rates, "USD", "THB", date.AddDate(0, 0, 10))
+	assert.Equal(t, "36/1", later.String())
+
+	_, err := LookupRate(context.Background(), rates, "USD", "THB", date.AddDate(0, -1, 0))
+	assert.Equal(t, ErrNoRate, err)
+}
+
+func TestGetAllExpensesInBase(t *testing.T) {
+	// Arrange
+	store := newTestStore()
+	store.Create(context.Background(), &Expense{Title: "coffee", Amount: 450, Currency: "USD"})
+	store.Create(context.Background(), &Expense{Title: "sushi", Amount: 1000, Currency: "JPY"})
+
+	// Expenses are converted with the rate of the day they were spent.
+	rates := NewMemoryRateStore()
+	rates.UpsertRates(context.Background(), []ExchangeRate{
+		{Currency: "USD", Base: "THB", Date: testTime.Format(DateLayout), Rate: "35.5"},
+		{Currency: "USD", Base: "THB", Date: testTime.AddDate(0, 0, 1).Format(DateLayout), Rate: "36"},
+	})
+
+	expected := `[{"id":1,"title":"coffee","amount":4.5,"note":"","tags":null,"currency":"USD",` + testTimes + `,"amount_in_base":159.75,"base_currency":"THB"},` +
+		`{"id":2,"title":"sushi","amount":1000,"note":"","tags":null,"currency":"JPY",` + testTimes + `,"base_currency":"THB"}]`
+
+	req := httptest.NewRequest(http.MethodGet, "/expenses?base=thb", nil)
+	rec := httptest.NewRecorder()
+
+	e := echo.New()
+	c := e.NewContext(req, rec)
+
+	handler := Handler{Store: store, Rates: rates}
+
+	// Act
+	handler.GetAllExpenses(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, expected, strings.TrimSpace(rec.Body.String()))
+}
+
+func TestBaseCurrencyOfUser(t *testing.T) {
+	// Arrange
+	users := auth.NewMemoryUserStore()
+	users.CreateUser(context.Background(), &auth.User{Name: "peem"}, "")
+
+	store := newTestStore()
+	ctx := auth.WithUser(context.Background(), 1)
+	store.Create(ctx, &Expense{Title: "coffee", Amount: 450, Currency: "USD"})
+
+	rates := NewMemoryRateStore()
+	rates.UpsertRates(context.Background(), []ExchangeRate{
+		{Currency: "USD", Base: "THB", Date: testTime.Format(DateLayout), Rate: "35.5"},
+		{Currency: "EUR", Base: "THB", Date: testTime.Format(DateLayout), Rate: "38"},
+	})
+
+	handler := Handler{Store: store, Rates: rates, Users: users}
+
+	// Act
+	c, setRec := newIDContext(http.MethodPut, "/users/me/base-currency", `{"base_currency": "thb"}`, "")
+	asUser(c, 1)
+	handler.SetBaseCurrency(c)
+
+	c, invalidRec := newIDContext(http.MethodPut, "/users/me/base-currency", `{"base_currency": "baht"}`, "")
+	asUser(c, 1)
+	handler.SetBaseCurrency(c)
+
+	c, preferredRec := newIDContext(http.MethodGet, "/expenses/1", "", "1")
+	asUser(c, 1)
+	handler.GetExpenseByID(c)
+
+	c, queryRec := newIDContext(http.MethodGet, "/expenses/1?base=EUR", "", "1")
+	asUser(c, 1)
+	handler.GetExpenseByID(c)
+
+	c, clearRec := newIDContext(http.MethodPut, "/users/me/base-currency", `{"base_currency": ""}`, "")
+	asUser(c, 1)
+	handler.SetBaseCurrency(c)
+
+	c, clearedRec := newIDContext(http.MethodGet, "/expenses/1", "", "1")
+	asUser(c, 1)
+	handler.GetExpenseByID(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, setRec.Code)
+	assert.Contains(t, setRec.Body.String(), `"base_currency":"THB"`)
+	assert.Equal(t, http.StatusBadRequest, invalidRec.Code)
+	assert.Contains(t, preferredRec.Body.String(), `"amount_in_base":159.75,"base_currency":"THB"`)
+	assert.Contains(t, queryRec.Body.String(), `"base_currency":"EUR"`)
+	assert.Equal(t, http.StatusOK, clearRec.Code)
+	assert.NotContains(t, clearRec.Body.String(), "base_currency")
+	assert.NotContains(t, clearedRec.Body.String(), "amount_in_base")
+}
+
+func TestUpsertExchangeRates(t *testing.T) {
+	cases := map[string]struct {
+		contentType string
+		body        string
+		code        int
+	}{
+		"json":         {echo.MIMEApplicationJSON, `[{"currency":"usd","base":"THB","date":"2026-10-01","rate":35.5}]`, http.StatusOK},
+		"csv":          {"text/csv", "date,currency,rate\n2026-10-01,USD,35.5\n", http.StatusOK},
+		"invalid json": {echo.MIMEApplicationJSON, `[{"currency":"usd","date":"2026-10-01","rate":"abc"}]`, http.StatusBadRequest},
+		"invalid rate": {echo.MIMEApplicationJSON, `[{"currency":"usd","date":"2026-10-01","rate":0}]`, http.StatusBadRequest},
+		"too precise":  {echo.MIMEApplicationJSON, `[{"currency":"usd","date":"2026-10-01","rate":1e-11}]`, http.StatusBadRequest},
+		"too large":    {echo.MIMEApplicationJSON, `[{"currency":"usd","date":"2026-10-01","rate":"1e999"}]`, http.StatusBadRequest},
+		"exponent":     {echo.MIMEApplicationJSON, `[{"currency":"usd","date":"2026-10-01","rate":"3.55e1"}]`, http.StatusOK},
+		"invalid csv":  {"text/csv", "date,currency\n2026-10-01,USD\n", http.StatusBadRequest},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			// Arrange
+			rates := NewMemoryRateStore()
+
+			req := httptest.NewRequest(http.MethodPost, "/exchange-rates", strings.NewReader(tc.body))
+			req.Header.Set(echo.HeaderContentType, tc.contentType)
+			rec := httptest.NewRecorder()
+
+			e := echo.New()
+			c := e.NewContext(req, rec)
+
+			handler := Handler{Rates: rates}
+
+			// Act
+			handler.UpsertExchangeRates(c)
+
+			// Assert
+			assert.Equal(t, tc.code, rec.Code)
+			if tc.code == http.StatusOK {
+				got, _ := rates.ListRates(context.Background(), "USD")
+				assert.Equal(t, []ExchangeRate{{Currency: "USD", Base: "THB", Date: "2026-10-01", Rate: "35.5"}}, got)
+			}
+		})
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	for input, expected := range map[string]string{
+		`".5"`: "0.5", `"5."`: "5", `"+5"`: "5", `-1e-3`: "-0.001", `"35.1200"`: "35.12", `1E2`: "100",
+	} {
+		var d Decimal
+		err := json.Unmarshal([]byte(input), &d)
+		data, marshalErr := json.Marshal(d)
+
+		assert.NoError(t, err, input)
+		assert.NoError(t, marshalErr, input)
+		assert.Equal(t, expected, string(data), input)
+	}
+}
+
+func TestGetExchangeRatesEmpty(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/exchange-rates", nil)
+	rec := httptest.NewRecorder()
+
+	Handler{Rates: NewMemoryRateStore()}.GetExchangeRates(echo.New().NewContext(req, rec))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "[]", strings.TrimSpace(rec.Body.String()))
+}
+
+func TestCreateExpenseUnknownCurrency(t *testing.T) {
+	// Arrange
+	req := httptest.NewRequest(http.MethodPost, "/expenses",
+		strings.NewReader(`{"title":"coffee","amount":4.5,"currency":"XYZ"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	e := echo.New()
+	c := e.NewContext(req, rec)
+
+	handler := Handler{Store: NewMemoryStore()}
+
+	// Act
+	handler.CreateExpense(c)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,202 @@
+package expenses
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SyntaxError is an error in a search query.
+// Position is the 1-based character position of the offending token.
+type SyntaxError struct {
+	Message  string `json:"message"`
+	Position int    `json:"position"`
+	Token    string `json:"token,omitempty"`
+}
+
+func (err *SyntaxError) Error() string {
+	if err.Token != "" {
+		return fmt.Sprintf("%s at position %d (%q)", err.Message, err.Position, err.Token)
+	}
+	return fmt.Sprintf("%s at position %d", err.Message, err.Position)
+}
+
+// Condition is one term of a search query such as amount>100 or -tag:work.
+// A term without a field, such as smoothie, has an empty Field
+// and matches the title or the note.
+type Condition struct {
+	Field    string
+	Operator string
+	Value    string
+	Negated  bool
+
+	// Position is the 1-based character position of the term.
+	Position int
+	// ValuePosition is the 1-based character position of the value.
+	ValuePosition int
+}
+
+// SearchQuery is a parsed search query. Every condition must match.
+type SearchQuery struct {
+	Conditions []Condition
+
+	// plans hold the compiled conditions in the same order.
+	plans []plan
+}
+
+// searchFields are the fields of a search query and the operators they accept.
+var searchFields = map[string][]string{
+	"tag":      {":"},
+	"title":    {":"},
+	"note":     {":"},
+	"currency": {":"},
+	"amount":   {":", "=", ">", ">=", "<", "<="},
+	"date":     {":", "=", ">", ">=", "<", "<="},
+}
+
+// ParseSearch parses a search query such as
+//
+//	tag:food amount>100 -tag:work note:"team lunch" date:2026-09
+//
+// Terms are separated by spaces and all of them must match.
+// A leading "-" negates a term, and values with spaces are double quoted
+// with \" and \\ escapes. Errors are *SyntaxError.
+// Dates are in DefaultTimezone.
+func ParseSearch(input string) (*SearchQuery, error) {
+	return ParseSearchInLocation(input, DefaultTimezone)
+}
+
+// ParseSearchInLocation is like ParseSearch but reads dates in location.
+func ParseSearchInLocation(input string, location *time.Location) (*SearchQuery, error) {
+
+	runes := []rune(input)
+	query := &SearchQuery{}
+	i := 0
+
+	for {
+		for i < len(runes) && unicode.IsSpace(runes[i]) {
+			i++
+		}
+		if i >= len(runes) {
+			break
+		}
+
+		condition := Condition{Position: i + 1}
+
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			condition.Negated = true
+			i++
+		}
+
+		// A field is a run of letters followed by an operator.
+		start := i
+		for i < len(runes) && (unicode.IsLetter(runes[i]) || runes[i] == '_') {
+			i++
+		}
+		operator := readOperator(runes, i)
+
+		if operator != "" && i > start {
+			condition.Field = strings.ToLower(string(runes[start:i]))
+			condition.Operator = operator
+
+			operators, ok := searchFields[condition.Field]
+			if !ok {
+				return nil, &SyntaxError{"unknown field", start + 1, string(runes[start:i])}
+			}
+			if !contains(operators, operator) {
+				return nil, &SyntaxError{
+					fmt.Sprintf("operator %s cannot be used with %s", operator, condition.Field),
+					i + 1, operator,
+				}
+			}
+			i += len(operator)
+		} else {
+			i = start
+		}
+
+		condition.ValuePosition = i + 1
+		value, next, err := readValue(runes, i)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" {
+			if condition.Field != "" {
+				return nil, &SyntaxError{"missing value", i + 1, ""}
+			}
+			return nil, &SyntaxError{"empty term", condition.Position, string(runes[condition.Position-1 : next])}
+		}
+		condition.Value = value
+		i = next
+
+		if i < len(runes) && !unicode.IsSpace(runes[i]) {
+			return nil, &SyntaxError{"expected a space", i + 1, string(runes[i])}
+		}
+
+		query.Conditions = append(query.Conditions, condition)
+	}
+
+	for _, condition := range query.Conditions {
+		plan, err := planCondition(condition, location)
+		if err != nil {
+			return nil, err
+		}
+		query.plans = append(query.plans, plan)
+	}
+
+	return query, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// readOperator returns the operator starting at i, if any.
+func readOperator(runes []rune, i int) string {
+	rest := string(runes[i:])
+	for _, operator := range []string{">=", "<=", ":", "=", ">", "<"} {
+		if strings.HasPrefix(rest, operator) {
+			return operator
+		}
+	}
+	return ""
+}
+
+// readValue reads a quoted or bare value starting at i
+// and returns it with the index after it.
+func readValue(runes []rune, i int) (string, int, error) {
+
+	if i < len(runes) && runes[i] == '"' {
+		quote := i
+		var value strings.Builder
+		for i++; i < len(runes); i++ {
+			switch runes[i] {
+			case '\\':
+				if i+1 < len(runes) {
+					i++
+					value.WriteRune(runes[i])
+				}
+			case '"':
+				return value.String(), i + 1, nil
+			default:
+				value.WriteRune(runes[i])
+			}
+		}
+		return "", 0, &SyntaxError{"unterminated quote", quote + 1, `"`}
+	}
+
+	start := i
+	for i < len(runes) && !unicode.IsSpace(runes[i]) {
+		if runes[i] == '"' {
+			return "", 0, &SyntaxError{"unexpected quote", i + 1, `"`}
+		}
+		i++
+	}
+
+	return string(runes[start:i]), i, nil
+}
@@ -0,0 +1,210 @@
+package expenses
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// plan is a compiled search condition. sql returns a boolean SQL expression
+// using arg to add parameters, and match is the same condition in Go.
+type plan struct {
+	sql   func(arg func(interface{}) string) string
+	match func(expense Expense) bool
+}
+
+// comparisons are the SQL comparison of each operator.
+var comparisons = map[string]string{
+	":": "=", "=": "=", ">": ">", ">=": ">=", "<": "<", "<=": "<=",
+}
+
+func compare(cmp int, operator string) bool {
+	switch comparisons[operator] {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return cmp == 0
+}
+
+func containsFold(s string, substring string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substring))
+}
+
+// planCondition compiles a condition, checking its value.
+// Dates are read in location.
+func planCondition(condition Condition, location *time.Location) (plan, error) {
+
+	invalid := func(format string, args ...interface{}) error {
+		return &SyntaxError{fmt.Sprintf(format, args...), condition.ValuePosition, condition.Value}
+	}
+
+	value := condition.Value
+	var p plan
+
+	switch condition.Field {
+
+	case "":
+		p = plan{
+			sql: func(arg func(interface{}) string) string {
+				pattern := arg(escapeLike(value))
+				return fmt.Sprintf("(title ILIKE '%%' || %s || '%%' OR note ILIKE '%%' || %s || '%%')", pattern, pattern)
+			},
+			match: func(expense Expense) bool {
+				return containsFold(expense.Title, value) || containsFold(expense.Note, value)
+			},
+		}
+
+	case "tag":
+		value := NormalizeTag(value)
+		p = plan{
+			sql: func(arg func(interface{}) string) string {
+				return arg(value) + " = ANY(tags)"
+			},
+			match: func(expense Expense) bool {
+				return contains(expense.Tags, value)
+			},
+		}
+
+	case "title", "note":
+		column := condition.Field
+		p = plan{
+			sql: func(arg func(interface{}) string) string {
+				return column + " ILIKE '%' || " + arg(escapeLike(value)) + " || '%'"
+			},
+			match: func(expense Expense) bool {
+				if column == "title" {
+					return containsFold(expense.Title, value)
+				}
+				return containsFold(expense.Note, value)
+			},
+		}
+
+	case "currency":
+		currency, err := NormalizeCurrency(value)
+		if err != nil {
+			return plan{}, invalid("unknown currency")
+		}
+		p = plan{
+			sql: func(arg func(interface{}) string) string {
+				return "currency = " + arg(currency)
+			},
+			match: func(expense Expense) bool {
+				return expense.Currency == currency
+			},
+		}
+
+	case "amount":
+		amount, err := parseMinorUnits(value, maxExponent, RoundHalfUp)
+		if err != nil {
+			return plan{}, invalid("invalid amount")
+		}
+		operator := condition.Operator
+		p = plan{
+			sql: func(arg func(interface{}) string) string {
+				return rescaledAmountSQL + " " + comparisons[operator] + " " + arg(amount)
+			},
+			match: func(expense Expense) bool {
+				cmp := 0
+				if rescaled := expense.Amount.Rescale(expense.Currency); rescaled < amount {
+					cmp = -1
+				} else if rescaled > amount {
+					cmp = 1
+				}
+				return compare(cmp, operator)
+			},
+		}
+
+	case "date":
+		// A date is a year, a month, a day or a period such as this_month.
+		r, err := dateRange(value, location)
+		if err != nil {
+			if r, err = PeriodRange(value, time.Now(), location); err != nil {
+				return plan{}, invalid("invalid date, use YYYY, YYYY-MM, YYYY-MM-DD or a period such as this_month")
+			}
+		}
+		from, before := *r.From, *r.Before
+
+		// date>2026-09 is after September and date<=2026-09 is until its end.
+		var bounds DateRange
+		switch comparisons[condition.Operator] {
+		case "=":
+			bounds = r
+		case ">":
+			bounds.From = &before
+		case ">=":
+			bounds.From = &from
+		case "<":
+			bounds.Before = &from
+		case "<=":
+			bounds.Before = &before
+		}
+
+		p = plan{
+			sql: func(arg func(interface{}) string) string {
+				var conditions []string
+				if bounds.From != nil {
+					conditions = append(conditions, "spent_at >= "+arg(*bounds.From))
+				}
+				if bounds.Before != nil {
+					conditions = append(conditions, "spent_at < "+arg(*bounds.Before))
+				}
+				return "(" + strings.Join(conditions, " AND ") + ")"
+			},
+			match: func(expense Expense) bool {
+				return bounds.Contains(expense.SpentAt)
+			},
+		}
+
+	default:
+		return plan{}, &SyntaxError{"unknown field", condition.Position, condition.Field}
+	}
+
+	if condition.Negated {
+		positive := p
+		p = plan{
+			sql: func(arg func(interface{}) string) string {
+				// COALESCE treats NULL columns, such as missing tags, as not matching.
+				return "NOT COALESCE(" + positive.sql(arg) + ", false)"
+			},
+			match: func(expense Expense) bool {
+				return !positive.match(expense)
+			},
+		}
+	}
+
+	return p, nil
+}
+
+// SQL returns the conditions as a parameterized boolean SQL expression.
+// arg adds a parameter and returns its placeholder.
+func (query *SearchQuery) SQL(arg func(interface{}) string) string {
+
+	if len(query.plans) == 0 {
+		return "TRUE"
+	}
+
+	var conditions []string
+	for _, plan := range query.plans {
+		conditions = append(conditions, plan.sql(arg))
+	}
+
+	return strings.Join(conditions, " AND ")
+}
+
+// Match reports whether expense matches every condition.
+func (query *SearchQuery) Match(expense Expense) bool {
+
+	for _, plan := range query.plans {
+		if !plan.match(expense) {
+			return false
+		}
+	}
+
+	return true
+}
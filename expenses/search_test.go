@@ -0,0 +1,127 @@
+package expenses
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSearch(t *testing.T) {
+	// Act
+	got, err := ParseSearch(`tag:food amount>100 -tag:work note:"team \"big\" lunch" smoothie`)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []Condition{
+		{Field: "tag", Operator: ":", Value: "food", Position: 1, ValuePosition: 5},
+		{Field: "amount", Operator: ">", Value: "100", Position: 10, ValuePosition: 17},
+		{Field: "tag", Operator: ":", Value: "work", Negated: true, Position: 21, ValuePosition: 26},
+		{Field: "note", Operator: ":", Value: `team "big" lunch`, Position: 31, ValuePosition: 36},
+		{Value: "smoothie", Position: 57, ValuePosition: 57},
+	}, got.Conditions)
+}
+
+func TestParseSearchErrors(t *testing.T) {
+	cases := map[string]SyntaxError{
+		`tag:food color:red`: {"unknown field", 10, "color"},
+		`tag>food`:           {"operator > cannot be used with tag", 4, ">"},
+		`amount>=`:           {"missing value", 9, ""},
+		`amount>ten`:         {"invalid amount", 8, "ten"},
+		`note:"team lunch`:   {"unterminated quote", 6, `"`},
+		`note:"team"lunch`:   {"expected a space", 12, "l"},
+		`note:te"am`:         {"unexpected quote", 8, `"`},
+		`currency:abc`:       {"unknown currency", 10, "abc"},
+		`ข้าว amount>x`:      {"invalid amount", 13, "x"},
+		`date:2026-13`:       {"invalid date, use YYYY, YYYY-MM, YYYY-MM-DD or a period such as this_month", 6, "2026-13"},
+		`tag:food ""`:        {"empty term", 10, `""`},
+	}
+
+	for input, expected := range cases {
+		t.Run(input, func(t *testing.T) {
+			_, err := ParseSearch(input)
+
+			assert.Equal(t, &expected, err)
+		})
+	}
+}
+
+func TestSearchSQL(t *testing.T) {
+	// Arrange
+	search, _ := ParseSearch(`tag:food amount<=100.5 -tag:work note:"50%" lunch currency:usd`)
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// Act
+	got := search.SQL(arg)
+
+	// Assert
+	assert.Equal(t, "$1 = ANY(tags) AND "+rescaledAmountSQL+" <= $2 AND NOT COALESCE($3 = ANY(tags), false)"+
+		" AND note ILIKE '%' || $4 || '%'"+
+		" AND (title ILIKE '%' || $5 || '%' OR note ILIKE '%' || $5 || '%') AND currency = $6", got)
+	assert.Equal(t, []interface{}{"food", Money(100500), "work", `50\%`, "lunch", "USD"}, args)
+}
+
+func TestSearchSQLDate(t *testing.T) {
+	// Arrange
+	bangkok, _ := ParseTimezone("Asia/Bangkok")
+	search, _ := ParseSearchInLocation(`date:2026-09 date<=2026`, bangkok)
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// Act
+	got := search.SQL(arg)
+
+	// Assert
+	assert.Equal(t, "(spent_at >= $1 AND spent_at < $2) AND (spent_at < $3)", got)
+	assert.Equal(t, []interface{}{
+		time.Date(2026, 8, 31, 17, 0, 0, 0, time.UTC),
+		time.Date(2026, 9, 30, 17, 0, 0, 0, time.UTC),
+		time.Date(2026, 12, 31, 17, 0, 0, 0, time.UTC),
+	}, args)
+}
+
+func TestGetAllExpensesSearch(t *testing.T) {
+	cases := map[string][]int{
+		`tag:food`:                       {1, 4},
+		`tag:beverage -tag:coffee`:       {1},
+		`amount>=88 amount<200`:          {2, 4},
+		`amount:79`:                      {1, 5},
+		`smoothie -bowl`:                 {1},
+		`title:"smoothie bowl" tag:food`: {4},
+		`-tag:food -tag:coffee`:          {3},
+	}
+
+	store := newListStore()
+
+	for q, expected := range cases {
+		t.Run(q, func(t *testing.T) {
+			ids, rec := listExpenses(t, store, "/expenses?q="+url.QueryEscape(q))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, expected, ids)
+		})
+	}
+}
+
+func TestGetAllExpensesSearchError(t *testing.T) {
+	// Act
+	_, rec := listExpenses(t, NewMemoryStore(), "/expenses?q="+url.QueryEscape("tag:food amount>lots"))
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var got SyntaxError
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, SyntaxError{"invalid amount", 17, "lots"}, got)
+}
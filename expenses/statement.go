@@ -0,0 +1,216 @@
+package expenses
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PeemPeimn/assessment/importer"
+	"github.com/labstack/echo/v4"
+)
+
+// Media types of bank statements. Uploads are also accepted as
+// application/octet-stream since few clients know these.
+var (
+	ofxMediaTypes = []string{"application/x-ofx", "application/ofx", "application/vnd.intu.qfx", echo.MIMEOctetStream}
+	qifMediaTypes = []string{"application/qif", "application/x-qif", echo.MIMEOctetStream}
+)
+
+// StatementImportResult is the response of ImportOFX and ImportQIF.
+type StatementImportResult struct {
+	DryRun       bool `json:"dry_run"`
+	Transactions int  `json:"transactions"`
+	Imported     int  `json:"imported"`
+
+	// Duplicates are the transactions imported before, or listed twice in the file.
+	Duplicates int `json:"duplicates"`
+
+	// Credits are the deposits left out without credits=true.
+	Credits int `json:"credits"`
+
+	Errors   []ImportRowError `json:"errors"`
+	Expenses []Expense        `json:"expenses"`
+}
+
+// statementExpense maps a bank transaction to an expense. Money leaving
+// the account is spending, so the amount of the expense has the opposite sign.
+// The category of a QIF transaction, such as Food:Groceries, becomes its tags.
+func statementExpense(transaction importer.Transaction) (Expense, []ImportRowError) {
+
+	var errs []ImportRowError
+	fail := func(column string, message string) {
+		errs = append(errs, ImportRowError{Row: transaction.Line, Column: column, Message: message})
+	}
+
+	expense := Expense{Title: transaction.Payee, Note: transaction.Memo, SpentAt: transaction.Date}
+	if expense.Title == "" {
+		expense.Title, expense.Note = transaction.Memo, ""
+	}
+	if expense.Title == "" {
+		expense.Title = transaction.Type
+	}
+	if expense.Title == "" {
+		fail("payee", "the transaction has no payee, memo or type for the title")
+	}
+
+	amount, err := ParseMoney(transaction.Amount, transaction.Currency, DefaultRounding)
+	if err != nil {
+		fail("amount", err.Error())
+	}
+	expense.Amount = -amount
+
+	if expense.Currency, err = NormalizeCurrency(transaction.Currency); err != nil {
+		fail("currency", err.Error())
+	}
+
+	category := transaction.Category
+	if i := strings.IndexByte(category, '/'); i >= 0 {
+		// A class follows the category after a slash.
+		category = category[:i]
+	}
+	if !strings.HasPrefix(category, "[") {
+		// A category in brackets is a transfer to another account.
+		expense.Tags = NormalizeTags(strings.Split(category, ":"))
+	}
+
+	if field := invalidText(expense); field != "" {
+		fail(field, field+" cannot contain NUL characters")
+	}
+
+	return expense, errs
+}
+
+// importStatement imports the transactions of a bank statement read by parse.
+// Transactions imported before are skipped by their ID, so a statement can be
+// imported again after the bank added transactions to it.
+func (handler Handler) importStatement(c echo.Context, mediaTypes []string,
+	parse func(file io.Reader, location *time.Location) (importer.Statement, error)) error {
+
+	dryRun, err := parseDryRun(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	credits := false
+	if value := c.QueryParam("credits"); value != "" {
+		if credits, err = strconv.ParseBool(value); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"credits must be true or false"})
+		}
+	}
+
+	location, err := ParseTimezone(c.QueryParam("tz"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	file, err := importFile(c, mediaTypes...)
+	if err != nil {
+		return c.JSON(http.StatusUnsupportedMediaType, ErrorResponse{err.Error()})
+	}
+	defer file.Close()
+
+	statement, err := parse(file, location)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"cannot read the file. " + err.Error()})
+	}
+
+	result := StatementImportResult{
+		DryRun:       dryRun,
+		Transactions: len(statement.Transactions),
+		Errors:       []ImportRowError{},
+		Expenses:     []Expense{},
+	}
+	for i, lineError := range statement.Errors {
+		// A transaction may have an error in several fields.
+		if i == 0 || statement.Errors[i-1].Line != lineError.Line {
+			result.Transactions++
+		}
+		result.Errors = append(result.Errors,
+			ImportRowError{Row: lineError.Line, Column: lineError.Field, Message: lineError.Message})
+	}
+
+	ctx := c.Request().Context()
+
+	var ids []string
+	for _, transaction := range statement.Transactions {
+		ids = append(ids, transaction.ID)
+	}
+	imported, err := handler.Store.Imported(ctx, ids)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot find the imported transactions. " + err.Error()})
+	}
+
+	ids = nil
+	for _, transaction := range statement.Transactions {
+		if imported[transaction.ID] {
+			result.Duplicates++
+			continue
+		}
+		imported[transaction.ID] = true
+
+		expense, errs := statementExpense(transaction)
+		switch {
+		case errs != nil:
+			result.Errors = append(result.Errors, errs...)
+		case expense.Amount < 0 && !credits:
+			result.Credits++
+		default:
+			result.Expenses = append(result.Expenses, expense)
+			ids = append(ids, transaction.ID)
+		}
+	}
+
+	switch {
+	case dryRun:
+		return c.JSON(http.StatusOK, result)
+	case len(result.Errors) > 0:
+		result.Expenses = []Expense{}
+		return c.JSON(http.StatusUnprocessableEntity, result)
+	}
+
+	if err := handler.Store.CreateImported(ctx, result.Expenses, ids); err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot import the expenses. " + err.Error()})
+	}
+
+	// Another import may have created some of the expenses in the meantime.
+	created := result.Expenses[:0]
+	for _, expense := range result.Expenses {
+		if expense.ID == 0 {
+			result.Duplicates++
+			continue
+		}
+		created = append(created, expense)
+	}
+	handler.evaluateAlerts(c, created...)
+	result.Expenses = created
+	result.Imported = len(created)
+
+	return c.JSON(http.StatusCreated, result)
+}
+
+// ImportOFX handles HTTP POST request to import the transactions of an OFX
+// or QFX bank statement as expenses. Debits become expenses and, with
+// credits=true, deposits become negative expenses. Times without offset
+// are in the timezone of tz. See ImportExpenses for dry_run and the errors.
+func (handler Handler) ImportOFX(c echo.Context) error {
+	return handler.importStatement(c, ofxMediaTypes, importer.ParseOFX)
+}
+
+// ImportQIF handles HTTP POST request to import the transactions of a QIF
+// file like ImportOFX. date_order is the order of the dates, mdy by default
+// as Quicken writes them, dmy or ymd.
+func (handler Handler) ImportQIF(c echo.Context) error {
+
+	order := importer.MDY
+	if value := c.QueryParam("date_order"); value != "" {
+		order = importer.DateOrder(strings.ToLower(value))
+	}
+
+	return handler.importStatement(c, qifMediaTypes, func(file io.Reader, location *time.Location) (importer.Statement, error) {
+		return importer.ParseQIF(file, order, location)
+	})
+}
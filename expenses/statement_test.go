@@ -0,0 +1,200 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+const testOFX = `OFXHEADER:100
+DATA:OFXSGML
+
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>THB
+<BANKACCTFROM><ACCTID>123<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20260914<TRNAMT>-79.00<FITID>1<NAME>Smoothie<MEMO>night market</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20260914<TRNAMT>1000.00<FITID>2<NAME>Salary</STMTTRN>
+<STMTTRN><TRNTYPE>ATM<DTPOSTED>20260915120000[+7:ICT]<TRNAMT>-15.50<FITID>3<CURRENCY><CURSYM>USD</CURRENCY></STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20260915<TRNAMT>-15.50<FITID>3<NAME>Listed twice</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>
+`
+
+func importStatement(t *testing.T, handler Handler, format string, query string, contentType string, body string) (StatementImportResult, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, "/expenses/import/"+format+query, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	if format == "ofx" {
+		handler.ImportOFX(c)
+	} else {
+		handler.ImportQIF(c)
+	}
+
+	var result StatementImportResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result, rec
+}
+
+func TestImportOFX(t *testing.T) {
+	// Arrange
+	handler := Handler{Store: newTestStore()}
+
+	// Act
+	result, rec := importStatement(t, handler, "ofx", "", "application/x-ofx", testOFX)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 4, result.Transactions)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, 1, result.Duplicates)
+	assert.Equal(t, 1, result.Credits)
+	assert.Empty(t, result.Errors)
+
+	expenses, _ := handler.Store.List(context.Background(), ListQuery{Sort: "id", Limit: 10})
+	assert.Equal(t, []Expense{
+		{ID: 1, Title: "Smoothie", Amount: 7900, Note: "night market", Currency: "THB",
+			SpentAt: time.Date(2026, 9, 14, 0, 0, 0, 0, time.UTC), CreatedAt: testTime, UpdatedAt: testTime},
+		{ID: 2, Title: "ATM", Amount: 1550, Currency: "USD",
+			SpentAt: time.Date(2026, 9, 15, 5, 0, 0, 0, time.UTC), CreatedAt: testTime, UpdatedAt: testTime},
+	}, expenses)
+}
+
+func TestImportOFXTwice(t *testing.T) {
+	// Arrange
+	handler := Handler{Store: newTestStore()}
+	importStatement(t, handler, "ofx", "", echo.MIMEOctetStream, testOFX)
+
+	// Act
+	preview, previewRec := importStatement(t, handler, "ofx", "?dry_run=true&credits=true", echo.MIMEOctetStream, testOFX)
+	result, rec := importStatement(t, handler, "ofx", "?credits=true", echo.MIMEOctetStream, testOFX)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, previewRec.Code)
+	assert.True(t, preview.DryRun)
+	assert.Equal(t, 3, preview.Duplicates)
+	assert.Len(t, preview.Expenses, 1)
+	assert.Equal(t, "Salary", preview.Expenses[0].Title)
+	assert.Equal(t, Money(-100000), preview.Expenses[0].Amount)
+	assert.Equal(t, 0, preview.Expenses[0].ID)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 1, result.Imported)
+	assert.Equal(t, 3, result.Expenses[0].ID)
+
+	expenses, _ := handler.Store.List(context.Background(), ListQuery{Sort: "id", Limit: 10})
+	assert.Len(t, expenses, 3)
+}
+
+func TestImportQIF(t *testing.T) {
+	// Arrange
+	handler := Handler{Store: newTestStore()}
+	file := "!Type:Bank\n" +
+		"D14/09/2026\nT-79.00\nPSmoothie\nLFood:Beverage/Work\n^\n" +
+		"D15/09/2026\nT-500.00\nPSavings\nL[Savings]\n^\n"
+
+	// Act
+	result, rec := importStatement(t, handler, "qif", "?date_order=DMY&tz=UTC", "application/qif", file)
+	again, _ := importStatement(t, handler, "qif", "?date_order=dmy&tz=UTC", "application/qif", file)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, Expense{ID: 1, Title: "Smoothie", Amount: 7900, Currency: "THB", Tags: []string{"food", "beverage"},
+		SpentAt: time.Date(2026, 9, 14, 0, 0, 0, 0, time.UTC), CreatedAt: testTime, UpdatedAt: testTime},
+		result.Expenses[0])
+	assert.Empty(t, result.Expenses[1].Tags)
+
+	assert.Equal(t, 0, again.Imported)
+	assert.Equal(t, 2, again.Duplicates)
+}
+
+func TestImportStatementErrors(t *testing.T) {
+	// Arrange
+	handler := Handler{Store: newTestStore()}
+	file := "!Type:Bank\n" +
+		"D9/14/2026\nT-1\nPRice\n^\n" +
+		"D9/31/2026\nTabc\nPBus\n^\n" +
+		"D9/14/2026\nT-1\n^\n"
+
+	// Act
+	preview, previewRec := importStatement(t, handler, "qif", "?dry_run=true", "application/qif", file)
+	result, rec := importStatement(t, handler, "qif", "", "application/qif", file)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, previewRec.Code)
+	assert.Equal(t, 3, preview.Transactions)
+	assert.Len(t, preview.Expenses, 1)
+	assert.Equal(t, []ImportRowError{
+		{Row: 6, Column: "T", Message: `invalid amount "abc"`},
+		{Row: 6, Column: "D", Message: `invalid date "9/31/2026" for the mdy date order`},
+		{Row: 10, Column: "payee", Message: "the transaction has no payee, memo or type for the title"},
+	}, preview.Errors)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Empty(t, result.Expenses)
+	expenses, _ := handler.Store.List(context.Background(), ListQuery{Sort: "id", Limit: 10})
+	assert.Empty(t, expenses)
+
+	for _, test := range []struct {
+		format, query, contentType, body string
+		status                           int
+	}{
+		{"ofx", "", MIMETextCSV, testOFX, http.StatusUnsupportedMediaType},
+		{"ofx", "?credits=maybe", echo.MIMEOctetStream, testOFX, http.StatusBadRequest},
+		{"ofx", "?tz=Mars/Olympus", echo.MIMEOctetStream, testOFX, http.StatusBadRequest},
+		{"ofx", "", echo.MIMEOctetStream, "title,amount\n", http.StatusBadRequest},
+		{"qif", "?date_order=ydm", echo.MIMEOctetStream, file, http.StatusBadRequest},
+		{"qif", "", echo.MIMEOctetStream, testOFX, http.StatusBadRequest},
+	} {
+		_, rec := importStatement(t, handler, test.format, test.query, test.contentType, test.body)
+
+		assert.Equal(t, test.status, rec.Code, test.format+test.query)
+	}
+}
+
+func TestPostgresStoreCreateImported(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	claim := "INSERT INTO imported_transactions \\(workspace_id, owner_id, transaction_id\\) VALUES \\(\\$1, \\$2, \\$3\\) ON CONFLICT DO NOTHING"
+	mock.ExpectBegin()
+	mock.ExpectExec(claim).WithArgs(0, 0, "ofx:123:1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO expenses").
+		WithArgs(0, 0, "rice", 5000, "", sqlmock.AnyArg(), "THB", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "spent_at", "created_at", "updated_at"}).
+			AddRow(7, testTime, testTime, testTime))
+	mock.ExpectExec("UPDATE imported_transactions SET expense_id = \\$1 WHERE workspace_id = \\$2 AND owner_id = \\$3 AND transaction_id = \\$4").
+		WithArgs(7, 0, 0, "ofx:123:1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(claim).WithArgs(0, 0, "ofx:123:2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	expenses := []Expense{
+		{Title: "rice", Amount: 5000, Currency: "THB"},
+		{Title: "bus", Amount: 1500, Currency: "THB"},
+	}
+
+	// Act
+	err = NewPostgresStore(db).CreateImported(context.Background(), expenses, []string{"ofx:123:1", "ofx:123:2"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 7, expenses[0].ID)
+	assert.Equal(t, 0, expenses[1].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,75 @@
+package expenses
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by an ExpenseStore
+// when there is no expense with the requested ID.
+var ErrNotFound = errors.New("expense not found")
+
+// ExpenseStore is the storage used by Handler.
+// PostgresStore is used by the server and MemoryStore
+// is used for local development and unit tests.
+type ExpenseStore interface {
+	// Create inserts a new expense and sets its ID.
+	Create(ctx context.Context, expense *Expense) error
+
+	// CreateAll inserts every expense, or none of them, and sets their IDs.
+	CreateAll(ctx context.Context, expenses []Expense) error
+
+	// Imported returns which of the IDs of bank transactions were imported before.
+	Imported(ctx context.Context, transactionIDs []string) (map[string]bool, error)
+
+	// CreateImported inserts expenses[i] as the bank transaction of transactionIDs[i],
+	// every one or none of them, and sets their IDs. The expenses of transactions
+	// imported before are skipped and keep ID 0.
+	CreateImported(ctx context.Context, expenses []Expense, transactionIDs []string) error
+
+	// Batch runs the operations in one transaction and returns the error of each,
+	// ErrNotFound when the expense to update or delete does not exist.
+	// Creates are inserted together before the other operations run in order.
+	// With atomic set, nothing is saved when an operation fails. Otherwise
+	// the failure of an operation does not undo or stop the others.
+	// Created and updated expenses are stored in the Expense of their operation.
+	Batch(ctx context.Context, operations []BatchOperation, atomic bool) ([]error, error)
+
+	// Get returns the expense of the given ID unless it is deleted.
+	Get(ctx context.Context, id int) (Expense, error)
+
+	// Update replaces every field of the expense of expense.ID unless it is deleted.
+	Update(ctx context.Context, expense *Expense) error
+
+	// Modify runs apply on the expense of the given ID and saves the result
+	// atomically, so concurrent writes cannot be lost in between.
+	// An error from apply is returned as is and nothing is saved.
+	Modify(ctx context.Context, id int, apply func(expense *Expense) error) (Expense, error)
+
+	// List returns the expenses which are not deleted and match the query,
+	// in the order of the query.
+	List(ctx context.Context, query ListQuery) ([]Expense, error)
+
+	// Stream calls fn with each expense List would return, one at a time,
+	// without holding every expense in memory. An error from fn stops it
+	// and is returned as is.
+	Stream(ctx context.Context, query ListQuery, fn func(expense Expense) error) error
+
+	// Search finds at most limit expenses which are not deleted
+	// by words of their title or note, best matches first.
+	Search(ctx context.Context, text string, limit int) ([]SearchResult, error)
+
+	// Delete moves the expense of the given ID to the trash by setting DeletedAt.
+	Delete(ctx context.Context, id int) error
+
+	// ListTrash returns the deleted expenses, the most recently deleted first.
+	ListTrash(ctx context.Context) ([]Expense, error)
+
+	// Restore takes the deleted expense of the given ID out of the trash.
+	Restore(ctx context.Context, id int) (Expense, error)
+
+	// Purge permanently removes expenses deleted before the given time
+	// and returns how many were removed.
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
@@ -0,0 +1,343 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DefaultPercentiles are the percentiles of a summary when a request does not choose them.
+var DefaultPercentiles = []float64{50, 90}
+
+// periodUnits are the periods a summary can be grouped by.
+var periodUnits = []string{"day", "week", "month"}
+
+type (
+
+	// SummaryQuery groups the expenses matching Filter.
+	// GroupBy has "tag", a period unit of periodUnits, or both.
+	// Periods are in Location.
+	SummaryQuery struct {
+		Filter      ListQuery
+		GroupBy     []string
+		Percentiles []float64
+		Location    *time.Location
+	}
+
+	// SummaryGroup is the statistics of the amounts of a group of expenses.
+	// Groups are also split by currency, since amounts of different currencies
+	// cannot be added. An expense with many tags is in the group of each tag,
+	// and an expense without tags is in the group of the empty tag.
+	SummaryGroup struct {
+		Tag *string `json:"tag,omitempty"`
+
+		// Period is the first day of the day, week or month.
+		Period string `json:"period,omitempty"`
+
+		Currency    string           `json:"currency"`
+		Count       int              `json:"count"`
+		Total       Money            `json:"total"`
+		Average     Money            `json:"average"`
+		Min         Money            `json:"min"`
+		Max         Money            `json:"max"`
+		Percentiles map[string]Money `json:"percentiles"`
+	}
+
+	// Summary is the response of GetSummary.
+	// Totals are the statistics of every matching expense by currency.
+	Summary struct {
+		GroupBy  []string       `json:"group_by"`
+		Timezone string         `json:"timezone"`
+		Totals   []SummaryGroup `json:"totals"`
+		Groups   []SummaryGroup `json:"groups"`
+	}
+
+	// ReportStore computes reports of expenses.
+	ReportStore interface {
+		// Summarize returns the groups of the query ordered by tag, period and currency.
+		Summarize(ctx context.Context, query SummaryQuery) ([]SummaryGroup, error)
+	}
+)
+
+// MarshalJSON writes the amounts with the decimals of currency.
+func (group SummaryGroup) MarshalJSON() ([]byte, error) {
+
+	percentiles := map[string]json.RawMessage{}
+	for name, amount := range group.Percentiles {
+		percentiles[name] = amount.jsonIn(group.Currency)
+	}
+	data, err := json.Marshal(percentiles)
+	if err != nil {
+		return nil, err
+	}
+
+	type plain SummaryGroup
+	return replaceMembers(plain(group), map[string]json.RawMessage{
+		"total":       group.Total.jsonIn(group.Currency),
+		"average":     group.Average.jsonIn(group.Currency),
+		"min":         group.Min.jsonIn(group.Currency),
+		"max":         group.Max.jsonIn(group.Currency),
+		"percentiles": data,
+	})
+}
+
+// UnmarshalJSON reads a group whose amounts have the decimals of its currency.
+func (group *SummaryGroup) UnmarshalJSON(data []byte) error {
+
+	type plain SummaryGroup
+	fields := struct {
+		*plain
+		Total       json.RawMessage            `json:"total"`
+		Average     json.RawMessage            `json:"average"`
+		Min         json.RawMessage            `json:"min"`
+		Max         json.RawMessage            `json:"max"`
+		Percentiles map[string]json.RawMessage `json:"percentiles"`
+	}{plain: (*plain)(group)}
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	for _, amount := range []struct {
+		data json.RawMessage
+		to   *Money
+	}{
+		{fields.Total, &group.Total},
+		{fields.Average, &group.Average},
+		{fields.Min, &group.Min},
+		{fields.Max, &group.Max},
+	} {
+		if err := amount.to.unmarshalIn(amount.data, group.Currency); err != nil {
+			return err
+		}
+	}
+
+	if fields.Percentiles != nil {
+		group.Percentiles = map[string]Money{}
+	}
+	for name, amount := range fields.Percentiles {
+		var percentile Money
+		if err := percentile.unmarshalIn(amount, group.Currency); err != nil {
+			return err
+		}
+		group.Percentiles[name] = percentile
+	}
+
+	return nil
+}
+
+// percentileName names a percentile in SummaryGroup.Percentiles, such as p90.
+func percentileName(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// periodUnit returns the period unit of GroupBy, if any.
+func (query SummaryQuery) periodUnit() string {
+	for _, group := range query.GroupBy {
+		if contains(periodUnits, group) {
+			return group
+		}
+	}
+	return ""
+}
+
+// ParseSummaryQuery reads the query parameters of GetSummary:
+// the filters read by ParseFilters, group_by (tag, day, week, month,
+// or tag with a period such as tag,month) and percentiles (such as 50,90,99).
+func ParseSummaryQuery(c echo.Context) (SummaryQuery, error) {
+
+	filter, location, err := ParseFilters(c)
+	if err != nil {
+		return SummaryQuery{}, err
+	}
+
+	query := SummaryQuery{Filter: filter, Percentiles: DefaultPercentiles, Location: location}
+
+	if groupBy := c.QueryParam("group_by"); groupBy != "" {
+		periods := 0
+		for _, group := range strings.Split(groupBy, ",") {
+			group = strings.TrimSpace(group)
+			switch {
+			case group == "tag" && !contains(query.GroupBy, "tag"):
+			case contains(periodUnits, group):
+				periods++
+			default:
+				return query, fmt.Errorf("cannot group by %q, use tag, day, week, month or tag with one of them", group)
+			}
+			query.GroupBy = append(query.GroupBy, group)
+		}
+		if periods > 1 {
+			return query, errors.New("group_by can have only one of day, week and month")
+		}
+	}
+
+	if percentiles := c.QueryParam("percentiles"); percentiles != "" {
+		query.Percentiles = nil
+		for _, value := range strings.Split(percentiles, ",") {
+			p, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || p < 0 || p > 100 {
+				return query, fmt.Errorf("invalid percentile %q, use a number from 0 to 100", value)
+			}
+			query.Percentiles = append(query.Percentiles, p)
+		}
+	}
+
+	return query, nil
+}
+
+// GetSummary handles HTTP GET request to summarize the amounts of expenses,
+// grouped as asked by the query parameters read by ParseSummaryQuery.
+func (handler Handler) GetSummary(c echo.Context) error {
+
+	query, err := ParseSummaryQuery(c)
+
+	var syntaxError *SyntaxError
+	if errors.As(err, &syntaxError) {
+		return c.JSON(http.StatusBadRequest, syntaxError)
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	ctx := c.Request().Context()
+
+	groups, err := handler.Reports.Summarize(ctx, query)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot summarize expenses. " + err.Error()})
+	}
+
+	totalQuery := query
+	totalQuery.GroupBy = nil
+	totals, err := handler.Reports.Summarize(ctx, totalQuery)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot summarize expenses. " + err.Error()})
+	}
+
+	summary := Summary{
+		GroupBy:  query.GroupBy,
+		Timezone: query.Location.String(),
+		Totals:   totals,
+		Groups:   groups,
+	}
+	if summary.GroupBy == nil {
+		summary.GroupBy = []string{}
+	}
+	if summary.Totals == nil {
+		summary.Totals = []SummaryGroup{}
+	}
+	if summary.Groups == nil {
+		summary.Groups = []SummaryGroup{}
+	}
+
+	return c.JSON(http.StatusOK, summary)
+}
+
+// roundHalfAway rounds x to the nearest integer, away from zero on ties,
+// the way Postgres rounds NUMERIC.
+func roundHalfAway(x float64) Money {
+	return Money(math.Round(x))
+}
+
+// percentile interpolates the p-th percentile of sorted amounts
+// like the percentile_cont aggregate of Postgres.
+func percentile(sorted []Money, p float64) Money {
+
+	position := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+
+	fraction := position - float64(lower)
+	return roundHalfAway(float64(sorted[lower]) + fraction*float64(sorted[lower+1]-sorted[lower]))
+}
+
+// SummarizeExpenses is the Summarize of stores which cannot aggregate in SQL.
+func SummarizeExpenses(expenses []Expense, query SummaryQuery) []SummaryGroup {
+
+	location := query.Location
+	if location == nil {
+		location = DefaultTimezone
+	}
+	unit := query.periodUnit()
+	byTag := contains(query.GroupBy, "tag")
+
+	type key struct{ tag, period, currency string }
+	amounts := map[key][]Money{}
+
+	for _, expense := range expenses {
+		if !query.Filter.Matches(expense) {
+			continue
+		}
+
+		k := key{currency: expense.Currency}
+		if unit != "" {
+			k.period = startOf(expense.SpentAt.In(location), unit).Format(DateLayout)
+		}
+
+		tags := []string{""}
+		if byTag && len(expense.Tags) > 0 {
+			tags = expense.Tags
+		}
+		for _, tag := range tags {
+			k.tag = tag
+			amounts[k] = append(amounts[k], expense.Amount)
+		}
+	}
+
+	var keys []key
+	for k := range amounts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].tag != keys[j].tag {
+			return keys[i].tag < keys[j].tag
+		}
+		if keys[i].period != keys[j].period {
+			return keys[i].period < keys[j].period
+		}
+		return keys[i].currency < keys[j].currency
+	})
+
+	var groups []SummaryGroup
+
+	for _, k := range keys {
+		values := amounts[k]
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+		group := SummaryGroup{
+			Period:      k.period,
+			Currency:    k.currency,
+			Count:       len(values),
+			Min:         values[0],
+			Max:         values[len(values)-1],
+			Percentiles: map[string]Money{},
+		}
+		if byTag {
+			tag := k.tag
+			group.Tag = &tag
+		}
+		for _, value := range values {
+			group.Total += value
+		}
+		group.Average = roundHalfAway(float64(group.Total) / float64(group.Count))
+		for _, p := range query.Percentiles {
+			group.Percentiles[percentileName(p)] = percentile(values, p)
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups
+}
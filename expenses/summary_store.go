@@ -0,0 +1,88 @@
+package expenses
+
+import (
+	"context"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+func (store *PostgresStore) Summarize(ctx context.Context, query SummaryQuery) ([]SummaryGroup, error) {
+
+	var args []interface{}
+	arg := placeholders(&args)
+
+	location := query.Location
+	if location == nil {
+		location = DefaultTimezone
+	}
+
+	percentiles := make([]float64, len(query.Percentiles))
+	for i, p := range query.Percentiles {
+		percentiles[i] = p / 100
+	}
+
+	from := "expenses"
+	tag, period := "''", "''"
+
+	if contains(query.GroupBy, "tag") {
+		from += " LEFT JOIN LATERAL unnest(expenses.tags) AS expense_tag(name) ON true"
+		tag = "COALESCE(expense_tag.name, '')"
+	}
+	if unit := query.periodUnit(); unit != "" {
+		period = "to_char(date_trunc('" + unit + "', spent_at AT TIME ZONE " + arg(location.String()) + "), 'YYYY-MM-DD')"
+	}
+
+	statement := `
+		SELECT ` + tag + `, ` + period + `, currency, count(*), sum(amount)::BIGINT, round(avg(amount))::BIGINT,
+			min(amount), max(amount),
+			percentile_cont(` + arg(pq.Array(percentiles)) + `::FLOAT8[]) WITHIN GROUP (ORDER BY amount)
+		FROM ` + from + `
+		WHERE ` + strings.Join(query.Filter.filterSQL(ctx, arg), " AND ") + `
+		GROUP BY 1, 2, 3
+		ORDER BY 1, 2, 3`
+
+	rows, err := store.DB.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []SummaryGroup
+
+	for rows.Next() {
+		var group SummaryGroup
+		var tagName string
+		var values pq.Float64Array
+
+		err := rows.Scan(&tagName, &group.Period, &group.Currency, &group.Count, &group.Total, &group.Average,
+			&group.Min, &group.Max, &values)
+		if err != nil {
+			return nil, err
+		}
+
+		if contains(query.GroupBy, "tag") {
+			group.Tag = &tagName
+		}
+		group.Percentiles = map[string]Money{}
+		for i, p := range query.Percentiles {
+			if i < len(values) {
+				group.Percentiles[percentileName(p)] = roundHalfAway(values[i])
+			}
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups, rows.Err()
+}
+
+func (store *MemoryStore) Summarize(ctx context.Context, query SummaryQuery) ([]SummaryGroup, error) {
+
+	expenses, err := store.List(ctx, ListQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	return SummarizeExpenses(expenses, query), nil
+}
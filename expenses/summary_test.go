@@ -0,0 +1,160 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSummaryStore() *MemoryStore {
+	store := NewMemoryStore()
+	for _, expense := range []Expense{
+		{Title: "smoothie", Amount: 7900, Tags: []string{"food"}, SpentAt: time.Date(2026, 8, 31, 18, 0, 0, 0, time.UTC)},
+		{Title: "rice", Amount: 5000, Tags: []string{"food"}, SpentAt: time.Date(2026, 9, 2, 5, 0, 0, 0, time.UTC)},
+		{Title: "noodles", Amount: 6000, Tags: []string{"food", "work"}, SpentAt: time.Date(2026, 9, 20, 5, 0, 0, 0, time.UTC)},
+		{Title: "bus", Amount: 1500, SpentAt: time.Date(2026, 9, 21, 5, 0, 0, 0, time.UTC)},
+		{Title: "coffee", Amount: 450, Currency: "USD", Tags: []string{"food"}, SpentAt: time.Date(2026, 9, 22, 5, 0, 0, 0, time.UTC)},
+	} {
+		if expense.Currency == "" {
+			expense.Currency = "THB"
+		}
+		store.Create(context.Background(), &expense)
+	}
+	return store
+}
+
+func getSummary(t *testing.T, store *MemoryStore, target string) (Summary, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	handler := Handler{Store: store, Reports: store}
+	handler.GetSummary(c)
+
+	var summary Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	return summary, rec
+}
+
+func TestGetSummaryByTag(t *testing.T) {
+	// Act
+	got, rec := getSummary(t, newSummaryStore(), "/expenses/summary?group_by=tag&q=currency:thb&percentiles=50,75")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	empty, food, work := "", "food", "work"
+	assert.Equal(t, []SummaryGroup{
+		{Tag: &empty, Currency: "THB", Count: 1, Total: 1500, Average: 1500, Min: 1500, Max: 1500,
+			Percentiles: map[string]Money{"p50": 1500, "p75": 1500}},
+		{Tag: &food, Currency: "THB", Count: 3, Total: 18900, Average: 6300, Min: 5000, Max: 7900,
+			Percentiles: map[string]Money{"p50": 6000, "p75": 6950}},
+		{Tag: &work, Currency: "THB", Count: 1, Total: 6000, Average: 6000, Min: 6000, Max: 6000,
+			Percentiles: map[string]Money{"p50": 6000, "p75": 6000}},
+	}, got.Groups)
+	assert.Equal(t, []SummaryGroup{
+		{Currency: "THB", Count: 4, Total: 20400, Average: 5100, Min: 1500, Max: 7900,
+			Percentiles: map[string]Money{"p50": 5500, "p75": 6475}},
+	}, got.Totals)
+}
+
+func TestGetSummaryByTagAndMonth(t *testing.T) {
+	// Act
+	got, rec := getSummary(t, newSummaryStore(), "/expenses/summary?group_by=tag,month&tags=food&tz=Asia/Bangkok")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"tag", "month"}, got.GroupBy)
+	assert.Equal(t, "Asia/Bangkok", got.Timezone)
+
+	type row struct {
+		tag, period, currency string
+		count                 int
+		total                 Money
+	}
+	var rows []row
+	for _, group := range got.Groups {
+		rows = append(rows, row{*group.Tag, group.Period, group.Currency, group.Count, group.Total})
+	}
+
+	// The smoothie was spent on the 1st of September in Bangkok.
+	assert.Equal(t, []row{
+		{"food", "2026-09-01", "THB", 3, 18900},
+		{"food", "2026-09-01", "USD", 1, 450},
+		{"work", "2026-09-01", "THB", 1, 6000},
+	}, rows)
+}
+
+func TestGetSummaryByWeek(t *testing.T) {
+	got, rec := getSummary(t, newSummaryStore(), "/expenses/summary?group_by=week&q=currency:thb")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var periods []string
+	for _, group := range got.Groups {
+		assert.Nil(t, group.Tag)
+		periods = append(periods, group.Period)
+	}
+	assert.Equal(t, []string{"2026-08-31", "2026-09-14", "2026-09-21"}, periods)
+}
+
+func TestGetSummaryErrors(t *testing.T) {
+	for _, target := range []string{
+		"/expenses/summary?group_by=title",
+		"/expenses/summary?group_by=day,month",
+		"/expenses/summary?group_by=tag,tag",
+		"/expenses/summary?percentiles=101",
+		"/expenses/summary?min_amount=abc",
+		"/expenses/summary?q=amount>x",
+	} {
+		_, rec := getSummary(t, NewMemoryStore(), target)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
+
+func TestPostgresStoreSummarize(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"tag", "period", "currency", "count", "sum", "avg", "min", "max", "percentile_cont"}).
+		AddRow("food", "2026-09-01", "THB", 3, 18900, 6300, 5000, 7900, `{6000,7854.5}`)
+	mock.ExpectQuery("SELECT COALESCE\\(expense_tag.name, ''\\), to_char\\(date_trunc\\('month', spent_at AT TIME ZONE \\$1\\), 'YYYY-MM-DD'\\),"+
+		"(.+) FROM expenses LEFT JOIN LATERAL unnest\\(expenses.tags\\)(.+)"+
+		"WHERE workspace_id = \\$3 AND \\(owner_id = \\$4 OR \\$5\\) AND deleted_at IS NULL AND "+regexp.QuoteMeta(rescaledAmountSQL)+" >= \\$6 GROUP BY 1, 2, 3").
+		WithArgs("Asia/Bangkok", `{0.5,0.95}`, 0, 0, false, 1000).
+		WillReturnRows(rows)
+
+	bangkok, _ := ParseTimezone("Asia/Bangkok")
+	minAmount := Money(1000)
+	store := NewPostgresStore(db)
+
+	// Act
+	got, err := store.Summarize(context.Background(), SummaryQuery{
+		Filter:      ListQuery{MinAmount: &minAmount},
+		GroupBy:     []string{"tag", "month"},
+		Percentiles: []float64{50, 95},
+		Location:    bangkok,
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	food := "food"
+	assert.Equal(t, []SummaryGroup{{
+		Tag: &food, Period: "2026-09-01", Currency: "THB", Count: 3, Total: 18900, Average: 6300, Min: 5000, Max: 7900,
+		Percentiles: map[string]Money{"p50": 6000, "p95": 7855},
+	}}, got)
+}
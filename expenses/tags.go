@@ -0,0 +1,194 @@
+package expenses
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// Errors returned by a TagStore.
+var (
+	ErrTagNotFound = errors.New("tag not found")
+	ErrTagExists   = errors.New("tag already exists")
+)
+
+// tagColor is the format of Tag.Color, such as #ff8800.
+var tagColor = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+type (
+
+	// Tag is a tag of the expenses of a workspace. Count is the number of
+	// expenses of the workspace having the tag, not counting the ones in the trash.
+	Tag struct {
+		Name        string `json:"name"`
+		Color       string `json:"color,omitempty"`
+		Description string `json:"description,omitempty"`
+		Count       int    `json:"count"`
+	}
+
+	// TagMerge is the request of MergeTags.
+	TagMerge struct {
+		Sources []string `json:"sources"`
+		Target  string   `json:"target"`
+	}
+
+	// TagStore stores the tags of expenses. A tag exists while an expense,
+	// deleted or not, has it or while it has a color or description.
+	// Names are normalized by NormalizeTag. Tags belong to the workspace,
+	// so renames and merges change the expenses of all of its members.
+	TagStore interface {
+		// ListTags returns every tag, the most used first.
+		ListTags(ctx context.Context) ([]Tag, error)
+
+		// UpdateTag renames the tag of the given name to tag.Name
+		// on every expense and replaces its color and description.
+		// It returns ErrTagExists when tag.Name is another existing tag.
+		UpdateTag(ctx context.Context, name string, tag Tag) (Tag, error)
+
+		// MergeTags replaces the sources by target on every expense.
+		// target keeps its color and description, or takes the ones
+		// of the first source having them.
+		MergeTags(ctx context.Context, sources []string, target string) (Tag, error)
+	}
+)
+
+// NormalizeTag lower-cases name and collapses its spaces,
+// so "Food", "food" and " FOOD " are the same tag.
+func NormalizeTag(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// NormalizeTags normalizes tags, dropping empty and repeated ones.
+// It returns nil when no tag is left.
+func NormalizeTags(tags []string) []string {
+
+	var normalized []string
+	for _, tag := range tags {
+		if tag = NormalizeTag(tag); tag != "" && !contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+
+	return normalized
+}
+
+// retag replaces the sources by target in tags, keeping the first position
+// of target and dropping repeats.
+func retag(tags []string, sources []string, target string) []string {
+
+	var retagged []string
+	for _, tag := range tags {
+		if contains(sources, tag) {
+			tag = target
+		}
+		if !contains(retagged, tag) {
+			retagged = append(retagged, tag)
+		}
+	}
+
+	return retagged
+}
+
+// GetTags handles HTTP GET request to list the tags with their usage counts.
+func (handler Handler) GetTags(c echo.Context) error {
+
+	tags, err := handler.Tags.ListTags(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot list tags. " + err.Error()})
+	}
+
+	if tags == nil {
+		tags = []Tag{}
+	}
+
+	return c.JSON(http.StatusOK, tags)
+}
+
+// PutTag handles HTTP PUT request to rename a tag on every expense
+// and to set its color and description. A missing name keeps the name.
+// Renaming changes the expenses of every member, so it needs auth.WriteAllExpenses.
+func (handler Handler) PutTag(c echo.Context) error {
+
+	var tag Tag
+	if err := c.Bind(&tag); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot read request's body. " + err.Error()})
+	}
+
+	name := NormalizeTag(c.Param("name"))
+	if tag.Name = NormalizeTag(tag.Name); tag.Name == "" {
+		tag.Name = name
+	}
+
+	tag.Color = strings.ToLower(strings.TrimSpace(tag.Color))
+	if tag.Color != "" && !tagColor.MatchString(tag.Color) {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"color must be a hex color such as #ff8800."})
+	}
+	tag.Description = strings.TrimSpace(tag.Description)
+
+	if identity, _ := auth.IdentityOf(c); tag.Name != name && !identity.Can(auth.WriteAllExpenses) {
+		return c.JSON(http.StatusForbidden,
+			ErrorResponse{"renaming a tag needs the " + string(auth.WriteAllExpenses) + " permission."})
+	}
+
+	updated, err := handler.Tags.UpdateTag(c.Request().Context(), name, tag)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, updated)
+	case ErrTagNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	case ErrTagExists:
+		return c.JSON(http.StatusConflict,
+			ErrorResponse{err.Error() + ". Use POST /tags/merge to merge tags."})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot update the tag. " + err.Error()})
+	}
+}
+
+// MergeTags handles HTTP POST request to merge synonym tags into a target tag.
+// Merging changes the expenses of every member, so its route needs auth.WriteAllExpenses.
+func (handler Handler) MergeTags(c echo.Context) error {
+
+	var merge TagMerge
+	if err := c.Bind(&merge); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot read request's body. " + err.Error()})
+	}
+
+	target := NormalizeTag(merge.Target)
+	if target == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"target is required."})
+	}
+
+	var sources []string
+	for _, source := range NormalizeTags(merge.Sources) {
+		if source != target {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"sources must have a tag other than the target."})
+	}
+
+	tag, err := handler.Tags.MergeTags(c.Request().Context(), sources, target)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, tag)
+	case ErrTagNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot merge the tags. " + err.Error()})
+	}
+}
@@ -0,0 +1,288 @@
+package expenses
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/lib/pq"
+)
+
+// tagsQuery selects the tags of the workspace of $1 used by its expenses
+// or having a row in the tags table. The members of a workspace share its tags.
+const tagsQuery = `
+	SELECT name, COALESCE(meta.color, ''), COALESCE(meta.description, ''), COALESCE(used.count, 0)
+	FROM (
+		SELECT tag AS name, count(*) FILTER (WHERE deleted_at IS NULL) AS count
+		FROM expenses, unnest(tags) AS tag
+		WHERE workspace_id = $1
+		GROUP BY tag
+	) used
+	FULL JOIN (SELECT name, color, description FROM tags WHERE workspace_id = $1) meta USING (name)`
+
+func scanTag(row scanner) (Tag, error) {
+	var tag Tag
+	err := row.Scan(&tag.Name, &tag.Color, &tag.Description, &tag.Count)
+	return tag, err
+}
+
+func findTag(ctx context.Context, db queryer, name string) (Tag, error) {
+
+	tag, err := scanTag(db.QueryRowContext(ctx, tagsQuery+" WHERE name = $2", auth.WorkspaceID(ctx), name))
+	if err == sql.ErrNoRows {
+		return Tag{}, ErrTagNotFound
+	}
+
+	return tag, err
+}
+
+// retagExpenses replaces the sources by target on every expense of the workspace,
+// keeping the first position of target and dropping repeats.
+func retagExpenses(ctx context.Context, db queryer, sources []string, target string) error {
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE expenses SET updated_at = now(), tags = ARRAY(
+			SELECT tag FROM (
+				SELECT CASE WHEN tag = ANY($1) THEN $2::TEXT ELSE tag END AS tag, position
+				FROM unnest(tags) WITH ORDINALITY AS old(tag, position)
+			) new
+			GROUP BY tag
+			ORDER BY min(position)
+		)
+		WHERE workspace_id = $3 AND tags && $1::TEXT[]
+	`, pq.Array(sources), target, auth.WorkspaceID(ctx))
+
+	return err
+}
+
+func (store *PostgresStore) ListTags(ctx context.Context) ([]Tag, error) {
+
+	rows, err := store.DB.QueryContext(ctx, tagsQuery+" ORDER BY 4 DESC, name", auth.WorkspaceID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []Tag
+
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (store *PostgresStore) UpdateTag(ctx context.Context, name string, tag Tag) (Tag, error) {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Tag{}, err
+	}
+	defer tx.Rollback()
+
+	if _, err := findTag(ctx, tx, name); err != nil {
+		return Tag{}, err
+	}
+
+	if tag.Name != name {
+		_, err := findTag(ctx, tx, tag.Name)
+		if err == nil {
+			return Tag{}, ErrTagExists
+		}
+		if err != ErrTagNotFound {
+			return Tag{}, err
+		}
+
+		if err := retagExpenses(ctx, tx, []string{name}, tag.Name); err != nil {
+			return Tag{}, err
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM tags WHERE workspace_id = $1 AND name = $2", auth.WorkspaceID(ctx), name)
+		if err != nil {
+			return Tag{}, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO tags (workspace_id, name, color, description)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''))
+		ON CONFLICT (workspace_id, name) DO UPDATE SET color = EXCLUDED.color, description = EXCLUDED.description
+	`, auth.WorkspaceID(ctx), tag.Name, tag.Color, tag.Description)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	updated, err := findTag(ctx, tx, tag.Name)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	return updated, tx.Commit()
+}
+
+func (store *PostgresStore) MergeTags(ctx context.Context, sources []string, target string) (Tag, error) {
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Tag{}, err
+	}
+	defer tx.Rollback()
+
+	if err := retagExpenses(ctx, tx, sources, target); err != nil {
+		return Tag{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO tags (workspace_id, name, color, description)
+		SELECT workspace_id, $2, color, description FROM tags
+		WHERE workspace_id = $3 AND name = ANY($1::TEXT[])
+		ORDER BY array_position($1::TEXT[], name)
+		LIMIT 1
+		ON CONFLICT (workspace_id, name) DO NOTHING
+	`, pq.Array(sources), target, auth.WorkspaceID(ctx))
+	if err != nil {
+		return Tag{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM tags WHERE workspace_id = $1 AND name = ANY($2::TEXT[])",
+		auth.WorkspaceID(ctx), pq.Array(sources))
+	if err != nil {
+		return Tag{}, err
+	}
+
+	merged, err := findTag(ctx, tx, target)
+	if err != nil {
+		return Tag{}, err
+	}
+
+	return merged, tx.Commit()
+}
+
+// findTag returns the tag of the given name of a workspace with its count
+// of expenses of the workspace. The caller must hold store.mu.
+func (store *MemoryStore) findTag(workspace int, name string) (Tag, bool) {
+
+	tag, found := store.tags[tagKey{workspace, name}]
+	tag.Name = name
+
+	for id, expense := range store.expenses {
+		if store.owners[id].workspace == workspace && contains(expense.Tags, name) {
+			found = true
+			if expense.DeletedAt == nil {
+				tag.Count++
+			}
+		}
+	}
+
+	return tag, found
+}
+
+// retagExpenses replaces the sources by target on every expense of a workspace.
+// The caller must hold store.mu for writing.
+func (store *MemoryStore) retagExpenses(workspace int, sources []string, target string) {
+	for id, expense := range store.expenses {
+		if store.owners[id].workspace != workspace {
+			continue
+		}
+		for _, source := range sources {
+			if contains(expense.Tags, source) {
+				expense.Tags = retag(expense.Tags, sources, target)
+				expense.UpdatedAt = store.now()
+				store.expenses[id] = expense
+				break
+			}
+		}
+	}
+}
+
+func (store *MemoryStore) ListTags(ctx context.Context) ([]Tag, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	workspace := auth.WorkspaceID(ctx)
+
+	names := map[string]bool{}
+	for key := range store.tags {
+		if key.workspace == workspace {
+			names[key.name] = true
+		}
+	}
+	for id, expense := range store.expenses {
+		if store.owners[id].workspace != workspace {
+			continue
+		}
+		for _, name := range expense.Tags {
+			names[name] = true
+		}
+	}
+
+	var tags []Tag
+	for name := range names {
+		tag, _ := store.findTag(workspace, name)
+		tags = append(tags, tag)
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+func (store *MemoryStore) UpdateTag(ctx context.Context, name string, tag Tag) (Tag, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	workspace := auth.WorkspaceID(ctx)
+
+	if _, found := store.findTag(workspace, name); !found {
+		return Tag{}, ErrTagNotFound
+	}
+
+	if tag.Name != name {
+		if _, found := store.findTag(workspace, tag.Name); found {
+			return Tag{}, ErrTagExists
+		}
+		store.retagExpenses(workspace, []string{name}, tag.Name)
+		delete(store.tags, tagKey{workspace, name})
+	}
+
+	store.tags[tagKey{workspace, tag.Name}] = Tag{Name: tag.Name, Color: tag.Color, Description: tag.Description}
+
+	updated, _ := store.findTag(workspace, tag.Name)
+	return updated, nil
+}
+
+func (store *MemoryStore) MergeTags(ctx context.Context, sources []string, target string) (Tag, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	workspace := auth.WorkspaceID(ctx)
+
+	store.retagExpenses(workspace, sources, target)
+
+	targetKey := tagKey{workspace, target}
+	for _, source := range sources {
+		sourceKey := tagKey{workspace, source}
+		meta, ok := store.tags[sourceKey]
+		if _, exists := store.tags[targetKey]; ok && !exists {
+			meta.Name = target
+			store.tags[targetKey] = meta
+		}
+		delete(store.tags, sourceKey)
+	}
+
+	merged, found := store.findTag(workspace, target)
+	if !found {
+		return Tag{}, ErrTagNotFound
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,238 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTagContext(method string, target string, body string, name string) (echo.Context, *httptest.ResponseRecorder) {
+	c, rec := newIDContext(method, target, body, "")
+
+	// The requests are of an admin, who may rename tags.
+	auth.SetIdentity(c, auth.Identity{Subject: "api-key:1", WorkspaceID: 1, WorkspaceRole: auth.Admin})
+	if name != "" {
+		c.SetParamNames("name")
+		c.SetParamValues(name)
+	}
+
+	return c, rec
+}
+
+func newTagStore() *MemoryStore {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	store.Create(ctx, &Expense{Title: "smoothie", Tags: []string{"food", "beverage"}})
+	store.Create(ctx, &Expense{Title: "noodles", Tags: []string{"foods"}})
+	store.Create(ctx, &Expense{Title: "rice", Tags: []string{"meal", "foods"}})
+	store.Create(ctx, &Expense{Title: "bus", Tags: []string{"travel"}})
+	store.Delete(ctx, 4)
+	return store
+}
+
+func TestNormalizeTags(t *testing.T) {
+	assert.Equal(t, "team lunch", NormalizeTag("  Team   LUNCH "))
+	assert.Equal(t, []string{"food", "coffee"}, NormalizeTags([]string{"Food", " food", "", "COFFEE"}))
+	assert.Nil(t, NormalizeTags([]string{" "}))
+}
+
+func TestCreateExpenseNormalizesTags(t *testing.T) {
+	// Arrange
+	body := `{"title": "smoothie", "amount": 79, "tags": ["Food", "food ", "Beverage"]}`
+	c, rec := newTagContext(http.MethodPost, "/expenses", body, "")
+	handler := Handler{Store: NewMemoryStore()}
+
+	// Act
+	handler.CreateExpense(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"tags":["food","beverage"]`)
+}
+
+func TestGetTags(t *testing.T) {
+	// Arrange
+	store := newTagStore()
+	handler := Handler{Store: store, Tags: store}
+	c, rec := newTagContext(http.MethodGet, "/tags", "", "")
+
+	// Act
+	handler.GetTags(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var got []Tag
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, []Tag{
+		{Name: "foods", Count: 2},
+		{Name: "beverage", Count: 1},
+		{Name: "food", Count: 1},
+		{Name: "meal", Count: 1},
+		{Name: "travel", Count: 0},
+	}, got)
+}
+
+func TestPutTag(t *testing.T) {
+	// Arrange
+	store := newTagStore()
+	handler := Handler{Store: store, Tags: store}
+	body := `{"name": "Drinks", "color": "#FF8800", "description": "coffee and smoothies"}`
+	c, rec := newTagContext(http.MethodPut, "/tags/beverage", body, "beverage")
+
+	// Act
+	handler.PutTag(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"name": "drinks", "color": "#ff8800", "description": "coffee and smoothies", "count": 1}`,
+		rec.Body.String())
+
+	expense, _ := store.Get(context.Background(), 1)
+	assert.Equal(t, []string{"food", "drinks"}, expense.Tags)
+}
+
+func TestPutTagErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"unknown", `{"name": "other"}`, http.StatusNotFound},
+		{"food", `{"name": "Foods"}`, http.StatusConflict},
+		{"food", `{"color": "orange"}`, http.StatusBadRequest},
+		{"food", `{"description": "meals"}`, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		store := newTagStore()
+		handler := Handler{Store: store, Tags: store}
+		c, rec := newTagContext(http.MethodPut, "/tags/"+test.name, test.body, test.name)
+
+		handler.PutTag(c)
+
+		assert.Equal(t, test.code, rec.Code, test.body)
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	// Arrange
+	store := newTagStore()
+	store.UpdateTag(context.Background(), "meal", Tag{Name: "meal", Color: "#00ff00"})
+	handler := Handler{Store: store, Tags: store}
+	body := `{"sources": ["Foods", "meal", "food"], "target": "food"}`
+	c, rec := newTagContext(http.MethodPost, "/tags/merge", body, "")
+
+	// Act
+	handler.MergeTags(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"name": "food", "color": "#00ff00", "count": 3}`, rec.Body.String())
+
+	expense, _ := store.Get(context.Background(), 3)
+	assert.Equal(t, []string{"food"}, expense.Tags)
+
+	tags, _ := store.ListTags(context.Background())
+	assert.Len(t, tags, 3)
+}
+
+func TestTagsOfOtherMembers(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	handler := Handler{Store: store, Tags: store}
+	ours := auth.WithWorkspace(auth.WithUser(context.Background(), 1), 1)
+	theirs := auth.WithWorkspace(auth.WithUser(context.Background(), 2), 1)
+	store.Create(ours, &Expense{Title: "smoothie", Tags: []string{"food"}})
+	store.Create(theirs, &Expense{Title: "noodles", Tags: []string{"food"}})
+	store.Create(theirs, &Expense{Title: "coffee", Tags: []string{"drinks"}})
+
+	// Act
+	c, memberRec := newTagContext(http.MethodPut, "/tags/food", `{"name": "meals"}`, "food")
+	asWorkspace(c, 1, 1, auth.Member)
+	handler.PutTag(c)
+
+	c, existsRec := newTagContext(http.MethodPut, "/tags/food", `{"name": "drinks"}`, "food")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.PutTag(c)
+
+	c, renameRec := newTagContext(http.MethodPut, "/tags/food", `{"name": "meals"}`, "food")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.PutTag(c)
+
+	c, listRec := newTagContext(http.MethodGet, "/tags", "", "")
+	asWorkspace(c, 1, 1, auth.Member)
+	handler.GetTags(c)
+
+	theirExpense, _ := store.Get(theirs, 2)
+
+	// Assert
+	assert.Equal(t, http.StatusForbidden, memberRec.Code)
+	assert.Equal(t, http.StatusConflict, existsRec.Code)
+	assert.Equal(t, http.StatusOK, renameRec.Code)
+	assert.JSONEq(t, `{"name": "meals", "count": 2}`, renameRec.Body.String())
+	assert.Equal(t, []string{"meals"}, theirExpense.Tags)
+	assert.JSONEq(t, `[{"name": "meals", "count": 2}, {"name": "drinks", "count": 1}]`, listRec.Body.String())
+}
+
+func TestMergeTagsErrors(t *testing.T) {
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"sources": ["food"], "target": ""}`, http.StatusBadRequest},
+		{`{"sources": ["Food"], "target": "food"}`, http.StatusBadRequest},
+		{`{"sources": ["unknown"], "target": "other"}`, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		store := newTagStore()
+		handler := Handler{Store: store, Tags: store}
+		c, rec := newTagContext(http.MethodPost, "/tags/merge", test.body, "")
+
+		handler.MergeTags(c)
+
+		assert.Equal(t, test.code, rec.Code, test.body)
+	}
+}
+
+func TestPostgresStoreMergeTags(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE expenses SET updated_at = now\\(\\), tags = ARRAY(.+) WHERE workspace_id = (.+) AND tags && (.+)").
+		WithArgs(`{"foods"}`, "food", 0).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO tags (.+) ON CONFLICT \\(workspace_id, name\\) DO NOTHING").
+		WithArgs(`{"foods"}`, "food", 0).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM tags").
+		WithArgs(0, `{"foods"}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FULL JOIN \\(SELECT name, color, description FROM tags WHERE workspace_id = \\$1\\) meta USING \\(name\\) WHERE name = (.+)").
+		WithArgs(0, "food").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "color", "description", "count"}).
+			AddRow("food", "", "", 3))
+	mock.ExpectCommit()
+
+	store := NewPostgresStore(db)
+
+	// Act
+	got, err := store.MergeTags(context.Background(), []string{"foods"}, "food")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, Tag{Name: "food", Count: 3}, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,215 @@
+package expenses
+
+import (
+	"html"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Limits of the number of results of SearchExpenses.
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// Markers around the matched words of a highlight.
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+)
+
+// Markers ts_headline puts around the matched words, which escapeHeadline
+// replaces by HighlightStart and HighlightStop. They are private use
+// characters, which Search removes from the text of an expense before
+// ts_headline, so that they are not mistaken for the markers.
+const (
+	headlineStart = "\uE000"
+	headlineStop  = "\uE001"
+)
+
+// minSimilarity is the trigram similarity for a fuzzy match of a word,
+// the default threshold of pg_trgm.
+const minSimilarity = 0.3
+
+type (
+
+	// SearchResult is an expense found by a text search.
+	// Higher ranks are better matches.
+	SearchResult struct {
+		Expense    Expense    `json:"expense"`
+		Rank       float64    `json:"rank"`
+		Highlights Highlights `json:"highlights"`
+	}
+
+	// Highlights are the title and note escaped as HTML, with matched
+	// words between HighlightStart and HighlightStop.
+	Highlights struct {
+		Title string `json:"title"`
+		Note  string `json:"note"`
+	}
+)
+
+// SearchExpenses handles HTTP GET request to find expenses by text in their title or note.
+// It matches whole words and misspelled words, ranked best first.
+func (handler Handler) SearchExpenses(c echo.Context) error {
+
+	text := strings.TrimSpace(c.QueryParam("text"))
+	if text == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"text is required."})
+	}
+
+	limit := DefaultSearchLimit
+	if value := c.QueryParam("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MaxSearchLimit {
+			return c.JSON(http.StatusBadRequest,
+				ErrorResponse{"limit must be between 1 and " + strconv.Itoa(MaxSearchLimit) + "."})
+		}
+		limit = n
+	}
+
+	results, err := handler.Store.Search(c.Request().Context(), text, limit)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot search expenses. " + err.Error()})
+	}
+
+	if results == nil {
+		results = []SearchResult{}
+	}
+
+	return c.JSON(http.StatusOK, results)
+}
+
+// words splits text into lower-case words of letters and digits.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r)
+	})
+}
+
+// trigrams returns the trigrams of a word the way pg_trgm does,
+// padding it with two spaces before and one after.
+func trigrams(word string) map[string]bool {
+	runes := []rune("  " + word + " ")
+	set := map[string]bool{}
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
+
+// similarity is the pg_trgm similarity of two words,
+// the shared trigrams divided by all trigrams.
+func similarity(a string, b string) float64 {
+	x, y := trigrams(a), trigrams(b)
+	shared := 0
+	for trigram := range x {
+		if y[trigram] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(x)+len(y)-shared)
+}
+
+// matchWord scores how well a query word matches a word of the text.
+func matchWord(query string, word string) float64 {
+	switch {
+	case query == word:
+		return 1
+	case strings.HasPrefix(word, query):
+		return 0.8
+	}
+	if s := similarity(query, word); s >= minSimilarity {
+		return s * 0.8
+	}
+	return 0
+}
+
+// escapeHeadline escapes the HTML of a headline of ts_headline
+// and replaces its markers by HighlightStart and HighlightStop.
+func escapeHeadline(headline string) string {
+	return strings.NewReplacer(headlineStart, HighlightStart, headlineStop, HighlightStop).
+		Replace(html.EscapeString(headline))
+}
+
+// scoreText returns the score of text for the query words
+// and the text escaped as HTML with its matched words highlighted.
+func scoreText(queryWords []string, text string) (float64, string) {
+
+	score := 0.0
+	var highlighted strings.Builder
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			highlighted.WriteString(html.EscapeString(string(runes[i])))
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || unicode.Is(unicode.Mn, runes[i])) {
+			i++
+		}
+		word := string(runes[start:i])
+
+		best := 0.0
+		for _, query := range queryWords {
+			if s := matchWord(query, strings.ToLower(word)); s > best {
+				best = s
+			}
+		}
+
+		if best > 0 {
+			score += best
+			highlighted.WriteString(HighlightStart + word + HighlightStop)
+		} else {
+			highlighted.WriteString(word)
+		}
+	}
+
+	return score, highlighted.String()
+}
+
+// RankExpenses is the text search of stores without full-text indexes.
+// Title matches weigh twice as much as note matches.
+func RankExpenses(expenses []Expense, text string, limit int) []SearchResult {
+
+	queryWords := words(text)
+	var results []SearchResult
+
+	for _, expense := range expenses {
+		titleScore, title := scoreText(queryWords, expense.Title)
+		noteScore, note := scoreText(queryWords, expense.Note)
+
+		rank := titleScore + noteScore/2
+		if rank == 0 {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Expense:    expense,
+			Rank:       rank,
+			Highlights: Highlights{Title: title, Note: note},
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Expense.ID < results[j].Expense.ID
+	})
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
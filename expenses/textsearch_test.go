@@ -0,0 +1,125 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, similarity("smoothie", "smoothie"))
+	assert.InDelta(t, 0.5, similarity("smoothie", "smoothy"), 0.1)
+	assert.Less(t, similarity("smoothie", "latte"), minSimilarity)
+}
+
+func TestRankExpenses(t *testing.T) {
+	// Arrange
+	expenses := []Expense{
+		{ID: 1, Title: "Latte", Note: "with a smoothie for Ann"},
+		{ID: 2, Title: "Mango smoothie", Note: "after gym"},
+		{ID: 3, Title: "Rent"},
+		{ID: 4, Title: "Smoothies", Note: "team"},
+	}
+
+	// Act
+	got := RankExpenses(expenses, "smoothie", 10)
+
+	// Assert
+	var ids []int
+	for _, result := range got {
+		ids = append(ids, result.Expense.ID)
+	}
+	assert.Equal(t, []int{2, 4, 1}, ids)
+	assert.Equal(t, "Mango <mark>smoothie</mark>", got[0].Highlights.Title)
+	assert.Equal(t, "with a <mark>smoothie</mark> for Ann", got[2].Highlights.Note)
+}
+
+func TestRankExpensesFuzzy(t *testing.T) {
+	got := RankExpenses([]Expense{{ID: 1, Title: "smoothie"}, {ID: 2, Title: "rent"}}, "smoothy", 10)
+
+	assert.Len(t, got, 1)
+	assert.Equal(t, "<mark>smoothie</mark>", got[0].Highlights.Title)
+}
+
+func TestRankExpensesEscapesHTML(t *testing.T) {
+	got := RankExpenses([]Expense{{ID: 1, Title: `<img src=x onerror="alert(1)"> smoothie`, Note: "Tom & Jerry's"}}, "smoothie", 10)
+
+	assert.Len(t, got, 1)
+	assert.Equal(t, "&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>smoothie</mark>", got[0].Highlights.Title)
+	assert.Equal(t, "Tom &amp; Jerry&#39;s", got[0].Highlights.Note)
+}
+
+func TestSearchExpenses(t *testing.T) {
+	// Arrange
+	store := newListStore()
+
+	req := httptest.NewRequest(http.MethodGet, "/expenses/search?text=smothie&limit=1", nil)
+	rec := httptest.NewRecorder()
+
+	e := echo.New()
+	c := e.NewContext(req, rec)
+
+	handler := Handler{Store: store}
+
+	// Act
+	handler.SearchExpenses(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var got []SearchResult
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Len(t, got, 1)
+	assert.Equal(t, 1, got[0].Expense.ID)
+}
+
+func TestSearchExpensesInvalid(t *testing.T) {
+	for _, target := range []string{"/expenses/search", "/expenses/search?text=a&limit=500"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+
+		e := echo.New()
+		c := e.NewContext(req, rec)
+
+		handler := Handler{Store: NewMemoryStore()}
+		handler.SearchExpenses(c)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
+
+func TestPostgresStoreSearch(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	rows := sqlmock.NewRows(append(expenseRowsColumns(), "rank", "title_headline", "note_headline")).
+		AddRow(1, "smoothie", 7900, "<b>big</b> & cold", `{}`, "THB", testTime, testTime, testTime, nil, 0.9,
+			headlineStart+"smoothie"+headlineStop, "<b>big</b> & cold")
+	mock.ExpectQuery("websearch_to_tsquery(.+)ts_headline\\('simple', translate\\(coalesce\\(title, ''\\), '"+
+		headlineStart+headlineStop+"', ''\\)(.+) ORDER BY rank DESC, id LIMIT (.+)").
+		WithArgs("smoothie", 20, 0, 0, false).
+		WillReturnRows(rows)
+
+	store := NewPostgresStore(db)
+
+	// Act
+	got, err := store.Search(context.Background(), "smoothie", 20)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []SearchResult{{
+		Expense: Expense{ID: 1, Title: "smoothie", Amount: 7900, Note: "<b>big</b> & cold", Currency: "THB",
+			SpentAt: testTime, CreatedAt: testTime, UpdatedAt: testTime},
+		Rank:       0.9,
+		Highlights: Highlights{Title: "<mark>smoothie</mark>", Note: "&lt;b&gt;big&lt;/b&gt; &amp; cold"},
+	}}, got)
+}
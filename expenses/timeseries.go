@@ -0,0 +1,380 @@
+package expenses
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MaxTimeSeriesPoints is the maximum number of points of a time series.
+const MaxTimeSeriesPoints = 1000
+
+// Modes of the value of a time series point.
+const (
+	ModeTotal         = "total"
+	ModeCumulative    = "cumulative"
+	ModeMovingAverage = "moving_average"
+)
+
+// DefaultMovingAverageWindow is the number of points averaged by ModeMovingAverage.
+const DefaultMovingAverageWindow = 7
+
+type (
+
+	// TimeSeriesQuery selects the points of a time series.
+	// Points are the Interval units, such as months, from the unit containing From
+	// until Before, in Location.
+	TimeSeriesQuery struct {
+		Filter   ListQuery
+		Interval string
+		Location *time.Location
+		Currency string
+		From     time.Time
+		Before   time.Time
+
+		// Mode chooses Value: ModeTotal, ModeCumulative or ModeMovingAverage
+		// over Window points.
+		Mode   string
+		Window int
+
+		// ComparePrevious adds the values of the points before From.
+		ComparePrevious bool
+	}
+
+	// TimeSeriesPoint is the spending of one interval.
+	// Period is the first day of the interval.
+	TimeSeriesPoint struct {
+		Period string `json:"period"`
+		Count  int    `json:"count"`
+		Total  Money  `json:"total"`
+		Value  Money  `json:"value"`
+
+		// Partial is set on the last point when the series ends before its
+		// interval does, such as the current month.
+		Partial bool `json:"partial,omitempty"`
+
+		// PreviousPeriod and PreviousValue are the point as many intervals
+		// earlier as the series is long, when the request compares them.
+		// The previous value of a partial point covers as much of its interval.
+		PreviousPeriod string `json:"previous_period,omitempty"`
+		PreviousValue  *Money `json:"previous_value,omitempty"`
+	}
+
+	// TimeSeries is the response of GetTimeSeries.
+	TimeSeries struct {
+		Interval string            `json:"interval"`
+		Timezone string            `json:"timezone"`
+		Currency string            `json:"currency"`
+		Mode     string            `json:"mode"`
+		Window   int               `json:"window,omitempty"`
+		Points   []TimeSeriesPoint `json:"points"`
+	}
+)
+
+// MarshalJSON writes the amounts of the points with the decimals of currency.
+func (series TimeSeries) MarshalJSON() ([]byte, error) {
+
+	points := make([]json.RawMessage, len(series.Points))
+	for i, point := range series.Points {
+		amounts := map[string]json.RawMessage{
+			"total": point.Total.jsonIn(series.Currency),
+			"value": point.Value.jsonIn(series.Currency),
+		}
+		if point.PreviousValue != nil {
+			amounts["previous_value"] = point.PreviousValue.jsonIn(series.Currency)
+		}
+
+		var err error
+		if points[i], err = replaceMembers(point, amounts); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.Marshal(points)
+	if err != nil {
+		return nil, err
+	}
+
+	type plain TimeSeries
+	return replaceMembers(plain(series), map[string]json.RawMessage{"points": data})
+}
+
+// UnmarshalJSON reads a series whose amounts have the decimals of its currency.
+func (series *TimeSeries) UnmarshalJSON(data []byte) error {
+
+	type plain TimeSeries
+	fields := struct {
+		*plain
+		Points []json.RawMessage `json:"points"`
+	}{plain: (*plain)(series)}
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	series.Points = nil
+	for _, data := range fields.Points {
+		var point TimeSeriesPoint
+		amounts := struct {
+			*TimeSeriesPoint
+			Total         json.RawMessage `json:"total"`
+			Value         json.RawMessage `json:"value"`
+			PreviousValue json.RawMessage `json:"previous_value"`
+		}{TimeSeriesPoint: &point}
+		if err := json.Unmarshal(data, &amounts); err != nil {
+			return err
+		}
+
+		if err := point.Total.unmarshalIn(amounts.Total, series.Currency); err != nil {
+			return err
+		}
+		if err := point.Value.unmarshalIn(amounts.Value, series.Currency); err != nil {
+			return err
+		}
+		if amounts.PreviousValue != nil {
+			point.PreviousValue = new(Money)
+			if err := point.PreviousValue.unmarshalIn(amounts.PreviousValue, series.Currency); err != nil {
+				return err
+			}
+		}
+		series.Points = append(series.Points, point)
+	}
+
+	return nil
+}
+
+// ParseTimeSeriesQuery reads the query parameters of GetTimeSeries:
+// the filters read by ParseFilters, which must have from or a period,
+// tag (not with tags), interval (day, week or month), currency (DefaultCurrency by default),
+// mode (total, cumulative or moving_average), window
+// and compare (previous_period).
+func ParseTimeSeriesQuery(c echo.Context) (TimeSeriesQuery, error) {
+
+	filter, location, err := ParseFilters(c)
+	if err != nil {
+		return TimeSeriesQuery{}, err
+	}
+	if tag := NormalizeTag(c.QueryParam("tag")); tag != "" {
+		if len(filter.Tags) > 0 {
+			return TimeSeriesQuery{}, errors.New("tag cannot be combined with tags")
+		}
+		filter.Tags = []string{tag}
+	}
+
+	query := TimeSeriesQuery{Filter: filter, Location: location, Mode: ModeTotal}
+
+	query.Interval = c.QueryParam("interval")
+	if !contains(periodUnits, query.Interval) {
+		return query, errors.New("interval must be day, week or month")
+	}
+
+	if query.Currency, err = NormalizeCurrency(c.QueryParam("currency")); err != nil {
+		return query, err
+	}
+
+	if filter.Spent.From == nil {
+		return query, errors.New("from or a period is required")
+	}
+	query.From = startOf(filter.Spent.From.In(location), query.Interval).UTC()
+	query.Before = time.Now().UTC()
+	if filter.Spent.Before != nil {
+		query.Before = *filter.Spent.Before
+	}
+	if !query.From.Before(query.Before) {
+		return query, errors.New("from must be before to")
+	}
+	if len(query.periods()) > MaxTimeSeriesPoints {
+		return query, fmt.Errorf("the series cannot have more than %d points", MaxTimeSeriesPoints)
+	}
+
+	switch mode := c.QueryParam("mode"); mode {
+	case "", ModeTotal, ModeCumulative:
+		if mode != "" {
+			query.Mode = mode
+		}
+	case ModeMovingAverage:
+		query.Mode = mode
+		query.Window = DefaultMovingAverageWindow
+		if window := c.QueryParam("window"); window != "" {
+			n, err := strconv.Atoi(window)
+			if err != nil || n < 1 || n > MaxTimeSeriesPoints {
+				return query, fmt.Errorf("window must be between 1 and %d", MaxTimeSeriesPoints)
+			}
+			query.Window = n
+		}
+	default:
+		return query, errors.New("mode must be total, cumulative or moving_average")
+	}
+
+	switch c.QueryParam("compare") {
+	case "":
+	case "previous_period":
+		query.ComparePrevious = true
+	default:
+		return query, errors.New("compare must be previous_period")
+	}
+
+	return query, nil
+}
+
+// periods returns the start of every point of the series.
+func (query TimeSeriesQuery) periods() []time.Time {
+
+	var periods []time.Time
+	start := query.From.In(query.Location)
+	for t := start; t.Before(query.Before) && len(periods) <= MaxTimeSeriesPoints; {
+		periods = append(periods, t)
+		t = addUnits(start, query.Interval, len(periods))
+	}
+
+	return periods
+}
+
+// seriesTotals returns the totals of a series of periods and the count of expenses
+// in each of them, including empty periods.
+func (handler Handler) seriesTotals(c echo.Context, query TimeSeriesQuery, periods []time.Time, before time.Time) ([]Money, []int, error) {
+
+	filter := query.Filter
+	from := periods[0].UTC()
+	filter.Spent = DateRange{From: &from, Before: &before}
+	if query.Filter.Spent.Before != nil && query.Filter.Spent.Before.Before(before) {
+		filter.Spent.Before = query.Filter.Spent.Before
+	}
+
+	groups, err := handler.Reports.Summarize(c.Request().Context(), SummaryQuery{
+		Filter:   filter,
+		GroupBy:  []string{query.Interval},
+		Location: query.Location,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := map[string]int{}
+	for i, period := range periods {
+		index[period.Format(DateLayout)] = i
+	}
+
+	totals := make([]Money, len(periods))
+	counts := make([]int, len(periods))
+	for _, group := range groups {
+		if i, ok := index[group.Period]; ok && group.Currency == query.Currency {
+			totals[i] += group.Total
+			counts[i] += group.Count
+		}
+	}
+
+	return totals, counts, nil
+}
+
+// applyMode turns the totals of a series into the values of the mode.
+func (query TimeSeriesQuery) applyMode(totals []Money) []Money {
+
+	values := make([]Money, len(totals))
+	var sum Money
+
+	for i, total := range totals {
+		sum += total
+		switch query.Mode {
+		case ModeCumulative:
+			values[i] = sum
+		case ModeMovingAverage:
+			if i >= query.Window {
+				sum -= totals[i-query.Window]
+			}
+			n := query.Window
+			if i+1 < n {
+				n = i + 1
+			}
+			values[i] = roundHalfAway(float64(sum) / float64(n))
+		default:
+			values[i] = total
+		}
+	}
+
+	return values
+}
+
+// GetTimeSeries handles HTTP GET request to get the spending of every
+// day, week or month of a range, including the ones without expenses.
+// See ParseTimeSeriesQuery for the query parameters.
+func (handler Handler) GetTimeSeries(c echo.Context) error {
+
+	query, err := ParseTimeSeriesQuery(c)
+
+	var syntaxError *SyntaxError
+	if errors.As(err, &syntaxError) {
+		return c.JSON(http.StatusBadRequest, syntaxError)
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{err.Error()})
+	}
+
+	periods := query.periods()
+
+	totals, counts, err := handler.seriesTotals(c, query, periods, query.Before)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot query the time series. " + err.Error()})
+	}
+	values := query.applyMode(totals)
+
+	series := TimeSeries{
+		Interval: query.Interval,
+		Timezone: query.Location.String(),
+		Currency: query.Currency,
+		Mode:     query.Mode,
+		Window:   query.Window,
+		Points:   make([]TimeSeriesPoint, len(periods)),
+	}
+
+	for i, period := range periods {
+		series.Points[i] = TimeSeriesPoint{
+			Period: period.Format(DateLayout),
+			Count:  counts[i],
+			Total:  totals[i],
+			Value:  values[i],
+		}
+	}
+
+	last := len(periods) - 1
+	partial := query.Before.Before(addUnits(periods[0], query.Interval, len(periods)))
+	series.Points[last].Partial = partial
+
+	if query.ComparePrevious {
+		previous := make([]time.Time, len(periods))
+		for i := range periods {
+			previous[i] = addUnits(periods[0], query.Interval, i-len(periods))
+		}
+
+		// A partial last point is compared with the same time
+		// from the start of the previous point.
+		before := periods[0].UTC()
+		if partial {
+			if cut := previous[last].Add(query.Before.Sub(periods[last])); cut.Before(before) {
+				before = cut.UTC()
+			}
+		}
+
+		previousQuery := query
+		previousQuery.Filter.Spent = DateRange{}
+		previousTotals, _, err := handler.seriesTotals(c, previousQuery, previous, before)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError,
+				ErrorResponse{"cannot query the previous period. " + err.Error()})
+		}
+
+		for i, value := range query.applyMode(previousTotals) {
+			value := value
+			series.Points[i].PreviousPeriod = previous[i].Format(DateLayout)
+			series.Points[i].PreviousValue = &value
+		}
+	}
+
+	return c.JSON(http.StatusOK, series)
+}
@@ -0,0 +1,127 @@
+package expenses
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func getTimeSeries(t *testing.T, store *MemoryStore, target string) (TimeSeries, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+
+	handler := Handler{Store: store, Reports: store}
+	handler.GetTimeSeries(c)
+
+	var series TimeSeries
+	if err := json.Unmarshal(rec.Body.Bytes(), &series); err != nil {
+		t.Fatal(err)
+	}
+	return series, rec
+}
+
+func TestGetTimeSeries(t *testing.T) {
+	cases := []struct {
+		query   string
+		periods []string
+		values  []Money
+	}{
+		// Months without expenses are in the series.
+		{"interval=month&from=2026-07-01&to=2026-10-31",
+			[]string{"2026-07-01", "2026-08-01", "2026-09-01", "2026-10-01"}, []Money{0, 7900, 12500, 0}},
+		// The smoothie was spent on the 1st of September in Bangkok.
+		{"interval=month&from=2026-08-01&to=2026-09-30&tz=Asia/Bangkok",
+			[]string{"2026-08-01", "2026-09-01"}, []Money{0, 20400}},
+		{"interval=month&from=2026-07-01&to=2026-10-31&mode=cumulative",
+			[]string{"2026-07-01", "2026-08-01", "2026-09-01", "2026-10-01"}, []Money{0, 7900, 20400, 20400}},
+		{"interval=day&from=2026-09-19&to=2026-09-22&mode=moving_average&window=2",
+			[]string{"2026-09-19", "2026-09-20", "2026-09-21", "2026-09-22"}, []Money{0, 3000, 3750, 750}},
+		// Weeks start on the Monday of the week of from.
+		{"interval=week&from=2026-09-16&to=2026-09-27&tag=Work",
+			[]string{"2026-09-14", "2026-09-21"}, []Money{6000, 0}},
+		{"interval=day&from=2026-09-22&to=2026-09-22&currency=usd",
+			[]string{"2026-09-22"}, []Money{450}},
+	}
+
+	for _, test := range cases {
+		// Act
+		got, rec := getTimeSeries(t, newSummaryStore(), "/expenses/timeseries?"+test.query)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code, test.query)
+
+		var periods []string
+		var values []Money
+		for _, point := range got.Points {
+			periods = append(periods, point.Period)
+			values = append(values, point.Value)
+			assert.Nil(t, point.PreviousValue, test.query)
+		}
+		assert.Equal(t, test.periods, periods, test.query)
+		assert.Equal(t, test.values, values, test.query)
+	}
+}
+
+func TestGetTimeSeriesComparePrevious(t *testing.T) {
+	// Act
+	got, rec := getTimeSeries(t, newSummaryStore(),
+		"/expenses/timeseries?interval=month&from=2026-09-01&to=2026-10-31&compare=previous_period")
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "month", got.Interval)
+	assert.Equal(t, "THB", got.Currency)
+	assert.Equal(t, ModeTotal, got.Mode)
+
+	august, july := Money(7900), Money(0)
+	assert.Equal(t, []TimeSeriesPoint{
+		{Period: "2026-09-01", Count: 3, Total: 12500, Value: 12500, PreviousPeriod: "2026-07-01", PreviousValue: &july},
+		{Period: "2026-10-01", Count: 0, Total: 0, Value: 0, PreviousPeriod: "2026-08-01", PreviousValue: &august},
+	}, got.Points)
+}
+
+func TestGetTimeSeriesComparePartial(t *testing.T) {
+	// Act
+	got, rec := getTimeSeries(t, newSummaryStore(),
+		"/expenses/timeseries?interval=month&from=2026-09-01&to=2026-09-21&compare=previous_period")
+	whole, wholeRec := getTimeSeries(t, newSummaryStore(),
+		"/expenses/timeseries?interval=month&from=2026-09-01&to=2026-09-30&compare=previous_period")
+
+	// Assert
+	partAugust, wholeAugust := Money(0), Money(7900)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []TimeSeriesPoint{
+		{Period: "2026-09-01", Count: 3, Total: 12500, Value: 12500, Partial: true,
+			PreviousPeriod: "2026-08-01", PreviousValue: &partAugust},
+	}, got.Points)
+	assert.Equal(t, http.StatusOK, wholeRec.Code)
+	assert.Equal(t, []TimeSeriesPoint{
+		{Period: "2026-09-01", Count: 3, Total: 12500, Value: 12500,
+			PreviousPeriod: "2026-08-01", PreviousValue: &wholeAugust},
+	}, whole.Points)
+}
+
+func TestGetTimeSeriesErrors(t *testing.T) {
+	for _, query := range []string{
+		"from=2026-09-01",
+		"interval=year&from=2026-09-01",
+		"interval=month",
+		"interval=month&from=2026-10-01&to=2026-09-01",
+		"interval=day&from=2020-01-01&to=2026-01-01",
+		"interval=month&from=2026-09-01&mode=median",
+		"interval=month&from=2026-09-01&mode=moving_average&window=0",
+		"interval=month&from=2026-09-01&compare=last_year",
+		"interval=month&from=2026-09-01&currency=ABC",
+		"interval=month&from=2026-09-01&q=amount>x",
+		"interval=month&from=2026-09-01&tag=work&tags=food&tags_match=any",
+	} {
+		_, rec := getTimeSeries(t, NewMemoryStore(), "/expenses/timeseries?"+query)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
@@ -0,0 +1,109 @@
+package expenses
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DeleteExpense handles HTTP DELETE request to move an expense to the trash.
+// The expense can be restored until the Purger removes it.
+func (handler Handler) DeleteExpense(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	err = handler.Store.Delete(c.Request().Context(), id)
+
+	switch err {
+
+	case ErrNotFound:
+		return c.JSON(http.StatusNotFound,
+			ErrorResponse{"cannot find the expense of that id. " + err.Error()})
+
+	case nil:
+		return c.NoContent(http.StatusNoContent)
+
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot delete the expense. " + err.Error()})
+	}
+}
+
+// GetTrash handles HTTP GET request to list deleted expenses.
+func (handler Handler) GetTrash(c echo.Context) error {
+
+	expenses, err := handler.Store.ListTrash(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot query the trash. " + err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, expenses)
+}
+
+// RestoreExpense handles HTTP POST request to take an expense out of the trash.
+func (handler Handler) RestoreExpense(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	expense, err := handler.Store.Restore(c.Request().Context(), id)
+
+	switch err {
+
+	case ErrNotFound:
+		return c.JSON(http.StatusNotFound,
+			ErrorResponse{"cannot find a deleted expense of that id. " + err.Error()})
+
+	case nil:
+		handler.evaluateAlerts(c, expense)
+		return c.JSON(http.StatusOK, expense)
+
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot restore the expense. " + err.Error()})
+	}
+}
+
+// Purger permanently removes expenses which have been in the trash
+// for longer than Retention, checking every Interval.
+type Purger struct {
+	Store     ExpenseStore
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+// PurgeOnce removes the expenses deleted before now minus Retention.
+func (purger Purger) PurgeOnce(ctx context.Context, now time.Time) (int, error) {
+	return purger.Store.Purge(ctx, now.Add(-purger.Retention))
+}
+
+// Run purges right away and then every Interval until ctx is done.
+func (purger Purger) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(purger.Interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := purger.PurgeOnce(ctx, time.Now())
+		if err != nil {
+			log.Println("cannot purge the trash.", err)
+		} else if purged > 0 {
+			log.Printf("purged %d expenses from the trash.", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package expenses
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// newIDContext returns the context of a request with a JSON body and,
+// unless id is empty, the id parameter of its route.
+func newIDContext(method string, target string, body string, id string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	c := echo.New().NewContext(req, rec)
+	if id != "" {
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+	}
+
+	return c, rec
+}
+
+func TestDeleteAndRestoreExpense(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	store.Create(context.Background(), &Expense{Title: "smoothie", Amount: 7900, Currency: "THB"})
+	handler := Handler{Store: store}
+
+	// Act & Assert
+	c, rec := newIDContext(http.MethodDelete, "/expenses/1", "", "1")
+	handler.DeleteExpense(c)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	list, _ := store.List(context.Background(), ListQuery{})
+	assert.Empty(t, list)
+
+	c, rec = newIDContext(http.MethodGet, "/expenses/1", "", "1")
+	handler.GetExpenseByID(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = newIDContext(http.MethodDelete, "/expenses/1", "", "1")
+	handler.DeleteExpense(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = newIDContext(http.MethodGet, "/expenses/trash", "", "")
+	handler.GetTrash(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"deleted_at":`)
+
+	c, rec = newIDContext(http.MethodPost, "/expenses/1/restore", "", "1")
+	handler.RestoreExpense(c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `"deleted_at":`)
+
+	c, rec = newIDContext(http.MethodPost, "/expenses/1/restore", "", "1")
+	handler.RestoreExpense(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	got, err := store.Get(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "smoothie", got.Title)
+}
+
+func TestPurger(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		store.Create(ctx, &Expense{Title: "latte"})
+	}
+	store.Delete(ctx, 1)
+	store.Delete(ctx, 2)
+
+	purger := Purger{Store: store, Retention: time.Hour}
+
+	// Act
+	tooEarly, _ := purger.PurgeOnce(ctx, time.Now())
+	purged, err := purger.PurgeOnce(ctx, time.Now().Add(2*time.Hour))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 0, tooEarly)
+	assert.Equal(t, 2, purged)
+
+	trash, _ := store.ListTrash(ctx)
+	assert.Empty(t, trash)
+	list, _ := store.List(ctx, ListQuery{})
+	assert.Len(t, list, 1)
+}
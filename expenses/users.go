@@ -0,0 +1,229 @@
+package expenses
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+
+	// UserRequest is the body of CreateUser. A user without a password
+	// cannot log in, and only uses API keys. A user without a role is a member.
+	UserRequest struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+
+	// PasswordRequest is the body of SetPassword.
+	PasswordRequest struct {
+		Password string `json:"password"`
+	}
+
+	// RoleRequest is the body of SetUserRole.
+	RoleRequest struct {
+		Role string `json:"role"`
+	}
+
+	// BaseCurrencyRequest is the body of SetBaseCurrency.
+	// An empty base currency is the default of the server.
+	BaseCurrencyRequest struct {
+		BaseCurrency string `json:"base_currency"`
+	}
+)
+
+// CreateUser handles HTTP POST request to create a user.
+// The bootstrap key only creates the first user.
+func (handler Handler) CreateUser(c echo.Context) error {
+
+	var request UserRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+	}
+
+	if identity, _ := auth.IdentityOf(c); identity.Bootstrap() {
+		users, err := handler.Users.ListUsers(c.Request().Context())
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError,
+				ErrorResponse{"cannot list users. " + err.Error()})
+		}
+		if len(users) > 0 {
+			return c.JSON(http.StatusForbidden, ErrorResponse{"the bootstrap key can only create the first user."})
+		}
+	}
+
+	user := auth.User{Name: strings.TrimSpace(request.Name)}
+	if user.Name == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"name is required."})
+	}
+
+	if request.Role != "" {
+		var err error
+		if user.Role, err = auth.ParseRole(request.Role); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid role. " + err.Error()})
+		}
+	}
+
+	var hash string
+	if request.Password != "" {
+		var err error
+		if hash, err = auth.HashPassword(request.Password); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid password. " + err.Error()})
+		}
+	}
+
+	err := handler.Users.CreateUser(c.Request().Context(), &user, hash)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusCreated, user)
+	case auth.ErrUserExists:
+		return c.JSON(http.StatusConflict, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot create the user. " + err.Error()})
+	}
+}
+
+// GetUsers handles HTTP GET request to list the users who are members
+// of the workspace of the request.
+func (handler Handler) GetUsers(c echo.Context) error {
+
+	ctx := c.Request().Context()
+
+	members, err := handler.Workspaces.ListMembers(ctx, auth.WorkspaceID(ctx))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot list members. " + err.Error()})
+	}
+
+	users := []auth.User{}
+	for _, member := range members {
+		user, err := handler.Users.GetUser(ctx, member.UserID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError,
+				ErrorResponse{"cannot list users. " + err.Error()})
+		}
+		users = append(users, user)
+	}
+
+	return c.JSON(http.StatusOK, users)
+}
+
+// GetCurrentUser handles HTTP GET request to get the user of the request.
+func (handler Handler) GetCurrentUser(c echo.Context) error {
+
+	ctx := c.Request().Context()
+
+	user, err := handler.Users.GetUser(ctx, auth.UserID(ctx))
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, user)
+	case auth.ErrUserNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{"the request has no user."})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot find the user. " + err.Error()})
+	}
+}
+
+// SetPassword handles HTTP PUT request to change the password of the user of the request.
+func (handler Handler) SetPassword(c echo.Context) error {
+
+	var request PasswordRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+	}
+
+	hash, err := auth.HashPassword(request.Password)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid password. " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	err = handler.Users.SetPassword(ctx, auth.UserID(ctx), hash)
+
+	switch err {
+	case nil:
+		return c.NoContent(http.StatusNoContent)
+	case auth.ErrUserNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{"the request has no user."})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot set the password. " + err.Error()})
+	}
+}
+
+// SetBaseCurrency handles HTTP PUT request to change the currency amounts are
+// converted to for the user of the request when a request does not choose one.
+func (handler Handler) SetBaseCurrency(c echo.Context) error {
+
+	var request BaseCurrencyRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+	}
+
+	var currency string
+	if strings.TrimSpace(request.BaseCurrency) != "" {
+		var err error
+		if currency, err = NormalizeCurrency(request.BaseCurrency); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid base_currency. " + err.Error()})
+		}
+	}
+
+	ctx := c.Request().Context()
+	user, err := handler.Users.SetBaseCurrency(ctx, auth.UserID(ctx), currency)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, user)
+	case auth.ErrUserNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{"the request has no user."})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot set the base currency. " + err.Error()})
+	}
+}
+
+// SetUserRole handles HTTP PUT request to assign a role to a user.
+func (handler Handler) SetUserRole(c echo.Context) error {
+
+	if identity, _ := auth.IdentityOf(c); identity.Bootstrap() {
+		return c.JSON(http.StatusForbidden, ErrorResponse{"the bootstrap key can only create the first user."})
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	var request RoleRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+	}
+
+	role, err := auth.ParseRole(request.Role)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid role. " + err.Error()})
+	}
+
+	user, err := handler.Users.SetRole(c.Request().Context(), id, role)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, user)
+	case auth.ErrUserNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot set the role. " + err.Error()})
+	}
+}
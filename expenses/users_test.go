@@ -0,0 +1,226 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// asUser makes c a request of the member of userID, as the middleware does.
+// A userID of 0 leaves c without a user.
+func asUser(c echo.Context, userID int) {
+	asRole(c, userID, auth.Member)
+}
+
+// asRole makes c a request of the user of userID having role.
+func asRole(c echo.Context, userID int, role auth.Role) {
+	if userID != 0 {
+		auth.SetIdentity(c, auth.Identity{Subject: "api-key:1", UserID: userID, KeyID: 1, Role: role})
+		c.SetRequest(c.Request().WithContext(auth.WithUser(c.Request().Context(), userID)))
+	}
+}
+
+// asBootstrap makes c a request of the bootstrap key.
+func asBootstrap(c echo.Context) {
+	auth.SetIdentity(c, auth.Identity{Subject: "bootstrap", Name: "bootstrap"})
+}
+
+func TestUsers(t *testing.T) {
+	// Arrange
+	handler := Handler{Users: auth.NewMemoryUserStore()}
+
+	// Act
+	c, rec := newIDContext(http.MethodPost, "/users", `{"name": " peem "}`, "")
+	asBootstrap(c)
+	handler.CreateUser(c)
+	var user auth.User
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+
+	c, conflictRec := newIDContext(http.MethodPost, "/users", `{"name": "peem"}`, "")
+	asRole(c, 1, auth.Admin)
+	handler.CreateUser(c)
+
+	c, secondRec := newIDContext(http.MethodPost, "/users", `{"name": "other"}`, "")
+	asBootstrap(c)
+	handler.CreateUser(c)
+
+	c, meRec := newIDContext(http.MethodGet, "/users/me", "", "")
+	asUser(c, user.ID)
+	handler.GetCurrentUser(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, 1, user.ID)
+	assert.Equal(t, "peem", user.Name)
+	assert.Equal(t, http.StatusConflict, conflictRec.Code)
+	assert.Equal(t, http.StatusForbidden, secondRec.Code)
+	assert.Equal(t, http.StatusOK, meRec.Code)
+	assert.Contains(t, meRec.Body.String(), `"id":1`)
+}
+
+func TestUsersOfWorkspace(t *testing.T) {
+	// Arrange
+	users := auth.NewMemoryUserStore()
+	workspaces := auth.NewMemoryWorkspaceStore()
+	handler := Handler{Users: users, Workspaces: workspaces}
+
+	ctx := context.Background()
+	users.CreateUser(ctx, &auth.User{Name: "peem", Role: auth.Admin}, "")
+	users.CreateUser(ctx, &auth.User{Name: "contractor"}, "")
+	workspaces.CreateWorkspace(ctx, &auth.Workspace{Name: "team"}, 1)
+	workspaces.CreateWorkspace(ctx, &auth.Workspace{Name: "contractor"}, 2)
+
+	// Act
+	c, rec := newIDContext(http.MethodGet, "/users", "", "")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.GetUsers(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"peem"`)
+	assert.NotContains(t, rec.Body.String(), `"name":"contractor"`)
+}
+
+func TestUsersErrors(t *testing.T) {
+	handler := Handler{Users: auth.NewMemoryUserStore()}
+
+	c, rec := newIDContext(http.MethodPost, "/users", `{"name": " "}`, "")
+	asBootstrap(c)
+	handler.CreateUser(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	c, rec = newIDContext(http.MethodPost, "/users", `{"name": "peem", "role": "owner"}`, "")
+	asBootstrap(c)
+	handler.CreateUser(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	c, rec = newIDContext(http.MethodGet, "/users/me", "", "")
+	asBootstrap(c)
+	handler.GetCurrentUser(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestExpensesOfOtherUsers(t *testing.T) {
+	// Arrange
+	store := newTestStore()
+	handler := Handler{Store: store, Tags: store}
+	ours := auth.WithUser(context.Background(), 1)
+	theirs := auth.WithUser(context.Background(), 2)
+	store.Create(ours, &Expense{Title: "smoothie", Amount: 7900, Currency: "THB", Tags: []string{"food"}})
+	store.Create(theirs, &Expense{Title: "rent", Amount: 900000, Currency: "THB", Tags: []string{"home"}})
+
+	// Act
+	c, getRec := newIDContext(http.MethodGet, "/expenses/2", "", "2")
+	asUser(c, 1)
+	handler.GetExpenseByID(c)
+
+	c, putRec := newIDContext(http.MethodPut, "/expenses/2", `{"title": "mine", "amount": 1}`, "2")
+	asUser(c, 1)
+	handler.PutExpense(c)
+
+	c, deleteRec := newIDContext(http.MethodDelete, "/expenses/2", "", "2")
+	asUser(c, 1)
+	handler.DeleteExpense(c)
+
+	c, listRec := newIDContext(http.MethodGet, "/expenses", "", "")
+	asUser(c, 1)
+	handler.GetAllExpenses(c)
+	var listed []Expense
+	assert.NoError(t, json.Unmarshal(listRec.Body.Bytes(), &listed))
+
+	c, createRec := newIDContext(http.MethodPost, "/expenses", `{"title": "coffee", "amount": 60}`, "")
+	asUser(c, 2)
+	handler.CreateExpense(c)
+
+	tags, _ := store.ListTags(ours)
+	theirExpense, theirErr := store.Get(theirs, 2)
+	theirList, _ := store.List(theirs, ListQuery{})
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, getRec.Code)
+	assert.Equal(t, http.StatusNotFound, putRec.Code)
+	assert.Equal(t, http.StatusNotFound, deleteRec.Code)
+	assert.Equal(t, http.StatusOK, listRec.Code)
+	assert.Len(t, listed, 1)
+	assert.Equal(t, "smoothie", listed[0].Title)
+	assert.Equal(t, http.StatusCreated, createRec.Code)
+
+	assert.Equal(t, []Tag{{Name: "food", Count: 1}, {Name: "home", Count: 1}}, tags)
+	assert.NoError(t, theirErr)
+	assert.Equal(t, "rent", theirExpense.Title)
+	assert.Len(t, theirList, 2)
+}
+
+func TestSetUserRole(t *testing.T) {
+	// Arrange
+	handler := Handler{Users: auth.NewMemoryUserStore()}
+	handler.Users.CreateUser(context.Background(), &auth.User{Name: "peem"}, "")
+
+	// Act
+	c, rec := newIDContext(http.MethodPut, "/users/1/role", `{"role": "approver"}`, "1")
+	asRole(c, 2, auth.Admin)
+	handler.SetUserRole(c)
+
+	c, bootstrapRec := newIDContext(http.MethodPut, "/users/1/role", `{"role": "admin"}`, "1")
+	asBootstrap(c)
+	handler.SetUserRole(c)
+	var user auth.User
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+
+	c, unknownRec := newIDContext(http.MethodPut, "/users/1/role", `{"role": "owner"}`, "1")
+	handler.SetUserRole(c)
+
+	c, missingRec := newIDContext(http.MethodPut, "/users/2/role", `{"role": "auditor"}`, "2")
+	handler.SetUserRole(c)
+
+	stored, _ := handler.Users.GetUser(context.Background(), 1)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, auth.Approver, user.Role)
+	assert.Equal(t, auth.Approver, stored.Role)
+	assert.Equal(t, http.StatusForbidden, bootstrapRec.Code)
+	assert.Equal(t, http.StatusBadRequest, unknownRec.Code)
+	assert.Equal(t, http.StatusNotFound, missingRec.Code)
+}
+
+func TestExpensesOfAllUsers(t *testing.T) {
+	// Arrange
+	store := newTestStore()
+	handler := Handler{Store: store, Tags: store}
+	store.Create(auth.WithUser(context.Background(), 2), &Expense{Title: "rent", Amount: 900000, Currency: "THB"})
+	all := func(c echo.Context) {
+		asRole(c, 1, auth.Admin)
+		c.SetRequest(c.Request().WithContext(auth.WithAllUsers(c.Request().Context())))
+	}
+
+	// Act
+	c, getRec := newIDContext(http.MethodGet, "/expenses/1", "", "1")
+	all(c)
+	handler.GetExpenseByID(c)
+
+	c, putRec := newIDContext(http.MethodPut, "/expenses/1", `{"title": "rent", "amount": 8000}`, "1")
+	all(c)
+	handler.PutExpense(c)
+
+	c, createRec := newIDContext(http.MethodPost, "/expenses", `{"title": "coffee", "amount": 60}`, "")
+	all(c)
+	handler.CreateExpense(c)
+
+	theirs, _ := store.List(auth.WithUser(context.Background(), 2), ListQuery{})
+	ours, _ := store.List(auth.WithUser(context.Background(), 1), ListQuery{})
+
+	// Assert
+	assert.Equal(t, http.StatusOK, getRec.Code)
+	assert.Equal(t, http.StatusOK, putRec.Code)
+	assert.Equal(t, http.StatusCreated, createRec.Code)
+	assert.Len(t, theirs, 1)
+	assert.Equal(t, Money(800000), theirs[0].Amount)
+	assert.Len(t, ours, 1)
+	assert.Equal(t, "coffee", ours[0].Title)
+}
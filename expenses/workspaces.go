@@ -0,0 +1,283 @@
+package expenses
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// invitationTTL is how long an invitation without expires_at can be accepted.
+const invitationTTL = 7 * 24 * time.Hour
+
+type (
+
+	// WorkspaceRequest is the body of CreateWorkspace.
+	WorkspaceRequest struct {
+		Name string `json:"name"`
+	}
+
+	// InvitationRequest is the body of CreateInvitation. An invitation without
+	// a role invites a member, and one without expires_at expires in 7 days.
+	InvitationRequest struct {
+		Role      string `json:"role"`
+		ExpiresAt string `json:"expires_at"`
+	}
+
+	// AcceptRequest is the body of AcceptInvitation.
+	AcceptRequest struct {
+		Token string `json:"token"`
+	}
+)
+
+// CreateWorkspace handles HTTP POST request to create a workspace
+// of which the user of the request is the admin.
+func (handler Handler) CreateWorkspace(c echo.Context) error {
+
+	var request WorkspaceRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+	}
+
+	workspace := auth.Workspace{Name: strings.TrimSpace(request.Name)}
+	if workspace.Name == "" {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"name is required."})
+	}
+
+	ctx := c.Request().Context()
+	userID := auth.UserID(ctx)
+	if userID == 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"the request has no user."})
+	}
+
+	if err := handler.Workspaces.CreateWorkspace(ctx, &workspace, userID); err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot create the workspace. " + err.Error()})
+	}
+
+	return c.JSON(http.StatusCreated, workspace)
+}
+
+// GetWorkspaces handles HTTP GET request to list the workspaces of the user of the request.
+func (handler Handler) GetWorkspaces(c echo.Context) error {
+
+	ctx := c.Request().Context()
+	workspaces, err := handler.Workspaces.ListWorkspaces(ctx, auth.UserID(ctx))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot list workspaces. " + err.Error()})
+	}
+	if workspaces == nil {
+		workspaces = []auth.Workspace{}
+	}
+
+	return c.JSON(http.StatusOK, workspaces)
+}
+
+// GetMembers handles HTTP GET request to list the members of the workspace of the request.
+func (handler Handler) GetMembers(c echo.Context) error {
+
+	ctx := c.Request().Context()
+	members, err := handler.Workspaces.ListMembers(ctx, auth.WorkspaceID(ctx))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot list members. " + err.Error()})
+	}
+	if members == nil {
+		members = []auth.Membership{}
+	}
+
+	return c.JSON(http.StatusOK, members)
+}
+
+// SetMemberRole handles HTTP PUT request to assign a role to a member
+// of the workspace of the request. The id is the ID of the user.
+func (handler Handler) SetMemberRole(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	var request RoleRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+	}
+
+	role, err := auth.ParseRole(request.Role)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid role. " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	member, err := handler.Workspaces.SetMemberRole(ctx, auth.WorkspaceID(ctx), id, role)
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusOK, member)
+	case auth.ErrNotMember:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	case auth.ErrLastAdmin:
+		return c.JSON(http.StatusConflict, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot set the role. " + err.Error()})
+	}
+}
+
+// RemoveMember handles HTTP DELETE request to remove a member from the
+// workspace of the request. The id is the ID of the user.
+func (handler Handler) RemoveMember(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	err = handler.Workspaces.RemoveMember(ctx, auth.WorkspaceID(ctx), id)
+
+	switch err {
+	case nil:
+		return c.NoContent(http.StatusNoContent)
+	case auth.ErrNotMember:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	case auth.ErrLastAdmin:
+		return c.JSON(http.StatusConflict, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot remove the member. " + err.Error()})
+	}
+}
+
+// CreateInvitation handles HTTP POST request to invite a user to the workspace
+// of the request. The response has the token, which is not shown again.
+func (handler Handler) CreateInvitation(c echo.Context) error {
+
+	var request InvitationRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	now := time.Now()
+
+	invitation := auth.Invitation{
+		WorkspaceID: auth.WorkspaceID(ctx),
+		Role:        auth.Member,
+		InvitedBy:   auth.UserID(ctx),
+		ExpiresAt:   now.Add(invitationTTL).UTC(),
+	}
+
+	if request.Role != "" {
+		var err error
+		if invitation.Role, err = auth.ParseRole(request.Role); err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid role. " + err.Error()})
+		}
+	}
+
+	if request.ExpiresAt != "" {
+		expiresAt, err := ParseTime(request.ExpiresAt, DefaultTimezone)
+		if err == nil && !expiresAt.After(now) {
+			err = errors.New("it is in the past")
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid expires_at. " + err.Error()})
+		}
+		invitation.ExpiresAt = expiresAt
+	}
+
+	token, hash, err := auth.NewInvitationToken()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, ErrorResponse{"cannot generate a token. " + err.Error()})
+	}
+
+	if err := handler.Workspaces.CreateInvitation(ctx, &invitation, hash); err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot create the invitation. " + err.Error()})
+	}
+	invitation.Token = token
+
+	return c.JSON(http.StatusCreated, invitation)
+}
+
+// GetInvitations handles HTTP GET request to list the invitations of the
+// workspace of the request without their tokens.
+func (handler Handler) GetInvitations(c echo.Context) error {
+
+	ctx := c.Request().Context()
+	invitations, err := handler.Workspaces.ListInvitations(ctx, auth.WorkspaceID(ctx))
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot list invitations. " + err.Error()})
+	}
+	if invitations == nil {
+		invitations = []auth.Invitation{}
+	}
+
+	return c.JSON(http.StatusOK, invitations)
+}
+
+// RevokeInvitation handles HTTP DELETE request to revoke an invitation
+// of the workspace of the request which is not accepted.
+func (handler Handler) RevokeInvitation(c echo.Context) error {
+
+	id, err := parseID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid id. " + err.Error()})
+	}
+
+	ctx := c.Request().Context()
+	err = handler.Workspaces.RevokeInvitation(ctx, auth.WorkspaceID(ctx), id)
+
+	switch err {
+	case nil:
+		return c.NoContent(http.StatusNoContent)
+	case auth.ErrInvitationNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot revoke the invitation. " + err.Error()})
+	}
+}
+
+// AcceptInvitation handles HTTP POST request to join the workspace of an
+// invitation token as the user of the request.
+func (handler Handler) AcceptInvitation(c echo.Context) error {
+
+	var request AcceptRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest,
+			ErrorResponse{"cannot unmarshal request's body. " + err.Error()})
+	}
+
+	if !strings.HasPrefix(request.Token, auth.InvitationPrefix) {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"invalid token."})
+	}
+
+	ctx := c.Request().Context()
+	userID := auth.UserID(ctx)
+	if userID == 0 {
+		return c.JSON(http.StatusBadRequest, ErrorResponse{"the request has no user."})
+	}
+
+	member, err := handler.Workspaces.AcceptInvitation(ctx, auth.HashKey(request.Token), userID, time.Now())
+
+	switch err {
+	case nil:
+		return c.JSON(http.StatusCreated, member)
+	case auth.ErrInvitationNotFound:
+		return c.JSON(http.StatusNotFound, ErrorResponse{err.Error()})
+	case auth.ErrAlreadyMember:
+		return c.JSON(http.StatusConflict, ErrorResponse{err.Error()})
+	default:
+		return c.JSON(http.StatusInternalServerError,
+			ErrorResponse{"cannot accept the invitation. " + err.Error()})
+	}
+}
@@ -0,0 +1,202 @@
+package expenses
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/PeemPeimn/assessment/auth"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// asWorkspace makes c a request of the user of userID in a workspace where
+// the user has role, as the middleware does.
+func asWorkspace(c echo.Context, userID int, workspaceID int, role auth.Role) {
+	auth.SetIdentity(c, auth.Identity{Subject: "api-key:1", UserID: userID, KeyID: 1, Role: auth.Member,
+		WorkspaceID: workspaceID, WorkspaceRole: role})
+	ctx := auth.WithWorkspace(auth.WithUser(c.Request().Context(), userID), workspaceID)
+	c.SetRequest(c.Request().WithContext(ctx))
+}
+
+func TestWorkspaces(t *testing.T) {
+	// Arrange
+	handler := Handler{Workspaces: auth.NewMemoryWorkspaceStore()}
+
+	// Act
+	c, createRec := newIDContext(http.MethodPost, "/workspaces", `{"name": " team "}`, "")
+	asUser(c, 1)
+	handler.CreateWorkspace(c)
+	var workspace auth.Workspace
+	assert.NoError(t, json.Unmarshal(createRec.Body.Bytes(), &workspace))
+
+	c, inviteRec := newIDContext(http.MethodPost, "/invitations", `{"role": "approver"}`, "")
+	asWorkspace(c, 1, workspace.ID, auth.Admin)
+	handler.CreateInvitation(c)
+	var invitation auth.Invitation
+	assert.NoError(t, json.Unmarshal(inviteRec.Body.Bytes(), &invitation))
+
+	c, acceptRec := newIDContext(http.MethodPost, "/invitations/accept", `{"token": "`+invitation.Token+`"}`, "")
+	asUser(c, 2)
+	handler.AcceptInvitation(c)
+
+	c, againRec := newIDContext(http.MethodPost, "/invitations/accept", `{"token": "`+invitation.Token+`"}`, "")
+	asUser(c, 3)
+	handler.AcceptInvitation(c)
+
+	c, listRec := newIDContext(http.MethodGet, "/workspaces", "", "")
+	asUser(c, 2)
+	handler.GetWorkspaces(c)
+
+	c, invitationsRec := newIDContext(http.MethodGet, "/invitations", "", "")
+	asWorkspace(c, 1, workspace.ID, auth.Admin)
+	handler.GetInvitations(c)
+
+	c, membersRec := newIDContext(http.MethodGet, "/members", "", "")
+	asWorkspace(c, 2, workspace.ID, auth.Approver)
+	handler.GetMembers(c)
+
+	// Assert
+	assert.Equal(t, http.StatusCreated, createRec.Code)
+	assert.Equal(t, "team", workspace.Name)
+	assert.Equal(t, auth.Admin, workspace.Role)
+	assert.Equal(t, http.StatusCreated, inviteRec.Code)
+	assert.Equal(t, auth.Approver, invitation.Role)
+	assert.Equal(t, 1, invitation.InvitedBy)
+	assert.Contains(t, invitation.Token, auth.InvitationPrefix)
+	assert.Equal(t, http.StatusCreated, acceptRec.Code)
+	assert.Contains(t, acceptRec.Body.String(), `"role":"approver"`)
+	assert.Equal(t, http.StatusNotFound, againRec.Code)
+	assert.Equal(t, http.StatusOK, listRec.Code)
+	assert.Contains(t, listRec.Body.String(), `"name":"team","role":"approver"`)
+	assert.Equal(t, http.StatusOK, invitationsRec.Code)
+	assert.NotContains(t, invitationsRec.Body.String(), invitation.Token)
+	assert.Contains(t, invitationsRec.Body.String(), `"accepted_by":2`)
+	assert.Equal(t, http.StatusOK, membersRec.Code)
+	assert.Contains(t, membersRec.Body.String(), `"user_id":1`)
+	assert.Contains(t, membersRec.Body.String(), `"user_id":2`)
+}
+
+func TestMembers(t *testing.T) {
+	// Arrange
+	workspaces := auth.NewMemoryWorkspaceStore()
+	handler := Handler{Workspaces: workspaces}
+	ctx := context.Background()
+	workspaces.CreateWorkspace(ctx, &auth.Workspace{Name: "team"}, 1)
+	workspaces.CreateWorkspace(ctx, &auth.Workspace{Name: "contractor"}, 3)
+	workspaces.CreateInvitation(ctx, &auth.Invitation{WorkspaceID: 1, Role: auth.Member, ExpiresAt: testTime}, "hash")
+	workspaces.AcceptInvitation(ctx, "hash", 2, testTime.Add(-1))
+
+	// Act
+	c, lastAdminRec := newIDContext(http.MethodPut, "/members/1/role", `{"role": "member"}`, "1")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.SetMemberRole(c)
+
+	c, roleRec := newIDContext(http.MethodPut, "/members/2/role", `{"role": "auditor"}`, "2")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.SetMemberRole(c)
+
+	c, otherRec := newIDContext(http.MethodPut, "/members/3/role", `{"role": "member"}`, "3")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.SetMemberRole(c)
+
+	c, invalidRec := newIDContext(http.MethodPut, "/members/2/role", `{"role": "owner"}`, "2")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.SetMemberRole(c)
+
+	c, removeRec := newIDContext(http.MethodDelete, "/members/2", "", "2")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.RemoveMember(c)
+
+	c, removeLastRec := newIDContext(http.MethodDelete, "/members/1", "", "1")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.RemoveMember(c)
+
+	c, revokeRec := newIDContext(http.MethodDelete, "/invitations/1", "", "1")
+	asWorkspace(c, 3, 2, auth.Admin)
+	handler.RevokeInvitation(c)
+
+	members, _ := workspaces.ListMembers(ctx, 1)
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, lastAdminRec.Code)
+	assert.Equal(t, http.StatusOK, roleRec.Code)
+	assert.Contains(t, roleRec.Body.String(), `"role":"auditor"`)
+	assert.Equal(t, http.StatusNotFound, otherRec.Code)
+	assert.Equal(t, http.StatusBadRequest, invalidRec.Code)
+	assert.Equal(t, http.StatusNoContent, removeRec.Code)
+	assert.Equal(t, http.StatusConflict, removeLastRec.Code)
+	assert.Equal(t, http.StatusNotFound, revokeRec.Code)
+	assert.Len(t, members, 1)
+}
+
+func TestInvitationErrors(t *testing.T) {
+	handler := Handler{Workspaces: auth.NewMemoryWorkspaceStore()}
+
+	c, rec := newIDContext(http.MethodPost, "/invitations", `{"role": "owner"}`, "")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.CreateInvitation(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	c, rec = newIDContext(http.MethodPost, "/invitations", `{"expires_at": "2020-01-01"}`, "")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.CreateInvitation(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	c, rec = newIDContext(http.MethodPost, "/invitations/accept", `{"token": "exp_abc"}`, "")
+	asUser(c, 2)
+	handler.AcceptInvitation(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	c, rec = newIDContext(http.MethodPost, "/invitations/accept", `{"token": "inv_abc"}`, "")
+	asUser(c, 2)
+	handler.AcceptInvitation(c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	c, rec = newIDContext(http.MethodPost, "/workspaces", `{"name": " "}`, "")
+	asUser(c, 1)
+	handler.CreateWorkspace(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestExpensesOfOtherWorkspaces(t *testing.T) {
+	// Arrange
+	store := newTestStore()
+	handler := Handler{Store: store, Tags: store}
+	team := auth.WithWorkspace(auth.WithUser(context.Background(), 1), 1)
+	contractor := auth.WithWorkspace(auth.WithUser(context.Background(), 1), 2)
+	store.Create(team, &Expense{Title: "smoothie", Amount: 7900, Currency: "THB", Tags: []string{"food"}})
+	store.Create(contractor, &Expense{Title: "laptop", Amount: 3500000, Currency: "THB", Tags: []string{"hardware"}})
+
+	// Act
+	c, getRec := newIDContext(http.MethodGet, "/expenses/2", "", "2")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.GetExpenseByID(c)
+
+	c, allRec := newIDContext(http.MethodGet, "/expenses/2", "", "2")
+	asWorkspace(c, 1, 1, auth.Admin)
+	c.SetRequest(c.Request().WithContext(auth.WithAllUsers(c.Request().Context())))
+	handler.GetExpenseByID(c)
+
+	c, deleteRec := newIDContext(http.MethodDelete, "/expenses/2", "", "2")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.DeleteExpense(c)
+
+	c, tagsRec := newIDContext(http.MethodGet, "/tags", "", "")
+	asWorkspace(c, 1, 1, auth.Admin)
+	handler.GetTags(c)
+
+	ours, _ := store.List(team, ListQuery{})
+	theirs, _ := store.List(contractor, ListQuery{})
+
+	// Assert
+	assert.Equal(t, http.StatusNotFound, getRec.Code)
+	assert.Equal(t, http.StatusNotFound, allRec.Code)
+	assert.Equal(t, http.StatusNotFound, deleteRec.Code)
+	assert.Equal(t, http.StatusOK, tagsRec.Code)
+	assert.Contains(t, tagsRec.Body.String(), "food")
+	assert.NotContains(t, tagsRec.Body.String(), "hardware")
+	assert.Len(t, ours, 1)
+	assert.Len(t, theirs, 1)
+}
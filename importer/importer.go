@@ -0,0 +1,111 @@
+// Package importer reads the transactions of bank statements in the OFX
+// and QIF formats exported by banks and personal finance software.
+//
+// Each transaction has an ID which stays the same when the statement is
+// exported again, so a caller can skip the transactions it imported before.
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type (
+
+	// Transaction is a transaction of a bank statement.
+	Transaction struct {
+		// ID identifies the transaction across imports: the FITID of the bank
+		// with its account for OFX, or a hash of the fields for QIF, which has no IDs.
+		ID string
+
+		// Line is the line of the transaction in the file.
+		Line int
+
+		Account string
+
+		// Type is the kind of transaction of OFX, such as DEBIT or ATM,
+		// or the number of QIF, which is a check number or a kind such as DEP.
+		Type string
+		Date time.Time
+
+		// Amount is a decimal number with a dot, negative for money leaving the account.
+		Amount string
+
+		// Currency is the ISO 4217 code of the amount, empty when the file does not tell.
+		Currency string
+
+		Payee    string
+		Memo     string
+		Category string
+	}
+
+	// Statement is the transactions read from a file, and the errors of the
+	// transactions which could not be read.
+	Statement struct {
+		Transactions []Transaction
+		Errors       []LineError
+	}
+
+	// LineError is an invalid transaction of a file.
+	LineError struct {
+		Line    int
+		Field   string
+		Message string
+	}
+)
+
+func (err LineError) Error() string {
+	if err.Field == "" {
+		return fmt.Sprintf("line %d: %s", err.Line, err.Message)
+	}
+	return fmt.Sprintf("line %d: %s: %s", err.Line, err.Field, err.Message)
+}
+
+// decimal is a number after parseDecimal removed its thousands separators.
+var decimal = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// parseDecimal reads an amount such as -1,234.56, 1.234,56 or +12,50
+// into a decimal number with a dot. A single comma followed by three digits
+// separates thousands, and any other single comma is the decimal separator.
+func parseDecimal(value string) (string, error) {
+
+	s := strings.NewReplacer(" ", "", "\u00a0", "", "'", "").Replace(strings.TrimSpace(value))
+	s = strings.TrimPrefix(s, "+")
+
+	dot, comma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+	switch {
+	case comma > dot && dot >= 0:
+		s = strings.Replace(strings.ReplaceAll(s, ".", ""), ",", ".", 1)
+	case comma >= 0 && dot >= 0:
+		s = strings.ReplaceAll(s, ",", "")
+	case comma >= 0 && strings.Count(s, ",") == 1 && len(s)-comma-1 != 3:
+		s = strings.Replace(s, ",", ".", 1)
+	case comma >= 0:
+		s = strings.ReplaceAll(s, ",", "")
+	}
+
+	if !decimal.MatchString(s) {
+		return "", fmt.Errorf("invalid amount %q", value)
+	}
+
+	return s, nil
+}
+
+// decode returns the text of a file. Files which are not UTF-8 are read
+// as Latin-1, the usual encoding of older exports.
+func decode(data []byte) string {
+
+	if utf8.Valid(data) {
+		return strings.TrimPrefix(string(data), "\ufeff")
+	}
+
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+
+	return string(runes)
+}
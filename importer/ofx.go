@@ -0,0 +1,204 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ofxEntities are the character references of OFX text.
+var ofxEntities = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'", "&nbsp;", " ")
+
+// ofxDate is a date such as 20260914, 20260914183000 or 20260914183000.000[+7:ICT].
+var ofxDate = regexp.MustCompile(`^(\d{8})(\d{6})?(?:\.\d+)?\s*(?:\[([+-]?\d+(?:\.\d+)?)(?::[^\]]*)?\])?$`)
+
+// ParseOFX reads the bank and credit card transactions of an OFX file,
+// either OFX 1 (SGML, where the tags of values need not be closed) or OFX 2 (XML).
+// Times without offset are in location.
+func ParseOFX(r io.Reader, location *time.Location) (Statement, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Statement{}, err
+	}
+	text := decode(data)
+
+	start := strings.Index(strings.ToUpper(text), "<OFX>")
+	if start < 0 {
+		return Statement{}, errors.New("not an OFX file, it has no <OFX> element")
+	}
+
+	var statement Statement
+	line := 1 + strings.Count(text[:start], "\n")
+	text = text[start:]
+
+	// open are the names of the open aggregates, the innermost last.
+	var open []string
+	var account, currency string
+	var transaction *Transaction
+
+	parent := func() string {
+		if len(open) == 0 {
+			return ""
+		}
+		return open[len(open)-1]
+	}
+
+	// invalid is set when an element of the transaction was invalid.
+	invalid := false
+	fail := func(line int, name string, message string) {
+		statement.Errors = append(statement.Errors, LineError{line, name, message})
+		invalid = true
+	}
+
+	// value stores a value of an element of a transaction or statement.
+	value := func(name string, value string) {
+		var err error
+		switch {
+		case name == "CURDEF":
+			currency = value
+		case name == "ACCTID" && (parent() == "BANKACCTFROM" || parent() == "CCACCTFROM"):
+			account = value
+		case transaction == nil:
+		case name == "NAME" && (parent() == "PAYEE" || transaction.Payee == ""):
+			transaction.Payee = value
+		case name == "CURSYM":
+			transaction.Currency = value
+		case name == "FITID":
+			transaction.ID = value
+		case name == "TRNTYPE":
+			transaction.Type = value
+		case name == "DTPOSTED":
+			transaction.Date, err = parseOFXDate(value, location)
+		case name == "TRNAMT":
+			transaction.Amount, err = parseDecimal(value)
+		case name == "MEMO":
+			transaction.Memo = value
+		}
+		if err != nil {
+			fail(line, name, err.Error())
+		}
+	}
+
+	// finish adds the transaction when it has every required element.
+	finish := func() {
+		if transaction.ID == "" {
+			fail(transaction.Line, "FITID", "the transaction has no FITID")
+		}
+		if transaction.Date.IsZero() && !invalid {
+			fail(transaction.Line, "DTPOSTED", "the transaction has no DTPOSTED")
+		}
+		if transaction.Amount == "" && !invalid {
+			fail(transaction.Line, "TRNAMT", "the transaction has no TRNAMT")
+		}
+
+		if !invalid {
+			transaction.ID = "ofx:" + account + ":" + transaction.ID
+			transaction.Account = account
+			if transaction.Currency == "" {
+				transaction.Currency = currency
+			}
+			statement.Transactions = append(statement.Transactions, *transaction)
+		}
+		transaction = nil
+		invalid = false
+	}
+
+	for len(text) > 0 {
+		// Find the next tag and the text before it.
+		i := strings.IndexByte(text, '<')
+		if i < 0 {
+			break
+		}
+		line += strings.Count(text[:i], "\n")
+		text = text[i:]
+
+		j := strings.IndexByte(text, '>')
+		if j < 0 {
+			return Statement{}, fmt.Errorf("line %d: the tag is not closed", line)
+		}
+		tag := strings.ToUpper(strings.TrimSpace(text[1:j]))
+		text = text[j+1:]
+
+		if strings.HasPrefix(tag, "?") || strings.HasPrefix(tag, "!") || strings.HasSuffix(tag, "/") {
+			continue
+		}
+
+		if strings.HasPrefix(tag, "/") {
+			// Close the aggregate and the ones left open inside it.
+			// Closing tags of values were never opened and are ignored.
+			name := tag[1:]
+			for k := len(open) - 1; k >= 0; k-- {
+				if open[k] != name {
+					continue
+				}
+				for len(open) > k {
+					if parent() == "STMTTRN" && transaction != nil {
+						finish()
+					}
+					open = open[:len(open)-1]
+				}
+				break
+			}
+			continue
+		}
+
+		end := strings.IndexByte(text, '<')
+		if end < 0 {
+			end = len(text)
+		}
+		content := strings.TrimSpace(text[:end])
+
+		if content != "" {
+			value(tag, ofxEntities.Replace(content))
+			continue
+		}
+
+		switch tag {
+		case "STMTRS", "CCSTMTRS":
+			account, currency = "", ""
+		case "STMTTRN":
+			transaction = &Transaction{Line: line}
+		}
+		open = append(open, tag)
+	}
+
+	if transaction != nil {
+		finish()
+	}
+
+	return statement, nil
+}
+
+// parseOFXDate reads a date of OFX, such as 20260914 or 20260914183000.000[-5:EST].
+func parseOFXDate(value string, location *time.Location) (time.Time, error) {
+
+	match := ofxDate.FindStringSubmatch(value)
+	if match == nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	if match[3] != "" {
+		hours, err := strconv.ParseFloat(match[3], 64)
+		if err != nil || hours < -14 || hours > 14 {
+			return time.Time{}, fmt.Errorf("invalid offset of date %q", value)
+		}
+		location = time.FixedZone("", int(hours*3600))
+	}
+
+	layout := "20060102"
+	if match[2] != "" {
+		layout += "150405"
+	}
+
+	t, err := time.ParseInLocation(layout, match[1]+match[2], location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	return t, nil
+}
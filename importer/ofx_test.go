@@ -0,0 +1,152 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var bangkok = time.FixedZone("ICT", 7*3600)
+
+func TestParseOFXSGML(t *testing.T) {
+	// Arrange
+	file := `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+CHARSET:1252
+
+<OFX>
+<BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>THB
+<BANKACCTFROM><BANKID>004<ACCTID>123-4-56789<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20260901<DTEND>20260930
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20260914183000.000[+7:ICT]
+<TRNAMT>-1,234.50
+<FITID>2026091401
+<NAME>Tops &amp; Co
+<MEMO>groceries
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20260915
+<TRNAMT>500.00
+<FITID>2026091502
+<NAME>Salary
+<CURRENCY><CURRATE>1.0<CURSYM>USD</CURRENCY>
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+	// Act
+	statement, err := ParseOFX(strings.NewReader(file), bangkok)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, statement.Errors)
+	assert.Equal(t, []Transaction{
+		{ID: "ofx:123-4-56789:2026091401", Line: 12, Account: "123-4-56789", Type: "DEBIT",
+			Date:   time.Date(2026, 9, 14, 11, 30, 0, 0, time.UTC).In(time.FixedZone("", 7*3600)),
+			Amount: "-1234.50", Currency: "THB", Payee: "Tops & Co", Memo: "groceries"},
+		{ID: "ofx:123-4-56789:2026091502", Line: 20, Account: "123-4-56789", Type: "CREDIT",
+			Date: time.Date(2026, 9, 15, 0, 0, 0, 0, bangkok), Amount: "500.00", Currency: "USD", Payee: "Salary"},
+	}, statement.Transactions)
+}
+
+func TestParseOFXXML(t *testing.T) {
+	// Arrange
+	file := `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX>
+  <CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+    <CURDEF>USD</CURDEF>
+    <CCACCTFROM><ACCTID>4111</ACCTID></CCACCTFROM>
+    <BANKTRANLIST>
+      <STMTTRN>
+        <TRNTYPE>DEBIT</TRNTYPE>
+        <DTPOSTED>20260914120000[-5:EST]</DTPOSTED>
+        <TRNAMT>-12,50</TRNAMT>
+        <FITID>A1</FITID>
+        <PAYEE><NAME>Coffee Shop</NAME><ADDR1>1 Main St</ADDR1></PAYEE>
+        <MEMO></MEMO>
+      </STMTTRN>
+    </BANKTRANLIST>
+  </CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1>
+</OFX>`
+
+	// Act
+	statement, err := ParseOFX(strings.NewReader(file), time.UTC)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, statement.Errors)
+	assert.Len(t, statement.Transactions, 1)
+	transaction := statement.Transactions[0]
+	assert.Equal(t, "ofx:4111:A1", transaction.ID)
+	assert.Equal(t, "Coffee Shop", transaction.Payee)
+	assert.Equal(t, "-12.50", transaction.Amount)
+	assert.Equal(t, "USD", transaction.Currency)
+	assert.Equal(t, time.Date(2026, 9, 14, 17, 0, 0, 0, time.UTC), transaction.Date.UTC())
+}
+
+func TestParseOFXErrors(t *testing.T) {
+	// Arrange
+	file := "<OFX>\n<STMTRS>\n<CURDEF>THB\n" +
+		"<STMTTRN><DTPOSTED>2026-09-14<TRNAMT>-1<FITID>1</STMTTRN>\n" +
+		"<STMTTRN><DTPOSTED>20260914<TRNAMT>abc<FITID>2</STMTTRN>\n" +
+		"<STMTTRN><DTPOSTED>20260914<TRNAMT>-1</STMTTRN>\n" +
+		"<STMTTRN><DTPOSTED>20260914<FITID>4</STMTTRN>\n" +
+		"<STMTTRN><DTPOSTED>20260914<TRNAMT>-1<FITID>5</STMTTRN>\n" +
+		"</STMTRS></OFX>"
+
+	// Act
+	statement, err := ParseOFX(strings.NewReader(file), time.UTC)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []LineError{
+		{4, "DTPOSTED", `invalid date "2026-09-14"`},
+		{5, "TRNAMT", `invalid amount "abc"`},
+		{6, "FITID", "the transaction has no FITID"},
+		{7, "TRNAMT", "the transaction has no TRNAMT"},
+	}, statement.Errors)
+	assert.Len(t, statement.Transactions, 1)
+	assert.Equal(t, "ofx::5", statement.Transactions[0].ID)
+
+	_, err = ParseOFX(strings.NewReader("title,amount\nrice,50\n"), time.UTC)
+	assert.Error(t, err)
+
+	_, err = ParseOFX(strings.NewReader("<OFX><STMTTRN"), time.UTC)
+	assert.Error(t, err)
+}
+
+func TestParseDecimal(t *testing.T) {
+	cases := map[string]string{
+		"-1,234.56": "-1234.56",
+		"1.234,56":  "1234.56",
+		"+12,50":    "12.50",
+		"1,234":     "1234",
+		"1,234,567": "1234567",
+		" -7 ":      "-7",
+		"1'000.5":   "1000.5",
+	}
+
+	for value, expected := range cases {
+		got, err := parseDecimal(value)
+
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, got, value)
+	}
+
+	for _, value := range []string{"", "abc", "1.2.3", "--1", "1e5"} {
+		_, err := parseDecimal(value)
+
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,205 @@
+package importer
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateOrder is the order of the day, month and year of the dates of a QIF file,
+// which depends on the locale of the software writing it.
+type DateOrder string
+
+const (
+	MDY DateOrder = "mdy"
+	DMY DateOrder = "dmy"
+	YMD DateOrder = "ymd"
+)
+
+// qifTypes are the types of QIF sections holding bank transactions.
+// Other sections, such as investments, categories and memorized transactions, are skipped.
+var qifTypes = map[string]bool{"bank": true, "cash": true, "ccard": true, "oth a": true, "oth l": true}
+
+// ParseQIF reads the transactions of the bank, cash and credit card
+// sections of a QIF file. Dates are in order and in location.
+func ParseQIF(r io.Reader, order DateOrder, location *time.Location) (Statement, error) {
+
+	switch order {
+	case MDY, DMY, YMD:
+	default:
+		return Statement{}, fmt.Errorf("unknown date order %q, use mdy, dmy or ymd", order)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Statement{}, err
+	}
+
+	var statement Statement
+	var section, account string
+
+	// fields are the lines of the current record by their code.
+	fields := map[byte]string{}
+	start := 0
+
+	// seen counts the transactions with the same fields, which get different IDs.
+	seen := map[string]int{}
+
+	// finish adds the current record as a transaction of a bank section.
+	finish := func() {
+		defer func() { fields = map[byte]string{} }()
+
+		switch {
+		case len(fields) == 0:
+			return
+		case section == "account":
+			account = fields['N']
+			return
+		case !qifTypes[section]:
+			return
+		}
+
+		transaction := Transaction{
+			Line:     start,
+			Account:  account,
+			Type:     fields['N'],
+			Payee:    fields['P'],
+			Memo:     fields['M'],
+			Category: fields['L'],
+		}
+		if transaction.Category == "" {
+			// A split transaction has the category of each split instead.
+			transaction.Category = fields['S']
+		}
+		valid := true
+
+		amount, ok := fields['T']
+		if !ok {
+			amount = fields['U']
+		}
+		if transaction.Amount, err = parseDecimal(amount); err != nil {
+			statement.Errors = append(statement.Errors, LineError{start, "T", fmt.Sprintf("invalid amount %q", amount)})
+			valid = false
+		}
+
+		if transaction.Date, err = parseQIFDate(fields['D'], order, location); err != nil {
+			statement.Errors = append(statement.Errors, LineError{start, "D", err.Error()})
+			valid = false
+		}
+
+		if !valid {
+			return
+		}
+
+		key := strings.Join([]string{account, transaction.Date.Format("2006-01-02"), transaction.Amount,
+			transaction.Type, transaction.Payee, transaction.Memo}, "\x00")
+		seen[key]++
+		sum := sha256.Sum256([]byte(key + "\x00" + strconv.Itoa(seen[key])))
+		transaction.ID = "qif:" + hex.EncodeToString(sum[:16])
+
+		statement.Transactions = append(statement.Transactions, transaction)
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(decode(data)))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "!") {
+			finish()
+			header := strings.ToLower(text[1:])
+			switch {
+			case header == "account":
+				section = "account"
+			case strings.HasPrefix(header, "type:"):
+				section = strings.TrimSpace(header[len("type:"):])
+			}
+			// Options such as !Option:AutoSwitch keep the section.
+			continue
+		}
+
+		if section == "" {
+			return Statement{}, errors.New("not a QIF file, it does not start with a !Type header")
+		}
+
+		if text[0] == '^' {
+			finish()
+			continue
+		}
+
+		if len(fields) == 0 {
+			start = line
+		}
+		// Splits and address lines repeat their codes, and only the first one is kept.
+		if _, ok := fields[text[0]]; !ok {
+			fields[text[0]] = strings.TrimSpace(text[1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Statement{}, err
+	}
+	if section == "" {
+		return Statement{}, errors.New("not a QIF file, it does not start with a !Type header")
+	}
+
+	finish()
+
+	return statement, nil
+}
+
+// parseQIFDate reads a date such as 9/14/2026, 9/14'26, 14.09.26 or 2026-09-14.
+// A two-digit year after an apostrophe is after 2000, as Quicken writes them.
+func parseQIFDate(value string, order DateOrder, location *time.Location) (time.Time, error) {
+
+	invalid := fmt.Errorf("invalid date %q for the %s date order", value, order)
+
+	parts := strings.FieldsFunc(strings.TrimSpace(value), func(r rune) bool {
+		return strings.ContainsRune("/-.' ", r)
+	})
+	if len(parts) != 3 {
+		return time.Time{}, invalid
+	}
+
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		numbers[i] = n
+	}
+
+	var year, month, day int
+	var yearDigits int
+	switch order {
+	case MDY:
+		month, day, year, yearDigits = numbers[0], numbers[1], numbers[2], len(parts[2])
+	case DMY:
+		day, month, year, yearDigits = numbers[0], numbers[1], numbers[2], len(parts[2])
+	case YMD:
+		year, month, day, yearDigits = numbers[0], numbers[1], numbers[2], len(parts[0])
+	}
+
+	if yearDigits <= 2 {
+		if year >= 70 && !strings.Contains(value, "'") {
+			year += 1900
+		} else {
+			year += 2000
+		}
+	}
+
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, location)
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day {
+		return time.Time{}, invalid
+	}
+
+	return t, nil
+}
@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQIF(t *testing.T) {
+	// Arrange
+	file := "!Account\nNChecking\nTBank\n^\n" +
+		"!Type:Bank\n" +
+		"D9/14'26\nT-1,234.50\nPTops\nMgroceries\nLFood:Groceries\n^\n" +
+		"D9/14'26\nT-20.00\nPBus\nNATM\n^\n" +
+		"D9/14'26\nT-20.00\nPBus\nNATM\n^\n" +
+		"!Type:Cat\nNFood\nE\n^\n" +
+		"!Type:CCard\nD12/31/99\nU-5\nPCoffee\nSFood\n$-3\nSDrinks\n$-2\n"
+
+	// Act
+	statement, err := ParseQIF(strings.NewReader(file), MDY, bangkok)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, statement.Errors)
+	assert.Len(t, statement.Transactions, 4)
+
+	first := statement.Transactions[0]
+	assert.Equal(t, Transaction{ID: first.ID, Line: 6, Account: "Checking",
+		Date: time.Date(2026, 9, 14, 0, 0, 0, 0, bangkok), Amount: "-1234.50",
+		Payee: "Tops", Memo: "groceries", Category: "Food:Groceries"}, first)
+	assert.True(t, strings.HasPrefix(first.ID, "qif:"))
+
+	// Identical transactions get different IDs, which are the same in every import.
+	assert.NotEqual(t, statement.Transactions[1].ID, statement.Transactions[2].ID)
+	again, _ := ParseQIF(strings.NewReader(file), MDY, bangkok)
+	assert.Equal(t, statement.Transactions[2].ID, again.Transactions[2].ID)
+
+	assert.Equal(t, time.Date(1999, 12, 31, 0, 0, 0, 0, bangkok), statement.Transactions[3].Date)
+	assert.Equal(t, "-5", statement.Transactions[3].Amount)
+	assert.Equal(t, "Food", statement.Transactions[3].Category)
+}
+
+func TestParseQIFErrors(t *testing.T) {
+	// Arrange
+	file := "!Type:Bank\n" +
+		"D31/12/2026\nT-1\nPRice\n^\n" +
+		"D2026-12-31\nTabc\nPBus\n^\n"
+
+	// Act
+	statement, err := ParseQIF(strings.NewReader(file), MDY, time.UTC)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Empty(t, statement.Transactions)
+	assert.Equal(t, []LineError{
+		{2, "D", `invalid date "31/12/2026" for the mdy date order`},
+		{6, "T", `invalid amount "abc"`},
+		{6, "D", `invalid date "2026-12-31" for the mdy date order`},
+	}, statement.Errors)
+
+	_, err = ParseQIF(strings.NewReader("D9/14/26\nT-1\n^\n"), MDY, time.UTC)
+	assert.Error(t, err)
+
+	_, err = ParseQIF(strings.NewReader(file), DateOrder("ydm"), time.UTC)
+	assert.Error(t, err)
+}
+
+func TestParseQIFDate(t *testing.T) {
+	cases := []struct {
+		value    string
+		order    DateOrder
+		expected time.Time
+	}{
+		{"9/14/2026", MDY, time.Date(2026, 9, 14, 0, 0, 0, 0, time.UTC)},
+		{"9/14/ 6", MDY, time.Date(2006, 9, 14, 0, 0, 0, 0, time.UTC)},
+		{"14.09.26", DMY, time.Date(2026, 9, 14, 0, 0, 0, 0, time.UTC)},
+		{"2026-09-14", YMD, time.Date(2026, 9, 14, 0, 0, 0, 0, time.UTC)},
+		{"1/2'98", MDY, time.Date(2098, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range cases {
+		got, err := parseQIFDate(test.value, test.order, time.UTC)
+
+		assert.NoError(t, err, test.value)
+		assert.Equal(t, test.expected, got, test.value)
+	}
+
+	_, err := parseQIFDate("2/30/2026", MDY, time.UTC)
+	assert.Error(t, err)
+}
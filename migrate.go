@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/PeemPeimn/assessment/migrations"
+	_ "github.com/lib/pq"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+commands:
+  status      list migrations and whether they are applied
+  up          apply every pending migration
+  down N      revert the last N applied migrations
+  new NAME    create empty up and down files in ` + migrations.Dir
+
+// runMigrate handles the "migrate" subcommand of the server binary.
+func runMigrate(args []string) {
+
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+
+	if args[0] == "new" {
+		if len(args) != 2 {
+			log.Fatal(migrateUsage)
+		}
+		paths, err := migrations.New(migrations.Dir, args[1])
+		if err != nil {
+			log.Fatal("cannot create the migration. ", err)
+		}
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		return
+	}
+
+	all, err := migrations.Default()
+	if err != nil {
+		log.Fatal("cannot load the migrations. ", err)
+	}
+
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatal("cannot connect to the database. ", err)
+	}
+	defer db.Close()
+
+	migrator := migrations.NewMigrator(db, all)
+	ctx := context.Background()
+
+	switch args[0] {
+
+	case "status":
+		statuses, migrating, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if migrating {
+			fmt.Println("a migration is in progress")
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Modified {
+				state += " (modified)"
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+
+	case "down":
+		if len(args) != 2 {
+			log.Fatal(migrateUsage)
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			log.Fatal("N must be a positive number.")
+		}
+		reverted, err := migrator.Down(ctx, n)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
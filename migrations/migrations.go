@@ -0,0 +1,147 @@
+// Package migrations applies versioned SQL migrations to the database.
+//
+// Migrations are SQL files in the sql folder named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+// They are embedded into the binary and applied in version order.
+// Applied versions are recorded with a checksum in the schema_migrations table
+// so an edited migration is detected instead of silently ignored.
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Dir is the folder of the migration files relative to the repository root.
+// It is used by New since embedded files cannot be written.
+const Dir = "migrations/sql"
+
+//go:embed sql/*.sql
+var embedded embed.FS
+
+// Migration is a single version of the schema.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Default returns the migrations embedded into the binary.
+func Default() ([]Migration, error) {
+	sub, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		return nil, err
+	}
+	return Load(sub)
+}
+
+// Load reads the migrations in the root of fsys ordered by version.
+// Every version must have an up file, the down file is optional.
+func Load(fsys fs.FS) ([]Migration, error) {
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names %q and %q", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up file", migration.Version)
+		}
+		migration.Checksum = checksum(migration.Up)
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func checksum(sql string) string {
+	sum := sha256.Sum256([]byte(sql))
+	return hex.EncodeToString(sum[:])
+}
+
+// New creates empty up and down files for the next version in dir
+// and returns their paths.
+func New(dir string, name string) ([]string, error) {
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = regexp.MustCompile(`\W+`).ReplaceAllString(name, "_")
+	if name == "" || name == "_" {
+		return nil, fmt.Errorf("migration name is required")
+	}
+
+	existing, err := Load(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	version := 1
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		file := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %04d %s (%s)\n", version, name, direction)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, file)
+	}
+
+	return paths, nil
+}
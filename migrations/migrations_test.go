@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	// Arrange
+	fsys := fstest.MapFS{
+		"0002_add_note.up.sql":   {Data: []byte("ALTER TABLE t ADD note TEXT;")},
+		"0002_add_note.down.sql": {Data: []byte("ALTER TABLE t DROP note;")},
+		"0001_create_t.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+		"README.md":              {Data: []byte("ignored")},
+	}
+
+	// Act
+	got, err := Load(fsys)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, 1, got[0].Version)
+	assert.Equal(t, "create_t", got[0].Name)
+	assert.Equal(t, "", got[0].Down)
+	assert.Equal(t, 2, got[1].Version)
+	assert.Equal(t, "ALTER TABLE t DROP note;", got[1].Down)
+	assert.Equal(t, checksum("ALTER TABLE t ADD note TEXT;"), got[1].Checksum)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"bad name":     {"create.up.sql": {Data: []byte("x")}},
+		"missing up":   {"0001_t.down.sql": {Data: []byte("x")}},
+		"two names":    {"0001_a.up.sql": {Data: []byte("x")}, "0001_b.down.sql": {Data: []byte("x")}},
+		"empty up sql": {"0001_a.up.sql": {Data: []byte("")}},
+	}
+
+	for name, fsys := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+	got, err := Default()
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, got)
+	for i, migration := range got {
+		assert.Equal(t, i+1, migration.Version, "versions must not have gaps")
+		assert.NotEmpty(t, migration.Down, "migration %d has no down file", migration.Version)
+	}
+}
+
+func TestNew(t *testing.T) {
+	// Arrange
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "0001_create_t.up.sql"), []byte("CREATE TABLE t (id INT);"), 0o644)
+
+	// Act
+	paths, err := New(dir, "Add Currency")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "0002_add_currency.up.sql"),
+		filepath.Join(dir, "0002_add_currency.down.sql"),
+	}, paths)
+
+	got, err := Load(os.DirFS(dir))
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+}
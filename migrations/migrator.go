@@ -0,0 +1,243 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// lockKey is the Postgres advisory lock held while migrating
+// so two server instances cannot migrate at the same time.
+const lockKey = 2565_0001
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// Status reports whether a migration has been applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Modified is true when the applied checksum differs from the file.
+	Modified bool
+}
+
+// applied is a row of the schema_migrations table.
+type applied struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+// NewMigrator returns a Migrator applying migrations to db.
+func NewMigrator(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{DB: db, Migrations: migrations}
+}
+
+// withLock runs fn on a single connection holding the advisory lock.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("cannot lock migrations. %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			checksum TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("cannot create schema_migrations. %w", err)
+	}
+
+	return fn(conn)
+}
+
+func readApplied(ctx context.Context, conn *sql.Conn) (map[int]applied, error) {
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int]applied{}
+	for rows.Next() {
+		var version int
+		var row applied
+		if err := rows.Scan(&version, &row.checksum, &row.appliedAt); err != nil {
+			return nil, err
+		}
+		versions[version] = row
+	}
+
+	return versions, rows.Err()
+}
+
+// Status returns every known migration and whether it is applied.
+// It does not wait for a migration run by another process: migrating is
+// then true, and the statuses are those of the migrations applied so far.
+func (m *Migrator) Status(ctx context.Context) (statuses []Status, migrating bool, err error) {
+
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockKey).Scan(&locked); err != nil {
+		return nil, false, fmt.Errorf("cannot lock migrations. %w", err)
+	}
+	if locked {
+		defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+	}
+
+	// Nothing is applied before the first migration creates schema_migrations.
+	var exists bool
+	if err := conn.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return nil, false, err
+	}
+
+	versions := map[int]applied{}
+	if exists {
+		if versions, err = readApplied(ctx, conn); err != nil {
+			return nil, false, err
+		}
+	}
+
+	for _, migration := range m.Migrations {
+		row, ok := versions[migration.Version]
+		statuses = append(statuses, Status{
+			Migration: migration,
+			Applied:   ok,
+			AppliedAt: row.appliedAt,
+			Modified:  ok && row.checksum != migration.Checksum,
+		})
+	}
+
+	return statuses, !locked, nil
+}
+
+// Up applies every pending migration in version order, each in its own transaction,
+// and returns the applied migrations.
+// It fails without applying anything when an applied migration was modified.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+
+	var done []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := readApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		known := map[int]bool{}
+		for _, migration := range m.Migrations {
+			known[migration.Version] = true
+			row, ok := versions[migration.Version]
+			if ok && row.checksum != migration.Checksum {
+				return fmt.Errorf("migration %d_%s was modified after it was applied",
+					migration.Version, migration.Name)
+			}
+		}
+		for version := range versions {
+			if !known[version] {
+				return fmt.Errorf("applied migration %d is unknown to this binary", version)
+			}
+		}
+
+		for _, migration := range m.Migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					"INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+					migration.Version, migration.Name, migration.Checksum)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("cannot apply migration %d_%s. %w", migration.Version, migration.Name, err)
+			}
+
+			done = append(done, migration)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+// Down reverts the last n applied migrations in reverse version order
+// and returns the reverted migrations.
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+
+	var done []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := readApplied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.Migrations) - 1; i >= 0 && len(done) < n; i-- {
+			migration := m.Migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s cannot be reverted", migration.Version, migration.Name)
+			}
+
+			err := inTx(ctx, conn, func(tx *sql.Tx) error {
+				if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx,
+					"DELETE FROM schema_migrations WHERE version=$1", migration.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("cannot revert migration %d_%s. %w", migration.Version, migration.Name, err)
+			}
+
+			done = append(done, migration)
+		}
+		return nil
+	})
+
+	return done, err
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
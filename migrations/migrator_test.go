@@ -0,0 +1,150 @@
+package migrations
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_t", Up: "CREATE TABLE t (id INT)", Down: "DROP TABLE t", Checksum: checksum("CREATE TABLE t (id INT)")},
+	{Version: 2, Name: "add_note", Up: "ALTER TABLE t ADD note TEXT", Down: "ALTER TABLE t DROP note", Checksum: checksum("ALTER TABLE t ADD note TEXT")},
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("SELECT pg_advisory_lock").WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestUp(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	expectLock(mock)
+	mock.ExpectQuery("SELECT version, checksum, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "checksum", "applied_at"}).
+			AddRow(1, testMigrations[0].Checksum, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("ALTER TABLE t ADD note TEXT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs(2, "add_note", testMigrations[1].Checksum).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	applied, err := NewMigrator(db, testMigrations).Up(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, testMigrations[1:], applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpModified(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	expectLock(mock)
+	mock.ExpectQuery("SELECT version, checksum, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "checksum", "applied_at"}).
+			AddRow(1, "edited", time.Now()))
+	mock.ExpectExec("SELECT pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	applied, err := NewMigrator(db, testMigrations).Up(context.Background())
+
+	// Assert
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "modified")
+	assert.Empty(t, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDown(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	expectLock(mock)
+	mock.ExpectQuery("SELECT version, checksum, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "checksum", "applied_at"}).
+			AddRow(1, testMigrations[0].Checksum, time.Now()).
+			AddRow(2, testMigrations[1].Checksum, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("ALTER TABLE t DROP note").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	reverted, err := NewMigrator(db, testMigrations).Down(context.Background(), 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, testMigrations[1:], reverted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatus(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	appliedAt := time.Date(2026, 9, 15, 5, 30, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT pg_try_advisory_lock").WithArgs(lockKey).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectQuery("SELECT to_regclass").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT version, checksum, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "checksum", "applied_at"}).
+			AddRow(1, "other", appliedAt))
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	statuses, migrating, err := NewMigrator(db, testMigrations).Status(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, migrating)
+	assert.Equal(t, []Status{
+		{Migration: testMigrations[0], Applied: true, AppliedAt: appliedAt, Modified: true},
+		{Migration: testMigrations[1]},
+	}, statuses)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStatusMigrating(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	mock.ExpectQuery("SELECT pg_try_advisory_lock").WithArgs(lockKey).
+		WillReturnRows(sqlmock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	mock.ExpectQuery("SELECT to_regclass").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	// Act
+	statuses, migrating, err := NewMigrator(db, testMigrations).Status(context.Background())
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, migrating)
+	assert.Equal(t, []Status{{Migration: testMigrations[0]}, {Migration: testMigrations[1]}}, statuses)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,2 @@
+ALTER TABLE expenses
+	ALTER COLUMN amount TYPE FLOAT USING amount::FLOAT / 100;
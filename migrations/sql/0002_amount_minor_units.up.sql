@@ -0,0 +1,4 @@
+-- Store amounts as BIGINT minor units (satang) instead of FLOAT baht.
+-- Rounding through NUMERIC keeps values such as 79.5 exact.
+ALTER TABLE expenses
+	ALTER COLUMN amount TYPE BIGINT USING round(amount::NUMERIC * 100)::BIGINT;
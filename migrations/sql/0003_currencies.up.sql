@@ -0,0 +1,12 @@
+ALTER TABLE expenses
+	ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'THB';
+
+-- One unit of currency is worth rate units of base from effective_on
+-- until the next rate of the same pair.
+CREATE TABLE exchange_rates (
+	currency CHAR(3) NOT NULL,
+	base CHAR(3) NOT NULL,
+	effective_on DATE NOT NULL,
+	rate NUMERIC(24, 10) NOT NULL CHECK (rate > 0),
+	PRIMARY KEY (currency, base, effective_on)
+);
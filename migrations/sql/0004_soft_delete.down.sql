@@ -0,0 +1,6 @@
+DELETE FROM expenses WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS expenses_deleted_at_idx;
+
+ALTER TABLE expenses
+	DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,5 @@
+ALTER TABLE expenses
+	ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX expenses_deleted_at_idx ON expenses (deleted_at)
+	WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS expenses_amount_id_idx;
+
+DROP INDEX IF EXISTS expenses_tags_idx;
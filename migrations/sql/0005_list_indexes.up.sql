@@ -0,0 +1,5 @@
+-- Indexes for the filters and keyset pagination of GET /expenses.
+CREATE INDEX expenses_tags_idx ON expenses USING GIN (tags);
+
+CREATE INDEX expenses_amount_id_idx ON expenses (amount, id)
+	WHERE deleted_at IS NULL;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS expenses_search_text_trgm_idx;
+
+DROP INDEX IF EXISTS expenses_search_vector_idx;
+
+ALTER TABLE expenses
+	DROP COLUMN IF EXISTS search_text,
+	DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,15 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- The simple configuration does not stem words, so it works for Thai and English.
+ALTER TABLE expenses
+	ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('simple', coalesce(note, '')), 'B')
+	) STORED,
+	ADD COLUMN search_text TEXT GENERATED ALWAYS AS (
+		coalesce(title, '') || ' ' || coalesce(note, '')
+	) STORED;
+
+CREATE INDEX expenses_search_vector_idx ON expenses USING GIN (search_vector);
+
+CREATE INDEX expenses_search_text_trgm_idx ON expenses USING GIN (search_text gin_trgm_ops);
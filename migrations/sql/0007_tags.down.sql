@@ -0,0 +1,2 @@
+-- Normalized tags are kept.
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,18 @@
+-- Colors and descriptions of tags. Tags without them have no row.
+CREATE TABLE IF NOT EXISTS tags (
+	name TEXT PRIMARY KEY CHECK (name = lower(name) AND name <> ''),
+	color TEXT,
+	description TEXT
+);
+
+-- Normalize existing tags the way NormalizeTag does, so "Food" and " food" become one tag.
+UPDATE expenses SET tags = ARRAY(
+	SELECT tag FROM (
+		SELECT lower(btrim(regexp_replace(tag, '\s+', ' ', 'g'))) AS tag, position
+		FROM unnest(tags) WITH ORDINALITY AS old(tag, position)
+	) new
+	WHERE tag <> ''
+	GROUP BY tag
+	ORDER BY min(position)
+)
+WHERE tags IS NOT NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS expenses_spent_at_id_idx;
+
+ALTER TABLE expenses
+	DROP COLUMN IF EXISTS spent_at,
+	DROP COLUMN IF EXISTS created_at,
+	DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,8 @@
+-- Existing expenses were spent, created and updated when this migration runs.
+ALTER TABLE expenses
+	ADD COLUMN spent_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE INDEX expenses_spent_at_id_idx ON expenses (spent_at, id)
+	WHERE deleted_at IS NULL;
@@ -0,0 +1,12 @@
+-- Amounts which can be spent on a tag every week or month.
+-- Periods start on starts_on, which is the first day of a week or month.
+CREATE TABLE IF NOT EXISTS budgets (
+	id SERIAL PRIMARY KEY,
+	tag TEXT NOT NULL CHECK (tag = lower(tag) AND tag <> ''),
+	period TEXT NOT NULL CHECK (period IN ('week', 'month')),
+	amount BIGINT NOT NULL CHECK (amount > 0),
+	currency CHAR(3) NOT NULL,
+	rollover BOOLEAN NOT NULL DEFAULT false,
+	starts_on DATE NOT NULL,
+	UNIQUE (tag, period, currency)
+);
@@ -0,0 +1,27 @@
+-- Limits of the spending of a tag every week or month. An alert fires when
+-- the spending of a period reaches one of the thresholds, in percent of amount.
+CREATE TABLE IF NOT EXISTS alert_rules (
+	id SERIAL PRIMARY KEY,
+	tag TEXT NOT NULL CHECK (tag = lower(tag) AND tag <> ''),
+	period TEXT NOT NULL CHECK (period IN ('week', 'month')),
+	amount BIGINT NOT NULL CHECK (amount > 0),
+	currency CHAR(3) NOT NULL,
+	thresholds FLOAT8[] NOT NULL
+);
+
+-- Fired alerts. A threshold of a rule fires once per period.
+CREATE TABLE IF NOT EXISTS alerts (
+	id SERIAL PRIMARY KEY,
+	rule_id INT NOT NULL REFERENCES alert_rules (id) ON DELETE CASCADE,
+	period_start DATE NOT NULL,
+	threshold FLOAT8 NOT NULL,
+	tag TEXT NOT NULL,
+	amount BIGINT NOT NULL,
+	spent BIGINT NOT NULL,
+	currency CHAR(3) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	acknowledged_at TIMESTAMPTZ,
+	UNIQUE (rule_id, period_start, threshold)
+);
+
+CREATE INDEX alerts_unacknowledged_idx ON alerts (id) WHERE acknowledged_at IS NULL;
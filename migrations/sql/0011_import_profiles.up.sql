@@ -0,0 +1,5 @@
+-- Saved column mappings of CSV files imported by POST /expenses/import.
+CREATE TABLE IF NOT EXISTS import_profiles (
+	name TEXT PRIMARY KEY,
+	profile JSONB NOT NULL
+);
@@ -0,0 +1,8 @@
+-- Bank transactions imported from OFX and QIF statements, so importing
+-- a statement again does not duplicate their expenses. The row is claimed
+-- before its expense is inserted, in the same transaction.
+CREATE TABLE IF NOT EXISTS imported_transactions (
+	transaction_id TEXT PRIMARY KEY,
+	expense_id INT REFERENCES expenses (id) ON DELETE CASCADE,
+	imported_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,12 @@
+-- API keys authenticating requests. Only the SHA-256 hash of a key is stored,
+-- and prefix is its start, to tell the keys apart.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL CHECK (name <> ''),
+	prefix TEXT NOT NULL,
+	hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ
+);
@@ -0,0 +1,17 @@
+DELETE FROM imported_transactions a USING imported_transactions b
+	WHERE a.transaction_id = b.transaction_id AND a.owner_id > b.owner_id;
+
+ALTER TABLE imported_transactions
+	DROP CONSTRAINT imported_transactions_pkey,
+	ADD PRIMARY KEY (transaction_id),
+	DROP COLUMN IF EXISTS owner_id;
+
+ALTER TABLE api_keys
+	DROP COLUMN IF EXISTS user_id;
+
+DROP INDEX IF EXISTS expenses_owner_id_idx;
+
+ALTER TABLE expenses
+	DROP COLUMN IF EXISTS owner_id;
+
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,36 @@
+-- Users owning expenses and API keys.
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE CHECK (name <> ''),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Expenses and API keys created before users belong to the user "default".
+INSERT INTO users (name)
+	SELECT 'default'
+	WHERE EXISTS (SELECT 1 FROM expenses) OR EXISTS (SELECT 1 FROM api_keys);
+
+ALTER TABLE expenses
+	ADD COLUMN owner_id INT REFERENCES users (id);
+UPDATE expenses SET owner_id = (SELECT id FROM users WHERE name = 'default');
+ALTER TABLE expenses
+	ALTER COLUMN owner_id SET NOT NULL;
+
+CREATE INDEX expenses_owner_id_idx ON expenses (owner_id, id);
+
+ALTER TABLE api_keys
+	ADD COLUMN user_id INT REFERENCES users (id);
+UPDATE api_keys SET user_id = (SELECT id FROM users WHERE name = 'default');
+ALTER TABLE api_keys
+	ALTER COLUMN user_id SET NOT NULL;
+
+-- Each user imports the transactions of their own statements.
+ALTER TABLE imported_transactions
+	ADD COLUMN owner_id INT REFERENCES users (id);
+UPDATE imported_transactions SET owner_id = expenses.owner_id
+	FROM expenses WHERE expenses.id = imported_transactions.expense_id;
+DELETE FROM imported_transactions WHERE owner_id IS NULL;
+ALTER TABLE imported_transactions
+	ALTER COLUMN owner_id SET NOT NULL,
+	DROP CONSTRAINT imported_transactions_pkey,
+	ADD PRIMARY KEY (owner_id, transaction_id);
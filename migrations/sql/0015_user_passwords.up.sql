@@ -0,0 +1,3 @@
+-- The bcrypt hashes of the passwords users log in with to get tokens.
+ALTER TABLE users
+	ADD COLUMN password_hash TEXT;
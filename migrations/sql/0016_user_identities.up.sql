@@ -0,0 +1,9 @@
+-- The accounts of identity providers users log in with, by the issuer
+-- of the provider and the subject of the account.
+CREATE TABLE IF NOT EXISTS user_identities (
+	issuer TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (issuer, subject)
+);
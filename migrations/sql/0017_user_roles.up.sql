@@ -0,0 +1,8 @@
+-- The role of a user grants the permissions of its requests.
+ALTER TABLE users
+	ADD COLUMN role TEXT NOT NULL DEFAULT 'member'
+	CHECK (role IN ('admin', 'approver', 'member', 'auditor'));
+
+-- The user owning the expenses created before users keeps managing
+-- the shared budgets, alert rules and rates.
+UPDATE users SET role = 'admin' WHERE name = 'default';
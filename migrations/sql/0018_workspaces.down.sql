@@ -0,0 +1,47 @@
+-- Keys unique within a workspace become unique again by keeping the rows
+-- of the first workspace.
+DELETE FROM imported_transactions a USING imported_transactions b
+	WHERE a.owner_id = b.owner_id AND a.transaction_id = b.transaction_id AND a.workspace_id > b.workspace_id;
+DELETE FROM tags a USING tags b
+	WHERE a.name = b.name AND a.workspace_id > b.workspace_id;
+DELETE FROM budgets a USING budgets b
+	WHERE a.tag = b.tag AND a.period = b.period AND a.currency = b.currency AND a.workspace_id > b.workspace_id;
+DELETE FROM exchange_rates a USING exchange_rates b
+	WHERE a.currency = b.currency AND a.base = b.base AND a.effective_on = b.effective_on
+		AND a.workspace_id > b.workspace_id;
+DELETE FROM import_profiles a USING import_profiles b
+	WHERE a.name = b.name AND a.workspace_id > b.workspace_id;
+
+ALTER TABLE imported_transactions
+	DROP CONSTRAINT imported_transactions_pkey,
+	ADD PRIMARY KEY (owner_id, transaction_id),
+	DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE tags
+	DROP CONSTRAINT tags_pkey,
+	ADD PRIMARY KEY (name),
+	DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE budgets
+	DROP CONSTRAINT budgets_workspace_id_tag_period_currency_key,
+	ADD UNIQUE (tag, period, currency),
+	DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE exchange_rates
+	DROP CONSTRAINT exchange_rates_pkey,
+	ADD PRIMARY KEY (currency, base, effective_on),
+	DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE import_profiles
+	DROP CONSTRAINT import_profiles_pkey,
+	ADD PRIMARY KEY (name),
+	DROP COLUMN IF EXISTS workspace_id;
+
+DROP INDEX IF EXISTS alerts_workspace_id_idx;
+DROP INDEX IF EXISTS alert_rules_workspace_id_idx;
+DROP INDEX IF EXISTS expenses_workspace_id_idx;
+CREATE INDEX expenses_owner_id_idx ON expenses (owner_id, id);
+
+ALTER TABLE alerts DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE expenses DROP COLUMN IF EXISTS workspace_id;
+
+DROP TABLE IF EXISTS workspace_invitations;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,92 @@
+-- Workspaces isolate the expenses, tags, budgets, alerts, exchange rates
+-- and import profiles of the teams sharing a deployment.
+CREATE TABLE IF NOT EXISTS workspaces (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL CHECK (name <> ''),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- The members of a workspace, whose role in it grants the permissions of their requests there.
+CREATE TABLE IF NOT EXISTS workspace_members (
+	workspace_id INT NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+	user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	role TEXT NOT NULL CHECK (role IN ('admin', 'approver', 'member', 'auditor')),
+	joined_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX workspace_members_user_id_idx ON workspace_members (user_id, joined_at);
+
+-- Invitations to join a workspace, accepted once. Only the SHA-256 hash of their token is stored.
+CREATE TABLE IF NOT EXISTS workspace_invitations (
+	id SERIAL PRIMARY KEY,
+	workspace_id INT NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+	role TEXT NOT NULL CHECK (role IN ('admin', 'approver', 'member', 'auditor')),
+	hash TEXT NOT NULL UNIQUE,
+	invited_by INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	accepted_by INT REFERENCES users (id) ON DELETE SET NULL,
+	accepted_at TIMESTAMPTZ
+);
+
+-- Everything created before workspaces belongs to the workspace "default",
+-- of which every user is a member with the role they had.
+INSERT INTO workspaces (name)
+	SELECT 'default'
+	WHERE EXISTS (SELECT 1 FROM users) OR EXISTS (SELECT 1 FROM expenses)
+		OR EXISTS (SELECT 1 FROM tags) OR EXISTS (SELECT 1 FROM budgets)
+		OR EXISTS (SELECT 1 FROM alert_rules) OR EXISTS (SELECT 1 FROM exchange_rates)
+		OR EXISTS (SELECT 1 FROM import_profiles);
+
+INSERT INTO workspace_members (workspace_id, user_id, role)
+	SELECT workspaces.id, users.id, users.role FROM workspaces, users;
+
+ALTER TABLE expenses ADD COLUMN workspace_id INT REFERENCES workspaces (id) ON DELETE CASCADE;
+ALTER TABLE imported_transactions ADD COLUMN workspace_id INT REFERENCES workspaces (id) ON DELETE CASCADE;
+ALTER TABLE tags ADD COLUMN workspace_id INT REFERENCES workspaces (id) ON DELETE CASCADE;
+ALTER TABLE budgets ADD COLUMN workspace_id INT REFERENCES workspaces (id) ON DELETE CASCADE;
+ALTER TABLE alert_rules ADD COLUMN workspace_id INT REFERENCES workspaces (id) ON DELETE CASCADE;
+ALTER TABLE alerts ADD COLUMN workspace_id INT REFERENCES workspaces (id) ON DELETE CASCADE;
+ALTER TABLE exchange_rates ADD COLUMN workspace_id INT REFERENCES workspaces (id) ON DELETE CASCADE;
+ALTER TABLE import_profiles ADD COLUMN workspace_id INT REFERENCES workspaces (id) ON DELETE CASCADE;
+
+UPDATE expenses SET workspace_id = (SELECT id FROM workspaces WHERE name = 'default');
+UPDATE imported_transactions SET workspace_id = (SELECT id FROM workspaces WHERE name = 'default');
+UPDATE tags SET workspace_id = (SELECT id FROM workspaces WHERE name = 'default');
+UPDATE budgets SET workspace_id = (SELECT id FROM workspaces WHERE name = 'default');
+UPDATE alert_rules SET workspace_id = (SELECT id FROM workspaces WHERE name = 'default');
+UPDATE alerts SET workspace_id = (SELECT id FROM workspaces WHERE name = 'default');
+UPDATE exchange_rates SET workspace_id = (SELECT id FROM workspaces WHERE name = 'default');
+UPDATE import_profiles SET workspace_id = (SELECT id FROM workspaces WHERE name = 'default');
+
+ALTER TABLE expenses ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE alert_rules ALTER COLUMN workspace_id SET NOT NULL;
+ALTER TABLE alerts ALTER COLUMN workspace_id SET NOT NULL;
+
+-- Names and other keys are only unique within a workspace.
+ALTER TABLE imported_transactions
+	ALTER COLUMN workspace_id SET NOT NULL,
+	DROP CONSTRAINT imported_transactions_pkey,
+	ADD PRIMARY KEY (workspace_id, owner_id, transaction_id);
+ALTER TABLE tags
+	ALTER COLUMN workspace_id SET NOT NULL,
+	DROP CONSTRAINT tags_pkey,
+	ADD PRIMARY KEY (workspace_id, name);
+ALTER TABLE budgets
+	ALTER COLUMN workspace_id SET NOT NULL,
+	DROP CONSTRAINT budgets_tag_period_currency_key,
+	ADD UNIQUE (workspace_id, tag, period, currency);
+ALTER TABLE exchange_rates
+	ALTER COLUMN workspace_id SET NOT NULL,
+	DROP CONSTRAINT exchange_rates_pkey,
+	ADD PRIMARY KEY (workspace_id, currency, base, effective_on);
+ALTER TABLE import_profiles
+	ALTER COLUMN workspace_id SET NOT NULL,
+	DROP CONSTRAINT import_profiles_pkey,
+	ADD PRIMARY KEY (workspace_id, name);
+
+DROP INDEX IF EXISTS expenses_owner_id_idx;
+CREATE INDEX expenses_workspace_id_idx ON expenses (workspace_id, owner_id, id);
+CREATE INDEX alert_rules_workspace_id_idx ON alert_rules (workspace_id, id);
+CREATE INDEX alerts_workspace_id_idx ON alerts (workspace_id, id);
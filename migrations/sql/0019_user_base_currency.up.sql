@@ -0,0 +1,2 @@
+-- The ISO 4217 code amounts are converted to for a user, or NULL for DEFAULT_CURRENCY.
+ALTER TABLE users ADD COLUMN base_currency TEXT CHECK (base_currency ~ '^[A-Z]{3}$');
@@ -0,0 +1,23 @@
+-- Amounts were hundredths of the major unit of every currency. Store them in
+-- the minor units of the ISO 4217 exponent of their currency instead, which
+-- are whole units for the currencies without decimals and thousandths for
+-- those with three decimals. Budgets and rules keep a positive amount.
+UPDATE expenses SET amount = round(amount / 100.0)
+	WHERE currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'VND', 'VUV', 'XAF', 'XOF', 'XPF');
+UPDATE expenses SET amount = amount * 10
+	WHERE currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND');
+
+UPDATE budgets SET amount = greatest(round(amount / 100.0), 1)
+	WHERE currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'VND', 'VUV', 'XAF', 'XOF', 'XPF');
+UPDATE budgets SET amount = amount * 10
+	WHERE currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND');
+
+UPDATE alert_rules SET amount = greatest(round(amount / 100.0), 1)
+	WHERE currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'VND', 'VUV', 'XAF', 'XOF', 'XPF');
+UPDATE alert_rules SET amount = amount * 10
+	WHERE currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND');
+
+UPDATE alerts SET amount = round(amount / 100.0), spent = round(spent / 100.0)
+	WHERE currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'VND', 'VUV', 'XAF', 'XOF', 'XPF');
+UPDATE alerts SET amount = amount * 10, spent = spent * 10
+	WHERE currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND');
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS expenses_rescaled_amount_id_idx;
+
+CREATE INDEX expenses_amount_id_idx ON expenses (amount, id)
+	WHERE deleted_at IS NULL;
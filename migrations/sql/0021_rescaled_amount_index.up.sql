@@ -0,0 +1,10 @@
+-- GET /expenses sorts amounts rescaled to thousandths, as the amount filters
+-- compare them, so index that expression instead of the raw minor units.
+-- It must stay the same as rescaledAmountSQL in expenses/postgres.go.
+DROP INDEX IF EXISTS expenses_amount_id_idx;
+
+CREATE INDEX expenses_rescaled_amount_id_idx ON expenses ((amount::numeric * CASE
+	WHEN currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 1000
+	WHEN currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 1
+	ELSE 10 END), id)
+	WHERE deleted_at IS NULL;
@@ -6,40 +6,233 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
+	"github.com/PeemPeimn/assessment/auth"
 	"github.com/PeemPeimn/assessment/expenses"
 	"github.com/labstack/echo/v4"
 )
 
-// This function is a middleware function handling Token Authorization.
-// According to Postman's tests the validation value is "November 10, 2009".
-func authorizationHandler(next echo.HandlerFunc) echo.HandlerFunc {
-	return func(c echo.Context) error {
-		auth := c.Request().Header.Get("Authorization")
-		if auth == "November 10, 2009" {
-			return next(c)
+func main() {
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	if name := os.Getenv("MONEY_ROUNDING"); name != "" {
+		rounding, err := expenses.ParseRounding(name)
+		if err != nil {
+			log.Fatal(err)
 		}
-		return c.JSON(http.StatusUnauthorized, "invalid key value.")
+		expenses.DefaultRounding = rounding
 	}
-}
 
-func main() {
+	if code := os.Getenv("DEFAULT_CURRENCY"); code != "" {
+		currency, err := expenses.NormalizeCurrency(code)
+		if err != nil {
+			log.Fatal(err)
+		}
+		expenses.DefaultCurrency = currency
+	}
+
+	if name := os.Getenv("DEFAULT_TIMEZONE"); name != "" {
+		location, err := expenses.ParseTimezone(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		expenses.DefaultTimezone = location
+	}
+
+	var handler expenses.Handler
+
+	// EXPENSE_STORE=memory runs the server without a database for local development.
+	if os.Getenv("EXPENSE_STORE") == "memory" {
+		store := expenses.NewMemoryStore()
+		handler = expenses.Handler{
+			Store:   store,
+			Rates:   expenses.NewMemoryRateStore(),
+			Tags:    store,
+			Reports: store,
+			Budgets: expenses.NewMemoryBudgetStore(),
+			Alerts:  expenses.NewMemoryAlertStore(),
+
+			Profiles: expenses.NewMemoryImportProfileStore(),
+			Users:    auth.NewMemoryUserStore(),
+			Keys:     auth.NewMemoryKeyStore(),
+
+			Workspaces: auth.NewMemoryWorkspaceStore(),
+		}
+	} else {
+		db := expenses.InitDB(os.Getenv("DATABASE_URL"))
+		store := expenses.NewPostgresStore(db)
+		handler = expenses.Handler{
+			Store:   store,
+			Rates:   expenses.NewPostgresRateStore(db),
+			Tags:    store,
+			Reports: store,
+			Budgets: expenses.NewPostgresBudgetStore(db),
+			Alerts:  expenses.NewPostgresAlertStore(db),
+
+			Profiles: expenses.NewPostgresImportProfileStore(db),
+			Users:    auth.NewPostgresUserStore(db),
+			Keys:     auth.NewPostgresKeyStore(db),
+
+			Workspaces: auth.NewPostgresWorkspaceStore(db),
+		}
+	}
+
+	// Deleted expenses stay in the trash for TRASH_RETENTION_DAYS, 30 days by default.
+	retentionDays := 30
+	if days := os.Getenv("TRASH_RETENTION_DAYS"); days != "" {
+		var err error
+		if retentionDays, err = strconv.Atoi(days); err != nil || retentionDays < 0 {
+			log.Fatal("TRASH_RETENTION_DAYS must be a number of days.")
+		}
+	}
 
-	db := expenses.InitDB(os.Getenv("DATABASE_URL"))
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
 
-	handler := expenses.Handler{DB: db}
+	go expenses.Purger{
+		Store:     handler.Store,
+		Retention: time.Duration(retentionDays) * 24 * time.Hour,
+		Interval:  time.Hour,
+	}.Run(background)
+
+	// JWT_KEYS enables the login with the keys signing its tokens, such as
+	// "2026-10:EdDSA:/keys/2026-10.pem,2026-09:RS256:/keys/2026-09.pem".
+	// The first key signs, the others only verify tokens issued before a rotation.
+	if spec := os.Getenv("JWT_KEYS"); spec != "" {
+		keys, err := auth.LoadSigningKeys(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handler.Tokens = &auth.Tokens{
+			Keys:   keys,
+			Issuer: os.Getenv("JWT_ISSUER"),
+			TTL:    envDuration("JWT_TTL", 15*time.Minute),
+			Leeway: envDuration("JWT_LEEWAY", time.Minute),
+			Now:    time.Now,
+		}
+	}
+
+	// OIDC_ISSUER enables single sign-on with an OpenID Connect identity provider,
+	// whose users get the tokens of JWT_KEYS.
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		if handler.Tokens == nil {
+			log.Fatal("OIDC_ISSUER needs JWT_KEYS to issue tokens.")
+		}
+		handler.OIDC = &auth.OIDC{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+			NameClaim:    os.Getenv("OIDC_NAME_CLAIM"),
+			Client:       &http.Client{Timeout: 10 * time.Second},
+			Leeway:       handler.Tokens.Leeway,
+			Now:          time.Now,
+		}
+		if handler.OIDC.ClientID == "" || handler.OIDC.RedirectURL == "" {
+			log.Fatal("OIDC_ISSUER needs OIDC_CLIENT_ID and OIDC_REDIRECT_URL.")
+		}
+	}
 
 	echoInstance := echo.New()
 
-	// Use the customized handler.
-	echoInstance.Use(authorizationHandler)
+	// Every request needs the API key or token of a user, which scopes the
+	// expenses of the request. BOOTSTRAP_API_KEY is also accepted to create the first
+	// user and its key. The request is in the workspace of its X-Workspace header,
+	// or the first one the user joined, and the role of the user there must grant the
+	// permission each route declares with auth.Require.
+	authenticator := auth.Authenticator{
+		Keys:           handler.Keys,
+		Users:          handler.Users,
+		Workspaces:     handler.Workspaces,
+		Tokens:         handler.Tokens,
+		PublicPaths:    []string{"/auth/login", "/auth/oidc/login", "/auth/oidc/callback", "/.well-known/jwks.json"},
+		BootstrapKey:   os.Getenv("BOOTSTRAP_API_KEY"),
+		BootstrapPaths: []string{"/users", "/api-keys"},
+		Now:            time.Now,
+	}
+	echoInstance.Use(authenticator.Middleware)
+
+	echoInstance.POST("/expenses", handler.CreateExpense, auth.Require(auth.WriteExpenses))
+	echoInstance.POST("/expenses/batch", handler.BatchExpenses, auth.Require(auth.WriteExpenses))
+	echoInstance.GET("/expenses/:id", handler.GetExpenseByID, auth.Require(auth.ReadExpenses))
+	echoInstance.PUT("/expenses/:id", handler.PutExpense, auth.Require(auth.WriteExpenses))
+	echoInstance.PATCH("/expenses/:id", handler.PatchExpense, auth.Require(auth.WriteExpenses))
+	echoInstance.GET("/expenses", handler.GetAllExpenses, auth.Require(auth.ReadExpenses))
+	echoInstance.DELETE("/expenses/:id", handler.DeleteExpense, auth.Require(auth.WriteExpenses))
+	echoInstance.GET("/expenses/trash", handler.GetTrash, auth.Require(auth.ReadExpenses))
+	echoInstance.GET("/expenses/search", handler.SearchExpenses, auth.Require(auth.ReadExpenses))
+	echoInstance.GET("/expenses/summary", handler.GetSummary, auth.Require(auth.ReadExpenses))
+	echoInstance.GET("/expenses/timeseries", handler.GetTimeSeries, auth.Require(auth.ReadExpenses))
+	echoInstance.GET("/expenses/export.csv", handler.ExportExpenses, auth.Require(auth.ReadExpenses))
+	echoInstance.POST("/expenses/import", handler.ImportExpenses, auth.Require(auth.WriteExpenses))
+	echoInstance.POST("/expenses/import/ofx", handler.ImportOFX, auth.Require(auth.WriteExpenses))
+	echoInstance.POST("/expenses/import/qif", handler.ImportQIF, auth.Require(auth.WriteExpenses))
+	echoInstance.POST("/expenses/:id/restore", handler.RestoreExpense, auth.Require(auth.WriteExpenses))
+
+	echoInstance.GET("/exchange-rates", handler.GetExchangeRates, auth.Require(auth.ReadShared))
+	echoInstance.POST("/exchange-rates", handler.UpsertExchangeRates, auth.Require(auth.WriteShared))
 
-	echoInstance.POST("/expenses", handler.CreateExpense)
-	echoInstance.GET("/expenses/:id", handler.GetExpenseByID)
-	echoInstance.PUT("/expenses/:id", handler.PutExpense)
-	echoInstance.GET("/expenses", handler.GetAllExpenses)
+	echoInstance.GET("/tags", handler.GetTags, auth.Require(auth.ReadExpenses))
+	echoInstance.PUT("/tags/:name", handler.PutTag, auth.Require(auth.WriteShared))
+	echoInstance.POST("/tags/merge", handler.MergeTags, auth.Require(auth.WriteAllExpenses))
+
+	echoInstance.GET("/budgets", handler.GetBudgets, auth.Require(auth.ReadShared))
+	echoInstance.POST("/budgets", handler.CreateBudget, auth.Require(auth.WriteShared))
+	echoInstance.GET("/budgets/status", handler.GetBudgetStatus, auth.Require(auth.ReadShared))
+	echoInstance.GET("/budgets/:id", handler.GetBudgetByID, auth.Require(auth.ReadShared))
+	echoInstance.PUT("/budgets/:id", handler.PutBudget, auth.Require(auth.WriteShared))
+	echoInstance.DELETE("/budgets/:id", handler.DeleteBudget, auth.Require(auth.WriteShared))
+
+	echoInstance.GET("/alert-rules", handler.GetAlertRules, auth.Require(auth.ReadShared))
+	echoInstance.POST("/alert-rules", handler.CreateAlertRule, auth.Require(auth.WriteShared))
+	echoInstance.GET("/alert-rules/:id", handler.GetAlertRuleByID, auth.Require(auth.ReadShared))
+	echoInstance.PUT("/alert-rules/:id", handler.PutAlertRule, auth.Require(auth.WriteShared))
+	echoInstance.DELETE("/alert-rules/:id", handler.DeleteAlertRule, auth.Require(auth.WriteShared))
+	echoInstance.GET("/alerts", handler.GetAlerts, auth.Require(auth.ReadShared))
+	echoInstance.POST("/alerts/:id/acknowledge", handler.AcknowledgeAlert, auth.Require(auth.AcknowledgeAlerts))
+
+	echoInstance.GET("/users", handler.GetUsers, auth.Require(auth.ManageMembers))
+	echoInstance.POST("/users", handler.CreateUser, auth.Require(auth.ManageUsers))
+	echoInstance.PUT("/users/:id/role", handler.SetUserRole, auth.Require(auth.ManageUsers))
+	echoInstance.GET("/users/me", handler.GetCurrentUser)
+	echoInstance.PUT("/users/me/password", handler.SetPassword)
+	echoInstance.PUT("/users/me/base-currency", handler.SetBaseCurrency)
+
+	echoInstance.GET("/workspaces", handler.GetWorkspaces)
+	echoInstance.POST("/workspaces", handler.CreateWorkspace)
+	echoInstance.GET("/members", handler.GetMembers, auth.Require(auth.ReadShared))
+	echoInstance.PUT("/members/:id/role", handler.SetMemberRole, auth.Require(auth.ManageMembers))
+	echoInstance.DELETE("/members/:id", handler.RemoveMember, auth.Require(auth.ManageMembers))
+	echoInstance.GET("/invitations", handler.GetInvitations, auth.Require(auth.ManageMembers))
+	echoInstance.POST("/invitations", handler.CreateInvitation, auth.Require(auth.ManageMembers))
+	echoInstance.DELETE("/invitations/:id", handler.RevokeInvitation, auth.Require(auth.ManageMembers))
+	echoInstance.POST("/invitations/accept", handler.AcceptInvitation)
+
+	echoInstance.POST("/auth/login", handler.Login)
+	echoInstance.GET("/auth/oidc/login", handler.StartOIDCLogin)
+	echoInstance.GET("/auth/oidc/callback", handler.OIDCCallback)
+	echoInstance.POST("/auth/oidc/link", handler.LinkOIDC)
+	echoInstance.GET("/.well-known/jwks.json", handler.GetJWKS)
+
+	echoInstance.GET("/api-keys", handler.GetAPIKeys)
+	echoInstance.POST("/api-keys", handler.IssueAPIKey)
+	echoInstance.POST("/api-keys/:id/rotate", handler.RotateAPIKey)
+	echoInstance.DELETE("/api-keys/:id", handler.RevokeAPIKey)
+
+	echoInstance.GET("/import-profiles", handler.GetImportProfiles, auth.Require(auth.ReadShared))
+	echoInstance.GET("/import-profiles/:name", handler.GetImportProfile, auth.Require(auth.ReadShared))
+	echoInstance.PUT("/import-profiles/:name", handler.PutImportProfile, auth.Require(auth.WriteShared))
+	echoInstance.DELETE("/import-profiles/:name", handler.DeleteImportProfile, auth.Require(auth.WriteShared))
 
 	// Start server
 	go func() {
@@ -52,6 +245,7 @@ func main() {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 	<-shutdown
+	stopBackground()
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := echoInstance.Shutdown(ctx); err != nil {
@@ -60,3 +254,17 @@ func main() {
 	log.Println("shut down gracefully.")
 
 }
+
+// envDuration returns the duration of the environment variable name,
+// such as "15m", or fallback when it is not set.
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		log.Fatal(name + " must be a duration such as 15m.")
+	}
+	return duration
+}